@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -42,29 +43,42 @@ func main() {
 		AllowMethods: "GET, POST, PUT, DELETE, PATCH, OPTIONS",
 	}))
 
-	app.Static("/uploads", "./uploads")
-
 	app.Get("/", handlers.GetRoot)
 	app.Get("/health", handlers.GetHealth)
 
-	emailService := services.NewEmailService(cfg.Email.ResendAPIKey, cfg.Email.FromEmail, cfg.Email.FromName, cfg.Email.AppURL)
-	achievementService := services.NewAchievementService(database.DB)
-	authService := services.NewAuthService(database.DB, cfg, emailService)
-	userService := services.NewUserService(database.DB)
-	roleService := services.NewRoleService(database.DB)
+	settingsService := services.NewSettingsService(database.DB)
+	emailService := services.NewEmailService(database.DB, settingsService, cfg.Email.ResendAPIKey, cfg.Email.FromEmail, cfg.Email.FromName, cfg.Email.AppURL)
+	cacheService := services.NewCacheService(cfg.Redis.URL)
+	streakService := services.NewStreakService(database.DB, settingsService)
+	achievementService := services.NewAchievementService(database.DB, streakService)
+	userSessionService := services.NewUserSessionService(database.DB, cfg, cacheService)
+	authService := services.NewAuthService(database.DB, cfg, emailService, cacheService, userSessionService, settingsService)
+	if err := warmAuthCache(authService, userSessionService); err != nil {
+		// Without a complete revocation set the cache can't be trusted, so the
+		// auth middleware keeps checking Postgres directly
+		utils.ErrorLogger.Printf("Failed to warm token revocation cache: %v", err)
+	} else {
+		middleware.UseCache(cacheService)
+	}
+	userService := services.NewUserService(database.DB, cacheService)
+	roleService := services.NewRoleService(database.DB, cacheService)
 	categoryService := services.NewCategoryService(database.DB)
 	authorService := services.NewAuthorService(database.DB)
 	bookService := services.NewBookService(database.DB)
-	libraryService := services.NewLibraryService(database.DB)
-	ereaderService := services.NewEReaderService(database.DB)
+	libraryService := services.NewLibraryService(database.DB, streakService)
+	if cfg.Storage.FileURLSecret == "" {
+		utils.ErrorLogger.Println("FILE_URL_SECRET is not set: book files can't be opened until it matches upload-api's")
+	}
+	fileSigner := services.NewFileURLSigner(cfg.Storage.UploadAPIURL, cfg.Storage.FileURLSecret, time.Duration(cfg.Storage.FileURLTTLMinutes)*time.Minute)
+	watermarkService := services.NewWatermarkService(database.DB, settingsService)
+	ereaderService := services.NewEReaderService(database.DB, fileSigner, watermarkService)
 	sessionService := services.NewReadingSessionService(database.DB)
 	goalService := services.NewReadingGoalService(database.DB)
 	blogService := services.NewBlogService(database.DB)
 	faqService := services.NewFAQService(database.DB)
 	testimonialService := services.NewTestimonialService(database.DB)
 	contactService := services.NewContactService(database.DB)
-	settingsService := services.NewSettingsService(database.DB)
-	analyticsService := services.NewAnalyticsService(database.DB)
+	analyticsService := services.NewAnalyticsService(database.DB, streakService)
 	reportService := services.NewReportService(database.DB)
 	notificationService := services.NewNotificationService(database.DB)
 	auditService := services.NewAuditService(database.DB)
@@ -72,20 +86,52 @@ func main() {
 	aboutService := services.NewAboutService(database.DB)
 	wishlistService := services.NewWishlistService(database.DB)
 	groupService := services.NewGroupService(database.DB)
+	assignmentService := services.NewReadingAssignmentService(database.DB, notificationService)
+	teacherService := services.NewTeacherService(database.DB, analyticsService, streakService)
+	recommendationService := services.NewRecommendationService(database.DB)
+	guardianService := services.NewGuardianService(database.DB, emailService, libraryService, sessionService, streakService, achievementService)
+	schoolService := services.NewSchoolService(database.DB, cacheService)
+	userImportService := services.NewUserImportService(database.DB, emailService)
 	chatService := services.NewChatService(database.DB)
 
 	// Initialize WebSocket hub
 	hub := websocket.NewHub()
 	go hub.Run()
 
+	// Deliver queued email in the background
+	go emailService.RunOutboxWorker()
+
+	// Close reading sessions that stopped sending heartbeats
+	go sessionService.RunSessionSweeper()
+
+	// Remind students of overdue reading assignments
+	go assignmentService.RunReminderWorker()
+
+	// Send guardians their weekly reading digest
+	go guardianService.RunDigestWorker()
+
+	// Rebuild "readers also enjoyed" neighbours from reading activity
+	go recommendationService.RunSimilarityWorker()
+
 	chatHandler := handlers.NewChatHandler(chatService, hub)
 
 	achievementService.SeedAchievements()
 
-	handlers.SetupRoutes(app, authService, userService, roleService, categoryService, authorService, bookService, libraryService, ereaderService, sessionService, goalService, achievementService, blogService, faqService, testimonialService, contactService, settingsService, analyticsService, reportService, notificationService, auditService, reviewService, aboutService, wishlistService, groupService, chatHandler)
+	handlers.SetupRoutes(app, authService, userSessionService, userService, roleService, categoryService, authorService, bookService, libraryService, ereaderService, sessionService, goalService, achievementService, blogService, faqService, testimonialService, contactService, settingsService, emailService, analyticsService, reportService, notificationService, auditService, reviewService, aboutService, wishlistService, groupService, schoolService, userImportService, streakService, watermarkService, assignmentService, teacherService, guardianService, recommendationService, fileSigner, chatHandler)
+
+	if err := middleware.VerifyRoutePermissions(database.DB); err != nil {
+		log.Fatal("Route permission check failed (run scripts/seed_permissions.go): ", err)
+	}
 
 	utils.InfoLogger.Printf("🚀 Server starting on port %s", cfg.Server.Port)
 	if err := app.Listen(":" + cfg.Server.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+func warmAuthCache(authService *services.AuthService, userSessionService *services.UserSessionService) error {
+	if err := authService.WarmRevokedTokens(); err != nil {
+		return err
+	}
+	return userSessionService.WarmRevokedSessions()
+}
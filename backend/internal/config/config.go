@@ -14,6 +14,7 @@ type Config struct {
 	JWT      JWTConfig
 	Redis    RedisConfig
 	Email    EmailConfig
+	Storage  StorageConfig
 }
 
 type ServerConfig struct {
@@ -35,6 +36,15 @@ type RedisConfig struct {
 	URL string
 }
 
+// StorageConfig points at the upload-api that holds book files. Book files
+// are only served there through URLs signed with FileURLSecret, which both
+// services share, and valid for FileURLTTLMinutes.
+type StorageConfig struct {
+	UploadAPIURL      string
+	FileURLSecret     string
+	FileURLTTLMinutes int
+}
+
 type EmailConfig struct {
 	ResendAPIKey string
 	FromEmail    string
@@ -49,6 +59,7 @@ func Load() *Config {
 
 	expireHours, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOURS", "24"))
 	refreshDays, _ := strconv.Atoi(getEnv("REFRESH_TOKEN_EXPIRE_DAYS", "7"))
+	fileURLTTL, _ := strconv.Atoi(getEnv("FILE_URL_TTL_MINUTES", "15"))
 
 	return &Config{
 		Server: ServerConfig{
@@ -72,6 +83,11 @@ func Load() *Config {
 			FromName:     getEnv("FROM_NAME", "ReadAgain"),
 			AppURL:       getEnv("APP_URL", "http://localhost:3000"),
 		},
+		Storage: StorageConfig{
+			UploadAPIURL:      getEnv("UPLOAD_API_URL", "http://localhost:8001"),
+			FileURLSecret:     getEnv("FILE_URL_SECRET", ""),
+			FileURLTTLMinutes: fileURLTTL,
+		},
 	}
 }
 
@@ -57,7 +57,7 @@ func (h *AchievementHandler) CreateAchievement(c *fiber.Ctx) error {
 		Name        string `json:"name" validate:"required,min=3"`
 		Description string `json:"description" validate:"required"`
 		Icon        string `json:"icon"`
-		Type        string `json:"type" validate:"required,oneof=books_purchased books_completed reading_minutes reading_sessions"`
+		Type        string `json:"type" validate:"required,oneof=books_purchased books_completed reading_minutes reading_sessions reading_streak"`
 		Target      int    `json:"target" validate:"required,gt=0"`
 		Points      int    `json:"points" validate:"required,gte=0"`
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
+	"readagain/internal/middleware"
 	"readagain/internal/services"
 	"readagain/internal/utils"
 )
@@ -37,7 +38,7 @@ func (h *AnalyticsHandler) GetSalesStats(c *fiber.Ctx) error {
 		}
 	}
 
-	stats, err := h.service.GetSalesStats(startDate, endDate)
+	stats, err := h.service.GetSalesStats(startDate, endDate, middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get sales stats: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch sales statistics"})
@@ -47,7 +48,7 @@ func (h *AnalyticsHandler) GetSalesStats(c *fiber.Ctx) error {
 }
 
 func (h *AnalyticsHandler) GetUserStats(c *fiber.Ctx) error {
-	stats, err := h.service.GetUserStats()
+	stats, err := h.service.GetUserStats(middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get user stats: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user statistics"})
@@ -57,7 +58,7 @@ func (h *AnalyticsHandler) GetUserStats(c *fiber.Ctx) error {
 }
 
 func (h *AnalyticsHandler) GetReadingStats(c *fiber.Ctx) error {
-	stats, err := h.service.GetReadingStats()
+	stats, err := h.service.GetReadingStats(middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get reading stats: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reading statistics"})
@@ -79,7 +80,7 @@ func (h *AnalyticsHandler) GetRevenueReport(c *fiber.Ctx) error {
 }
 
 func (h *AnalyticsHandler) GetGrowthMetrics(c *fiber.Ctx) error {
-	metrics, err := h.service.GetGrowthMetrics()
+	metrics, err := h.service.GetGrowthMetrics(middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get growth metrics: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch growth metrics"})
@@ -89,7 +90,7 @@ func (h *AnalyticsHandler) GetGrowthMetrics(c *fiber.Ctx) error {
 }
 
 func (h *AnalyticsHandler) GetEnhancedOverview(c *fiber.Ctx) error {
-	data, err := h.service.GetEnhancedOverview()
+	data, err := h.service.GetEnhancedOverview(middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get enhanced overview: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch enhanced analytics"})
@@ -101,7 +102,13 @@ func (h *AnalyticsHandler) GetEnhancedOverview(c *fiber.Ctx) error {
 func (h *AnalyticsHandler) GetReadingAnalyticsByPeriod(c *fiber.Ctx) error {
 	period := c.Query("period", "month")
 
-	data, err := h.service.GetReadingAnalyticsByPeriod(period)
+	var data map[string]interface{}
+	var err error
+	if teacherID := middleware.TeacherScope(c); teacherID != nil {
+		data, err = h.service.GetTeacherReadingAnalytics(period, *teacherID)
+	} else {
+		data, err = h.service.GetReadingAnalyticsByPeriod(period, middleware.SchoolScope(c))
+	}
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get reading analytics: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reading analytics"})
@@ -111,7 +118,7 @@ func (h *AnalyticsHandler) GetReadingAnalyticsByPeriod(c *fiber.Ctx) error {
 }
 
 func (h *AnalyticsHandler) GetReportsData(c *fiber.Ctx) error {
-	data, err := h.service.GetReportsData()
+	data, err := h.service.GetReportsData(middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get reports data: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports data"})
@@ -129,16 +136,24 @@ func (h *AnalyticsHandler) GenerateReport(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	// Built for the caller's school, the same as the download will be
+	pdf, err := h.reportService.GeneratePDF(req.Type, middleware.SchoolScope(c))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	return c.JSON(fiber.Map{
-		"message": "Report generation started",
-		"type":    req.Type,
+		"message":      "Report generated",
+		"type":         req.Type,
+		"size":         len(pdf),
+		"download_url": "/admin/reports/download/" + req.Type,
 	})
 }
 
 func (h *AnalyticsHandler) DownloadReport(c *fiber.Ctx) error {
 	reportType := c.Params("type")
 
-	pdf, err := h.reportService.GeneratePDF(reportType)
+	pdf, err := h.reportService.GeneratePDF(reportType, middleware.SchoolScope(c))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AssignmentHandler struct {
+	assignmentService *services.ReadingAssignmentService
+}
+
+func NewAssignmentHandler(assignmentService *services.ReadingAssignmentService) *AssignmentHandler {
+	return &AssignmentHandler{assignmentService: assignmentService}
+}
+
+func (h *AssignmentHandler) ListAssignments(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	var groupID *uint
+	if raw := c.Query("group_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid group ID"})
+		}
+		group := uint(id)
+		groupID = &group
+	}
+
+	assignments, meta, err := h.assignmentService.List(classScope(c), groupID, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"assignments": assignments, "pagination": meta})
+}
+
+func (h *AssignmentHandler) GetAssignment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid assignment ID"})
+	}
+
+	assignment, err := h.assignmentService.Get(uint(id), classScope(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"assignment": assignment})
+}
+
+func (h *AssignmentHandler) CreateAssignment(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var input services.AssignmentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	assignment, err := h.assignmentService.Create(userID, classScope(c), input)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "create_assignment", "reading_assignment", assignment.ID, "", assignment.Title)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"assignment": assignment})
+}
+
+func (h *AssignmentHandler) UpdateAssignment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid assignment ID"})
+	}
+
+	var input services.AssignmentUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	assignment, err := h.assignmentService.Update(uint(id), classScope(c), input)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "update_assignment", "reading_assignment", assignment.ID, "", assignment.Title)
+	return c.JSON(fiber.Map{"assignment": assignment})
+}
+
+func (h *AssignmentHandler) DeleteAssignment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid assignment ID"})
+	}
+
+	if err := h.assignmentService.Delete(uint(id), classScope(c)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "delete_assignment", "reading_assignment", uint(id), "", "")
+	return c.JSON(fiber.Map{"message": "Assignment deleted successfully"})
+}
+
+// GetMyAssignments lists the reader's own assignments; completed ones are
+// included with ?all=true.
+func (h *AssignmentHandler) GetMyAssignments(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	assignments, err := h.assignmentService.GetStudentAssignments(userID, c.QueryBool("all"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"assignments": assignments})
+}
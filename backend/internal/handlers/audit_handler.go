@@ -20,18 +20,13 @@ func NewAuditHandler(service *services.AuditService) *AuditHandler {
 func (h *AuditHandler) GetLogs(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	entityType := c.Query("entity_type")
-	action := c.Query("action")
-
-	var userID *uint
-	if userIDStr := c.Query("user_id"); userIDStr != "" {
-		if id, err := strconv.ParseUint(userIDStr, 10, 32); err == nil {
-			uid := uint(id)
-			userID = &uid
-		}
+
+	query, err := services.AuditLogSpec.Parse(c.Queries())
+	if err != nil {
+		return err
 	}
 
-	logs, meta, err := h.service.GetLogs(page, limit, userID, entityType, action)
+	logs, meta, err := h.service.GetLogs(page, limit, query)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get audit logs: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch audit logs"})
@@ -1,18 +1,22 @@
 package handlers
 
 import (
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 
 	"readagain/internal/models"
 	"readagain/internal/services"
+	"readagain/internal/utils"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService        *services.AuthService
+	userSessionService *services.UserSessionService
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *services.AuthService, userSessionService *services.UserSessionService) *AuthHandler {
+	return &AuthHandler{authService: authService, userSessionService: userSessionService}
 }
 
 type RegisterRequest struct {
@@ -28,6 +32,7 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	EmailOrUsername string `json:"email_or_username" validate:"required"`
 	Password        string `json:"password" validate:"required"`
+	DeviceName      string `json:"device_name"`
 }
 
 type RefreshTokenRequest struct {
@@ -60,7 +65,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	ipAddress := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	accessToken, refreshToken, user, err := h.authService.Login(req.EmailOrUsername, req.Password, ipAddress, userAgent)
+	accessToken, refreshToken, user, err := h.authService.Login(req.EmailOrUsername, req.Password, req.DeviceName, ipAddress, userAgent)
 	if err != nil {
 		return err
 	}
@@ -78,13 +83,14 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	accessToken, err := h.authService.RefreshToken(req.RefreshToken)
+	accessToken, refreshToken, err := h.authService.RefreshToken(req.RefreshToken, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return err
 	}
 
 	return c.JSON(fiber.Map{
-		"access_token": accessToken,
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
 	})
 }
 
@@ -101,6 +107,47 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	currentSession, _ := c.Locals("sessionID").(string)
+
+	sessions, err := h.userSessionService.ListActiveSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].FamilyID == currentSession
+	}
+
+	return c.JSON(fiber.Map{"sessions": sessions})
+}
+
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+
+	if err := h.userSessionService.RevokeSession(userID, uint(sessionID)); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"message": "Session revoked successfully"})
+}
+
+func (h *AuthHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	if err := h.userSessionService.RevokeAllSessions(userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"message": "All sessions revoked successfully"})
+}
+
 func (h *AuthHandler) GetMe(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
@@ -157,3 +204,49 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 		"message": "Password reset successfully",
 	})
 }
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	if err := h.authService.VerifyEmail(req.Token); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	var req ResendVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	if err := h.authService.ResendVerification(req.Email); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If the account exists and is unverified, a verification email has been sent",
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"readagain/internal/middleware"
+	"readagain/internal/models"
 	"readagain/internal/services"
 	"readagain/internal/utils"
 
@@ -34,40 +35,43 @@ func (h *BookHandler) ListBooks(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	search := c.Query("search", "")
-	categoryID, _ := strconv.ParseUint(c.Query("category_id", "0"), 10, 32)
-	authorID, _ := strconv.ParseUint(c.Query("author_id", "0"), 10, 32)
-	status := c.Query("status", "")
-	sortBy := c.Query("sort_by", "created_at")
-	sortOrder := c.Query("sort_order", "desc")
 
-	var isFeatured *bool
-	if c.Query("is_featured") != "" {
-		featured := c.Query("is_featured") == "true"
-		isFeatured = &featured
+	query, err := services.BookListSpec.Parse(c.Queries())
+	if err != nil {
+		return err
 	}
 
-	filters := services.BookFilters{
-		Search:     search,
-		CategoryID: uint(categoryID),
-		AuthorID:   uint(authorID),
-		IsFeatured: isFeatured,
-		Status:     status,
-		SortBy:     sortBy,
-		SortOrder:  sortOrder,
+	books, meta, err := h.bookService.ListBooks(page, limit, search, query)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to list books: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve books"})
 	}
 
-	books, meta, err := h.bookService.ListBooks(page, limit, filters)
+	facets, err := h.bookService.GetFacets(search, query)
 	if err != nil {
-		utils.ErrorLogger.Printf("Failed to list books: %v", err)
+		utils.ErrorLogger.Printf("Failed to count book facets: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve books"})
 	}
 
 	return c.JSON(fiber.Map{
 		"books":      books,
 		"pagination": meta,
+		"facets":     facets,
 	})
 }
 
+func (h *BookHandler) Suggest(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "8"))
+
+	suggestions, err := h.bookService.Suggest(c.Query("q"), limit)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch suggestions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve suggestions"})
+	}
+
+	return c.JSON(fiber.Map{"suggestions": suggestions})
+}
+
 func (h *BookHandler) GetBook(c *fiber.Ctx) error {
 	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -84,7 +88,7 @@ func (h *BookHandler) GetBook(c *fiber.Ctx) error {
 
 func (h *BookHandler) CreateBook(c *fiber.Ctx) error {
 	var req struct {
-		Title          string `json:"title" validate:"required"`
+		Title          string `json:"title" validate:"required_without=Metadata"`
 		AuthorID       uint   `json:"author_id" validate:"required"`
 		CategoryID     uint   `json:"category_id" validate:"required"`
 		Description    string `json:"description"`
@@ -96,6 +100,9 @@ func (h *BookHandler) CreateBook(c *fiber.Ctx) error {
 		CoverImage     string `json:"cover_image"`
 		BookFile       string `json:"book_file"`
 		FileSize       int64  `json:"file_size"`
+
+		ReadingLevel models.ReadingLevel       `json:"reading_level"`
+		Metadata     *services.BookFileMetadata `json:"metadata"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -117,6 +124,8 @@ func (h *BookHandler) CreateBook(c *fiber.Ctx) error {
 		req.FileSize,
 		req.PageCount,
 		req.Status,
+		req.ReadingLevel,
+		req.Metadata,
 	)
 
 	if err != nil {
@@ -124,7 +133,7 @@ func (h *BookHandler) CreateBook(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	utils.InfoLogger.Printf("Created book: %s", req.Title)
+	utils.InfoLogger.Printf("Created book: %s", book.Title)
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"book": book})
 }
 
@@ -171,6 +180,19 @@ func (h *BookHandler) UpdateBook(c *fiber.Ctx) error {
 	if fileSize, ok := req["file_size"].(float64); ok {
 		updates["file_size"] = int64(fileSize)
 	}
+	if raw, ok := req["reading_level"]; ok {
+		// Replaced as a whole; null clears it
+		var level models.ReadingLevel
+		if fields, ok := raw.(map[string]interface{}); ok {
+			level.GradeMin = optionalInt(fields["grade_min"])
+			level.GradeMax = optionalInt(fields["grade_max"])
+			level.Score = optionalInt(fields["score"])
+		}
+		if err := utils.Validate.Struct(&level); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+		}
+		updates["reading_level"] = level
+	}
 
 	book, err := h.bookService.UpdateBook(uint(bookID), updates)
 	if err != nil {
@@ -182,6 +204,57 @@ func (h *BookHandler) UpdateBook(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"book": book})
 }
 
+func optionalInt(value interface{}) *int {
+	if number, ok := value.(float64); ok {
+		n := int(number)
+		return &n
+	}
+	return nil
+}
+
+// GetTOC returns the book's chapters in reading order. Level gives the
+// nesting, so clients can rebuild the tree.
+func (h *BookHandler) GetTOC(c *fiber.Ctx) error {
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	chapters, err := h.bookService.GetChapters(uint(bookID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"chapters": chapters})
+}
+
+// UpdateTOC replaces the chapters with a table of contents extracted again
+// by upload-api, for books added before chapters existed or re-uploaded.
+func (h *BookHandler) UpdateTOC(c *fiber.Ctx) error {
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	var req struct {
+		TOC []services.BookTOCEntry `json:"toc" validate:"max=5000,dive"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := utils.Validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	chapters, err := h.bookService.ReplaceChapters(uint(bookID), req.TOC)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "update_book_toc", "book", uint(bookID), "", strconv.Itoa(len(chapters)))
+	return c.JSON(fiber.Map{"chapters": chapters})
+}
+
 func (h *BookHandler) DeleteBook(c *fiber.Ctx) error {
 	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type EmailHandler struct {
+	emailService *services.EmailService
+}
+
+func NewEmailHandler(emailService *services.EmailService) *EmailHandler {
+	return &EmailHandler{emailService: emailService}
+}
+
+func (h *EmailHandler) ListOutbox(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	status := c.Query("status")
+	search := c.Query("search")
+
+	emails, meta, err := h.emailService.ListOutbox(page, limit, status, search)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"emails": emails,
+		"meta":   meta,
+	})
+}
+
+func (h *EmailHandler) ResendOutbox(c *fiber.Ctx) error {
+	emailID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid email ID"})
+	}
+
+	email, err := h.emailService.ResendOutbox(uint(emailID))
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "resend_email", "email_outbox", email.ID, "", email.ToEmail)
+	return c.JSON(fiber.Map{
+		"message": "Email queued for resend",
+		"email":   email,
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FileHandler hands admins signed URLs for the upload-api actions that
+// change stored files.
+type FileHandler struct {
+	fileSigner *services.FileURLSigner
+}
+
+func NewFileHandler(fileSigner *services.FileURLSigner) *FileHandler {
+	return &FileHandler{fileSigner: fileSigner}
+}
+
+// SignMetadata returns a URL that re-extracts the metadata of an uploaded
+// book file.
+func (h *FileHandler) SignMetadata(c *fiber.Ctx) error {
+	return h.sign(c, "metadata")
+}
+
+// SignDelete returns a URL that deletes a stored cover, profile picture or
+// book file.
+func (h *FileHandler) SignDelete(c *fiber.Ctx) error {
+	return h.sign(c, "delete")
+}
+
+func (h *FileHandler) sign(c *fiber.Ctx, action string) error {
+	userID := c.Locals("userID").(uint)
+	filename := c.Params("filename")
+	if filename == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid file name"})
+	}
+
+	signed, err := h.fileSigner.SignAction(action, filename, userID)
+	if err != nil {
+		return utils.NewInternalServerError("Failed to create file URL", err)
+	}
+
+	return c.JSON(signed)
+}
@@ -190,7 +190,8 @@ func (h *GroupHandler) AssignBooks(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	if err := h.service.AssignBooks(uint(id), input.BookIDs); err != nil {
+	userID := c.Locals("userID").(uint)
+	if err := h.service.AssignBooks(uint(id), input.BookIDs, userID); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to assign books"})
 	}
 
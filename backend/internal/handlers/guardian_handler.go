@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type GuardianHandler struct {
+	guardianService *services.GuardianService
+}
+
+func NewGuardianHandler(guardianService *services.GuardianService) *GuardianHandler {
+	return &GuardianHandler{guardianService: guardianService}
+}
+
+type GuardianInviteRequest struct {
+	Email        string `json:"email" validate:"required,email"`
+	Relationship string `json:"relationship" validate:"max=50"`
+}
+
+type GuardianSignupRequest struct {
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
+	Password  string `json:"password" validate:"required,min=8"`
+}
+
+// Student side
+
+func (h *GuardianHandler) GetMyGuardians(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	guardians, invitations, err := h.guardianService.GetStudentGuardians(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"guardians": guardians, "invitations": invitations})
+}
+
+func (h *GuardianHandler) InviteMyGuardian(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req GuardianInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	invitation, err := h.guardianService.Invite(userID, userID, req.Email, req.Relationship)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "invite_guardian", "guardian_invitation", invitation.ID, "", invitation.Email)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"invitation": invitation})
+}
+
+func (h *GuardianHandler) CancelMyInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid invitation ID"})
+	}
+
+	if err := h.guardianService.CancelInvitation(userID, uint(id)); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"message": "Invitation cancelled"})
+}
+
+func (h *GuardianHandler) RemoveMyGuardian(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid guardian ID"})
+	}
+
+	if err := h.guardianService.RemoveGuardian(userID, uint(id)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "remove_guardian", "guardian_link", uint(id), "", "")
+	return c.JSON(fiber.Map{"message": "Guardian removed"})
+}
+
+// Staff side
+
+func (h *GuardianHandler) GetStudentGuardians(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student ID"})
+	}
+
+	guardians, invitations, err := h.guardianService.GetStudentGuardiansScoped(uint(id), classScope(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"guardians": guardians, "invitations": invitations})
+}
+
+func (h *GuardianHandler) InviteStudentGuardian(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student ID"})
+	}
+
+	var req GuardianInviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	invitation, err := h.guardianService.InviteForStudent(uint(id), userID, classScope(c), req.Email, req.Relationship)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "invite_guardian", "guardian_invitation", invitation.ID, "", fmt.Sprintf("student %d: %s", id, invitation.Email))
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"invitation": invitation})
+}
+
+// Invitation links
+
+func (h *GuardianHandler) GetInvitation(c *fiber.Ctx) error {
+	invitation, err := h.guardianService.GetInvitation(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"invitation": invitation})
+}
+
+// AcceptInvitationWithSignup creates a guardian account for someone who
+// doesn't have one yet.
+func (h *GuardianHandler) AcceptInvitationWithSignup(c *fiber.Ctx) error {
+	var req GuardianSignupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if err := utils.Validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	user, err := h.guardianService.AcceptWithAccount(c.Params("token"), req.FirstName, req.LastName, req.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Account created, you can now sign in",
+		"user":    user,
+	})
+}
+
+// AcceptInvitation links the signed-in user.
+func (h *GuardianHandler) AcceptInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	link, err := h.guardianService.Accept(c.Params("token"), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"link": link})
+}
+
+// Guardian side, read-only apart from the digest preference
+
+func parseChildID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, utils.NewBadRequestError("Invalid student ID")
+	}
+	return uint(id), nil
+}
+
+func (h *GuardianHandler) GetChildren(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	children, err := h.guardianService.GetChildren(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"children": children})
+}
+
+func (h *GuardianHandler) GetChild(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	studentID, err := parseChildID(c)
+	if err != nil {
+		return err
+	}
+
+	child, err := h.guardianService.GetChildOverview(userID, studentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"child": child})
+}
+
+func (h *GuardianHandler) GetChildLibrary(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	studentID, err := parseChildID(c)
+	if err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	library, meta, err := h.guardianService.GetChildLibrary(userID, studentID, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"library": library, "pagination": meta})
+}
+
+func (h *GuardianHandler) GetChildSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	studentID, err := parseChildID(c)
+	if err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	sessions, meta, err := h.guardianService.GetChildSessions(userID, studentID, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"sessions": sessions, "pagination": meta})
+}
+
+func (h *GuardianHandler) GetChildStreak(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	studentID, err := parseChildID(c)
+	if err != nil {
+		return err
+	}
+
+	streak, err := h.guardianService.GetChildStreak(userID, studentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"streak": streak})
+}
+
+func (h *GuardianHandler) GetChildAchievements(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	studentID, err := parseChildID(c)
+	if err != nil {
+		return err
+	}
+
+	achievements, err := h.guardianService.GetChildAchievements(userID, studentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"achievements": achievements})
+}
+
+func (h *GuardianHandler) UpdateDigest(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := h.guardianService.SetDigest(userID, req.Enabled); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"message": "Digest preference saved", "enabled": req.Enabled})
+}
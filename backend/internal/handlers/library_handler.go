@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"path/filepath"
 	"strconv"
 
+	"readagain/internal/middleware"
 	"readagain/internal/services"
 	"readagain/internal/utils"
 
@@ -13,12 +13,14 @@ import (
 type LibraryHandler struct {
 	libraryService *services.LibraryService
 	ereaderService *services.EReaderService
+	userService    *services.UserService
 }
 
-func NewLibraryHandler(libraryService *services.LibraryService, ereaderService *services.EReaderService) *LibraryHandler {
+func NewLibraryHandler(libraryService *services.LibraryService, ereaderService *services.EReaderService, userService *services.UserService) *LibraryHandler {
 	return &LibraryHandler{
 		libraryService: libraryService,
 		ereaderService: ereaderService,
+		userService:    userService,
 	}
 }
 
@@ -64,6 +66,8 @@ func (h *LibraryHandler) AddToLibrary(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Book added to library"})
 }
 
+// AccessBook returns a short-lived signed URL for the book file on
+// upload-api, which refuses unsigned requests for books.
 func (h *LibraryHandler) AccessBook(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -71,17 +75,12 @@ func (h *LibraryHandler) AccessBook(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
 	}
 
-	book, err := h.ereaderService.ValidateBookAccess(userID, uint(bookID))
+	signed, err := h.ereaderService.GetBookFileURL(userID, uint(bookID))
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
-	if book.FilePath == "" {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Book file not found"})
-	}
-
-	filePath := filepath.Join("./uploads", book.FilePath)
-	return c.SendFile(filePath)
+	return c.JSON(signed)
 }
 
 func (h *LibraryHandler) UpdateProgress(c *fiber.Ctx) error {
@@ -114,6 +113,75 @@ func (h *LibraryHandler) UpdateProgress(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Progress updated successfully"})
 }
 
+func (h *LibraryHandler) GetPosition(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	position, err := h.libraryService.GetPosition(userID, uint(bookID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"position": position})
+}
+
+// GetChapterProgress returns how far the reader has got through each
+// chapter of the book.
+func (h *LibraryHandler) GetChapterProgress(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	report, err := h.libraryService.GetChapterProgress(userID, uint(bookID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// UpdatePosition answers 409 with the stored position when another device
+// saved a newer one; the client should move the reader there.
+func (h *LibraryHandler) UpdatePosition(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	var input services.PositionUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	result, err := h.libraryService.UpdatePosition(userID, uint(bookID), input)
+	if err != nil {
+		return err
+	}
+
+	if result.Conflict {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":    "A newer reading position was saved on another device",
+			"conflict": true,
+			"position": result.Position,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"conflict": false,
+		"position": result.Position,
+	})
+}
+
 func (h *LibraryHandler) GetStatistics(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
@@ -370,17 +438,171 @@ func (h *LibraryHandler) DeleteHighlight(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Highlight deleted successfully"})
 }
 
+// SyncAnnotations takes a device's offline bookmark, note and highlight
+// changes in one batch and answers with everything changed since its token.
+func (h *LibraryHandler) SyncAnnotations(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	var input services.AnnotationSyncRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	result, err := h.ereaderService.SyncAnnotations(userID, uint(bookID), input)
+	if err != nil {
+		return err
+	}
+
+	utils.InfoLogger.Printf("User %d synced annotations for book %d (%d bookmarks, %d notes, %d highlights pushed)",
+		userID, bookID, len(input.Bookmarks), len(input.Notes), len(input.Highlights))
+	return c.JSON(result)
+}
+
+func (h *LibraryHandler) ExportAnnotations(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	id := uint(bookID)
+	return h.sendAnnotationExport(c, userID, &id)
+}
+
+// ExportAllAnnotations exports the annotations of every book in the library.
+func (h *LibraryHandler) ExportAllAnnotations(c *fiber.Ctx) error {
+	return h.sendAnnotationExport(c, c.Locals("userID").(uint), nil)
+}
+
+func (h *LibraryHandler) sendAnnotationExport(c *fiber.Ctx, userID uint, bookID *uint) error {
+	export, err := h.ereaderService.ExportAnnotations(userID, bookID, c.Query("format", "md"))
+	if err != nil {
+		return err
+	}
+
+	c.Set("Content-Type", export.ContentType)
+	c.Set("Content-Disposition", "attachment; filename="+export.Filename)
+	return c.Send(export.Data)
+}
+
+func (h *LibraryHandler) GetSharedAnnotations(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+
+	annotations, err := h.ereaderService.GetSharedAnnotations(userID, uint(bookID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"annotations": annotations})
+}
+
+func (h *LibraryHandler) SetAnnotationVisibility(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	annotationID, err := strconv.ParseUint(c.Params("annotationId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid annotation ID"})
+	}
+
+	var input struct {
+		Visibility string `json:"visibility" validate:"required,oneof=private group public"`
+		GroupID    *uint  `json:"group_id"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	kind := c.Params("type")
+	if err := h.ereaderService.SetVisibility(userID, kind, uint(annotationID), input.Visibility, input.GroupID); err != nil {
+		return err
+	}
+
+	utils.InfoLogger.Printf("User %d set %s %d visibility to %s", userID, kind, annotationID, input.Visibility)
+	return c.JSON(fiber.Map{"message": "Annotation visibility updated"})
+}
+
+func (h *LibraryHandler) ReplyToAnnotation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	annotationID, err := strconv.ParseUint(c.Params("annotationId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid annotation ID"})
+	}
+
+	var input struct {
+		Content string `json:"content" validate:"required,max=2000"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	reply, err := h.ereaderService.ReplyToAnnotation(userID, c.Params("type"), uint(annotationID), input.Content)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"reply": reply})
+}
+
+func (h *LibraryHandler) HideAnnotationReply(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	replyID, err := strconv.ParseUint(c.Params("replyId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid reply ID"})
+	}
+
+	input := struct {
+		Hidden bool `json:"hidden"`
+	}{Hidden: true}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+
+	reply, err := h.ereaderService.HideReply(userID, uint(replyID), input.Hidden)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "hide_annotation_reply", "annotation_reply", reply.ID, "", strconv.FormatBool(input.Hidden))
+	return c.JSON(fiber.Map{"reply": reply})
+}
+
 // Admin endpoints
 func (h *LibraryHandler) GetLibraryAssignments(c *fiber.Ctx) error {
 	skip, _ := strconv.Atoi(c.Query("skip", "0"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	search := c.Query("search", "")
 	status := c.Query("status", "")
-	userID := c.Query("user_id", "")
-	sortBy := c.Query("sort_by", "created_at")
-	sortOrder := c.Query("sort_order", "desc")
 
-	assignments, total, err := h.libraryService.GetAllAssignments(skip, limit, search, status, userID, sortBy, sortOrder)
+	query, err := services.AssignmentListSpec.Parse(c.Queries())
+	if err != nil {
+		return err
+	}
+
+	assignments, total, err := h.libraryService.GetAllAssignments(skip, limit, search, status, query, classScope(c))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -397,7 +619,7 @@ func (h *LibraryHandler) GetLibraryAssignments(c *fiber.Ctx) error {
 }
 
 func (h *LibraryHandler) GetLibraryStats(c *fiber.Ctx) error {
-	stats, err := h.libraryService.GetLibraryStats()
+	stats, err := h.libraryService.GetLibraryStats(classScope(c))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -419,6 +641,10 @@ func (h *LibraryHandler) AssignBookToUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
+	if err := h.userService.EnsureUserInSchool(input.UserID, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	if err := h.libraryService.AssignBook(input.UserID, input.BookID, input.Format); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -440,6 +666,10 @@ func (h *LibraryHandler) BulkAssignBook(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
+	if err := h.userService.EnsureUsersInSchool(input.UserIDs, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	count, err := h.libraryService.BulkAssignBook(input.UserIDs, input.BookID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
@@ -464,7 +694,7 @@ func (h *LibraryHandler) BulkRemoveAssignments(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	count, err := h.libraryService.BulkRemoveAssignments(input.AssignmentIDs)
+	count, err := h.libraryService.BulkRemoveAssignments(input.AssignmentIDs, middleware.SchoolScope(c))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -481,7 +711,7 @@ func (h *LibraryHandler) RemoveAssignment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	if err := h.libraryService.RemoveAssignment(uint(id)); err != nil {
+	if err := h.libraryService.RemoveAssignment(uint(id), middleware.SchoolScope(c)); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -491,7 +721,7 @@ func (h *LibraryHandler) RemoveAssignment(c *fiber.Ctx) error {
 func (h *LibraryHandler) GetBooksWithStudents(c *fiber.Ctx) error {
 	search := c.Query("search", "")
 	
-	books, err := h.libraryService.GetBooksWithStudents(search)
+	books, err := h.libraryService.GetBooksWithStudents(search, classScope(c))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -505,9 +735,9 @@ func (h *LibraryHandler) GetAssignmentDetails(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	details, err := h.libraryService.GetAssignmentDetails(uint(id))
+	details, err := h.libraryService.GetAssignmentDetails(uint(id), classScope(c))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(details)
@@ -519,9 +749,9 @@ func (h *LibraryHandler) GetAssignmentAnalytics(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	analytics, err := h.libraryService.GetAssignmentAnalytics(uint(id))
+	analytics, err := h.libraryService.GetAssignmentAnalytics(uint(id), classScope(c))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(analytics)
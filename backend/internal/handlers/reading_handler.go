@@ -12,14 +12,16 @@ import (
 )
 
 type ReadingHandler struct {
-	sessionService *services.ReadingSessionService
-	goalService    *services.ReadingGoalService
+	sessionService    *services.ReadingSessionService
+	goalService       *services.ReadingGoalService
+	assignmentService *services.ReadingAssignmentService
 }
 
-func NewReadingHandler(sessionService *services.ReadingSessionService, goalService *services.ReadingGoalService) *ReadingHandler {
+func NewReadingHandler(sessionService *services.ReadingSessionService, goalService *services.ReadingGoalService, assignmentService *services.ReadingAssignmentService) *ReadingHandler {
 	return &ReadingHandler{
-		sessionService: sessionService,
-		goalService:    goalService,
+		sessionService:    sessionService,
+		goalService:       goalService,
+		assignmentService: assignmentService,
 	}
 }
 
@@ -27,7 +29,8 @@ func (h *ReadingHandler) StartSession(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
 	var input struct {
-		BookID uint `json:"book_id" validate:"required"`
+		BookID  uint            `json:"book_id" validate:"required"`
+		Locator *models.Locator `json:"locator"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -38,7 +41,7 @@ func (h *ReadingHandler) StartSession(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	session, err := h.sessionService.StartSession(userID, input.BookID)
+	session, err := h.sessionService.StartSession(userID, input.BookID, input.Locator)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to start session: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -48,12 +51,40 @@ func (h *ReadingHandler) StartSession(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"session": session})
 }
 
+func (h *ReadingHandler) Heartbeat(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+
+	var input struct {
+		Locator *models.Locator `json:"locator"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	session, err := h.sessionService.Heartbeat(userID, uint(sessionID), input.Locator)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"session": session})
+}
+
 func (h *ReadingHandler) EndSession(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
 	var input struct {
-		SessionID uint `json:"session_id" validate:"required"`
-		Duration  int  `json:"duration" validate:"required,gte=0"`
+		SessionID uint            `json:"session_id" validate:"required"`
+		Locator   *models.Locator `json:"locator"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -64,13 +95,20 @@ func (h *ReadingHandler) EndSession(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	if err := h.sessionService.EndSession(input.SessionID, input.Duration); err != nil {
-		utils.ErrorLogger.Printf("Failed to end session: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	session, err := h.sessionService.EndSession(userID, input.SessionID, input.Locator)
+	if err != nil {
+		return err
+	}
+
+	if err := h.assignmentService.RefreshStudent(userID, session.BookID); err != nil {
+		utils.ErrorLogger.Printf("Failed to refresh assignments of user %d: %v", userID, err)
 	}
 
-	utils.InfoLogger.Printf("User %d ended reading session %d", userID, input.SessionID)
-	return c.JSON(fiber.Map{"message": "Session ended successfully"})
+	utils.InfoLogger.Printf("User %d ended reading session %d after %ds", userID, input.SessionID, session.Duration)
+	return c.JSON(fiber.Map{
+		"message": "Session ended successfully",
+		"session": session,
+	})
 }
 
 func (h *ReadingHandler) GetSessions(c *fiber.Ctx) error {
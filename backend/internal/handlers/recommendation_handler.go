@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"strconv"
+
+	"readagain/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RecommendationHandler struct {
+	recommendationService *services.RecommendationService
+}
+
+func NewRecommendationHandler(recommendationService *services.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{recommendationService: recommendationService}
+}
+
+// GetRecommended suggests books at the reader's level, along with the level
+// they were matched at.
+func (h *RecommendationHandler) GetRecommended(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	books, level, err := h.recommendationService.GetRecommended(userID, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"books": books, "level": level})
+}
+
+// GetSimilarBooks lists what readers who finished the book also enjoyed.
+func (h *RecommendationHandler) GetSimilarBooks(c *fiber.Ctx) error {
+	bookID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid book ID"})
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	books, err := h.recommendationService.GetSimilarBooks(uint(bookID), limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"books": books})
+}
+
+// GetPicks returns the reader's personalised picks for the dashboard.
+func (h *RecommendationHandler) GetPicks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	picks, err := h.recommendationService.GetPicks(userID, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"books": picks})
+}
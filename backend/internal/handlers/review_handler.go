@@ -56,7 +56,12 @@ func (h *ReviewHandler) GetBookReviews(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 
-	reviews, meta, err := h.service.GetBookReviews(uint(bookID), page, limit, true)
+	query, err := services.BookReviewSpec.Parse(c.Queries())
+	if err != nil {
+		return err
+	}
+
+	reviews, meta, err := h.service.GetBookReviews(uint(bookID), page, limit, true, query)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to get book reviews: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
@@ -75,10 +80,15 @@ func (h *ReviewHandler) GetBookReviews(c *fiber.Ctx) error {
 func (h *ReviewHandler) ListAllReviews(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	status := c.Query("status")
 	classLevel := c.Query("class_level")
+	search := c.Query("search")
+
+	query, err := services.ReviewListSpec.Parse(c.Queries())
+	if err != nil {
+		return err
+	}
 
-	reviews, meta, err := h.service.ListAll(page, limit, status, classLevel)
+	reviews, meta, err := h.service.ListAll(page, limit, classLevel, search, query)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to list reviews: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
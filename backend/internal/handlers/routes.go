@@ -11,6 +11,7 @@ import (
 func SetupRoutes(
 	app *fiber.App,
 	authService *services.AuthService,
+	userSessionService *services.UserSessionService,
 	userService *services.UserService,
 	roleService *services.RoleService,
 	categoryService *services.CategoryService,
@@ -26,6 +27,7 @@ func SetupRoutes(
 	testimonialService *services.TestimonialService,
 	contactService *services.ContactService,
 	settingsService *services.SettingsService,
+	emailService *services.EmailService,
 	analyticsService *services.AnalyticsService,
 	reportService *services.ReportService,
 	notificationService *services.NotificationService,
@@ -34,24 +36,42 @@ func SetupRoutes(
 	aboutService *services.AboutService,
 	wishlistService *services.WishlistService,
 	groupService *services.GroupService,
+	schoolService *services.SchoolService,
+	userImportService *services.UserImportService,
+	streakService *services.StreakService,
+	watermarkService *services.WatermarkService,
+	assignmentService *services.ReadingAssignmentService,
+	teacherService *services.TeacherService,
+	guardianService *services.GuardianService,
+	recommendationService *services.RecommendationService,
+	fileSigner *services.FileURLSigner,
 	chatHandler *ChatHandler,
 ) {
 	api := app.Group("/api/v1")
 
-	authHandler := NewAuthHandler(authService)
-	userHandler := NewUserHandler(userService)
+	authHandler := NewAuthHandler(authService, userSessionService)
+	userHandler := NewUserHandler(userService, userSessionService)
+	userImportHandler := NewUserImportHandler(userImportService)
 	roleHandler := NewRoleHandler(roleService)
 	categoryHandler := NewCategoryHandler(categoryService)
 	authorHandler := NewAuthorHandler(authorService)
 	bookHandler := NewBookHandler(bookService)
-	libraryHandler := NewLibraryHandler(libraryService, ereaderService)
-	readingHandler := NewReadingHandler(sessionService, goalService)
+	libraryHandler := NewLibraryHandler(libraryService, ereaderService, userService)
+	readingHandler := NewReadingHandler(sessionService, goalService, assignmentService)
+	streakHandler := NewStreakHandler(streakService)
 	achievementHandler := NewAchievementHandler(achievementService)
 	blogHandler := NewBlogHandler(blogService)
 	faqHandler := NewFAQHandler(faqService)
 	testimonialHandler := NewTestimonialHandler(testimonialService)
 	contactHandler := NewContactHandler(contactService)
-	settingsHandler := NewSettingsHandler(settingsService)
+	settingsHandler := NewSettingsHandler(settingsService, emailService)
+	watermarkHandler := NewWatermarkHandler(watermarkService)
+	assignmentHandler := NewAssignmentHandler(assignmentService)
+	teacherHandler := NewTeacherHandler(teacherService)
+	guardianHandler := NewGuardianHandler(guardianService)
+	recommendationHandler := NewRecommendationHandler(recommendationService)
+	fileHandler := NewFileHandler(fileSigner)
+	emailHandler := NewEmailHandler(emailService)
 	analyticsHandler := NewAnalyticsHandler(analyticsService, reportService)
 	notificationHandler := NewNotificationHandler(notificationService)
 	auditHandler := NewAuditHandler(auditService)
@@ -59,6 +79,7 @@ func SetupRoutes(
 	aboutHandler := NewAboutHandler(aboutService)
 	wishlistHandler := NewWishlistHandler(wishlistService)
 	groupHandler := NewGroupHandler(groupService)
+	schoolHandler := NewSchoolHandler(schoolService)
 
 	app.Use(middleware.AuditMiddleware(auditService))
 
@@ -68,34 +89,44 @@ func SetupRoutes(
 	auth.Post("/refresh", authHandler.RefreshToken)
 	auth.Post("/logout", middleware.AuthRequired(), authHandler.Logout)
 	auth.Get("/me", middleware.AuthRequired(), authHandler.GetMe)
+	auth.Get("/sessions", middleware.AuthRequired(), authHandler.ListSessions)
+	auth.Delete("/sessions", middleware.AuthRequired(), authHandler.RevokeAllSessions)
+	auth.Delete("/sessions/:id", middleware.AuthRequired(), authHandler.RevokeSession)
 	auth.Post("/forgot-password", authHandler.ForgotPassword)
 	auth.Post("/reset-password", authHandler.ResetPassword)
+	auth.Post("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/resend-verification", authHandler.ResendVerification)
+	auth.Get("/guardian-invitations/:token", guardianHandler.GetInvitation)
+	auth.Post("/guardian-invitations/:token/accept", guardianHandler.AcceptInvitationWithSignup)
 
 	users := api.Group("/users")
 	users.Get("/profile", middleware.AuthRequired(), userHandler.GetProfile)
 	users.Put("/profile", middleware.AuthRequired(), userHandler.UpdateProfile)
 	users.Post("/change-password", middleware.AuthRequired(), userHandler.ChangePassword)
 
-	users.Post("/", middleware.AdminRequired(), userHandler.CreateUser)
-	users.Get("/", middleware.AdminRequired(), userHandler.ListUsers)
-	users.Get("/:id", middleware.AdminRequired(), userHandler.GetUser)
-	users.Put("/:id", middleware.AdminRequired(), userHandler.UpdateUser)
-	users.Patch("/:id/status", middleware.AdminRequired(), userHandler.ToggleStatus)
-	users.Delete("/:id", middleware.AdminRequired(), userHandler.DeleteUser)
-	users.Post("/:id/roles", middleware.AdminRequired(), userHandler.AssignRole)
-	users.Delete("/:id/roles", middleware.AdminRequired(), userHandler.RemoveRole)
-	users.Post("/:id/reset-password", middleware.AdminRequired(), userHandler.AdminResetPassword)
+	users.Post("/", middleware.RequirePermission("users.create"), userHandler.CreateUser)
+	users.Get("/", middleware.RequirePermission("users.view"), userHandler.ListUsers)
+	users.Get("/:id", middleware.RequirePermission("users.view"), userHandler.GetUser)
+	users.Put("/:id", middleware.RequirePermission("users.edit"), userHandler.UpdateUser)
+	users.Patch("/:id/status", middleware.RequirePermission("users.edit"), userHandler.ToggleStatus)
+	users.Delete("/:id", middleware.RequirePermission("users.delete"), userHandler.DeleteUser)
+	users.Post("/:id/roles", middleware.RequirePermission("users.manage"), userHandler.AssignRole)
+	users.Delete("/:id/roles", middleware.RequirePermission("users.manage"), userHandler.RemoveRole)
+	users.Post("/:id/reset-password", middleware.RequirePermission("users.edit"), userHandler.AdminResetPassword)
+	users.Get("/:id/sessions", middleware.RequirePermission("users.view"), userHandler.ListUserSessions)
+	users.Delete("/:id/sessions", middleware.RequirePermission("users.edit"), userHandler.RevokeAllUserSessions)
+	users.Delete("/:id/sessions/:sessionId", middleware.RequirePermission("users.edit"), userHandler.RevokeUserSession)
 	
-	users.Post("/bulk/activate", middleware.AdminRequired(), userHandler.BulkActivate)
-	users.Post("/bulk/deactivate", middleware.AdminRequired(), userHandler.BulkDeactivate)
-	users.Post("/bulk/delete", middleware.AdminRequired(), userHandler.BulkDelete)
+	users.Post("/bulk/activate", middleware.RequirePermission("users.edit"), userHandler.BulkActivate)
+	users.Post("/bulk/deactivate", middleware.RequirePermission("users.edit"), userHandler.BulkDeactivate)
+	users.Post("/bulk/delete", middleware.RequirePermission("users.delete"), userHandler.BulkDelete)
 
 	roles := api.Group("/roles")
 	roles.Get("/", roleHandler.ListRoles)
-	roles.Post("/", middleware.AdminRequired(), roleHandler.CreateRole)
+	roles.Post("/", middleware.RequirePermission("roles.create"), roleHandler.CreateRole)
 	roles.Get("/:id", roleHandler.GetRole)
-	roles.Put("/:id", middleware.AdminRequired(), roleHandler.UpdateRole)
-	roles.Delete("/:id", middleware.AdminRequired(), roleHandler.DeleteRole)
+	roles.Put("/:id", middleware.RequirePermission("roles.edit"), roleHandler.UpdateRole)
+	roles.Delete("/:id", middleware.RequirePermission("roles.delete"), roleHandler.DeleteRole)
 
 	permissions := api.Group("/permissions")
 	permissions.Get("/", roleHandler.ListPermissions)
@@ -106,86 +137,141 @@ func SetupRoutes(
 	// RBAC aliases for frontend compatibility
 	rbac := api.Group("/rbac")
 	rbac.Get("/roles", roleHandler.ListRoles)
-	rbac.Post("/roles", middleware.AdminRequired(), roleHandler.CreateRole)
+	rbac.Post("/roles", middleware.RequirePermission("roles.create"), roleHandler.CreateRole)
 	rbac.Get("/roles/:id", roleHandler.GetRole)
-	rbac.Put("/roles/:id", middleware.AdminRequired(), roleHandler.UpdateRole)
-	rbac.Delete("/roles/:id", middleware.AdminRequired(), roleHandler.DeleteRole)
+	rbac.Put("/roles/:id", middleware.RequirePermission("roles.edit"), roleHandler.UpdateRole)
+	rbac.Delete("/roles/:id", middleware.RequirePermission("roles.delete"), roleHandler.DeleteRole)
 	rbac.Get("/roles/:id/permissions", roleHandler.GetRolePermissions)
-	rbac.Post("/roles/:id/permissions", middleware.AdminRequired(), roleHandler.AddPermission)
-	rbac.Delete("/roles/:id/permissions/:permissionId", middleware.AdminRequired(), roleHandler.RemovePermission)
+	rbac.Post("/roles/:id/permissions", middleware.RequirePermission("roles.edit"), roleHandler.AddPermission)
+	rbac.Delete("/roles/:id/permissions/:permissionId", middleware.RequirePermission("roles.edit"), roleHandler.RemovePermission)
 	rbac.Get("/permissions", roleHandler.ListPermissions)
 
 	// Admin users alias
-	adminUsers := api.Group("/admin/users", middleware.AdminRequired())
-	adminUsers.Get("/", userHandler.ListUsers)
-	adminUsers.Post("/", userHandler.CreateUser)
-	adminUsers.Get("/:id", userHandler.GetUser)
-	adminUsers.Put("/:id", userHandler.UpdateUser)
-	adminUsers.Put("/:id/status", userHandler.ToggleStatus)
-	adminUsers.Delete("/:id", userHandler.DeleteUser)
+	adminUsers := api.Group("/admin/users")
+	adminUsers.Get("/", middleware.RequirePermission("users.view"), userHandler.ListUsers)
+	adminUsers.Post("/", middleware.RequirePermission("users.create"), userHandler.CreateUser)
+	adminUsers.Post("/import", middleware.RequirePermission("users.create"), userImportHandler.Import)
+	adminUsers.Get("/import/:id", middleware.RequirePermission("users.create"), userImportHandler.GetJob)
+	adminUsers.Get("/:id", middleware.RequirePermission("users.view"), userHandler.GetUser)
+	adminUsers.Put("/:id", middleware.RequirePermission("users.edit"), userHandler.UpdateUser)
+	adminUsers.Put("/:id/status", middleware.RequirePermission("users.edit"), userHandler.ToggleStatus)
+	adminUsers.Delete("/:id", middleware.RequirePermission("users.delete"), userHandler.DeleteUser)
+
+	adminSchools := api.Group("/admin/schools")
+	adminSchools.Get("/", middleware.RequirePermission("schools.manage"), schoolHandler.ListSchools)
+	adminSchools.Post("/", middleware.RequirePermission("schools.manage"), schoolHandler.CreateSchool)
+	adminSchools.Get("/:id", middleware.RequirePermission("schools.manage"), schoolHandler.GetSchool)
+	adminSchools.Put("/:id", middleware.RequirePermission("schools.manage"), schoolHandler.UpdateSchool)
+	adminSchools.Delete("/:id", middleware.RequirePermission("schools.manage"), schoolHandler.DeleteSchool)
+	adminSchools.Post("/:id/users", middleware.RequirePermission("schools.manage"), schoolHandler.AssignUsers)
 
 	categories := api.Group("/categories")
 	categories.Get("/", categoryHandler.ListCategories)
 	categories.Get("/:id", categoryHandler.GetCategory)
-	categories.Post("/", middleware.AdminRequired(), categoryHandler.CreateCategory)
-	categories.Put("/:id", middleware.AdminRequired(), categoryHandler.UpdateCategory)
-	categories.Delete("/:id", middleware.AdminRequired(), categoryHandler.DeleteCategory)
+	categories.Post("/", middleware.RequirePermission("categories.manage"), categoryHandler.CreateCategory)
+	categories.Put("/:id", middleware.RequirePermission("categories.manage"), categoryHandler.UpdateCategory)
+	categories.Delete("/:id", middleware.RequirePermission("categories.manage"), categoryHandler.DeleteCategory)
 
 	authors := api.Group("/authors")
 	authors.Get("/", authorHandler.ListAuthors)
 	authors.Get("/:id", authorHandler.GetAuthor)
-	authors.Post("/", middleware.AdminRequired(), authorHandler.CreateAuthor)
-	authors.Put("/:id", middleware.AdminRequired(), authorHandler.UpdateAuthor)
-	authors.Delete("/:id", middleware.AdminRequired(), authorHandler.DeleteAuthor)
+	authors.Post("/", middleware.RequirePermission("authors.manage"), authorHandler.CreateAuthor)
+	authors.Put("/:id", middleware.RequirePermission("authors.manage"), authorHandler.UpdateAuthor)
+	authors.Delete("/:id", middleware.RequirePermission("authors.manage"), authorHandler.DeleteAuthor)
 
 	// Admin authors routes
-	adminAuthors := api.Group("/admin/authors", middleware.AdminRequired())
-	adminAuthors.Get("/", authorHandler.ListAuthors)
-	adminAuthors.Get("/stats", authorHandler.GetStats)
-	adminAuthors.Post("/", authorHandler.CreateAuthor)
-	adminAuthors.Get("/:id", authorHandler.GetAuthor)
-	adminAuthors.Put("/:id", authorHandler.UpdateAuthor)
-	adminAuthors.Delete("/:id", authorHandler.DeleteAuthor)
+	adminAuthors := api.Group("/admin/authors")
+	adminAuthors.Get("/", middleware.RequirePermission("authors.view"), authorHandler.ListAuthors)
+	adminAuthors.Get("/stats", middleware.RequirePermission("authors.view"), authorHandler.GetStats)
+	adminAuthors.Post("/", middleware.RequirePermission("authors.manage"), authorHandler.CreateAuthor)
+	adminAuthors.Get("/:id", middleware.RequirePermission("authors.view"), authorHandler.GetAuthor)
+	adminAuthors.Put("/:id", middleware.RequirePermission("authors.manage"), authorHandler.UpdateAuthor)
+	adminAuthors.Delete("/:id", middleware.RequirePermission("authors.manage"), authorHandler.DeleteAuthor)
 
 	books := api.Group("/books")
 	books.Get("/", bookHandler.ListBooks)
 	books.Get("/featured", bookHandler.GetFeaturedBooks)
 	books.Get("/new-releases", bookHandler.GetNewReleases)
 	books.Get("/bestsellers", bookHandler.GetBestsellers)
+	books.Get("/suggest", bookHandler.Suggest)
+	books.Get("/recommended", middleware.AuthRequired(), recommendationHandler.GetRecommended)
 	books.Get("/:id", bookHandler.GetBook)
-	books.Post("/", middleware.AdminRequired(), bookHandler.CreateBook)
-	books.Put("/:id", middleware.AdminRequired(), bookHandler.UpdateBook)
-	books.Delete("/:id", middleware.AdminRequired(), bookHandler.DeleteBook)
-	books.Patch("/:id/featured", middleware.AdminRequired(), bookHandler.ToggleFeatured)
+	books.Get("/:id/toc", bookHandler.GetTOC)
+	books.Get("/:id/similar", recommendationHandler.GetSimilarBooks)
+	books.Post("/", middleware.RequirePermission("books.create"), bookHandler.CreateBook)
+	books.Put("/:id", middleware.RequirePermission("books.edit"), bookHandler.UpdateBook)
+	books.Delete("/:id", middleware.RequirePermission("books.delete"), bookHandler.DeleteBook)
+	books.Patch("/:id/featured", middleware.RequirePermission("books.edit"), bookHandler.ToggleFeatured)
 
 	// Admin books routes
-	adminBooks := api.Group("/admin/books", middleware.AdminRequired())
-	adminBooks.Get("/", bookHandler.ListBooks)
-	adminBooks.Get("/stats", bookHandler.GetStats)
-	adminBooks.Post("/", bookHandler.CreateBook)
-	adminBooks.Get("/:id", bookHandler.GetBook)
-	adminBooks.Put("/:id", bookHandler.UpdateBook)
-	adminBooks.Delete("/:id", bookHandler.DeleteBook)
-	adminBooks.Patch("/:id/featured", bookHandler.ToggleFeatured)
+	adminBooks := api.Group("/admin/books")
+	adminBooks.Get("/", middleware.RequirePermission("books.view"), bookHandler.ListBooks)
+	adminBooks.Get("/stats", middleware.RequirePermission("books.view"), bookHandler.GetStats)
+	adminBooks.Post("/files/:filename/metadata-url", middleware.RequirePermission("books.edit"), fileHandler.SignMetadata)
+	adminBooks.Post("/files/:filename/delete-url", middleware.RequirePermission("books.delete"), fileHandler.SignDelete)
+	adminBooks.Post("/", middleware.RequirePermission("books.create"), bookHandler.CreateBook)
+	adminBooks.Get("/:id", middleware.RequirePermission("books.view"), bookHandler.GetBook)
+	adminBooks.Put("/:id", middleware.RequirePermission("books.edit"), bookHandler.UpdateBook)
+	adminBooks.Delete("/:id", middleware.RequirePermission("books.delete"), bookHandler.DeleteBook)
+	adminBooks.Patch("/:id/featured", middleware.RequirePermission("books.edit"), bookHandler.ToggleFeatured)
+	adminBooks.Put("/:id/toc", middleware.RequirePermission("books.edit"), bookHandler.UpdateTOC)
 
 	// Admin library routes
-	adminLibrary := api.Group("/admin", middleware.AdminRequired())
-	adminLibrary.Get("/library-assignments", libraryHandler.GetLibraryAssignments)
-	adminLibrary.Get("/library-stats", libraryHandler.GetLibraryStats)
-	adminLibrary.Post("/user-library", libraryHandler.AssignBookToUser)
-	adminLibrary.Post("/bulk-assign", libraryHandler.BulkAssignBook)
-	adminLibrary.Post("/bulk-remove", libraryHandler.BulkRemoveAssignments)
-	adminLibrary.Delete("/library-assignment/:id", libraryHandler.RemoveAssignment)
-	adminLibrary.Get("/books-with-students", libraryHandler.GetBooksWithStudents)
-	adminLibrary.Get("/library-assignment/:id/details", libraryHandler.GetAssignmentDetails)
-	adminLibrary.Get("/library-assignment/:id/analytics", libraryHandler.GetAssignmentAnalytics)
+	adminLibrary := api.Group("/admin")
+	adminLibrary.Get("/library-assignments", middleware.RequirePermission("library.view"), libraryHandler.GetLibraryAssignments)
+	adminLibrary.Get("/library-stats", middleware.RequirePermission("library.view"), libraryHandler.GetLibraryStats)
+	adminLibrary.Post("/user-library", middleware.RequirePermission("library.manage"), libraryHandler.AssignBookToUser)
+	adminLibrary.Post("/bulk-assign", middleware.RequirePermission("library.manage"), libraryHandler.BulkAssignBook)
+	adminLibrary.Post("/bulk-remove", middleware.RequirePermission("library.manage"), libraryHandler.BulkRemoveAssignments)
+	adminLibrary.Delete("/library-assignment/:id", middleware.RequirePermission("library.manage"), libraryHandler.RemoveAssignment)
+	adminLibrary.Get("/books-with-students", middleware.RequirePermission("library.view"), libraryHandler.GetBooksWithStudents)
+	adminLibrary.Get("/library-assignment/:id/details", middleware.RequirePermission("library.view"), libraryHandler.GetAssignmentDetails)
+	adminLibrary.Get("/library-assignment/:id/analytics", middleware.RequirePermission("reading.view_analytics"), libraryHandler.GetAssignmentAnalytics)
+	adminLibrary.Get("/streak-freezes", middleware.RequirePermission("reading.manage"), streakHandler.ListFreezes)
+	adminLibrary.Post("/streak-freezes", middleware.RequirePermission("reading.manage"), streakHandler.CreateFreeze)
+	adminLibrary.Delete("/streak-freezes/:id", middleware.RequirePermission("reading.manage"), streakHandler.DeleteFreeze)
+	adminLibrary.Get("/assignments", middleware.RequirePermission("assignments.manage"), assignmentHandler.ListAssignments)
+	adminLibrary.Post("/assignments", middleware.RequirePermission("assignments.manage"), assignmentHandler.CreateAssignment)
+	adminLibrary.Get("/assignments/:id", middleware.RequirePermission("assignments.manage"), assignmentHandler.GetAssignment)
+	adminLibrary.Put("/assignments/:id", middleware.RequirePermission("assignments.manage"), assignmentHandler.UpdateAssignment)
+	adminLibrary.Delete("/assignments/:id", middleware.RequirePermission("assignments.manage"), assignmentHandler.DeleteAssignment)
+	adminLibrary.Get("/students/:id/guardians", middleware.RequirePermission("guardians.manage"), guardianHandler.GetStudentGuardians)
+	adminLibrary.Post("/students/:id/guardian-invitations", middleware.RequirePermission("guardians.manage"), guardianHandler.InviteStudentGuardian)
+	adminLibrary.Put("/students/:id/reading-level", middleware.RequirePermission("reading_levels.manage"), teacherHandler.SetStudentReadingLevel)
+
+	// Teacher routes: a teacher's classes are the groups they created
+	teacher := api.Group("/teacher")
+	teacher.Get("/classes", middleware.RequirePermission("classes.view"), teacherHandler.ListClasses)
+	teacher.Get("/classes/:id/dashboard", middleware.RequirePermission("classes.view"), teacherHandler.GetClassDashboard)
+
+	// A student's own guardians
+	guardians := api.Group("/guardians", middleware.AuthRequired())
+	guardians.Get("/", guardianHandler.GetMyGuardians)
+	guardians.Post("/invitations", guardianHandler.InviteMyGuardian)
+	guardians.Delete("/invitations/:id", guardianHandler.CancelMyInvitation)
+	guardians.Delete("/:id", guardianHandler.RemoveMyGuardian)
+
+	// Guardian routes: read-only views of linked children
+	guardian := api.Group("/guardian", middleware.AuthRequired())
+	guardian.Post("/invitations/:token/accept", guardianHandler.AcceptInvitation)
+	guardian.Get("/children", guardianHandler.GetChildren)
+	guardian.Get("/children/:id", guardianHandler.GetChild)
+	guardian.Get("/children/:id/library", guardianHandler.GetChildLibrary)
+	guardian.Get("/children/:id/sessions", guardianHandler.GetChildSessions)
+	guardian.Get("/children/:id/streak", guardianHandler.GetChildStreak)
+	guardian.Get("/children/:id/achievements", guardianHandler.GetChildAchievements)
+	guardian.Put("/digest", guardianHandler.UpdateDigest)
 
 	library := api.Group("/library", middleware.AuthRequired())
 	library.Get("/", libraryHandler.GetLibrary)
 	library.Post("/", libraryHandler.AddToLibrary)
 	library.Get("/statistics", libraryHandler.GetStatistics)
+	library.Get("/annotations/export", libraryHandler.ExportAllAnnotations)
 	library.Get("/:id/access", libraryHandler.AccessBook)
 	library.Put("/:id/progress", libraryHandler.UpdateProgress)
+	library.Get("/:id/position", libraryHandler.GetPosition)
+	library.Put("/:id/position", libraryHandler.UpdatePosition)
+	library.Get("/:id/chapters", libraryHandler.GetChapterProgress)
 	library.Get("/:id/bookmarks", libraryHandler.GetBookmarks)
 	library.Post("/:id/bookmarks", libraryHandler.CreateBookmark)
 	library.Delete("/bookmarks/:bookmarkId", libraryHandler.DeleteBookmark)
@@ -196,11 +282,20 @@ func SetupRoutes(
 	library.Get("/:id/highlights", libraryHandler.GetHighlights)
 	library.Post("/:id/highlights", libraryHandler.CreateHighlight)
 	library.Delete("/highlights/:highlightId", libraryHandler.DeleteHighlight)
+	library.Post("/:id/annotations/sync", libraryHandler.SyncAnnotations)
+	library.Get("/:id/annotations/export", libraryHandler.ExportAnnotations)
+	library.Get("/:id/annotations/shared", libraryHandler.GetSharedAnnotations)
+	library.Put("/annotations/:type/:annotationId/visibility", middleware.RequirePermission("annotations.share"), libraryHandler.SetAnnotationVisibility)
+	library.Post("/annotations/:type/:annotationId/replies", libraryHandler.ReplyToAnnotation)
+	library.Put("/annotations/replies/:replyId/hide", middleware.RequirePermission("annotations.share"), libraryHandler.HideAnnotationReply)
 
 	reading := api.Group("/reading", middleware.AuthRequired())
 	reading.Post("/sessions/start", readingHandler.StartSession)
 	reading.Post("/sessions/end", readingHandler.EndSession)
+	reading.Post("/sessions/:id/heartbeat", readingHandler.Heartbeat)
 	reading.Get("/sessions", readingHandler.GetSessions)
+	reading.Get("/streak", streakHandler.GetStreak)
+	reading.Get("/assignments", assignmentHandler.GetMyAssignments)
 	reading.Get("/goals", readingHandler.GetGoals)
 	reading.Post("/goals", readingHandler.CreateGoal)
 	reading.Put("/goals/:id", readingHandler.UpdateGoal)
@@ -216,79 +311,85 @@ func SetupRoutes(
 	achievements.Get("/", achievementHandler.GetAllAchievements)
 	achievements.Get("/user", middleware.AuthRequired(), achievementHandler.GetUserAchievements)
 	achievements.Post("/check", middleware.AuthRequired(), achievementHandler.CheckAchievements)
-	achievements.Post("/", middleware.AdminRequired(), achievementHandler.CreateAchievement)
-	achievements.Put("/:id", middleware.AdminRequired(), achievementHandler.UpdateAchievement)
-	achievements.Delete("/:id", middleware.AdminRequired(), achievementHandler.DeleteAchievement)
+	achievements.Post("/", middleware.RequirePermission("achievements.manage"), achievementHandler.CreateAchievement)
+	achievements.Put("/:id", middleware.RequirePermission("achievements.manage"), achievementHandler.UpdateAchievement)
+	achievements.Delete("/:id", middleware.RequirePermission("achievements.manage"), achievementHandler.DeleteAchievement)
 
 	blogs := api.Group("/blogs")
 	blogs.Get("/", blogHandler.List)
 	blogs.Get("/:slug", blogHandler.GetBySlug)
 
-	adminBlogs := api.Group("/admin/blogs", middleware.AdminRequired())
-	adminBlogs.Get("/", blogHandler.AdminList)
-	adminBlogs.Get("/stats", blogHandler.GetStats)
-	adminBlogs.Get("/:id", blogHandler.GetByID)
-	adminBlogs.Post("/", blogHandler.Create)
-	adminBlogs.Put("/:id", blogHandler.Update)
-	adminBlogs.Delete("/:id", blogHandler.Delete)
+	adminBlogs := api.Group("/admin/blogs")
+	adminBlogs.Get("/", middleware.RequirePermission("blog.view"), blogHandler.AdminList)
+	adminBlogs.Get("/stats", middleware.RequirePermission("blog.view"), blogHandler.GetStats)
+	adminBlogs.Get("/:id", middleware.RequirePermission("blog.view"), blogHandler.GetByID)
+	adminBlogs.Post("/", middleware.RequirePermission("blog.create"), blogHandler.Create)
+	adminBlogs.Put("/:id", middleware.RequirePermission("blog.edit"), blogHandler.Update)
+	adminBlogs.Delete("/:id", middleware.RequirePermission("blog.delete"), blogHandler.Delete)
 
 	faqs := api.Group("/faqs")
 	faqs.Get("/", faqHandler.List)
 	faqs.Get("/categories", faqHandler.GetCategories)
 
-	adminFaqs := api.Group("/admin/faqs", middleware.AdminRequired())
-	adminFaqs.Get("/", faqHandler.AdminList)
-	adminFaqs.Get("/:id", faqHandler.GetByID)
-	adminFaqs.Post("/", faqHandler.Create)
-	adminFaqs.Put("/:id", faqHandler.Update)
-	adminFaqs.Delete("/:id", faqHandler.Delete)
+	adminFaqs := api.Group("/admin/faqs")
+	adminFaqs.Get("/", middleware.RequirePermission("faq.view"), faqHandler.AdminList)
+	adminFaqs.Get("/:id", middleware.RequirePermission("faq.view"), faqHandler.GetByID)
+	adminFaqs.Post("/", middleware.RequirePermission("faq.create"), faqHandler.Create)
+	adminFaqs.Put("/:id", middleware.RequirePermission("faq.edit"), faqHandler.Update)
+	adminFaqs.Delete("/:id", middleware.RequirePermission("faq.delete"), faqHandler.Delete)
 
 	testimonials := api.Group("/testimonials")
 	testimonials.Get("/", testimonialHandler.List)
 
-	adminTestimonials := api.Group("/admin/testimonials", middleware.AdminRequired())
-	adminTestimonials.Get("/", testimonialHandler.AdminList)
-	adminTestimonials.Get("/:id", testimonialHandler.GetByID)
-	adminTestimonials.Post("/", testimonialHandler.Create)
-	adminTestimonials.Put("/:id", testimonialHandler.Update)
-	adminTestimonials.Delete("/:id", testimonialHandler.Delete)
+	adminTestimonials := api.Group("/admin/testimonials")
+	adminTestimonials.Get("/", middleware.RequirePermission("testimonials.view"), testimonialHandler.AdminList)
+	adminTestimonials.Get("/:id", middleware.RequirePermission("testimonials.view"), testimonialHandler.GetByID)
+	adminTestimonials.Post("/", middleware.RequirePermission("testimonials.manage"), testimonialHandler.Create)
+	adminTestimonials.Put("/:id", middleware.RequirePermission("testimonials.manage"), testimonialHandler.Update)
+	adminTestimonials.Delete("/:id", middleware.RequirePermission("testimonials.manage"), testimonialHandler.Delete)
 
 	contact := api.Group("/contact")
 	contact.Post("/", contactHandler.Submit)
 
-	adminContact := api.Group("/admin/contact", middleware.AdminRequired())
-	adminContact.Get("/", contactHandler.List)
-	adminContact.Get("/:id", contactHandler.GetByID)
-	adminContact.Post("/:id/reply", contactHandler.Reply)
-	adminContact.Patch("/:id/status", contactHandler.UpdateStatus)
-	adminContact.Delete("/:id", contactHandler.Delete)
-
-	settings := api.Group("/admin/settings", middleware.AdminRequired())
-	settings.Get("/", settingsHandler.GetByCategory)
-	settings.Get("/:key", settingsHandler.GetByKey)
-	settings.Post("/", settingsHandler.Set)
-	settings.Delete("/:key", settingsHandler.Delete)
-	settings.Get("/email/config", settingsHandler.GetEmailSettings)
-	settings.Put("/email/config", settingsHandler.UpdateEmailSettings)
+	adminContact := api.Group("/admin/contact")
+	adminContact.Get("/", middleware.RequirePermission("contact.view"), contactHandler.List)
+	adminContact.Get("/:id", middleware.RequirePermission("contact.view"), contactHandler.GetByID)
+	adminContact.Post("/:id/reply", middleware.RequirePermission("contact.reply"), contactHandler.Reply)
+	adminContact.Patch("/:id/status", middleware.RequirePermission("contact.reply"), contactHandler.UpdateStatus)
+	adminContact.Delete("/:id", middleware.RequirePermission("contact.delete"), contactHandler.Delete)
+
+	settings := api.Group("/admin/settings")
+	settings.Get("/", middleware.RequirePermission("settings.view"), settingsHandler.GetByCategory)
+	settings.Get("/:key", middleware.RequirePermission("settings.view"), settingsHandler.GetByKey)
+	settings.Post("/", middleware.RequirePermission("settings.edit"), settingsHandler.Set)
+	settings.Delete("/:key", middleware.RequirePermission("settings.manage"), settingsHandler.Delete)
+	settings.Get("/email/config", middleware.RequirePermission("settings.view"), settingsHandler.GetEmailSettings)
+	settings.Put("/email/config", middleware.RequirePermission("settings.edit"), settingsHandler.UpdateEmailSettings)
 
 	// Email gateways endpoint
-	api.Get("/admin/email/gateways", middleware.AdminRequired(), settingsHandler.GetEmailSettings)
-	api.Post("/admin/email/gateways/test", middleware.AdminRequired(), settingsHandler.TestEmailGateway)
-
-	analytics := api.Group("/admin/analytics", middleware.AdminRequired())
-	analytics.Get("/dashboard", analyticsHandler.GetEnhancedOverview)
-	analytics.Get("/sales", analyticsHandler.GetSalesStats)
-	analytics.Get("/users", analyticsHandler.GetUserStats)
-	analytics.Get("/reading", analyticsHandler.GetReadingStats)
-	analytics.Get("/revenue", analyticsHandler.GetRevenueReport)
-	analytics.Get("/growth", analyticsHandler.GetGrowthMetrics)
-
-	api.Get("/analytics/reading", middleware.AdminRequired(), analyticsHandler.GetReadingAnalyticsByPeriod)
-
-	reports := api.Group("/admin/reports", middleware.AdminRequired())
-	reports.Get("/data", analyticsHandler.GetReportsData)
-	reports.Post("/generate", analyticsHandler.GenerateReport)
-	reports.Get("/download/:type", analyticsHandler.DownloadReport)
+	api.Get("/admin/email/gateways", middleware.RequirePermission("settings.view"), settingsHandler.GetEmailSettings)
+	api.Post("/admin/email/gateways/test", middleware.RequirePermission("settings.edit"), settingsHandler.TestEmailGateway)
+	// The outbox holds mail for every school, so only platform admins see it
+	api.Get("/admin/email/outbox", middleware.RequirePermission("settings.view"), middleware.RequireRole("platform_admin"), emailHandler.ListOutbox)
+	api.Post("/admin/email/outbox/:id/resend", middleware.RequirePermission("settings.edit"), middleware.RequireRole("platform_admin"), emailHandler.ResendOutbox)
+
+	// Tracing leaked copies of watermarked books
+	api.Post("/admin/watermarks/trace", middleware.RequirePermission("books.trace_leaks"), watermarkHandler.Trace)
+
+	analytics := api.Group("/admin/analytics")
+	analytics.Get("/dashboard", middleware.RequirePermission("analytics.view"), analyticsHandler.GetEnhancedOverview)
+	analytics.Get("/sales", middleware.RequirePermission("analytics.view"), analyticsHandler.GetSalesStats)
+	analytics.Get("/users", middleware.RequirePermission("analytics.view"), analyticsHandler.GetUserStats)
+	analytics.Get("/reading", middleware.RequirePermission("analytics.view"), analyticsHandler.GetReadingStats)
+	analytics.Get("/revenue", middleware.RequirePermission("analytics.view"), analyticsHandler.GetRevenueReport)
+	analytics.Get("/growth", middleware.RequirePermission("analytics.view"), analyticsHandler.GetGrowthMetrics)
+
+	api.Get("/analytics/reading", middleware.RequirePermission("reading.view_analytics"), analyticsHandler.GetReadingAnalyticsByPeriod)
+
+	reports := api.Group("/admin/reports")
+	reports.Get("/data", middleware.RequirePermission("reports.view"), analyticsHandler.GetReportsData)
+	reports.Post("/generate", middleware.RequirePermission("reports.generate"), analyticsHandler.GenerateReport)
+	reports.Get("/download/:type", middleware.RequirePermission("reports.export"), analyticsHandler.DownloadReport)
 
 	notifications := api.Group("/notifications", middleware.AuthRequired())
 	notifications.Get("/", notificationHandler.GetNotifications)
@@ -298,27 +399,28 @@ func SetupRoutes(
 	notifications.Post("/mark-all-read", notificationHandler.MarkAllAsRead)
 	notifications.Delete("/:id", notificationHandler.Delete)
 
-	audit := api.Group("/admin/audit", middleware.AdminRequired())
-	audit.Get("/", auditHandler.GetLogs)
-	audit.Get("/:id", auditHandler.GetLog)
+	audit := api.Group("/admin/audit")
+	audit.Get("/", middleware.RequirePermission("audit_logs.view"), auditHandler.GetLogs)
+	audit.Get("/:id", middleware.RequirePermission("audit_logs.view"), auditHandler.GetLog)
 
 	api.Post("/books/:id/reviews", middleware.AuthRequired(), reviewHandler.CreateReview)
 	api.Get("/books/:id/reviews", reviewHandler.GetBookReviews)
 	api.Get("/reviews/featured", reviewHandler.GetFeatured)
 
-	adminReviews := api.Group("/admin/reviews", middleware.AdminRequired())
-	adminReviews.Get("/", reviewHandler.ListAllReviews)
-	adminReviews.Get("/stats", reviewHandler.GetStats)
-	adminReviews.Patch("/", reviewHandler.UpdateStatus)
-	adminReviews.Patch("/feature", reviewHandler.ToggleFeatured)
-	adminReviews.Delete("/:id", reviewHandler.Delete)
+	adminReviews := api.Group("/admin/reviews")
+	adminReviews.Get("/", middleware.RequirePermission("reviews.view"), reviewHandler.ListAllReviews)
+	adminReviews.Get("/stats", middleware.RequirePermission("reviews.view"), reviewHandler.GetStats)
+	adminReviews.Patch("/", middleware.RequirePermission("reviews.moderate"), reviewHandler.UpdateStatus)
+	adminReviews.Patch("/feature", middleware.RequirePermission("reviews.moderate"), reviewHandler.ToggleFeatured)
+	adminReviews.Delete("/:id", middleware.RequirePermission("reviews.delete"), reviewHandler.Delete)
 
 	api.Get("/about", aboutHandler.Get)
-	api.Put("/admin/about", middleware.AdminRequired(), aboutHandler.Update)
+	api.Put("/admin/about", middleware.RequirePermission("about.edit"), aboutHandler.Update)
 
 	api.Get("/dashboard/stats", middleware.AuthRequired(), libraryHandler.GetDashboardStats)
 	api.Get("/dashboard/reading-progress", middleware.AuthRequired(), readingHandler.GetReadingProgress)
 	api.Get("/dashboard/analytics", middleware.AuthRequired(), libraryHandler.GetUserAnalytics)
+	api.Get("/dashboard/picks", middleware.AuthRequired(), recommendationHandler.GetPicks)
 
 	wishlist := api.Group("/wishlist", middleware.AuthRequired())
 	wishlist.Get("/", wishlistHandler.GetWishlist)
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/models"
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SchoolHandler struct {
+	schoolService *services.SchoolService
+}
+
+func NewSchoolHandler(schoolService *services.SchoolService) *SchoolHandler {
+	return &SchoolHandler{schoolService: schoolService}
+}
+
+func (h *SchoolHandler) ListSchools(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	search := c.Query("search")
+
+	schools, meta, err := h.schoolService.ListSchools(page, limit, search)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"schools": schools,
+		"meta":    meta,
+	})
+}
+
+func (h *SchoolHandler) GetSchool(c *fiber.Ctx) error {
+	schoolID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	school, err := h.schoolService.GetSchoolByID(uint(schoolID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"school": school})
+}
+
+func (h *SchoolHandler) CreateSchool(c *fiber.Ctx) error {
+	var school models.School
+	if err := c.BodyParser(&school); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(&school); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	if err := h.schoolService.CreateSchool(&school); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "create_school", "school", school.ID, "", school.Name)
+	utils.InfoLogger.Printf("School created: %s", school.Name)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"school": school})
+}
+
+func (h *SchoolHandler) UpdateSchool(c *fiber.Ctx) error {
+	schoolID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	var input struct {
+		Name         *string `json:"name"`
+		Category     *string `json:"category"`
+		Address      *string `json:"address"`
+		ContactEmail *string `json:"contact_email"`
+		PhoneNumber  *string `json:"phone_number"`
+		Timezone     *string `json:"timezone" validate:"omitempty,iana_timezone"`
+		IsActive     *bool   `json:"is_active"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	updates := make(map[string]interface{})
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	if input.Category != nil {
+		updates["category"] = *input.Category
+	}
+	if input.Address != nil {
+		updates["address"] = *input.Address
+	}
+	if input.ContactEmail != nil {
+		updates["contact_email"] = *input.ContactEmail
+	}
+	if input.PhoneNumber != nil {
+		updates["phone_number"] = *input.PhoneNumber
+	}
+	if input.Timezone != nil {
+		updates["timezone"] = *input.Timezone
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+
+	school, err := h.schoolService.UpdateSchool(uint(schoolID), updates)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "update_school", "school", school.ID, "", "")
+	return c.JSON(fiber.Map{"school": school})
+}
+
+func (h *SchoolHandler) DeleteSchool(c *fiber.Ctx) error {
+	schoolID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	if err := h.schoolService.DeleteSchool(uint(schoolID)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "delete_school", "school", uint(schoolID), "", "")
+	return c.JSON(fiber.Map{"message": "School deleted successfully"})
+}
+
+func (h *SchoolHandler) AssignUsers(c *fiber.Ctx) error {
+	schoolID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	var input struct {
+		UserIDs []uint `json:"user_ids" validate:"required,min=1"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	if err := h.schoolService.AssignUsers(uint(schoolID), input.UserIDs); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "assign_school_users", "school", uint(schoolID), "", fmt.Sprintf("%v", input.UserIDs))
+	utils.InfoLogger.Printf("Assigned %d users to school %d", len(input.UserIDs), schoolID)
+	return c.JSON(fiber.Map{
+		"message": "Users assigned to school successfully",
+		"count":   len(input.UserIDs),
+	})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
 
 	"readagain/internal/services"
@@ -8,17 +10,18 @@ import (
 )
 
 type SettingsHandler struct {
-	service *services.SettingsService
+	service      *services.SettingsService
+	emailService *services.EmailService
 }
 
-func NewSettingsHandler(service *services.SettingsService) *SettingsHandler {
-	return &SettingsHandler{service: service}
+func NewSettingsHandler(service *services.SettingsService, emailService *services.EmailService) *SettingsHandler {
+	return &SettingsHandler{service: service, emailService: emailService}
 }
 
 func (h *SettingsHandler) TestEmailGateway(c *fiber.Ctx) error {
 	var req struct {
-		GatewayType string `json:"gateway_type"`
-		TestEmail   string `json:"test_email"`
+		GatewayType string                 `json:"gateway_type" validate:"omitempty,oneof=resend smtp file"`
+		TestEmail   string                 `json:"test_email" validate:"required,email"`
 		Config      map[string]interface{} `json:"config"`
 	}
 
@@ -26,12 +29,30 @@ func (h *SettingsHandler) TestEmailGateway(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Simple test response - in a real implementation, you'd test the actual email sending
+	if err := utils.Validate.Struct(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	overrides := make(map[string]string, len(req.Config))
+	for key, value := range req.Config {
+		if value != nil {
+			overrides[key] = fmt.Sprint(value)
+		}
+	}
+
+	if err := h.emailService.SendTestEmail(req.GatewayType, overrides, req.TestEmail); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success":      false,
+			"error":        err.Error(),
+			"gateway_type": req.GatewayType,
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Email gateway test completed successfully",
+		"success":      true,
+		"message":      "Test email sent successfully",
 		"gateway_type": req.GatewayType,
-		"test_email": req.TestEmail,
+		"test_email":   req.TestEmail,
 	})
 }
 
@@ -108,9 +129,16 @@ func (h *SettingsHandler) GetEmailSettings(c *fiber.Ctx) error {
 
 func (h *SettingsHandler) UpdateEmailSettings(c *fiber.Ctx) error {
 	var req struct {
-		ResendAPIKey string `json:"resend_api_key" validate:"required"`
-		FromEmail    string `json:"from_email" validate:"required,email"`
-		FromName     string `json:"from_name" validate:"required"`
+		Driver         string `json:"driver" validate:"omitempty,oneof=resend smtp file"`
+		ResendAPIKey   string `json:"resend_api_key"`
+		FromEmail      string `json:"from_email" validate:"required,email"`
+		FromName       string `json:"from_name" validate:"required"`
+		SMTPHost       string `json:"smtp_host"`
+		SMTPPort       string `json:"smtp_port" validate:"omitempty,numeric"`
+		SMTPUsername   string `json:"smtp_username"`
+		SMTPPassword   string `json:"smtp_password"`
+		SMTPEncryption string `json:"smtp_encryption" validate:"omitempty,oneof=starttls tls none"`
+		FileDir        string `json:"file_dir"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -121,16 +149,40 @@ func (h *SettingsHandler) UpdateEmailSettings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	if err := h.service.UpdateEmailSettings(req.ResendAPIKey, req.FromEmail, req.FromName); err != nil {
+	if req.Driver == "" {
+		req.Driver = services.EmailDriverResend
+	}
+
+	existing, _ := h.service.GetEmailSettings()
+	switch {
+	case req.Driver == services.EmailDriverResend && req.ResendAPIKey == "" && existing["email.resend_api_key"] == "":
+		return c.Status(400).JSON(fiber.Map{"error": "resend_api_key is required for the resend driver"})
+	case req.Driver == services.EmailDriverSMTP && req.SMTPHost == "" && existing["email.smtp_host"] == "":
+		return c.Status(400).JSON(fiber.Map{"error": "smtp_host is required for the smtp driver"})
+	}
+
+	values := map[string]string{
+		"email.driver":          req.Driver,
+		"email.resend_api_key":  req.ResendAPIKey,
+		"email.from_email":      req.FromEmail,
+		"email.from_name":       req.FromName,
+		"email.smtp_host":       req.SMTPHost,
+		"email.smtp_port":       req.SMTPPort,
+		"email.smtp_username":   req.SMTPUsername,
+		"email.smtp_password":   req.SMTPPassword,
+		"email.smtp_encryption": req.SMTPEncryption,
+		"email.file_dir":        req.FileDir,
+	}
+
+	if err := h.service.UpdateEmailSettings(values); err != nil {
 		utils.ErrorLogger.Printf("Failed to update email settings: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update email settings"})
 	}
 
-	utils.InfoLogger.Println("Email settings updated")
+	utils.InfoLogger.Printf("Email settings updated (driver: %s)", req.Driver)
 	return c.JSON(fiber.Map{"message": "Email settings updated successfully"})
 }
 
-
 func (h *SettingsHandler) GetPublic(c *fiber.Ctx) error {
 	settings, err := h.service.GetByCategory("general")
 	if err != nil {
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/models"
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type StreakHandler struct {
+	streakService *services.StreakService
+}
+
+func NewStreakHandler(streakService *services.StreakService) *StreakHandler {
+	return &StreakHandler{streakService: streakService}
+}
+
+func (h *StreakHandler) GetStreak(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	streak, err := h.streakService.GetStreak(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"streak": streak})
+}
+
+func (h *StreakHandler) ListFreezes(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+
+	query, err := services.StreakFreezeSpec.Parse(c.Queries())
+	if err != nil {
+		return err
+	}
+
+	freezes, meta, err := h.streakService.ListFreezes(page, limit, query, middleware.SchoolScope(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"freezes": freezes, "pagination": meta})
+}
+
+// CreateFreeze freezes a day for everyone (no user or school), a school or
+// one reader, e.g. public holidays or a sick day.
+func (h *StreakHandler) CreateFreeze(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var input struct {
+		Date     string `json:"date" validate:"required,datetime=2006-01-02"`
+		UserID   *uint  `json:"user_id"`
+		SchoolID *uint  `json:"school_id"`
+		Reason   string `json:"reason" validate:"max=255"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	freeze := models.StreakFreeze{
+		Date:      input.Date,
+		UserID:    input.UserID,
+		SchoolID:  input.SchoolID,
+		Reason:    input.Reason,
+		CreatedBy: userID,
+	}
+	if err := h.streakService.CreateFreeze(&freeze, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "create_streak_freeze", "streak_freeze", freeze.ID, "", freeze.Date)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"freeze": freeze})
+}
+
+func (h *StreakHandler) DeleteFreeze(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid streak freeze ID"})
+	}
+
+	if err := h.streakService.DeleteFreeze(uint(id), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "delete_streak_freeze", "streak_freeze", uint(id), "", "")
+	return c.JSON(fiber.Map{"message": "Streak freeze deleted successfully"})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+	"readagain/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TeacherHandler struct {
+	teacherService *services.TeacherService
+}
+
+func NewTeacherHandler(teacherService *services.TeacherService) *TeacherHandler {
+	return &TeacherHandler{teacherService: teacherService}
+}
+
+// classScope confines teachers to the groups they run and everyone else to
+// their school's.
+func classScope(c *fiber.Ctx) services.ClassScope {
+	return services.ClassScope{SchoolID: middleware.SchoolScope(c), TeacherID: middleware.TeacherScope(c)}
+}
+
+func (h *TeacherHandler) ListClasses(c *fiber.Ctx) error {
+	classes, err := h.teacherService.ListClasses(classScope(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"classes": classes})
+}
+
+func (h *TeacherHandler) GetClassDashboard(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid class ID"})
+	}
+
+	dashboard, err := h.teacherService.GetClassDashboard(uint(id), classScope(c), c.Query("period", "month"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"dashboard": dashboard})
+}
+
+// SetStudentReadingLevel records the student's assessed reading level;
+// sending no grade or score clears it.
+func (h *TeacherHandler) SetStudentReadingLevel(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student ID"})
+	}
+
+	var input services.ReadingLevelInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
+	student, err := h.teacherService.SetStudentReadingLevel(uint(id), userID, classScope(c), input)
+	if err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "set_reading_level", "user", student.ID, "", fmt.Sprintf("grade=%s score=%s", formatOptionalInt(input.Grade), formatOptionalInt(input.Score)))
+	return c.JSON(fiber.Map{"user": student})
+}
+
+func formatOptionalInt(value *int) string {
+	if value == nil {
+		return "none"
+	}
+	return strconv.Itoa(*value)
+}
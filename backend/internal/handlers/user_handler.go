@@ -12,11 +12,12 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService    *services.UserService
+	userSessionService *services.UserSessionService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, userSessionService *services.UserSessionService) *UserHandler {
+	return &UserHandler{userService: userService, userSessionService: userSessionService}
 }
 
 func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
@@ -40,12 +41,17 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 		PhoneNumber *string `json:"phone_number"`
 		Bio         *string `json:"bio"`
 		AvatarURL   *string `json:"avatar_url"`
+		Timezone    *string `json:"timezone" validate:"omitempty,iana_timezone"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
+	if err := utils.Validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
+	}
+
 	updates := make(map[string]interface{})
 	if input.FirstName != nil {
 		updates["first_name"] = *input.FirstName
@@ -62,8 +68,11 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 	if input.AvatarURL != nil {
 		updates["avatar_url"] = *input.AvatarURL
 	}
+	if input.Timezone != nil {
+		updates["timezone"] = *input.Timezone
+	}
 
-	user, err := h.userService.UpdateUser(userID, updates)
+	user, err := h.userService.UpdateUser(userID, updates, nil)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to update user profile: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update profile"})
@@ -103,10 +112,25 @@ func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	search := c.Query("search", "")
 
-	users, meta, err := h.userService.ListUsers(page, limit, search)
+	schoolID := middleware.SchoolScope(c)
+	if schoolID == nil && c.Query("school_id") != "" {
+		id, err := strconv.ParseUint(c.Query("school_id"), 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+		}
+		filterID := uint(id)
+		schoolID = &filterID
+	}
+
+	query, err := services.UserListSpec.Parse(c.Queries())
+	if err != nil {
+		return err
+	}
+
+	users, meta, err := h.userService.ListUsers(page, limit, search, c.Query("role"), c.Query("status"), schoolID, query)
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to list users: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve users"})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -121,6 +145,10 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	user, err := h.userService.GetUserByID(uint(userID))
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
@@ -135,15 +163,24 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	var input map[string]interface{}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	user, err := h.userService.UpdateUser(uint(userID), input)
+	// Only platform admins may move users between schools
+	if middleware.SchoolScope(c) != nil {
+		delete(input, "school_id")
+	}
+
+	user, err := h.userService.UpdateUser(uint(userID), input, middleware.SchoolScope(c))
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to update user %d: %v", userID, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update user"})
+		return err
 	}
 
 	utils.InfoLogger.Printf("Admin updated user %d", userID)
@@ -156,6 +193,10 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	if err := h.userService.DeleteUser(uint(userID)); err != nil {
 		utils.ErrorLogger.Printf("Failed to delete user %d: %v", userID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete user"})
@@ -172,6 +213,10 @@ func (h *UserHandler) AssignRole(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	var input struct {
 		RoleID uint `json:"role_id" validate:"required"`
 	}
@@ -184,9 +229,9 @@ func (h *UserHandler) AssignRole(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	if err := h.userService.AssignRole(uint(userID), input.RoleID); err != nil {
+	if err := h.userService.AssignRole(uint(userID), input.RoleID, middleware.SchoolScope(c)); err != nil {
 		utils.ErrorLogger.Printf("Failed to assign role to user %d: %v", userID, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to assign role"})
+		return err
 	}
 
 	utils.InfoLogger.Printf("Admin assigned role %d to user %d", input.RoleID, userID)
@@ -199,6 +244,10 @@ func (h *UserHandler) RemoveRole(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	var input struct {
 		RoleID uint `json:"role_id" validate:"required"`
 	}
@@ -229,6 +278,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		LastName  string `json:"last_name"`
 		RoleID    uint   `json:"role_id"`
 		IsActive  *bool  `json:"is_active"`
+		SchoolID  *uint  `json:"school_id"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -239,29 +289,31 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
-	roleID := input.RoleID
-	if roleID == 0 {
-		roleID = 3
-	}
-
 	isActive := true
 	if input.IsActive != nil {
 		isActive = *input.IsActive
 	}
 
+	schoolID := input.SchoolID
+	if scope := middleware.SchoolScope(c); scope != nil {
+		schoolID = scope
+	}
+
 	user, err := h.userService.CreateUserByAdmin(
 		input.Email,
 		input.Username,
 		input.Password,
 		input.FirstName,
 		input.LastName,
-		roleID,
+		input.RoleID,
 		isActive,
+		schoolID,
+		middleware.SchoolScope(c),
 	)
 
 	if err != nil {
 		utils.ErrorLogger.Printf("Failed to create user: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	utils.InfoLogger.Printf("Admin created user: %s", input.Email)
@@ -274,6 +326,10 @@ func (h *UserHandler) ToggleStatus(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	var input struct {
 		IsActive bool `json:"is_active"`
 	}
@@ -298,6 +354,10 @@ func (h *UserHandler) AdminResetPassword(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	var input struct {
 		NewPassword string `json:"new_password" validate:"required,min=8"`
 	}
@@ -319,6 +379,65 @@ func (h *UserHandler) AdminResetPassword(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Password reset successfully"})
 }
 
+func (h *UserHandler) ListUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
+	sessions, err := h.userSessionService.ListActiveSessions(uint(userID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"sessions": sessions})
+}
+
+func (h *UserHandler) RevokeUserSession(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	sessionID, err := strconv.ParseUint(c.Params("sessionId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
+	if err := h.userSessionService.RevokeSession(uint(userID), uint(sessionID)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "revoke_user_session", "user", uint(userID), "", fmt.Sprintf("%d", sessionID))
+	return c.JSON(fiber.Map{"message": "Session revoked successfully"})
+}
+
+func (h *UserHandler) RevokeAllUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	if err := h.userService.EnsureUserInSchool(uint(userID), middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
+	if err := h.userSessionService.RevokeAllSessions(uint(userID)); err != nil {
+		return err
+	}
+
+	middleware.LogAudit(c, "revoke_user_sessions", "user", uint(userID), "", "")
+	return c.JSON(fiber.Map{"message": "All sessions revoked successfully"})
+}
+
 func (h *UserHandler) BulkActivate(c *fiber.Ctx) error {
 	var input struct {
 		UserIDs []uint `json:"user_ids" validate:"required,min=1"`
@@ -332,6 +451,10 @@ func (h *UserHandler) BulkActivate(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
+	if err := h.userService.EnsureUsersInSchool(input.UserIDs, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	if err := h.userService.BulkActivate(input.UserIDs); err != nil {
 		utils.ErrorLogger.Printf("Failed to bulk activate users: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -354,6 +477,10 @@ func (h *UserHandler) BulkDeactivate(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
+	if err := h.userService.EnsureUsersInSchool(input.UserIDs, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	if err := h.userService.BulkDeactivate(input.UserIDs); err != nil {
 		utils.ErrorLogger.Printf("Failed to bulk deactivate users: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -376,6 +503,10 @@ func (h *UserHandler) BulkDelete(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": utils.FormatValidationError(err)})
 	}
 
+	if err := h.userService.EnsureUsersInSchool(input.UserIDs, middleware.SchoolScope(c)); err != nil {
+		return err
+	}
+
 	if err := h.userService.BulkDelete(input.UserIDs); err != nil {
 		utils.ErrorLogger.Printf("Failed to bulk delete users: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
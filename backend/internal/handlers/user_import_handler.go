@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserImportHandler struct {
+	importService *services.UserImportService
+}
+
+func NewUserImportHandler(importService *services.UserImportService) *UserImportHandler {
+	return &UserImportHandler{importService: importService}
+}
+
+// Import takes a multipart "file" (.csv or .xlsx). It is a dry run unless
+// dry_run=false, which starts a background job to poll with GetJob.
+// Students imported without a password are emailed a link to set one;
+// send_credentials=true sends one to every new student.
+func (h *UserImportHandler) Import(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "File is required"})
+	}
+
+	dryRun, err := strconv.ParseBool(c.FormValue("dry_run", "true"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid dry_run value"})
+	}
+	sendCredentials, _ := strconv.ParseBool(c.FormValue("send_credentials", "false"))
+
+	schoolID := middleware.SchoolScope(c)
+	if schoolID == nil && c.FormValue("school_id") != "" {
+		id, err := strconv.ParseUint(c.FormValue("school_id"), 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid school ID"})
+		}
+		sid := uint(id)
+		schoolID = &sid
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read file"})
+	}
+	defer file.Close()
+
+	result, err := h.importService.Import(fileHeader.Filename, file, services.UserImportOptions{
+		SchoolID:        schoolID,
+		ImportedBy:      c.Locals("userID").(uint),
+		DryRun:          dryRun,
+		SendCredentials: sendCredentials,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !dryRun && result.Invalid > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":  fmt.Sprintf("%d of %d rows are invalid, nothing was imported", result.Invalid, result.Total),
+			"report": result,
+		})
+	}
+
+	if !dryRun {
+		middleware.LogAudit(c, "import_users", "user_import_job", result.JobID, "", fmt.Sprintf("%d users from %s", result.Valid, fileHeader.Filename))
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"report": result, "job_id": result.JobID})
+	}
+
+	return c.JSON(fiber.Map{"report": result})
+}
+
+// GetJob reports how a background import is going, with the report of
+// created accounts once it is done.
+func (h *UserImportHandler) GetJob(c *fiber.Ctx) error {
+	jobID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid import job ID"})
+	}
+
+	job, err := h.importService.GetJob(uint(jobID), middleware.SchoolScope(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(job)
+}
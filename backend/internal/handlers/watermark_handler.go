@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+
+	"readagain/internal/middleware"
+	"readagain/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WatermarkHandler struct {
+	watermarkService *services.WatermarkService
+}
+
+func NewWatermarkHandler(watermarkService *services.WatermarkService) *WatermarkHandler {
+	return &WatermarkHandler{watermarkService: watermarkService}
+}
+
+// Trace takes a leaked copy as the multipart "file", a code read off its
+// pages as "code", or both, and returns who the copy was issued to. Files
+// larger than the request limit can be traced with scripts/trace_watermark.go.
+func (h *WatermarkHandler) Trace(c *fiber.Ctx) error {
+	code := c.FormValue("code")
+
+	var data []byte
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read file"})
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read file"})
+		}
+	}
+
+	if data == nil && code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A file or a code is required"})
+	}
+
+	trace, err := h.watermarkService.Trace(data, code)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range trace.Matches {
+		middleware.LogAudit(c, "trace_watermark", "book_watermark", match.ID, "", fmt.Sprintf("code %s: user %d, book %d", match.Code, match.UserID, match.BookID))
+	}
+
+	return c.JSON(fiber.Map{"data": trace})
+}
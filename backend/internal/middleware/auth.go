@@ -8,80 +8,130 @@ import (
 	"readagain/internal/config"
 	"readagain/internal/database"
 	"readagain/internal/models"
+	"readagain/internal/services"
 	"readagain/internal/utils"
 )
 
+// cache backs permission lookups and token revocation checks; when it is not
+// configured the guards fall back to querying Postgres directly.
+var cache *services.CacheService
+
+func UseCache(cacheService *services.CacheService) {
+	cache = cacheService
+}
+
 func AuthRequired() fiber.Handler {
 	cfg := config.Load()
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return utils.NewUnauthorizedError("Missing authorization header")
+		if err := authenticate(c, cfg); err != nil {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			return utils.NewUnauthorizedError("Invalid authorization format")
-		}
+// authenticate validates the bearer token and stores the claims in locals
+// without advancing the handler chain, so other guards can build on it.
+func authenticate(c *fiber.Ctx, cfg *config.Config) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return utils.NewUnauthorizedError("Missing authorization header")
+	}
 
-		var blacklisted models.TokenBlacklist
-		if err := database.DB.Where("token = ?", tokenString).First(&blacklisted).Error; err == nil {
-			return utils.NewUnauthorizedError("Token has been revoked")
-		}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return utils.NewUnauthorizedError("Invalid authorization format")
+	}
 
-		claims, err := utils.ValidateAccessToken(tokenString, cfg.JWT.Secret)
-		if err != nil {
-			return utils.NewUnauthorizedError("Invalid or expired token")
-		}
+	if isTokenRevoked(tokenString) {
+		return utils.NewUnauthorizedError("Token has been revoked")
+	}
 
-		c.Locals("userID", claims.UserID)
-		c.Locals("email", claims.Email)
-		c.Locals("roleID", claims.RoleID)
-		c.Locals("token", tokenString)
+	claims, err := utils.ValidateAccessToken(tokenString, cfg.JWT.Secret)
+	if err != nil {
+		return utils.NewUnauthorizedError("Invalid or expired token")
+	}
 
-		return c.Next()
+	if claims.SessionID != "" && isSessionRevoked(claims.SessionID) {
+		return utils.NewUnauthorizedError("Session has been revoked")
 	}
+
+	c.Locals("userID", claims.UserID)
+	c.Locals("email", claims.Email)
+	c.Locals("roleID", claims.RoleID)
+	c.Locals("sessionID", claims.SessionID)
+	c.Locals("token", tokenString)
+
+	return nil
 }
 
-func AdminRequired() fiber.Handler {
-	cfg := config.Load()
-	return func(c *fiber.Ctx) error {
-		// Do auth check inline instead of calling AuthRequired()
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return utils.NewUnauthorizedError("Missing authorization header")
+func isTokenRevoked(token string) bool {
+	if cache != nil {
+		revoked, err := cache.IsTokenRevoked(token)
+		if err == nil {
+			return revoked
 		}
+		utils.ErrorLogger.Printf("Revocation cache lookup failed, using database: %v", err)
+	}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			return utils.NewUnauthorizedError("Invalid authorization format")
-		}
+	var blacklisted models.TokenBlacklist
+	return database.DB.Where("token = ?", token).First(&blacklisted).Error == nil
+}
 
-		var blacklisted models.TokenBlacklist
-		if err := database.DB.Where("token = ?", tokenString).First(&blacklisted).Error; err == nil {
-			return utils.NewUnauthorizedError("Token has been revoked")
+func isSessionRevoked(familyID string) bool {
+	if cache != nil {
+		revoked, err := cache.Exists(utils.RevokedSessionKey(familyID))
+		if err == nil {
+			return revoked
 		}
+		utils.ErrorLogger.Printf("Session cache lookup failed, using database: %v", err)
+	}
 
-		claims, err := utils.ValidateAccessToken(tokenString, cfg.JWT.Secret)
-		if err != nil {
-			return utils.NewUnauthorizedError("Invalid or expired token")
-		}
+	var count int64
+	database.DB.Model(&models.UserSession{}).Where("family_id = ? AND revoked_at IS NOT NULL", familyID).Count(&count)
+	return count > 0
+}
 
-		c.Locals("userID", claims.UserID)
-		c.Locals("email", claims.Email)
-		c.Locals("roleID", claims.RoleID)
-		c.Locals("token", tokenString)
+// applySchoolScope records the caller's role and, for anyone other than a
+// platform admin, the school their admin actions are confined to. Accounts
+// in other roles without a school, such as custom platform-wide roles, act
+// across schools with whatever permissions they were granted.
+func applySchoolScope(c *fiber.Ctx, perms *services.UserPermissions) error {
+	c.Locals("roleName", perms.RoleName)
 
-		// Check admin role by name
-		var role models.Role
-		if err := database.DB.First(&role, claims.RoleID).Error; err != nil {
-			return utils.NewForbiddenError("Invalid role")
-		}
+	if perms.RoleName == "platform_admin" {
+		return nil
+	}
 
-		if role.Name != "platform_admin" && role.Name != "school_admin" {
-			return utils.NewForbiddenError("Admin access required")
+	if perms.SchoolID == nil {
+		if services.SchoolRoles[perms.RoleName] {
+			// Staff accounts can't be created without a school; one that
+			// lost it must not fall back to seeing every school
+			return utils.NewForbiddenError("Your account is not assigned to a school")
 		}
+		return nil
+	}
+	c.Locals("schoolID", perms.SchoolID)
 
-		return c.Next()
+	return nil
+}
+
+// SchoolScope returns the school the current admin is restricted to, or nil
+// for platform admins who can see every school.
+func SchoolScope(c *fiber.Ctx) *uint {
+	schoolID, _ := c.Locals("schoolID").(*uint)
+	return schoolID
+}
+
+// TeacherScope returns the current user's ID when they are a teacher, whose
+// access is confined to the groups they run, or nil for everyone else.
+func TeacherScope(c *fiber.Ctx) *uint {
+	if role, _ := c.Locals("roleName").(string); role != "teacher" {
+		return nil
+	}
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return nil
 	}
+	return &userID
 }
@@ -1,68 +1,156 @@
 package middleware
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 
+	"readagain/internal/config"
 	"readagain/internal/database"
 	"readagain/internal/models"
+	"readagain/internal/services"
 	"readagain/internal/utils"
 )
 
-func RequirePermission(permission string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		if err := AuthRequired()(c); err != nil {
-			return err
+// routePermissions collects every permission name referenced by a route guard
+// so VerifyRoutePermissions can check them against the database at startup.
+var (
+	routePermissionsMu sync.Mutex
+	routePermissions   = make(map[string]struct{})
+)
+
+func registerRoutePermissions(permissions ...string) {
+	routePermissionsMu.Lock()
+	defer routePermissionsMu.Unlock()
+	for _, perm := range permissions {
+		routePermissions[perm] = struct{}{}
+	}
+}
+
+// VerifyRoutePermissions returns an error naming every permission used by a
+// route that has not been seeded into the permissions table.
+func VerifyRoutePermissions(db *gorm.DB) error {
+	routePermissionsMu.Lock()
+	names := make([]string, 0, len(routePermissions))
+	for name := range routePermissions {
+		names = append(names, name)
+	}
+	routePermissionsMu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	var existing []string
+	if err := db.Model(&models.Permission{}).Where("name IN ?", names).Pluck("name", &existing).Error; err != nil {
+		return fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
 		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("route permissions missing from permissions table: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// loadUserPermissions authenticates the request and returns the caller's
+// role and permission names, served from cache when one is configured.
+func loadUserPermissions(c *fiber.Ctx, cfg *config.Config) (*services.UserPermissions, error) {
+	if err := authenticate(c, cfg); err != nil {
+		return nil, err
+	}
 
-		userID := c.Locals("userID").(uint)
+	userID := c.Locals("userID").(uint)
+	key := utils.UserPermissionsKey(userID)
 
-		var user models.User
-		if err := database.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
-			return utils.NewUnauthorizedError("User not found")
+	if cache != nil {
+		var cached services.UserPermissions
+		if err := cache.Get(key, &cached); err == nil {
+			return &cached, nil
 		}
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+		return nil, utils.NewUnauthorizedError("User not found")
+	}
+
+	if user.Role == nil {
+		return nil, utils.NewForbiddenError("No role assigned")
+	}
+
+	perms := &services.UserPermissions{
+		RoleID:      user.RoleID,
+		RoleName:    user.Role.Name,
+		SchoolID:    user.SchoolID,
+		Permissions: make([]string, 0, len(user.Role.Permissions)),
+	}
+	for _, rp := range user.Role.Permissions {
+		perms.Permissions = append(perms.Permissions, rp.Name)
+	}
 
-		if user.Role == nil {
-			return utils.NewForbiddenError("No role assigned")
+	if cache != nil {
+		if err := cache.Set(key, perms, utils.CacheTTLMedium); err != nil {
+			utils.ErrorLogger.Printf("Failed to cache permissions for user %d: %v", userID, err)
 		}
+	}
 
-		hasPermission := false
-		for _, rp := range user.Role.Permissions {
-			if rp.Name == permission {
-				hasPermission = true
-				break
-			}
+	return perms, nil
+}
+
+func RequirePermission(permission string) fiber.Handler {
+	registerRoutePermissions(permission)
+	cfg := config.Load()
+	return func(c *fiber.Ctx) error {
+		perms, err := loadUserPermissions(c, cfg)
+		if err != nil {
+			return err
 		}
 
-		if !hasPermission {
+		if !perms.Has(permission) {
 			return utils.NewForbiddenError("Insufficient permissions")
 		}
 
+		if err := applySchoolScope(c, perms); err != nil {
+			return err
+		}
+
 		return c.Next()
 	}
 }
 
 func RequireAnyPermission(permissions ...string) fiber.Handler {
+	registerRoutePermissions(permissions...)
+	cfg := config.Load()
 	return func(c *fiber.Ctx) error {
-		if err := AuthRequired()(c); err != nil {
+		perms, err := loadUserPermissions(c, cfg)
+		if err != nil {
 			return err
 		}
 
-		userID := c.Locals("userID").(uint)
-
-		var user models.User
-		if err := database.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
-			return utils.NewUnauthorizedError("User not found")
-		}
-
-		if user.Role == nil {
-			return utils.NewForbiddenError("No role assigned")
-		}
-
-		for _, rp := range user.Role.Permissions {
-			for _, perm := range permissions {
-				if rp.Name == perm {
-					return c.Next()
+		for _, perm := range permissions {
+			if perms.Has(perm) {
+				if err := applySchoolScope(c, perms); err != nil {
+					return err
 				}
+				return c.Next()
 			}
 		}
 
@@ -71,54 +159,42 @@ func RequireAnyPermission(permissions ...string) fiber.Handler {
 }
 
 func RequireAllPermissions(permissions ...string) fiber.Handler {
+	registerRoutePermissions(permissions...)
+	cfg := config.Load()
 	return func(c *fiber.Ctx) error {
-		if err := AuthRequired()(c); err != nil {
+		perms, err := loadUserPermissions(c, cfg)
+		if err != nil {
 			return err
 		}
 
-		userID := c.Locals("userID").(uint)
-
-		var user models.User
-		if err := database.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
-			return utils.NewUnauthorizedError("User not found")
-		}
-
-		if user.Role == nil {
-			return utils.NewForbiddenError("No role assigned")
-		}
-
-		userPermissions := make(map[string]bool)
-		for _, rp := range user.Role.Permissions {
-			userPermissions[rp.Name] = true
-		}
-
 		for _, perm := range permissions {
-			if !userPermissions[perm] {
+			if !perms.Has(perm) {
 				return utils.NewForbiddenError("Insufficient permissions")
 			}
 		}
 
+		if err := applySchoolScope(c, perms); err != nil {
+			return err
+		}
+
 		return c.Next()
 	}
 }
 
 func RequireRole(roleName string) fiber.Handler {
+	cfg := config.Load()
 	return func(c *fiber.Ctx) error {
-		if err := AuthRequired()(c); err != nil {
+		perms, err := loadUserPermissions(c, cfg)
+		if err != nil {
 			return err
 		}
 
-		userID := c.Locals("userID").(uint)
-
-		var user models.User
-		if err := database.DB.Preload("Role").First(&user, userID).Error; err != nil {
-			return utils.NewUnauthorizedError("User not found")
-		}
-
-		if user.Role == nil || user.Role.Name != roleName {
+		if perms.RoleName != roleName {
 			return utils.NewForbiddenError("Role required: " + roleName)
 		}
 
+		c.Locals("roleName", perms.RoleName)
+
 		return c.Next()
 	}
 }
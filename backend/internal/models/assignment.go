@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ReadingAssignment is a book set for a group or for individual students to
+// read between StartsAt and DueAt. The required part of the book runs from
+// StartProgression to EndProgression, fractions of the whole book resolved
+// from the chapter or page range the teacher gave (the whole book if none).
+type ReadingAssignment struct {
+	BaseModel
+	BookID            uint                       `gorm:"not null;index" json:"book_id"`
+	Book              *Book                      `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	GroupID           *uint                      `gorm:"index" json:"group_id"`
+	Group             *Group                     `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+	SchoolID          *uint                      `gorm:"index" json:"school_id"`
+	AssignedBy        uint                       `gorm:"not null;index" json:"assigned_by"`
+	Assigner          *User                      `gorm:"foreignKey:AssignedBy" json:"assigner,omitempty"`
+	Title             string                     `gorm:"size:255" json:"title"`
+	Instructions      string                     `gorm:"type:text" json:"instructions"`
+	StartChapterID    *uint                      `json:"start_chapter_id"`
+	EndChapterID      *uint                      `json:"end_chapter_id"`
+	StartPage         int                        `json:"start_page"`
+	EndPage           int                        `json:"end_page"`
+	StartProgression  float64                    `gorm:"not null;default:0" json:"start_progression"`
+	EndProgression    float64                    `gorm:"not null;default:1" json:"end_progression"`
+	StartsAt          time.Time                  `gorm:"not null" json:"starts_at"`
+	DueAt             time.Time                  `gorm:"not null;index" json:"due_at"`
+	OverdueNotifiedAt *time.Time                 `json:"-"` // when the teacher was told the due date passed
+	Students          []ReadingAssignmentStudent `gorm:"foreignKey:AssignmentID" json:"students,omitempty"`
+}
+
+// ReadingAssignmentStudent is one student's standing on an assignment.
+// Progress (0-100) is the share of the required range read since the
+// assignment started; Status is not_started, in_progress, completed or late.
+type ReadingAssignmentStudent struct {
+	BaseModel
+	AssignmentID   uint               `gorm:"not null;uniqueIndex:idx_assignment_students_user" json:"assignment_id"`
+	Assignment     *ReadingAssignment `gorm:"foreignKey:AssignmentID" json:"assignment,omitempty"`
+	UserID         uint               `gorm:"not null;uniqueIndex:idx_assignment_students_user;index" json:"user_id"`
+	User           *User              `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Status         string             `gorm:"size:20;not null;default:'not_started';index" json:"status"`
+	Progress       float64            `gorm:"default:0" json:"progress"`
+	TimeSpent      int                `gorm:"default:0" json:"time_spent"` // seconds
+	StartedAt      *time.Time         `json:"started_at"`
+	CompletedAt    *time.Time         `json:"completed_at"`
+	LastRemindedAt *time.Time         `json:"last_reminded_at"`
+}
@@ -42,6 +42,51 @@ type Book struct {
 	SEOTitle         string     `json:"seo_title"`
 	SEODescription   string     `gorm:"type:text" json:"seo_description"`
 	SEOKeywords      string     `json:"seo_keywords"`
+
+	ReadingLevel ReadingLevel `gorm:"embedded;embeddedPrefix:reading_" json:"reading_level"`
+
+	// Maintained by BookService via raw SQL; never read or written through GORM
+	SearchVector string `gorm:"type:tsvector;index:idx_books_search_vector,type:gin;->:false;<-:false" json:"-"`
+}
+
+// ReadingLevel is how hard a book is to read: the school grades it suits,
+// from 0 (kindergarten) to 12, plus an optional score on a finer scale such
+// as Lexile. Books without a grade band have no level.
+type ReadingLevel struct {
+	GradeMin *int `gorm:"index" json:"grade_min" validate:"omitempty,min=0,max=12"`
+	GradeMax *int `gorm:"index" json:"grade_max" validate:"omitempty,min=0,max=12"`
+	Score    *int `json:"score" validate:"omitempty,min=0,max=2000"`
+}
+
+// BookChapter is one entry of a book's table of contents, in reading order
+// (Position). StartProgression and EndProgression bound the chapter as
+// fractions of the whole book, the unit reading positions are measured in;
+// StartPage and EndPage are the same range in pages. Nested entries lie
+// inside their parent's range.
+type BookChapter struct {
+	BaseModel
+	BookID           uint    `gorm:"not null;uniqueIndex:idx_book_chapters_position" json:"book_id"`
+	Position         int     `gorm:"not null;uniqueIndex:idx_book_chapters_position" json:"position"`
+	Title            string  `gorm:"size:500;not null" json:"title"`
+	Level            int     `gorm:"not null;default:1" json:"level"`
+	Href             string  `gorm:"size:500" json:"href,omitempty"`
+	SpineIndex       *int    `json:"spine_index,omitempty"`
+	StartPage        int     `json:"start_page"`
+	EndPage          int     `json:"end_page"`
+	StartProgression float64 `json:"start_progression"`
+	EndProgression   float64 `json:"end_progression"`
+}
+
+// BookSimilarity is one of a book's nearest neighbours by the readers they
+// share, rebuilt periodically from reading activity. Rank 1 is the closest.
+type BookSimilarity struct {
+	BaseModel
+	BookID        uint    `gorm:"not null;uniqueIndex:idx_book_similarities_pair;index:idx_book_similarities_rank,priority:1" json:"book_id"`
+	SimilarBookID uint    `gorm:"not null;uniqueIndex:idx_book_similarities_pair" json:"similar_book_id"`
+	SimilarBook   *Book   `gorm:"foreignKey:SimilarBookID" json:"similar_book,omitempty"`
+	Rank          int     `gorm:"not null;index:idx_book_similarities_rank,priority:2" json:"rank"`
+	Score         float64 `gorm:"not null" json:"score"`
+	CoReaders     int     `gorm:"not null" json:"co_readers"`
 }
 
 type Category struct {
@@ -50,3 +95,18 @@ type Category struct {
 	Description string `gorm:"type:text" json:"description"`
 	Status      string `gorm:"default:active" json:"status"`
 }
+
+// BookWatermark is the watermark stamped on one reader's copy of a PDF.
+// Code is embedded in the copy (and printed on it when the template shows
+// it), so a leaked file leads back to the reader it was issued to. Copies
+// are reused while the template and options stay the same.
+type BookWatermark struct {
+	BaseModel
+	UserID    uint   `gorm:"not null;index:idx_book_watermarks_user_book" json:"user_id"`
+	User      *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	BookID    uint   `gorm:"not null;index:idx_book_watermarks_user_book" json:"book_id"`
+	Book      *Book  `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Code      string `gorm:"size:32;uniqueIndex;not null" json:"code"`
+	Text      string `gorm:"size:1000" json:"text"` // the visible line; empty when it isn't shown
+	Invisible bool   `gorm:"not null;default:false" json:"invisible"`
+}
@@ -11,6 +11,8 @@ type ChatRoom struct {
 	Group       *Group  `gorm:"foreignKey:GroupID" json:"group,omitempty"`
 	BookID      *uint   `gorm:"index" json:"book_id"`
 	Book        *Book   `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	SchoolID    *uint   `gorm:"index" json:"school_id"`
+	School      *School `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
 	CreatedBy   uint    `gorm:"not null;index" json:"created_by"`
 	Creator     *User   `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
 	IsActive    bool    `gorm:"default:true;index" json:"is_active"`
@@ -2,11 +2,13 @@ package models
 
 type Group struct {
 	BaseModel
-	Name        string `gorm:"not null" json:"name" validate:"required"`
-	Description string `gorm:"type:text" json:"description"`
-	CreatedBy   uint   `gorm:"not null;index" json:"created_by"`
-	Creator     *User  `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
-	MemberCount int    `gorm:"default:0" json:"member_count"`
+	Name        string  `gorm:"not null" json:"name" validate:"required"`
+	Description string  `gorm:"type:text" json:"description"`
+	SchoolID    *uint   `gorm:"index" json:"school_id"`
+	School      *School `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
+	CreatedBy   uint    `gorm:"not null;index" json:"created_by"`
+	Creator     *User   `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	MemberCount int     `gorm:"default:0" json:"member_count"`
 }
 
 type GroupMember struct {
@@ -16,3 +18,14 @@ type GroupMember struct {
 	UserID  uint   `gorm:"not null;index" json:"user_id"`
 	User    *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
+
+// GroupBook records that a book was assigned to a group, which is what lets
+// annotations shared with the group reach its members.
+type GroupBook struct {
+	BaseModel
+	GroupID    uint   `gorm:"not null;uniqueIndex:idx_group_books_group_book" json:"group_id"`
+	Group      *Group `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+	BookID     uint   `gorm:"not null;uniqueIndex:idx_group_books_group_book;index" json:"book_id"`
+	Book       *Book  `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	AssignedBy uint   `json:"assigned_by"`
+}
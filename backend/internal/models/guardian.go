@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// GuardianInvitation asks someone, by email, to follow a student's reading
+// as their parent or guardian. Only a hash of the emailed token is kept.
+type GuardianInvitation struct {
+	BaseModel
+	StudentID    uint       `gorm:"not null;index" json:"student_id"`
+	Student      *User      `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	InvitedBy    uint       `gorm:"not null" json:"invited_by"`
+	Inviter      *User      `gorm:"foreignKey:InvitedBy" json:"inviter,omitempty"`
+	Email        string     `gorm:"size:255;not null;index" json:"email"`
+	Relationship string     `gorm:"size:50" json:"relationship"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	AcceptedBy   *uint      `json:"accepted_by"`
+}
+
+// GuardianLink gives a guardian read-only access to a student's reading and
+// a weekly digest of it.
+type GuardianLink struct {
+	BaseModel
+	GuardianID    uint       `gorm:"not null;uniqueIndex:idx_guardian_links_pair" json:"guardian_id"`
+	Guardian      *User      `gorm:"foreignKey:GuardianID" json:"guardian,omitempty"`
+	StudentID     uint       `gorm:"not null;uniqueIndex:idx_guardian_links_pair;index" json:"student_id"`
+	Student       *User      `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	Relationship  string     `gorm:"size:50" json:"relationship"`
+	DigestEnabled bool       `gorm:"not null;default:true" json:"digest_enabled"`
+	DigestSentAt  *time.Time `gorm:"index" json:"digest_sent_at"`
+}
@@ -2,32 +2,77 @@ package models
 
 import "time"
 
+// UserLibrary is a book on a user's shelf. Locator is the reading position
+// synced across devices; Progress (0-100) and CurrentPage are derived from it
+// on the server. PositionUpdatedAt is the device clock of the write that set
+// the locator, which later writes must beat to replace it. ChapterID is the
+// chapter the locator falls in.
 type UserLibrary struct {
 	BaseModel
-	UserID      uint       `gorm:"not null;index" json:"user_id"`
-	User        *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	BookID      uint       `gorm:"not null;index" json:"book_id"`
-	Book        *Book      `gorm:"foreignKey:BookID" json:"book,omitempty"`
-	Progress    float64    `gorm:"default:0" json:"progress"`
-	CurrentPage int        `gorm:"default:0" json:"current_page"`
-	LastReadAt  *time.Time `json:"last_read_at"`
-	CompletedAt *time.Time `json:"completed_at"`
-	IsFavorite  bool       `gorm:"default:false" json:"is_favorite"`
-	Rating      int        `json:"rating" validate:"omitempty,gte=1,lte=5"`
+	UserID            uint         `gorm:"not null;index" json:"user_id"`
+	User              *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	BookID            uint         `gorm:"not null;index" json:"book_id"`
+	Book              *Book        `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	SchoolID          *uint        `gorm:"index" json:"school_id"`
+	Progress          float64      `gorm:"default:0" json:"progress"`
+	CurrentPage       int          `gorm:"default:0" json:"current_page"`
+	Locator           *Locator     `gorm:"serializer:json;type:text" json:"locator"`
+	ChapterID         *uint        `gorm:"index" json:"chapter_id"`
+	Chapter           *BookChapter `gorm:"foreignKey:ChapterID" json:"chapter,omitempty"`
+	PositionUpdatedAt *time.Time   `json:"position_updated_at"`
+	PositionDevice    string       `gorm:"size:100" json:"position_device,omitempty"`
+	LastReadAt        *time.Time   `json:"last_read_at"`
+	CompletedAt       *time.Time   `json:"completed_at"`
+	IsFavorite        bool         `gorm:"default:false" json:"is_favorite"`
+	Rating            int          `json:"rating" validate:"omitempty,gte=1,lte=5"`
 }
 
+// Locator is a position in a publication, modelled on the Readium locator:
+// Href and CFI pin the spot in an EPUB, Progression is the fraction through
+// the current resource and TotalProgression through the whole book. Page is
+// set for fixed-layout formats such as PDF.
+type Locator struct {
+	Href             string  `json:"href,omitempty"`
+	CFI              string  `json:"cfi,omitempty"`
+	Progression      float64 `json:"progression" validate:"gte=0,lte=1"`
+	TotalProgression float64 `json:"total_progression" validate:"gte=0,lte=1"`
+	Page             int     `json:"page,omitempty" validate:"gte=0"`
+}
+
+// ReadingSession is one sitting with a book. The server owns its timing:
+// Duration counts only the time between heartbeats that arrive close enough
+// together, and PagesRead the forward movement of the reader's locator.
+// StartChapterID and EndChapterID are the chapters of the two locators.
 type ReadingSession struct {
 	BaseModel
-	UserID    uint       `gorm:"not null;index" json:"user_id"`
-	User      *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	BookID    uint       `gorm:"not null;index" json:"book_id"`
-	Book      *Book      `gorm:"foreignKey:BookID" json:"book,omitempty"`
-	StartTime time.Time  `gorm:"not null" json:"start_time"`
-	EndTime   *time.Time `json:"end_time"`
-	Duration  int        `json:"duration"`
-	PagesRead int        `json:"pages_read"`
-	StartPage int        `json:"start_page"`
-	EndPage   int        `json:"end_page"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	User            *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	BookID          uint       `gorm:"not null;index" json:"book_id"`
+	Book            *Book      `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Status          string     `gorm:"not null;default:'active';index" json:"status"` // active, ended, abandoned
+	StartTime       time.Time  `gorm:"not null" json:"start_time"`
+	EndTime         *time.Time `json:"end_time"`
+	LastHeartbeatAt time.Time  `gorm:"index" json:"last_heartbeat_at"`
+	Duration        int        `json:"duration"` // seconds
+	PagesRead       int        `json:"pages_read"`
+	StartPage       int        `json:"start_page"`
+	EndPage         int        `json:"end_page"`
+	StartLocator    *Locator   `gorm:"serializer:json;type:text" json:"start_locator,omitempty"`
+	EndLocator      *Locator   `gorm:"serializer:json;type:text" json:"end_locator,omitempty"`
+	StartChapterID  *uint      `gorm:"index" json:"start_chapter_id,omitempty"`
+	EndChapterID    *uint      `gorm:"index" json:"end_chapter_id,omitempty"`
+}
+
+// StreakFreeze is a day that neither extends nor breaks a reading streak: a
+// holiday for everyone (no user or school), for one school, or a freeze for
+// one user. Date is a calendar day in the reader's own timezone.
+type StreakFreeze struct {
+	BaseModel
+	Date      string `gorm:"size:10;not null;index" json:"date" validate:"required,datetime=2006-01-02"`
+	UserID    *uint  `gorm:"index" json:"user_id,omitempty"`
+	SchoolID  *uint  `gorm:"index" json:"school_id,omitempty"`
+	Reason    string `json:"reason"`
+	CreatedBy uint   `json:"created_by"`
 }
 
 type ReadingGoal struct {
@@ -42,8 +87,43 @@ type ReadingGoal struct {
 	IsCompleted bool      `gorm:"default:false" json:"is_completed"`
 }
 
+// AnnotationSync is embedded in bookmarks, notes and highlights for offline
+// sync. ClientID is a UUID minted by the device that created the annotation,
+// ClientUpdatedAt the device clock at its last edit (which decides
+// conflicts), and SyncVersion a server-wide counter that sync tokens refer
+// to. Deleted annotations stay behind as soft-deleted tombstones so other
+// devices learn about the deletion.
+type AnnotationSync struct {
+	ClientID        string    `gorm:"size:36;uniqueIndex" json:"client_id"`
+	ClientUpdatedAt time.Time `json:"client_updated_at"`
+	SyncVersion     int64     `gorm:"not null;default:nextval('annotation_sync_seq');index" json:"sync_version"`
+}
+
+// AnnotationSharing controls who besides its author sees a note or
+// highlight: nobody (private), members of GroupID (group), or everyone with
+// the book in their library (public).
+type AnnotationSharing struct {
+	Visibility string `gorm:"size:20;not null;default:'private';index" json:"visibility"`
+	GroupID    *uint  `gorm:"index" json:"group_id,omitempty"`
+}
+
+// AnnotationReply is a comment on a shared note or highlight. The author of
+// the annotation can hide replies, which keeps them from everyone else.
+type AnnotationReply struct {
+	BaseModel
+	AnnotationType string     `gorm:"size:20;not null;index:idx_annotation_replies_annotation" json:"annotation_type"` // note, highlight
+	AnnotationID   uint       `gorm:"not null;index:idx_annotation_replies_annotation" json:"annotation_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	User           *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Content        string     `gorm:"type:text;not null" json:"content"`
+	IsHidden       bool       `gorm:"default:false" json:"is_hidden"`
+	HiddenBy       *uint      `json:"hidden_by,omitempty"`
+	HiddenAt       *time.Time `json:"hidden_at,omitempty"`
+}
+
 type Bookmark struct {
 	BaseModel
+	AnnotationSync
 	UserID   uint   `gorm:"not null;index" json:"user_id"`
 	User     *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	BookID   uint   `gorm:"not null;index" json:"book_id"`
@@ -55,6 +135,8 @@ type Bookmark struct {
 
 type Note struct {
 	BaseModel
+	AnnotationSync
+	AnnotationSharing
 	UserID  uint   `gorm:"not null;index" json:"user_id"`
 	User    *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	BookID  uint   `gorm:"not null;index" json:"book_id"`
@@ -65,6 +147,8 @@ type Note struct {
 
 type Highlight struct {
 	BaseModel
+	AnnotationSync
+	AnnotationSharing
 	UserID      uint   `gorm:"not null;index" json:"user_id"`
 	User        *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	BookID      uint   `gorm:"not null;index" json:"book_id"`
@@ -76,4 +160,3 @@ type Highlight struct {
 	Context     string `gorm:"type:text" json:"context"`
 	CFIRange    string `json:"cfi_range"`
 }
-
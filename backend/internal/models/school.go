@@ -0,0 +1,12 @@
+package models
+
+type School struct {
+	BaseModel
+	Name         string `gorm:"uniqueIndex;not null" json:"name" validate:"required"`
+	Category     string `json:"category"` // primary, secondary, tertiary
+	Address      string `gorm:"type:text" json:"address"`
+	ContactEmail string `json:"contact_email" validate:"omitempty,email"`
+	PhoneNumber  string `json:"phone_number"`
+	Timezone     string `gorm:"size:64" json:"timezone" validate:"omitempty,timezone"` // IANA name; empty means UTC
+	IsActive     bool   `gorm:"default:true;index" json:"is_active"`
+}
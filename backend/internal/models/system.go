@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type SystemSettings struct {
 	BaseModel
 	Key         string `gorm:"uniqueIndex;not null" json:"key"`
@@ -10,6 +12,23 @@ type SystemSettings struct {
 	IsPublic    bool   `gorm:"default:false" json:"is_public"`
 }
 
+// EmailOutbox is a queued outbound email. Messages are rendered when queued
+// and delivered by the outbox worker, which retries failures with backoff.
+type EmailOutbox struct {
+	BaseModel
+	ToEmail       string     `gorm:"not null;index" json:"to_email"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	HTML          string     `gorm:"type:text" json:"html,omitempty"`
+	Template      string     `gorm:"index" json:"template"`
+	Status        string     `gorm:"not null;default:'pending';index" json:"status"` // pending, sending, sent, failed
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"default:5" json:"max_attempts"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	Driver        string     `json:"driver"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+}
+
 type AuditLog struct {
 	BaseModel
 	UserID     uint   `gorm:"index" json:"user_id"`
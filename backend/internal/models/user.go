@@ -10,16 +10,25 @@ type User struct {
 	FirstName                string     `gorm:"not null" json:"first_name" validate:"required"`
 	LastName                 string     `gorm:"not null" json:"last_name" validate:"required"`
 	PhoneNumber              string     `json:"phone_number"`
+	SchoolID                 *uint      `gorm:"index" json:"school_id"`
+	School                   *School    `gorm:"foreignKey:SchoolID" json:"school,omitempty"`
 	SchoolName               string     `json:"school_name"`
 	SchoolCategory           string     `json:"school_category"`
 	ClassLevel               string     `json:"class_level"`
 	Department               string     `json:"department"`
+	ReadingGrade             *int       `json:"reading_grade"` // assessed level, see ReadingLevel; nil falls back to ClassLevel
+	ReadingScore             *int       `json:"reading_score"`
+	ReadingAssessedAt        *time.Time `json:"reading_assessed_at"`
+	ReadingAssessedBy        *uint      `json:"reading_assessed_by,omitempty"`
+	Timezone                 string     `gorm:"size:64" json:"timezone" validate:"omitempty,timezone"` // IANA name; empty uses the school's
 	RoleID                   uint       `gorm:"index" json:"role_id"`
 	Role                     *Role      `gorm:"foreignKey:RoleID" json:"role,omitempty"`
 	IsActive                 bool       `gorm:"default:false;index" json:"is_active"`
 	IsEmailVerified          bool       `gorm:"default:false;index" json:"is_email_verified"`
 	VerificationToken        string     `gorm:"index" json:"-"`
 	VerificationTokenExpires *time.Time `json:"-"`
+	PasswordResetToken       string     `gorm:"index" json:"-"`
+	PasswordResetExpires     *time.Time `json:"-"`
 	LastLogin                *time.Time `json:"last_login"`
 }
 
@@ -52,3 +61,38 @@ type TokenBlacklist struct {
 	Token     string    `gorm:"uniqueIndex;not null" json:"token"`
 	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
 }
+
+// UserSession is one refresh token in a login's rotation chain. Every token
+// issued from the same login shares a FamilyID; presenting a rotated or
+// revoked token again, outside a few seconds' grace after its rotation,
+// revokes the whole family.
+type UserSession struct {
+	BaseModel
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	User       *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	FamilyID   string     `gorm:"not null;index" json:"family_id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	DeviceName string     `json:"device_name"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	Current    bool       `gorm:"-" json:"current"`
+}
+
+// UserImportJob is a roster import creating its accounts in the background.
+// Report holds the JSON import report once it is done.
+type UserImportJob struct {
+	BaseModel
+	SchoolID   *uint      `gorm:"index" json:"school_id"`
+	ImportedBy uint       `gorm:"not null;index" json:"imported_by"`
+	Filename   string     `json:"filename"`
+	Status     string     `gorm:"not null;default:'pending';index" json:"status"` // pending, running, done, failed
+	Total      int        `json:"total"`
+	Created    int        `json:"created"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	Report     string     `gorm:"type:text" json:"-"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
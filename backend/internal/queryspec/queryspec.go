@@ -0,0 +1,201 @@
+// Package queryspec parses the sort and filter query parameters of list
+// endpoints against a per-resource whitelist, so column names used in SQL
+// never come from the request.
+package queryspec
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/utils"
+)
+
+type FilterType int
+
+const (
+	String FilterType = iota
+	Uint
+	Int
+	Bool
+	Date // YYYY-MM-DD
+)
+
+// Filter maps a query parameter to a condition on Column. Op defaults to "=";
+// ">=" and "<=" make range filters such as date_from/date_to.
+type Filter struct {
+	Column string
+	Type   FilterType
+	Op     string
+}
+
+// Spec is the whitelist for one list endpoint.
+type Spec struct {
+	// Sorts maps accepted sort names to SQL expressions. An empty expression
+	// marks a sort the service orders by itself (e.g. search relevance).
+	Sorts        map[string]string
+	DefaultSort  string
+	DefaultOrder string // "asc" or "desc"
+	Filters      map[string]Filter
+	// Params lists other parameters the handler reads itself (search, status, ...)
+	Params []string
+}
+
+// commonParams are accepted by every endpoint. sort_by and sort_order are the
+// older spellings of sort and order.
+var commonParams = map[string]bool{
+	"page": true, "limit": true, "skip": true,
+	"sort": true, "order": true, "sort_by": true, "sort_order": true,
+}
+
+// Query is a parsed, validated request.
+type Query struct {
+	Sort string
+	// Explicit reports whether the client asked for Sort rather than getting the default.
+	Explicit bool
+	Order    string // "ASC" or "DESC"
+	Values   map[string]interface{}
+
+	spec *Spec
+}
+
+// Parse validates params (usually fiber's c.Queries()) and returns a 400
+// AppError naming the first unknown parameter, sort field or bad value.
+func (s *Spec) Parse(params map[string]string) (*Query, error) {
+	allowed := make(map[string]bool, len(s.Params))
+	for _, name := range s.Params {
+		allowed[name] = true
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	q := &Query{Values: make(map[string]interface{}), spec: s}
+
+	for _, key := range keys {
+		if commonParams[key] || allowed[key] {
+			continue
+		}
+
+		filter, ok := s.Filters[key]
+		if !ok {
+			return nil, utils.NewBadRequestError("Unknown query parameter: " + key)
+		}
+
+		raw := strings.TrimSpace(params[key])
+		if raw == "" {
+			continue
+		}
+
+		value, err := parseValue(filter.Type, raw)
+		if err != nil {
+			return nil, utils.NewBadRequestError("Invalid value for " + key + ": " + raw)
+		}
+		q.Values[key] = value
+	}
+
+	sortName := firstNonEmpty(params["sort"], params["sort_by"])
+	if sortName == "" {
+		q.Sort = s.DefaultSort
+	} else {
+		if _, ok := s.Sorts[sortName]; !ok {
+			return nil, utils.NewBadRequestError("Unknown sort field: " + sortName + " (allowed: " + s.sortNames() + ")")
+		}
+		q.Sort = sortName
+		q.Explicit = true
+	}
+
+	order := strings.ToLower(firstNonEmpty(params["order"], params["sort_order"], s.DefaultOrder, "desc"))
+	switch order {
+	case "asc":
+		q.Order = "ASC"
+	case "desc":
+		q.Order = "DESC"
+	default:
+		return nil, utils.NewBadRequestError("Invalid sort order: " + order + " (use asc or desc)")
+	}
+
+	return q, nil
+}
+
+// Has reports whether the filter name was supplied.
+func (q *Query) Has(name string) bool {
+	_, ok := q.Values[name]
+	return ok
+}
+
+// Where applies the parsed filters.
+func (q *Query) Where(db *gorm.DB) *gorm.DB {
+	names := make([]string, 0, len(q.Values))
+	for name := range q.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		filter := q.spec.Filters[name]
+		op := filter.Op
+		if op == "" {
+			op = "="
+		}
+		db = db.Where(filter.Column+" "+op+" ?", q.Values[name])
+	}
+	return db
+}
+
+// OrderBy applies the parsed sort, if it maps to a column.
+func (q *Query) OrderBy(db *gorm.DB) *gorm.DB {
+	if column := q.spec.Sorts[q.Sort]; column != "" {
+		db = db.Order(column + " " + q.Order)
+	}
+	return db
+}
+
+// Scope applies both filters and sort.
+func (q *Query) Scope(db *gorm.DB) *gorm.DB {
+	return q.OrderBy(q.Where(db))
+}
+
+func (s *Spec) sortNames() string {
+	names := make([]string, 0, len(s.Sorts))
+	for name := range s.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func parseValue(t FilterType, raw string) (interface{}, error) {
+	switch t {
+	case Uint:
+		v, err := strconv.ParseUint(raw, 10, 32)
+		return uint(v), err
+	case Int:
+		v, err := strconv.Atoi(raw)
+		return v, err
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Date:
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	default:
+		return raw, nil
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
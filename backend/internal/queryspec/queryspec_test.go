@@ -0,0 +1,136 @@
+package queryspec
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"readagain/internal/utils"
+)
+
+var testSpec = &Spec{
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"title":      "LOWER(title)",
+		"relevance":  "",
+	},
+	DefaultSort: "created_at",
+	Filters: map[string]Filter{
+		"school_id": {Column: "school_id", Type: Uint},
+		"level":     {Column: "level", Type: Int},
+		"active":    {Column: "is_active", Type: Bool},
+		"date_from": {Column: "created_at", Type: Date, Op: ">="},
+		"status":    {Column: "status", Type: String},
+	},
+	Params: []string{"search"},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]string
+		sort     string
+		explicit bool
+		order    string
+		values   map[string]interface{}
+		err      string // substring of the 400 message, when Parse fails
+	}{
+		{
+			name:   "defaults",
+			params: map[string]string{},
+			sort:   "created_at", order: "DESC",
+			values: map[string]interface{}{},
+		},
+		{
+			name:   "common and handler params are passed over",
+			params: map[string]string{"page": "2", "limit": "10", "skip": "0", "search": "dune"},
+			sort:   "created_at", order: "DESC",
+			values: map[string]interface{}{},
+		},
+		{
+			name:   "sort and order",
+			params: map[string]string{"sort": "title", "order": "ASC"},
+			sort:   "title", explicit: true, order: "ASC",
+			values: map[string]interface{}{},
+		},
+		{
+			name:   "older spellings",
+			params: map[string]string{"sort_by": "title", "sort_order": "asc"},
+			sort:   "title", explicit: true, order: "ASC",
+			values: map[string]interface{}{},
+		},
+		{
+			name:   "sort wins over sort_by",
+			params: map[string]string{"sort": "relevance", "sort_by": "title"},
+			sort:   "relevance", explicit: true, order: "DESC",
+			values: map[string]interface{}{},
+		},
+		{
+			name: "typed filters",
+			params: map[string]string{
+				"school_id": "7", "level": "-1", "active": "true",
+				"date_from": "2024-09-01", "status": " overdue ",
+			},
+			sort: "created_at", order: "DESC",
+			values: map[string]interface{}{
+				"school_id": uint(7), "level": -1, "active": true,
+				"date_from": "2024-09-01", "status": "overdue",
+			},
+		},
+		{
+			name:   "blank filters are ignored",
+			params: map[string]string{"school_id": "  ", "status": ""},
+			sort:   "created_at", order: "DESC",
+			values: map[string]interface{}{},
+		},
+		{name: "unknown parameter", params: map[string]string{"role": "admin"}, err: "Unknown query parameter: role"},
+		{name: "unknown sort", params: map[string]string{"sort": "password_hash"}, err: "Unknown sort field: password_hash (allowed: created_at, relevance, title)"},
+		{name: "bad order", params: map[string]string{"order": "sideways"}, err: "Invalid sort order: sideways"},
+		{name: "bad uint", params: map[string]string{"school_id": "-3"}, err: "Invalid value for school_id: -3"},
+		{name: "bad int", params: map[string]string{"level": "two"}, err: "Invalid value for level: two"},
+		{name: "bad bool", params: map[string]string{"active": "yes please"}, err: "Invalid value for active"},
+		{name: "bad date", params: map[string]string{"date_from": "2024-13-01"}, err: "Invalid value for date_from"},
+		{
+			name:   "first unknown parameter is named",
+			params: map[string]string{"zeta": "1", "alpha": "1"},
+			err:    "Unknown query parameter: alpha",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := testSpec.Parse(tt.params)
+			if tt.err != "" {
+				var appErr *utils.AppError
+				if !errors.As(err, &appErr) || appErr.Code != 400 {
+					t.Fatalf("Parse() error = %v, want a 400 AppError", err)
+				}
+				if !strings.Contains(appErr.Message, tt.err) {
+					t.Fatalf("Parse() error = %q, want it to contain %q", appErr.Message, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if q.Sort != tt.sort || q.Explicit != tt.explicit || q.Order != tt.order {
+				t.Errorf("Parse() sort = %q explicit = %v order = %q, want %q %v %q", q.Sort, q.Explicit, q.Order, tt.sort, tt.explicit, tt.order)
+			}
+			if !reflect.DeepEqual(q.Values, tt.values) {
+				t.Errorf("Parse() values = %#v, want %#v", q.Values, tt.values)
+			}
+		})
+	}
+}
+
+func TestParseDefaultOrder(t *testing.T) {
+	spec := &Spec{Sorts: map[string]string{"name": "name"}, DefaultSort: "name", DefaultOrder: "asc"}
+	q, err := spec.Parse(map[string]string{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if q.Order != "ASC" {
+		t.Errorf("Parse() order = %q, want ASC", q.Order)
+	}
+}
@@ -8,11 +8,12 @@ import (
 )
 
 type AchievementService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	streakService *StreakService
 }
 
-func NewAchievementService(db *gorm.DB) *AchievementService {
-	return &AchievementService{db: db}
+func NewAchievementService(db *gorm.DB, streakService *StreakService) *AchievementService {
+	return &AchievementService{db: db, streakService: streakService}
 }
 
 func (s *AchievementService) GetAllAchievements() ([]models.Achievement, error) {
@@ -49,11 +50,17 @@ func (s *AchievementService) CheckAndUnlockAchievements(userID uint) ([]models.A
 	var totalSessions int64
 	s.db.Model(&models.ReadingSession{}).Where("user_id = ?", userID).Count(&totalSessions)
 
+	var longestStreak int64
+	if streak, err := s.streakService.GetStreak(userID); err == nil {
+		longestStreak = int64(streak.Longest)
+	}
+
 	criteria := map[string]int64{
 		"books_purchased": totalBooks,
 		"books_completed": completedBooks,
 		"reading_minutes": totalReadingTime,
 		"reading_sessions": totalSessions,
+		"reading_streak": longestStreak,
 	}
 
 	var achievements []models.Achievement
@@ -7,11 +7,12 @@ import (
 )
 
 type AnalyticsService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	streakService *StreakService
 }
 
-func NewAnalyticsService(db *gorm.DB) *AnalyticsService {
-	return &AnalyticsService{db: db}
+func NewAnalyticsService(db *gorm.DB, streakService *StreakService) *AnalyticsService {
+	return &AnalyticsService{db: db, streakService: streakService}
 }
 
 type DashboardOverview struct {
@@ -64,30 +65,44 @@ type GrowthMetrics struct {
 	OrdersGrowth  float64 `json:"orders_growth"`
 }
 
-func (s *AnalyticsService) GetDashboardOverview() (*DashboardOverview, error) {
+// scoped starts a count over users, user_libraries or reading_sessions,
+// limited to one school unless schoolID is nil. The book catalogue is
+// shared, so books aren't scoped.
+func (s *AnalyticsService) scoped(table string, schoolID *uint) *gorm.DB {
+	query := s.db.Model(&struct{ ID uint }{}).Table(table)
+	if schoolID == nil {
+		return query
+	}
+	if table == "reading_sessions" {
+		return query.Where("user_id IN (SELECT id FROM users WHERE school_id = ?)", *schoolID)
+	}
+	return query.Where("school_id = ?", *schoolID)
+}
+
+func (s *AnalyticsService) GetDashboardOverview(schoolID *uint) (*DashboardOverview, error) {
 	var overview DashboardOverview
 	today := time.Now().Truncate(24 * time.Hour)
 
-	s.db.Model(&struct{ ID uint }{}).Table("users").Count(&overview.TotalUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("is_active = ?", true).Count(&overview.ActiveUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Count(&overview.TotalOrders) // Reusing field for books in libraries
+	s.scoped("users", schoolID).Count(&overview.TotalUsers)
+	s.scoped("users", schoolID).Where("is_active = ?", true).Count(&overview.ActiveUsers)
+	s.scoped("user_libraries", schoolID).Count(&overview.TotalOrders) // Reusing field for books in libraries
 	s.db.Model(&struct{ ID uint }{}).Table("books").Count(&overview.TotalBooks)
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress = ?", 100).Count(&overview.TotalBooksRead)
-	s.db.Model(&struct{ ID uint }{}).Table("reading_sessions").Select("COALESCE(SUM(duration), 0)").Scan(&overview.TotalReadingTime)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("created_at >= ?", today).Count(&overview.NewUsersToday)
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("created_at >= ?", today).Count(&overview.OrdersToday) // Reusing field for books added today
+	s.scoped("user_libraries", schoolID).Where("progress = ?", 100).Count(&overview.TotalBooksRead)
+	s.scoped("reading_sessions", schoolID).Select("COALESCE(SUM(duration), 0)").Scan(&overview.TotalReadingTime)
+	s.scoped("users", schoolID).Where("created_at >= ?", today).Count(&overview.NewUsersToday)
+	s.scoped("user_libraries", schoolID).Where("created_at >= ?", today).Count(&overview.OrdersToday) // Reusing field for books added today
 
 	// Count active readers (users with reading sessions in last 7 days)
 	weekAgo := today.AddDate(0, 0, -7)
-	s.db.Raw("SELECT COUNT(DISTINCT user_id) FROM reading_sessions WHERE created_at >= ?", weekAgo).Scan(&overview.TotalRevenue) // Reusing field
-	s.db.Raw("SELECT COUNT(DISTINCT user_id) FROM reading_sessions WHERE created_at >= ?", today).Scan(&overview.RevenueToday) // Reusing field
+	s.scoped("reading_sessions", schoolID).Where("created_at >= ?", weekAgo).Select("COUNT(DISTINCT user_id)").Scan(&overview.TotalRevenue) // Reusing field
+	s.scoped("reading_sessions", schoolID).Where("created_at >= ?", today).Select("COUNT(DISTINCT user_id)").Scan(&overview.RevenueToday)   // Reusing field
 
 	return &overview, nil
 }
 
-func (s *AnalyticsService) GetSalesStats(startDate, endDate *time.Time) (*SalesStats, error) {
+func (s *AnalyticsService) GetSalesStats(startDate, endDate *time.Time, schoolID *uint) (*SalesStats, error) {
 	var stats SalesStats
-	query := s.db.Model(&struct{ ID uint }{}).Table("user_libraries")
+	query := s.scoped("user_libraries", schoolID)
 
 	if startDate != nil {
 		query = query.Where("created_at >= ?", startDate)
@@ -97,9 +112,9 @@ func (s *AnalyticsService) GetSalesStats(startDate, endDate *time.Time) (*SalesS
 	}
 
 	query.Count(&stats.TotalOrders) // Books in libraries
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress = ?", 100).Count(&stats.CompletedOrders) // Completed books
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress > ? AND progress < ?", 0, 100).Count(&stats.PendingOrders) // In progress
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress = ?", 0).Count(&stats.CancelledOrders) // Not started
+	s.scoped("user_libraries", schoolID).Where("progress = ?", 100).Count(&stats.CompletedOrders) // Completed books
+	s.scoped("user_libraries", schoolID).Where("progress > ? AND progress < ?", 0, 100).Count(&stats.PendingOrders) // In progress
+	s.scoped("user_libraries", schoolID).Where("progress = ?", 0).Count(&stats.CancelledOrders) // Not started
 
 	// No revenue for school platform
 	stats.TotalRevenue = 0
@@ -108,28 +123,28 @@ func (s *AnalyticsService) GetSalesStats(startDate, endDate *time.Time) (*SalesS
 	return &stats, nil
 }
 
-func (s *AnalyticsService) GetUserStats() (*UserStats, error) {
+func (s *AnalyticsService) GetUserStats(schoolID *uint) (*UserStats, error) {
 	var stats UserStats
 	now := time.Now()
 	weekAgo := now.AddDate(0, 0, -7)
 	monthAgo := now.AddDate(0, -1, 0)
 
-	s.db.Model(&struct{ ID uint }{}).Table("users").Count(&stats.TotalUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("is_active = ?", true).Count(&stats.ActiveUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("is_active = ?", false).Count(&stats.InactiveUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("created_at >= ?", monthAgo).Count(&stats.NewThisMonth)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("created_at >= ?", weekAgo).Count(&stats.NewThisWeek)
+	s.scoped("users", schoolID).Count(&stats.TotalUsers)
+	s.scoped("users", schoolID).Where("is_active = ?", true).Count(&stats.ActiveUsers)
+	s.scoped("users", schoolID).Where("is_active = ?", false).Count(&stats.InactiveUsers)
+	s.scoped("users", schoolID).Where("created_at >= ?", monthAgo).Count(&stats.NewThisMonth)
+	s.scoped("users", schoolID).Where("created_at >= ?", weekAgo).Count(&stats.NewThisWeek)
 
 	return &stats, nil
 }
 
-func (s *AnalyticsService) GetReadingStats() (*ReadingStats, error) {
+func (s *AnalyticsService) GetReadingStats(schoolID *uint) (*ReadingStats, error) {
 	var stats ReadingStats
 
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress = ?", 100).Count(&stats.TotalBooksRead)
-	s.db.Model(&struct{ ID uint }{}).Table("reading_sessions").Select("COALESCE(SUM(duration), 0)").Scan(&stats.TotalReadingTime)
-	s.db.Model(&struct{ ID uint }{}).Table("reading_sessions").Count(&stats.TotalSessions)
-	s.db.Model(&struct{ ID uint }{}).Table("reading_sessions").Distinct("user_id").Count(&stats.ActiveReaders)
+	s.scoped("user_libraries", schoolID).Where("progress = ?", 100).Count(&stats.TotalBooksRead)
+	s.scoped("reading_sessions", schoolID).Select("COALESCE(SUM(duration), 0)").Scan(&stats.TotalReadingTime)
+	s.scoped("reading_sessions", schoolID).Count(&stats.TotalSessions)
+	s.scoped("reading_sessions", schoolID).Distinct("user_id").Count(&stats.ActiveReaders)
 
 	if stats.TotalSessions > 0 {
 		stats.AverageSessionTime = stats.TotalReadingTime / stats.TotalSessions
@@ -169,7 +184,7 @@ func (s *AnalyticsService) GetRevenueReport(days int) ([]RevenueReport, error) {
 	return reports, nil
 }
 
-func (s *AnalyticsService) GetGrowthMetrics() (*GrowthMetrics, error) {
+func (s *AnalyticsService) GetGrowthMetrics(schoolID *uint) (*GrowthMetrics, error) {
 	var metrics GrowthMetrics
 	now := time.Now()
 	lastMonth := now.AddDate(0, -1, 0)
@@ -179,14 +194,14 @@ func (s *AnalyticsService) GetGrowthMetrics() (*GrowthMetrics, error) {
 	var currentLibraries, previousLibraries int64
 	var currentReaders, previousReaders int64
 
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("created_at >= ?", lastMonth).Count(&currentUsers)
-	s.db.Model(&struct{ ID uint }{}).Table("users").Where("created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Count(&previousUsers)
+	s.scoped("users", schoolID).Where("created_at >= ?", lastMonth).Count(&currentUsers)
+	s.scoped("users", schoolID).Where("created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Count(&previousUsers)
 
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("created_at >= ?", lastMonth).Count(&currentLibraries)
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Count(&previousLibraries)
+	s.scoped("user_libraries", schoolID).Where("created_at >= ?", lastMonth).Count(&currentLibraries)
+	s.scoped("user_libraries", schoolID).Where("created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Count(&previousLibraries)
 
-	s.db.Raw("SELECT COUNT(DISTINCT user_id) FROM reading_sessions WHERE created_at >= ?", lastMonth).Scan(&currentReaders)
-	s.db.Raw("SELECT COUNT(DISTINCT user_id) FROM reading_sessions WHERE created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Scan(&previousReaders)
+	s.scoped("reading_sessions", schoolID).Where("created_at >= ?", lastMonth).Select("COUNT(DISTINCT user_id)").Scan(&currentReaders)
+	s.scoped("reading_sessions", schoolID).Where("created_at >= ? AND created_at < ?", twoMonthsAgo, lastMonth).Select("COUNT(DISTINCT user_id)").Scan(&previousReaders)
 
 	if previousUsers > 0 {
 		metrics.UsersGrowth = ((float64(currentUsers) - float64(previousUsers)) / float64(previousUsers)) * 100
@@ -201,24 +216,28 @@ func (s *AnalyticsService) GetGrowthMetrics() (*GrowthMetrics, error) {
 	return &metrics, nil
 }
 
-func (s *AnalyticsService) GetEnhancedOverview() (map[string]interface{}, error) {
-	overview, err := s.GetDashboardOverview()
+func (s *AnalyticsService) GetEnhancedOverview(schoolID *uint) (map[string]interface{}, error) {
+	overview, err := s.GetDashboardOverview(schoolID)
 	if err != nil {
 		return nil, err
 	}
 
-	growth, _ := s.GetGrowthMetrics()
+	growth, _ := s.GetGrowthMetrics(schoolID)
 
+	school, schoolArgs := schoolFilter("school_id", schoolID)
 	var userGrowth []map[string]interface{}
-	rows, _ := s.db.Raw(`
+	rows, err := s.db.Raw(`
 		SELECT 
 			TO_CHAR(created_at, 'Mon YYYY') as month,
 			COUNT(*) as users
 		FROM users
-		WHERE created_at >= NOW() - INTERVAL '6 months'
+		WHERE created_at >= NOW() - INTERVAL '6 months'`+school+`
 		GROUP BY TO_CHAR(created_at, 'Mon YYYY'), DATE_TRUNC('month', created_at)
 		ORDER BY DATE_TRUNC('month', created_at)
-	`).Rows()
+	`, schoolArgs...).Rows()
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
@@ -248,21 +267,49 @@ func (s *AnalyticsService) GetEnhancedOverview() (map[string]interface{}, error)
 	}, nil
 }
 
-func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[string]interface{}, error) {
-	// Calculate date range based on period
+// readingPeriodStart is where a "week", "month", "quarter" or "year" of
+// reading analytics begins; anything else is a month.
+func readingPeriodStart(period string) time.Time {
 	now := time.Now()
-	var startDate time.Time
 	switch period {
 	case "week":
-		startDate = now.AddDate(0, 0, -7)
-	case "month":
-		startDate = now.AddDate(0, -1, 0)
+		return now.AddDate(0, 0, -7)
 	case "quarter":
-		startDate = now.AddDate(0, -3, 0)
+		return now.AddDate(0, -3, 0)
 	case "year":
-		startDate = now.AddDate(-1, 0, 0)
+		return now.AddDate(-1, 0, 0)
 	default:
-		startDate = now.AddDate(0, -1, 0)
+		return now.AddDate(0, -1, 0)
+	}
+}
+
+func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string, schoolID *uint) (map[string]interface{}, error) {
+	userSchool, schoolArgs := schoolFilter("u.school_id", schoolID)
+	librarySchool, _ := schoolFilter("school_id", schoolID)
+	return s.readingAnalytics(readingPeriodStart(period), userSchool, librarySchool, schoolArgs)
+}
+
+// GetGroupReadingAnalytics is GetReadingAnalyticsByPeriod for the members of
+// one group.
+func (s *AnalyticsService) GetGroupReadingAnalytics(period string, groupID uint) (map[string]interface{}, error) {
+	members := "SELECT user_id FROM group_members WHERE group_id = ? AND deleted_at IS NULL"
+	return s.readingAnalytics(readingPeriodStart(period), " AND u.id IN ("+members+")", " AND user_id IN ("+members+")", []interface{}{groupID})
+}
+
+// GetTeacherReadingAnalytics is GetReadingAnalyticsByPeriod for the members
+// of every group a teacher runs.
+func (s *AnalyticsService) GetTeacherReadingAnalytics(period string, teacherID uint) (map[string]interface{}, error) {
+	members := `SELECT gm.user_id FROM group_members gm JOIN groups g ON g.id = gm.group_id
+		WHERE g.created_by = ? AND gm.deleted_at IS NULL AND g.deleted_at IS NULL`
+	return s.readingAnalytics(readingPeriodStart(period), " AND u.id IN ("+members+")", " AND user_id IN ("+members+")", []interface{}{teacherID})
+}
+
+// readingAnalytics runs the class-level reading queries since startDate for
+// the readers picked by userScope (a condition on users u) and
+// libraryScope (the same on user_libraries), both taking scopeArgs.
+func (s *AnalyticsService) readingAnalytics(startDate time.Time, userScope, libraryScope string, scopeArgs []interface{}) (map[string]interface{}, error) {
+	withScope := func(args ...interface{}) []interface{} {
+		return append(args, scopeArgs...)
 	}
 
 	// Class/Grade stats
@@ -283,10 +330,10 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 		FROM users u
 		LEFT JOIN reading_sessions rs ON u.id = rs.user_id AND rs.created_at >= ?
 		LEFT JOIN user_libraries ul ON u.id = ul.user_id AND ul.created_at >= ?
-		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')
+		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')`+userScope+`
 		GROUP BY u.class_level
 		ORDER BY u.class_level
-	`, startDate, startDate).Scan(&classStats)
+	`, withScope(startDate, startDate)...).Scan(&classStats)
 
 	// Struggling readers (< 30% avg completion)
 	type StrugglingReader struct {
@@ -303,12 +350,12 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 		       COUNT(ul.id) as books_started
 		FROM users u
 		LEFT JOIN user_libraries ul ON u.id = ul.user_id AND ul.created_at >= ?
-		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')
+		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')`+userScope+`
 		GROUP BY u.id, u.first_name, u.last_name, u.email, u.class_level
 		HAVING COALESCE(AVG(ul.progress), 0) < 30 AND COUNT(ul.id) > 0
 		ORDER BY avg_completion ASC
 		LIMIT 20
-	`, startDate).Scan(&strugglingReaders)
+	`, withScope(startDate)...).Scan(&strugglingReaders)
 
 	// Most/Least read books by grade
 	type BookByGrade struct {
@@ -327,14 +374,15 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 		LEFT JOIN authors a ON b.author_id = a.id
 		JOIN user_libraries ul ON b.id = ul.book_id AND ul.created_at >= ?
 		JOIN users u ON ul.user_id = u.id
-		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')
+		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')`+userScope+`
 		GROUP BY b.id, b.title, a.business_name, u.class_level
 		ORDER BY reader_count DESC
 		LIMIT 50
-	`, startDate).Scan(&mostReadBooks)
+	`, withScope(startDate)...).Scan(&mostReadBooks)
 
 	// Top readers with streaks
 	type TopReader struct {
+		UserID         uint   `json:"user_id"`
 		Name           string `json:"name"`
 		ClassLevel     string `json:"class_level"`
 		BooksCompleted int64  `json:"books_completed"`
@@ -343,18 +391,27 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 	}
 	var topReaders []TopReader
 	s.db.Raw(`
-		SELECT CONCAT(u.first_name, ' ', u.last_name) as name, u.class_level,
+		SELECT u.id as user_id, CONCAT(u.first_name, ' ', u.last_name) as name, u.class_level,
 		       COUNT(CASE WHEN ul.progress = 100 THEN 1 END) as books_completed,
-		       COALESCE(SUM(rs.duration), 0) as reading_time,
-		       0 as current_streak
+		       COALESCE(SUM(rs.duration), 0) as reading_time
 		FROM users u
 		LEFT JOIN user_libraries ul ON u.id = ul.user_id AND ul.created_at >= ?
 		LEFT JOIN reading_sessions rs ON u.id = rs.user_id AND rs.created_at >= ?
-		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')
+		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')`+userScope+`
 		GROUP BY u.id, u.first_name, u.last_name, u.class_level
 		ORDER BY books_completed DESC, reading_time DESC
 		LIMIT 20
-	`, startDate, startDate).Scan(&topReaders)
+	`, withScope(startDate, startDate)...).Scan(&topReaders)
+
+	readerIDs := make([]uint, len(topReaders))
+	for i, r := range topReaders {
+		readerIDs[i] = r.UserID
+	}
+	if streaks, err := s.streakService.GetStreaks(readerIDs); err == nil {
+		for i := range topReaders {
+			topReaders[i].CurrentStreak = streaks[topReaders[i].UserID].Current
+		}
+	}
 
 	// Active readers with recent library activity
 	type ActiveReader struct {
@@ -377,10 +434,11 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 		FROM users u
 		JOIN user_libraries ul ON u.id = ul.user_id AND ul.created_at >= ?
 		LEFT JOIN reading_sessions rs ON u.id = rs.user_id AND ul.book_id = rs.book_id
+		WHERE 1 = 1`+userScope+`
 		GROUP BY u.id, u.first_name, u.last_name, u.email, u.class_level
 		ORDER BY last_session DESC
 		LIMIT 50
-	`, startDate).Scan(&activeReaders)
+	`, withScope(startDate)...).Scan(&activeReaders)
 
 	// Overall stats
 	var totalActiveReaders, totalBooksCompleted int64
@@ -389,11 +447,12 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 		SELECT COUNT(DISTINCT u.id) as total_readers
 		FROM users u
 		JOIN user_libraries ul ON u.id = ul.user_id AND ul.created_at >= ?
-	`, startDate).Scan(&totalActiveReaders)
-	
-	s.db.Raw(`SELECT COUNT(*) FROM user_libraries WHERE progress = 100 AND created_at >= ?`, startDate).Scan(&totalBooksCompleted)
-	s.db.Raw(`SELECT COALESCE(AVG(duration), 0) FROM reading_sessions WHERE created_at >= ?`, startDate).Scan(&avgReadingTime)
-	s.db.Raw(`SELECT COALESCE(AVG(progress), 0) FROM user_libraries WHERE created_at >= ?`, startDate).Scan(&avgCompletionRate)
+		WHERE 1 = 1`+userScope+`
+	`, withScope(startDate)...).Scan(&totalActiveReaders)
+
+	s.db.Raw(`SELECT COUNT(*) FROM user_libraries WHERE progress = 100 AND created_at >= ?`+libraryScope, withScope(startDate)...).Scan(&totalBooksCompleted)
+	s.db.Raw(`SELECT COALESCE(AVG(rs.duration), 0) FROM reading_sessions rs JOIN users u ON u.id = rs.user_id WHERE rs.created_at >= ?`+userScope, withScope(startDate)...).Scan(&avgReadingTime)
+	s.db.Raw(`SELECT COALESCE(AVG(progress), 0) FROM user_libraries WHERE created_at >= ?`+libraryScope, withScope(startDate)...).Scan(&avgCompletionRate)
 
 	return map[string]interface{}{
 		"overview": map[string]interface{}{
@@ -410,17 +469,17 @@ func (s *AnalyticsService) GetReadingAnalyticsByPeriod(period string) (map[strin
 	}, nil
 }
 
-func (s *AnalyticsService) GetReportsData() (map[string]interface{}, error) {
+func (s *AnalyticsService) GetReportsData(schoolID *uint) (map[string]interface{}, error) {
 	// Get reading statistics
 	var totalBooksInLibraries int64
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Count(&totalBooksInLibraries)
+	s.scoped("user_libraries", schoolID).Count(&totalBooksInLibraries)
 
 	var completedBooks int64
-	s.db.Model(&struct{ ID uint }{}).Table("user_libraries").Where("progress = ?", 100).Count(&completedBooks)
+	s.scoped("user_libraries", schoolID).Where("progress = ?", 100).Count(&completedBooks)
 
 	var activeReaders int64
 	weekAgo := time.Now().AddDate(0, 0, -7)
-	s.db.Raw("SELECT COUNT(DISTINCT user_id) FROM reading_sessions WHERE created_at >= ?", weekAgo).Scan(&activeReaders)
+	s.scoped("reading_sessions", schoolID).Where("created_at >= ?", weekAgo).Select("COUNT(DISTINCT user_id)").Scan(&activeReaders)
 
 	// Get popular books
 	type PopularBook struct {
@@ -431,19 +490,37 @@ func (s *AnalyticsService) GetReportsData() (map[string]interface{}, error) {
 		Rating       float64 `json:"rating"`
 	}
 	var popularBooks []PopularBook
-	s.db.Raw(`
-		SELECT 
-			b.id as book_id, 
-			b.title, 
-			b.library_count,
-			b.view_count as views,
-			COALESCE(ROUND(AVG(r.rating)::numeric, 1), 0) as rating
-		FROM books b
-		LEFT JOIN reviews r ON b.id = r.book_id
-		GROUP BY b.id, b.title, b.library_count, b.view_count
-		ORDER BY b.library_count DESC, b.view_count DESC
-		LIMIT 10
-	`).Scan(&popularBooks)
+	if schoolID == nil {
+		s.db.Raw(`
+			SELECT 
+				b.id as book_id, 
+				b.title, 
+				b.library_count,
+				b.view_count as views,
+				COALESCE(ROUND(AVG(r.rating)::numeric, 1), 0) as rating
+			FROM books b
+			LEFT JOIN reviews r ON b.id = r.book_id
+			GROUP BY b.id, b.title, b.library_count, b.view_count
+			ORDER BY b.library_count DESC, b.view_count DESC
+			LIMIT 10
+		`).Scan(&popularBooks)
+	} else {
+		// A school sees the books on its own students' shelves
+		s.db.Raw(`
+			SELECT 
+				b.id as book_id, 
+				b.title, 
+				COUNT(DISTINCT ul.id) as library_count,
+				b.view_count as views,
+				COALESCE(ROUND(AVG(r.rating)::numeric, 1), 0) as rating
+			FROM books b
+			JOIN user_libraries ul ON ul.book_id = b.id AND ul.deleted_at IS NULL AND ul.school_id = ?
+			LEFT JOIN reviews r ON b.id = r.book_id AND r.user_id = ul.user_id
+			GROUP BY b.id, b.title, b.view_count
+			ORDER BY library_count DESC, b.view_count DESC
+			LIMIT 10
+		`, *schoolID).Scan(&popularBooks)
+	}
 
 	// Available report types
 	reports := []map[string]interface{}{
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+var AnnotationExportFormats = []string{"md", "pdf", "json", "csv"}
+
+type ExportedAnnotation struct {
+	Type      string    `json:"type"` // bookmark, note, highlight
+	Chapter   string    `json:"chapter"`
+	Page      int       `json:"page,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Color     string    `json:"color,omitempty"`
+	Context   string    `json:"context,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	section int
+	steps   []int
+}
+
+type ExportedBook struct {
+	ID          uint                 `json:"id"`
+	Title       string               `json:"title"`
+	Author      string               `json:"author"`
+	Annotations []ExportedAnnotation `json:"annotations"`
+}
+
+// AnnotationExport is a rendered export ready to be sent as a download.
+type AnnotationExport struct {
+	Data        []byte
+	ContentType string
+	Filename    string
+}
+
+var (
+	cfiAssertion = regexp.MustCompile(`\[[^\]]*\]`)
+	nonSlugChars = regexp.MustCompile("[^a-z0-9]+")
+)
+
+// cfiSteps returns the numeric steps of an EPUB CFI, using the start of a
+// range CFI, so CFIs can be compared in reading order. The second step is
+// the spine item; nil means the location isn't a CFI.
+func cfiSteps(cfi string) []int {
+	if !strings.HasPrefix(cfi, "epubcfi(") {
+		return nil
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(cfi, "epubcfi("), ")")
+	path = cfiAssertion.ReplaceAllString(path, "")
+	if parts := strings.Split(path, ","); len(parts) > 1 {
+		path = parts[0] + parts[1]
+	}
+
+	var steps []int
+	for _, field := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '!' || r == ':' || r == '~' || r == '@' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			break
+		}
+		steps = append(steps, n)
+	}
+	return steps
+}
+
+// place fills in where the annotation sits in the book and its heading:
+// the title of the chapter it is in, or else its spine item for EPUB and
+// its page for fixed-layout books.
+func (a *ExportedAnnotation) place(cfi string, chapters []models.BookChapter) {
+	a.steps = cfiSteps(cfi)
+	switch {
+	case len(a.steps) > 1:
+		a.section = a.steps[1] / 2
+		a.Chapter = fmt.Sprintf("Section %d", a.section)
+		// CFI spine steps are even and 1-based
+		if chapter := chapterOf(chapters, a.section-1, func(c models.BookChapter) int {
+			if c.SpineIndex == nil {
+				return -1
+			}
+			return *c.SpineIndex
+		}); chapter != nil {
+			a.Chapter = chapter.Title
+		}
+	case a.Page > 0:
+		a.Chapter = fmt.Sprintf("Page %d", a.Page)
+		if chapter := chapterOf(chapters, a.Page, func(c models.BookChapter) int {
+			if c.StartPage == 0 {
+				return -1
+			}
+			return c.StartPage
+		}); chapter != nil {
+			a.Chapter = chapter.Title
+		}
+	default:
+		a.Chapter = "Other"
+	}
+}
+
+// chapterOf returns the chapter whose start, as given by start, is the last
+// one at or before at, taking the outermost of chapters starting together.
+// Chapters whose start is unknown (-1) are skipped.
+func chapterOf(chapters []models.BookChapter, at int, start func(models.BookChapter) int) *models.BookChapter {
+	var found *models.BookChapter
+	for i := range chapters {
+		s := start(chapters[i])
+		if s >= 0 && s <= at && (found == nil || s > start(*found)) {
+			found = &chapters[i]
+		}
+	}
+	return found
+}
+
+func compareSteps(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+// sortReadingOrder orders annotations by section, page and position within
+// the section, falling back to creation time.
+func sortReadingOrder(annotations []ExportedAnnotation) {
+	sort.SliceStable(annotations, func(i, j int) bool {
+		a, b := annotations[i], annotations[j]
+		if a.section != b.section {
+			return a.section < b.section
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		if c := compareSteps(a.steps, b.steps); c != 0 {
+			return c < 0
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// ExportAnnotations renders the user's bookmarks, notes and highlights for
+// one book, or for every book in their library when bookID is nil.
+func (s *EReaderService) ExportAnnotations(userID uint, bookID *uint, format string) (*AnnotationExport, error) {
+	books, err := s.collectAnnotations(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := "annotations"
+	if bookID != nil && len(books) == 1 {
+		if slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(books[0].Title), "-"), "-"); slug != "" {
+			filename = slug + "-annotations"
+		}
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"exported_at": time.Now(),
+			"books":       books,
+		}, "", "  ")
+		if err != nil {
+			return nil, utils.NewInternalServerError("Failed to export annotations", err)
+		}
+		return &AnnotationExport{Data: data, ContentType: "application/json", Filename: filename + ".json"}, nil
+	case "csv":
+		data, err := annotationsCSV(books)
+		if err != nil {
+			return nil, utils.NewInternalServerError("Failed to export annotations", err)
+		}
+		return &AnnotationExport{Data: data, ContentType: "text/csv; charset=utf-8", Filename: filename + ".csv"}, nil
+	case "md":
+		return &AnnotationExport{Data: annotationsMarkdown(books), ContentType: "text/markdown; charset=utf-8", Filename: filename + ".md"}, nil
+	case "pdf":
+		data, err := annotationsPDF(books)
+		if err != nil {
+			return nil, utils.NewInternalServerError("Failed to export annotations", err)
+		}
+		return &AnnotationExport{Data: data, ContentType: "application/pdf", Filename: filename + ".pdf"}, nil
+	default:
+		return nil, utils.NewBadRequestError("Invalid export format: " + format + " (use " + strings.Join(AnnotationExportFormats, ", ") + ")")
+	}
+}
+
+func (s *EReaderService) collectAnnotations(userID uint, bookID *uint) ([]ExportedBook, error) {
+	libraryQuery := s.db.Where("user_id = ?", userID).Preload("Book.Author")
+	if bookID != nil {
+		libraryQuery = libraryQuery.Where("book_id = ?", *bookID)
+	}
+	var library []models.UserLibrary
+	if err := libraryQuery.Find(&library).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch library", err)
+	}
+	if bookID != nil && len(library) == 0 {
+		return nil, utils.NewNotFoundError("Book not found in library")
+	}
+
+	scope := func() *gorm.DB {
+		query := s.db.Where("user_id = ?", userID)
+		if bookID != nil {
+			query = query.Where("book_id = ?", *bookID)
+		}
+		return query
+	}
+
+	var bookmarks []models.Bookmark
+	if err := scope().Find(&bookmarks).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch bookmarks", err)
+	}
+	var notes []models.Note
+	if err := scope().Find(&notes).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch notes", err)
+	}
+	var highlights []models.Highlight
+	if err := scope().Find(&highlights).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch highlights", err)
+	}
+
+	bookIDs := make([]uint, len(library))
+	for i, item := range library {
+		bookIDs[i] = item.BookID
+	}
+	var chapterRows []models.BookChapter
+	if err := s.db.Where("book_id IN ?", bookIDs).Order("book_id, position").Find(&chapterRows).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch chapters", err)
+	}
+	chapters := map[uint][]models.BookChapter{}
+	for _, c := range chapterRows {
+		chapters[c.BookID] = append(chapters[c.BookID], c)
+	}
+
+	byBook := map[uint][]ExportedAnnotation{}
+	for _, b := range bookmarks {
+		a := ExportedAnnotation{Type: AnnotationBookmark, Page: b.Page, Location: b.Location, Note: b.Note, CreatedAt: b.CreatedAt}
+		a.place(b.Location, chapters[b.BookID])
+		byBook[b.BookID] = append(byBook[b.BookID], a)
+	}
+	for _, n := range notes {
+		a := ExportedAnnotation{Type: AnnotationNote, Page: n.Page, Note: n.Content, CreatedAt: n.CreatedAt}
+		a.place("", chapters[n.BookID])
+		byBook[n.BookID] = append(byBook[n.BookID], a)
+	}
+	for _, h := range highlights {
+		a := ExportedAnnotation{Type: AnnotationHighlight, Location: h.CFIRange, Text: h.Text, Color: h.Color, Context: h.Context, CreatedAt: h.CreatedAt}
+		a.place(h.CFIRange, chapters[h.BookID])
+		byBook[h.BookID] = append(byBook[h.BookID], a)
+	}
+
+	books := []ExportedBook{}
+	for _, item := range library {
+		annotations := byBook[item.BookID]
+		if len(annotations) == 0 && bookID == nil {
+			continue
+		}
+		sortReadingOrder(annotations)
+
+		book := ExportedBook{ID: item.BookID, Annotations: annotations}
+		if book.Annotations == nil {
+			book.Annotations = []ExportedAnnotation{}
+		}
+		if item.Book != nil {
+			book.Title = item.Book.Title
+			if item.Book.Author != nil {
+				book.Author = item.Book.Author.BusinessName
+			}
+		}
+		books = append(books, book)
+	}
+
+	sort.SliceStable(books, func(i, j int) bool {
+		return strings.ToLower(books[i].Title) < strings.ToLower(books[j].Title)
+	})
+	return books, nil
+}
+
+func annotationsCSV(books []ExportedBook) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"book", "author", "type", "chapter", "page", "location", "text", "note", "color", "context", "created_at"})
+	for _, book := range books {
+		for _, a := range book.Annotations {
+			page := ""
+			if a.Page > 0 {
+				page = strconv.Itoa(a.Page)
+			}
+			row := []string{book.Title, book.Author, a.Type, a.Chapter, page, a.Location, a.Text, a.Note, a.Color, a.Context, a.CreatedAt.Format(time.RFC3339)}
+			for i := range row {
+				row[i] = csvCell(row[i])
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvCell keeps spreadsheets from running a highlight or note that starts
+// like a formula: such cells are prefixed with a quote and read as text.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// markdownQuote prefixes every line so multi-paragraph highlights stay
+// inside one blockquote.
+func markdownQuote(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
+
+func annotationsMarkdown(books []ExportedBook) []byte {
+	var b strings.Builder
+	for i, book := range books {
+		if i > 0 {
+			b.WriteString("\n---\n\n")
+		}
+		fmt.Fprintf(&b, "# %s\n\n", book.Title)
+		if book.Author != "" {
+			fmt.Fprintf(&b, "*by %s*\n\n", book.Author)
+		}
+		if len(book.Annotations) == 0 {
+			b.WriteString("No annotations yet.\n")
+			continue
+		}
+
+		chapter := ""
+		for _, a := range book.Annotations {
+			if a.Chapter != chapter {
+				chapter = a.Chapter
+				fmt.Fprintf(&b, "## %s\n\n", chapter)
+			}
+			switch a.Type {
+			case AnnotationHighlight:
+				b.WriteString(markdownQuote(a.Text) + "\n\n")
+				if a.Context != "" {
+					fmt.Fprintf(&b, "Context: *%s*\n\n", strings.TrimSpace(a.Context))
+				}
+			case AnnotationNote:
+				fmt.Fprintf(&b, "**Note:** %s\n\n", strings.TrimSpace(a.Note))
+			case AnnotationBookmark:
+				b.WriteString("**Bookmark**")
+				if a.Page > 0 {
+					fmt.Fprintf(&b, " (page %d)", a.Page)
+				}
+				if a.Note != "" {
+					b.WriteString(": " + strings.TrimSpace(a.Note))
+				}
+				b.WriteString("\n\n")
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// highlightColors maps reader highlight colors to a PDF marker bar.
+var highlightColors = map[string][3]int{
+	"yellow": {250, 204, 21},
+	"green":  {74, 222, 128},
+	"blue":   {96, 165, 250},
+	"pink":   {244, 114, 182},
+	"purple": {192, 132, 252},
+	"orange": {251, 146, 60},
+}
+
+func annotationsPDF(books []ExportedBook) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	// Core fonts are cp1252; translate so accents and quotes survive.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	for _, book := range books {
+		pdf.AddPage()
+		pdf.SetFont("Arial", "B", 18)
+		pdf.MultiCell(0, 9, tr(book.Title), "", "L", false)
+		if book.Author != "" {
+			pdf.SetFont("Arial", "I", 11)
+			pdf.MultiCell(0, 6, tr("by "+book.Author), "", "L", false)
+		}
+		pdf.SetFont("Arial", "", 9)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.Cell(0, 8, fmt.Sprintf("Exported %s", time.Now().Format("2006-01-02")))
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(12)
+
+		if len(book.Annotations) == 0 {
+			pdf.SetFont("Arial", "", 11)
+			pdf.Cell(0, 7, "No annotations yet.")
+			continue
+		}
+
+		chapter := ""
+		for _, a := range book.Annotations {
+			if a.Chapter != chapter {
+				chapter = a.Chapter
+				pdf.Ln(2)
+				pdf.SetFont("Arial", "B", 13)
+				pdf.MultiCell(0, 8, tr(chapter), "B", "L", false)
+				pdf.Ln(3)
+			}
+
+			switch a.Type {
+			case AnnotationHighlight:
+				color, ok := highlightColors[a.Color]
+				if !ok {
+					color = highlightColors["yellow"]
+				}
+				x, y := pdf.GetX(), pdf.GetY()
+				pdf.SetFont("Arial", "I", 11)
+				pdf.SetX(x + 4)
+				pdf.MultiCell(0, 6, tr(strings.TrimSpace(a.Text)), "", "L", false)
+				if pdf.GetY() > y {
+					pdf.SetFillColor(color[0], color[1], color[2])
+					pdf.Rect(x, y, 1.5, pdf.GetY()-y, "F")
+				}
+				if a.Context != "" {
+					pdf.SetFont("Arial", "", 9)
+					pdf.SetTextColor(110, 110, 110)
+					pdf.SetX(x + 4)
+					pdf.MultiCell(0, 5, tr(strings.TrimSpace(a.Context)), "", "L", false)
+					pdf.SetTextColor(0, 0, 0)
+				}
+			case AnnotationNote:
+				pdf.SetFont("Arial", "B", 10)
+				pdf.Cell(12, 6, "Note")
+				pdf.SetFont("Arial", "", 10)
+				pdf.MultiCell(0, 6, tr(strings.TrimSpace(a.Note)), "", "L", false)
+			case AnnotationBookmark:
+				label := "Bookmark"
+				if a.Page > 0 {
+					label += fmt.Sprintf(" (page %d)", a.Page)
+				}
+				if a.Note != "" {
+					label += ": " + strings.TrimSpace(a.Note)
+				}
+				pdf.SetFont("Arial", "", 10)
+				pdf.MultiCell(0, 6, tr(label), "", "L", false)
+			}
+			pdf.Ln(3)
+		}
+	}
+
+	if len(books) == 0 {
+		pdf.AddPage()
+		pdf.SetFont("Arial", "", 11)
+		pdf.Cell(0, 7, "No annotations yet.")
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	return buf.Bytes(), err
+}
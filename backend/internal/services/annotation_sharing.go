@@ -0,0 +1,310 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	VisibilityPrivate = "private"
+	VisibilityGroup   = "group"
+	VisibilityPublic  = "public"
+)
+
+// SharedAnnotation is a note or highlight as other readers see it.
+type SharedAnnotation struct {
+	Type       string        `json:"type"` // note, highlight
+	ID         uint          `json:"id"`
+	AuthorID   uint          `json:"author_id"`
+	AuthorName string        `json:"author_name"`
+	Visibility string        `json:"visibility"`
+	GroupID    *uint         `json:"group_id,omitempty"`
+	Page       int           `json:"page,omitempty"`
+	Text       string        `json:"text,omitempty"`
+	Content    string        `json:"content,omitempty"`
+	Color      string        `json:"color,omitempty"`
+	Context    string        `json:"context,omitempty"`
+	CFIRange   string        `json:"cfi_range,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Replies    []SharedReply `json:"replies"`
+}
+
+// SharedReply is a reply as readers see it, without the replier's account.
+type SharedReply struct {
+	ID         uint      `json:"id"`
+	AuthorID   uint      `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Content    string    `json:"content"`
+	IsHidden   bool      `json:"is_hidden"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// authorName is how annotation and reply authors are shown to classmates.
+func authorName(user *models.User) string {
+	if user == nil {
+		return ""
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// sameSchoolAuthor matches annotations whose author is in the same school
+// as the user given as its argument, so public ones never reach readers at
+// other schools.
+const sameSchoolAuthor = "user_id IN (SELECT id FROM users WHERE deleted_at IS NULL AND school_id IS NOT DISTINCT FROM (SELECT school_id FROM users WHERE id = ?))"
+
+// annotationRef is what access checks need to know about a note or highlight.
+type annotationRef struct {
+	ID         uint
+	UserID     uint
+	BookID     uint
+	Visibility string
+	GroupID    *uint
+}
+
+func annotationModel(kind string) (interface{}, error) {
+	switch kind {
+	case AnnotationNote:
+		return &models.Note{}, nil
+	case AnnotationHighlight:
+		return &models.Highlight{}, nil
+	default:
+		return nil, utils.NewBadRequestError("Only notes and highlights can be shared")
+	}
+}
+
+func (s *EReaderService) findAnnotation(kind string, id uint) (*annotationRef, error) {
+	model, err := annotationModel(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []annotationRef
+	if err := s.db.Model(model).
+		Select("id, user_id, book_id, visibility, group_id").
+		Where("id = ?", id).
+		Limit(1).Scan(&refs).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch annotation", err)
+	}
+	if len(refs) == 0 {
+		return nil, utils.NewNotFoundError("Annotation not found")
+	}
+	return &refs[0], nil
+}
+
+// inGroup reports whether the user belongs to the group or runs it.
+func (s *EReaderService) inGroup(userID, groupID uint) bool {
+	var count int64
+	s.db.Model(&models.GroupMember{}).Where("group_id = ? AND user_id = ?", groupID, userID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	s.db.Model(&models.Group{}).Where("id = ? AND created_by = ?", groupID, userID).Count(&count)
+	return count > 0
+}
+
+func (s *EReaderService) groupHasBook(groupID, bookID uint) bool {
+	var count int64
+	s.db.Model(&models.GroupBook{}).Where("group_id = ? AND book_id = ?", groupID, bookID).Count(&count)
+	return count > 0
+}
+
+func (s *EReaderService) canView(userID uint, ref *annotationRef) bool {
+	if ref.UserID == userID {
+		return true
+	}
+	switch ref.Visibility {
+	case VisibilityPublic:
+		var count int64
+		s.db.Model(&models.UserLibrary{}).Where("user_id = ? AND book_id = ?", userID, ref.BookID).Count(&count)
+		if count == 0 {
+			return false
+		}
+		s.db.Model(&models.User{}).
+			Where("id = ? AND school_id IS NOT DISTINCT FROM (SELECT school_id FROM users WHERE id = ?)", ref.UserID, userID).
+			Count(&count)
+		return count > 0
+	case VisibilityGroup:
+		return ref.GroupID != nil && s.inGroup(userID, *ref.GroupID) && s.groupHasBook(*ref.GroupID, ref.BookID)
+	}
+	return false
+}
+
+// SetVisibility shares the user's own note or highlight. Sharing with a
+// group needs the book to have been assigned to that group.
+func (s *EReaderService) SetVisibility(userID uint, kind string, id uint, visibility string, groupID *uint) error {
+	ref, err := s.findAnnotation(kind, id)
+	if err != nil {
+		return err
+	}
+	if ref.UserID != userID {
+		return utils.NewNotFoundError("Annotation not found")
+	}
+
+	if visibility == VisibilityGroup {
+		if groupID == nil {
+			return utils.NewBadRequestError("group_id is required for group visibility")
+		}
+		if !s.inGroup(userID, *groupID) {
+			return utils.NewForbiddenError("You are not a member of this group")
+		}
+		if !s.groupHasBook(*groupID, ref.BookID) {
+			return utils.NewBadRequestError("This book has not been assigned to the group")
+		}
+	} else {
+		groupID = nil
+	}
+
+	model, _ := annotationModel(kind)
+	if err := s.db.Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+		"visibility": visibility,
+		"group_id":   groupID,
+	}).Error; err != nil {
+		return utils.NewInternalServerError("Failed to update annotation visibility", err)
+	}
+	return nil
+}
+
+// GetSharedAnnotations returns the shared notes and highlights on a book the
+// user may see, their own shared ones included, in page/creation order.
+// Public ones come only from readers at the user's school. Hidden replies
+// are only returned to the author of the annotation and the replier.
+func (s *EReaderService) GetSharedAnnotations(userID, bookID uint) ([]SharedAnnotation, error) {
+	var library models.UserLibrary
+	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
+		return nil, utils.NewForbiddenError("You don't own this book")
+	}
+
+	var groupIDs []uint
+	s.db.Model(&models.GroupBook{}).
+		Where("book_id = ? AND (group_id IN (SELECT group_id FROM group_members WHERE user_id = ? AND deleted_at IS NULL) OR group_id IN (SELECT id FROM groups WHERE created_by = ? AND deleted_at IS NULL))", bookID, userID, userID).
+		Pluck("group_id", &groupIDs)
+
+	visible := func() *gorm.DB {
+		query := s.db.Preload("User").Where("book_id = ? AND visibility <> ?", bookID, VisibilityPrivate)
+		if len(groupIDs) > 0 {
+			return query.Where("((visibility = ? AND "+sameSchoolAuthor+") OR user_id = ? OR (visibility = ? AND group_id IN ?))", VisibilityPublic, userID, userID, VisibilityGroup, groupIDs)
+		}
+		return query.Where("((visibility = ? AND "+sameSchoolAuthor+") OR user_id = ?)", VisibilityPublic, userID, userID)
+	}
+
+	var notes []models.Note
+	if err := visible().Order("page ASC, created_at ASC").Find(&notes).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch shared notes", err)
+	}
+	var highlights []models.Highlight
+	if err := visible().Order("created_at ASC").Find(&highlights).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch shared highlights", err)
+	}
+
+	shared := make([]SharedAnnotation, 0, len(notes)+len(highlights))
+	for _, n := range notes {
+		shared = append(shared, SharedAnnotation{
+			Type: AnnotationNote, ID: n.ID, AuthorID: n.UserID, AuthorName: authorName(n.User),
+			Visibility: n.Visibility, GroupID: n.GroupID, Page: n.Page, Content: n.Content, CreatedAt: n.CreatedAt,
+		})
+	}
+	for _, h := range highlights {
+		shared = append(shared, SharedAnnotation{
+			Type: AnnotationHighlight, ID: h.ID, AuthorID: h.UserID, AuthorName: authorName(h.User),
+			Visibility: h.Visibility, GroupID: h.GroupID, Text: h.Text, Color: h.Color, Context: h.Context,
+			CFIRange: h.CFIRange, CreatedAt: h.CreatedAt,
+		})
+	}
+
+	// Replies for every annotation at once, sorted out to each below
+	type annotationKey struct {
+		kind string
+		id   uint
+	}
+	byKey := make(map[annotationKey]*SharedAnnotation, len(shared))
+	var noteIDs, highlightIDs []uint
+	for i := range shared {
+		a := &shared[i]
+		a.Replies = []SharedReply{}
+		byKey[annotationKey{a.Type, a.ID}] = a
+		if a.Type == AnnotationNote {
+			noteIDs = append(noteIDs, a.ID)
+		} else {
+			highlightIDs = append(highlightIDs, a.ID)
+		}
+	}
+	if len(shared) > 0 {
+		var replies []models.AnnotationReply
+		if err := s.db.Preload("User").
+			Where("(annotation_type = ? AND annotation_id IN ?) OR (annotation_type = ? AND annotation_id IN ?)",
+				AnnotationNote, noteIDs, AnnotationHighlight, highlightIDs).
+			Order("created_at ASC").Find(&replies).Error; err != nil {
+			return nil, utils.NewInternalServerError("Failed to fetch annotation replies", err)
+		}
+		for _, r := range replies {
+			a := byKey[annotationKey{r.AnnotationType, r.AnnotationID}]
+			if a == nil || (r.IsHidden && a.AuthorID != userID && r.UserID != userID) {
+				continue
+			}
+			a.Replies = append(a.Replies, SharedReply{
+				ID: r.ID, AuthorID: r.UserID, AuthorName: authorName(r.User),
+				Content: r.Content, IsHidden: r.IsHidden, CreatedAt: r.CreatedAt,
+			})
+		}
+	}
+
+	return shared, nil
+}
+
+// ReplyToAnnotation adds a reply to a note or highlight the user can see.
+func (s *EReaderService) ReplyToAnnotation(userID uint, kind string, id uint, content string) (*models.AnnotationReply, error) {
+	ref, err := s.findAnnotation(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Visibility == VisibilityPrivate || !s.canView(userID, ref) {
+		return nil, utils.NewNotFoundError("Annotation not found")
+	}
+
+	reply := models.AnnotationReply{
+		AnnotationType: kind,
+		AnnotationID:   id,
+		UserID:         userID,
+		Content:        content,
+	}
+	if err := s.db.Create(&reply).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to create reply", err)
+	}
+	return &reply, nil
+}
+
+// HideReply hides or restores a reply. Only the author of the annotation
+// being replied to may do this.
+func (s *EReaderService) HideReply(userID, replyID uint, hidden bool) (*models.AnnotationReply, error) {
+	var reply models.AnnotationReply
+	if err := s.db.First(&reply, replyID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Reply not found")
+	}
+
+	ref, err := s.findAnnotation(reply.AnnotationType, reply.AnnotationID)
+	if err != nil {
+		return nil, err
+	}
+	if ref.UserID != userID {
+		return nil, utils.NewForbiddenError("Only the author of the annotation can hide replies")
+	}
+
+	updates := map[string]interface{}{"is_hidden": hidden, "hidden_by": nil, "hidden_at": nil}
+	if hidden {
+		updates["hidden_by"] = userID
+		updates["hidden_at"] = time.Now()
+	}
+	if err := s.db.Model(&reply).Updates(updates).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to update reply", err)
+	}
+
+	if err := s.db.First(&reply, replyID).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to update reply", err)
+	}
+	return &reply, nil
+}
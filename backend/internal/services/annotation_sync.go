@@ -0,0 +1,334 @@
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	AnnotationBookmark  = "bookmark"
+	AnnotationNote      = "note"
+	AnnotationHighlight = "highlight"
+)
+
+// nextSyncVersion stamps a changed annotation for sync tokens. Only take it
+// while holding lockAnnotations for the annotation's book.
+var nextSyncVersion = gorm.Expr("nextval('annotation_sync_seq')")
+
+// lockAnnotations locks the reader's library row for the book. Every write
+// that takes a sync version holds it, so a sync that returns a token has
+// seen every version below it that will ever be committed for the book.
+func lockAnnotations(tx *gorm.DB, userID, bookID uint) error {
+	var library models.UserLibrary
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		First(&library).Error
+}
+
+// AnnotationChange is the part of a synced annotation shared by all types.
+// UpdatedAt is the device clock at the last edit; Deleted marks a tombstone.
+type AnnotationChange struct {
+	ClientID  string    `json:"client_id" validate:"required,uuid"`
+	UpdatedAt time.Time `json:"updated_at" validate:"required"`
+	Deleted   bool      `json:"deleted"`
+}
+
+type BookmarkChange struct {
+	AnnotationChange
+	Page     int    `json:"page" validate:"gte=0"`
+	Location string `json:"location"`
+	Note     string `json:"note"`
+}
+
+type NoteChange struct {
+	AnnotationChange
+	Page    int    `json:"page" validate:"gte=0"`
+	Content string `json:"content"`
+}
+
+type HighlightChange struct {
+	AnnotationChange
+	Text        string `json:"text"`
+	Color       string `json:"color"`
+	Context     string `json:"context"`
+	CFIRange    string `json:"cfi_range"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+}
+
+// AnnotationSyncRequest carries the changes a device made since its last
+// sync. SyncToken is the token from that sync, empty on first sync.
+type AnnotationSyncRequest struct {
+	SyncToken  string            `json:"sync_token"`
+	Bookmarks  []BookmarkChange  `json:"bookmarks" validate:"max=500,dive"`
+	Notes      []NoteChange      `json:"notes" validate:"max=500,dive"`
+	Highlights []HighlightChange `json:"highlights" validate:"max=500,dive"`
+}
+
+type AnnotationRejection struct {
+	Type     string `json:"type"`
+	ClientID string `json:"client_id"`
+	Reason   string `json:"reason"`
+}
+
+// AnnotationTombstones lists the client IDs of deleted annotations.
+type AnnotationTombstones struct {
+	Bookmarks  []string `json:"bookmarks"`
+	Notes      []string `json:"notes"`
+	Highlights []string `json:"highlights"`
+}
+
+// AnnotationSyncResult holds every annotation that changed on the server
+// since the request's token, including the ones just pushed and the current
+// version of any the device lost a conflict on. The device stores
+// SyncToken and sends it next time.
+type AnnotationSyncResult struct {
+	SyncToken  string                `json:"sync_token"`
+	Bookmarks  []models.Bookmark     `json:"bookmarks"`
+	Notes      []models.Note         `json:"notes"`
+	Highlights []models.Highlight    `json:"highlights"`
+	Deleted    AnnotationTombstones  `json:"deleted"`
+	Rejected   []AnnotationRejection `json:"rejected"`
+}
+
+// annotationState is what conflict resolution needs to know about the
+// stored copy of an annotation.
+type annotationState struct {
+	ID              uint
+	UserID          uint
+	BookID          uint
+	ClientUpdatedAt time.Time
+	DeletedAt       *time.Time
+}
+
+// changeWins decides a conflict the same way on every device: the later edit
+// wins, on a tie a deletion beats an edit, and otherwise the server keeps
+// what it has.
+func changeWins(stored *annotationState, change AnnotationChange) bool {
+	if change.UpdatedAt.After(stored.ClientUpdatedAt) {
+		return true
+	}
+	if change.UpdatedAt.Equal(stored.ClientUpdatedAt) {
+		return change.Deleted && stored.DeletedAt == nil
+	}
+	return false
+}
+
+// SyncAnnotations applies a device's batched bookmark, note and highlight
+// changes and returns the server's changes since the device's token. Syncs
+// for the same book are serialized on the library row so versions handed
+// out under the lock are never skipped by a token.
+func (s *EReaderService) SyncAnnotations(userID, bookID uint, req AnnotationSyncRequest) (*AnnotationSyncResult, error) {
+	var since int64
+	if req.SyncToken != "" {
+		parsed, err := strconv.ParseInt(req.SyncToken, 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, utils.NewBadRequestError("Invalid sync token")
+		}
+		since = parsed
+	}
+
+	result := &AnnotationSyncResult{
+		Bookmarks:  []models.Bookmark{},
+		Notes:      []models.Note{},
+		Highlights: []models.Highlight{},
+		Deleted: AnnotationTombstones{
+			Bookmarks:  []string{},
+			Notes:      []string{},
+			Highlights: []string{},
+		},
+		Rejected: []AnnotationRejection{},
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAnnotations(tx, userID, bookID); err != nil {
+			return utils.NewForbiddenError("You don't own this book")
+		}
+
+		lost := map[string][]string{}
+		apply := func(kind string, model interface{}, change AnnotationChange, fields map[string]interface{}) error {
+			if reason := validateAnnotationChange(kind, change, fields); reason != "" {
+				result.Rejected = append(result.Rejected, AnnotationRejection{Type: kind, ClientID: change.ClientID, Reason: reason})
+				return nil
+			}
+			won, reason, err := applyAnnotationChange(tx, model, userID, bookID, change, fields)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				result.Rejected = append(result.Rejected, AnnotationRejection{Type: kind, ClientID: change.ClientID, Reason: reason})
+			} else if !won {
+				lost[kind] = append(lost[kind], change.ClientID)
+			}
+			return nil
+		}
+
+		for _, c := range req.Bookmarks {
+			if err := apply(AnnotationBookmark, &models.Bookmark{}, c.AnnotationChange, map[string]interface{}{
+				"page": c.Page, "location": c.Location, "note": c.Note,
+			}); err != nil {
+				return err
+			}
+		}
+		for _, c := range req.Notes {
+			if err := apply(AnnotationNote, &models.Note{}, c.AnnotationChange, map[string]interface{}{
+				"page": c.Page, "content": c.Content,
+			}); err != nil {
+				return err
+			}
+		}
+		for _, c := range req.Highlights {
+			color := c.Color
+			if color == "" {
+				color = "yellow"
+			}
+			if err := apply(AnnotationHighlight, &models.Highlight{}, c.AnnotationChange, map[string]interface{}{
+				"text": c.Text, "color": color, "context": c.Context, "cfi_range": c.CFIRange,
+				"start_offset": c.StartOffset, "end_offset": c.EndOffset,
+			}); err != nil {
+				return err
+			}
+		}
+
+		changed := func(kind string) *gorm.DB {
+			query := tx.Unscoped().Where("user_id = ? AND book_id = ?", userID, bookID)
+			if ids := lost[kind]; len(ids) > 0 {
+				return query.Where("(sync_version > ? OR client_id IN ?)", since, ids)
+			}
+			return query.Where("sync_version > ?", since)
+		}
+
+		var bookmarks []models.Bookmark
+		if err := changed(AnnotationBookmark).Order("sync_version ASC").Find(&bookmarks).Error; err != nil {
+			return utils.NewInternalServerError("Failed to fetch bookmark changes", err)
+		}
+		var notes []models.Note
+		if err := changed(AnnotationNote).Order("sync_version ASC").Find(&notes).Error; err != nil {
+			return utils.NewInternalServerError("Failed to fetch note changes", err)
+		}
+		var highlights []models.Highlight
+		if err := changed(AnnotationHighlight).Order("sync_version ASC").Find(&highlights).Error; err != nil {
+			return utils.NewInternalServerError("Failed to fetch highlight changes", err)
+		}
+
+		// A first sync has nothing to delete, so it gets no tombstones.
+		token := since
+		for _, b := range bookmarks {
+			token = max(token, b.SyncVersion)
+			if !b.DeletedAt.Valid {
+				result.Bookmarks = append(result.Bookmarks, b)
+			} else if since > 0 {
+				result.Deleted.Bookmarks = append(result.Deleted.Bookmarks, b.ClientID)
+			}
+		}
+		for _, n := range notes {
+			token = max(token, n.SyncVersion)
+			if !n.DeletedAt.Valid {
+				result.Notes = append(result.Notes, n)
+			} else if since > 0 {
+				result.Deleted.Notes = append(result.Deleted.Notes, n.ClientID)
+			}
+		}
+		for _, h := range highlights {
+			token = max(token, h.SyncVersion)
+			if !h.DeletedAt.Valid {
+				result.Highlights = append(result.Highlights, h)
+			} else if since > 0 {
+				result.Deleted.Highlights = append(result.Deleted.Highlights, h.ClientID)
+			}
+		}
+		result.SyncToken = strconv.FormatInt(token, 10)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validateAnnotationChange returns why a live annotation is missing the
+// content its type needs, or "" when it is fine. Tombstones need none.
+func validateAnnotationChange(kind string, change AnnotationChange, fields map[string]interface{}) string {
+	if change.Deleted {
+		return ""
+	}
+	switch kind {
+	case AnnotationNote:
+		if fields["content"] == "" {
+			return "content is required"
+		}
+	case AnnotationHighlight:
+		if fields["text"] == "" {
+			return "text is required"
+		}
+	}
+	return ""
+}
+
+// applyAnnotationChange creates, updates or deletes the annotation with the
+// change's client ID. It reports whether the change won against the stored
+// copy, or a rejection reason when the client ID belongs to someone else.
+func applyAnnotationChange(tx *gorm.DB, model interface{}, userID, bookID uint, change AnnotationChange, fields map[string]interface{}) (bool, string, error) {
+	// Device clocks far in the future would win every later conflict.
+	updatedAt := change.UpdatedAt
+	if now := time.Now(); updatedAt.After(now.Add(positionMaxClockSkew)) {
+		updatedAt = now
+	}
+	change.UpdatedAt = updatedAt
+
+	var stored []annotationState
+	if err := tx.Unscoped().Model(model).
+		Select("id, user_id, book_id, client_updated_at, deleted_at").
+		Where("client_id = ?", change.ClientID).
+		Limit(1).Scan(&stored).Error; err != nil {
+		return false, "", utils.NewInternalServerError("Failed to look up annotation", err)
+	}
+
+	now := time.Now()
+	if len(stored) == 0 {
+		// Created and deleted offline: the server never needs to know.
+		if change.Deleted {
+			return true, "", nil
+		}
+		fields["user_id"] = userID
+		fields["book_id"] = bookID
+		fields["client_id"] = change.ClientID
+		fields["client_updated_at"] = updatedAt
+		fields["created_at"] = now
+		fields["updated_at"] = now
+		if err := tx.Model(model).Create(fields).Error; err != nil {
+			return false, "", utils.NewInternalServerError("Failed to create annotation", err)
+		}
+		return true, "", nil
+	}
+
+	current := &stored[0]
+	if current.UserID != userID || current.BookID != bookID {
+		return false, "client_id is already used by another annotation", nil
+	}
+	if !changeWins(current, change) {
+		return false, "", nil
+	}
+
+	if change.Deleted {
+		fields = map[string]interface{}{"deleted_at": now}
+	} else {
+		fields["deleted_at"] = nil
+	}
+	fields["client_updated_at"] = updatedAt
+	fields["sync_version"] = nextSyncVersion
+	fields["updated_at"] = now
+	if err := tx.Unscoped().Model(model).Where("id = ?", current.ID).Updates(fields).Error; err != nil {
+		return false, "", utils.NewInternalServerError("Failed to update annotation", err)
+	}
+	return true, "", nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangeWins(t *testing.T) {
+	edited := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	deleted := edited.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		stored annotationState
+		change AnnotationChange
+		want   bool
+	}{
+		{
+			name:   "later edit wins",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited.Add(time.Second)},
+			want:   true,
+		},
+		{
+			name:   "earlier edit loses",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited.Add(-time.Second)},
+			want:   false,
+		},
+		{
+			name:   "same edit twice is a no-op",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited},
+			want:   false,
+		},
+		{
+			name:   "deletion beats an edit on a tie",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited, Deleted: true},
+			want:   true,
+		},
+		{
+			name:   "deleting a tombstone again on a tie",
+			stored: annotationState{ClientUpdatedAt: edited, DeletedAt: &deleted},
+			change: AnnotationChange{UpdatedAt: edited, Deleted: true},
+			want:   false,
+		},
+		{
+			name:   "edit loses to a deletion on a tie",
+			stored: annotationState{ClientUpdatedAt: edited, DeletedAt: &deleted},
+			change: AnnotationChange{UpdatedAt: edited},
+			want:   false,
+		},
+		{
+			name:   "later edit restores a deleted annotation",
+			stored: annotationState{ClientUpdatedAt: edited, DeletedAt: &deleted},
+			change: AnnotationChange{UpdatedAt: edited.Add(time.Millisecond)},
+			want:   true,
+		},
+		{
+			name:   "earlier deletion loses",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited.Add(-time.Minute), Deleted: true},
+			want:   false,
+		},
+		{
+			name:   "same instant in another zone is a tie",
+			stored: annotationState{ClientUpdatedAt: edited},
+			change: AnnotationChange{UpdatedAt: edited.In(time.FixedZone("UTC+2", 2*60*60))},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changeWins(&tt.stored, tt.change); got != tt.want {
+				t.Errorf("changeWins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
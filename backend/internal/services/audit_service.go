@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
+	"readagain/internal/queryspec"
 	"readagain/internal/utils"
 )
 
@@ -31,30 +32,39 @@ func (s *AuditService) Log(userID uint, action, entityType string, entityID uint
 	return s.db.Create(audit).Error
 }
 
-func (s *AuditService) GetLogs(page, limit int, userID *uint, entityType, action string) ([]models.AuditLog, *utils.PaginationMeta, error) {
+// AuditLogSpec whitelists the sort and filter parameters of the audit log.
+var AuditLogSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"created_at":  "created_at",
+		"action":      "action",
+		"entity_type": "entity_type",
+		"user_id":     "user_id",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"user_id":     {Column: "user_id", Type: queryspec.Uint},
+		"entity_type": {Column: "entity_type", Type: queryspec.String},
+		"resource":    {Column: "entity_type", Type: queryspec.String},
+		"entity_id":   {Column: "entity_id", Type: queryspec.Uint},
+		"action":      {Column: "action", Type: queryspec.String},
+		"date_from":   {Column: "DATE(created_at)", Type: queryspec.Date, Op: ">="},
+		"date_to":     {Column: "DATE(created_at)", Type: queryspec.Date, Op: "<="},
+	},
+}
+
+func (s *AuditService) GetLogs(page, limit int, q *queryspec.Query) ([]models.AuditLog, *utils.PaginationMeta, error) {
 	var logs []models.AuditLog
 	var total int64
 
-	query := s.db.Model(&models.AuditLog{}).Preload("User")
-
-	if userID != nil {
-		query = query.Where("user_id = ?", *userID)
-	}
-
-	if entityType != "" {
-		query = query.Where("entity_type = ?", entityType)
-	}
-
-	if action != "" {
-		query = query.Where("action = ?", action)
-	}
+	query := s.db.Model(&models.AuditLog{}).Preload("User").Scopes(q.Where)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, nil, err
 	}
 
 	offset := (page - 1) * limit
-	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+	if err := query.Scopes(q.OrderBy).Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
 		return nil, nil, err
 	}
 
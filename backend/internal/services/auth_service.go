@@ -1,6 +1,7 @@
 package services
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,16 +12,30 @@ import (
 )
 
 type AuthService struct {
-	db           *gorm.DB
-	cfg          *config.Config
-	emailService *EmailService
+	db                 *gorm.DB
+	cfg                *config.Config
+	emailService       *EmailService
+	cache              *CacheService
+	userSessionService *UserSessionService
+	settingsService    *SettingsService
 }
 
-func NewAuthService(db *gorm.DB, cfg *config.Config, emailService *EmailService) *AuthService {
+const (
+	verificationTokenTTL       = 24 * time.Hour
+	verificationResendInterval = time.Minute
+
+	// RequireEmailVerificationSetting blocks login for unverified accounts when "true"
+	RequireEmailVerificationSetting = "auth.require_email_verification"
+)
+
+func NewAuthService(db *gorm.DB, cfg *config.Config, emailService *EmailService, cache *CacheService, userSessionService *UserSessionService, settingsService *SettingsService) *AuthService {
 	return &AuthService{
-		db:           db,
-		cfg:          cfg,
-		emailService: emailService,
+		db:                 db,
+		cfg:                cfg,
+		emailService:       emailService,
+		cache:              cache,
+		userSessionService: userSessionService,
+		settingsService:    settingsService,
 	}
 }
 
@@ -34,37 +49,117 @@ func (s *AuthService) Register(email, username, password, firstName, lastName, s
 		return nil, utils.NewBadRequestError("Email or username already exists")
 	}
 
+	// Public sign-ups are always students; role IDs differ between installs
+	var studentRole models.Role
+	if err := s.db.Where("name = ?", "student").First(&studentRole).Error; err != nil {
+		return nil, utils.NewInternalServerError("Student role not found", err)
+	}
+
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
 		return nil, utils.NewInternalServerError("Failed to hash password", err)
 	}
 
+	verificationToken := utils.GenerateRandomToken(32)
+	verificationExpires := time.Now().Add(verificationTokenTTL)
+
 	user := &models.User{
-		Email:        email,
-		Username:     username,
-		PasswordHash: hashedPassword,
-		FirstName:    firstName,
-		LastName:     lastName,
-		SchoolName:   schoolName,
-		ClassLevel:   classLevel,
-		RoleID:       5,
-		IsActive:     false,
+		Email:                    email,
+		Username:                 username,
+		PasswordHash:             hashedPassword,
+		FirstName:                firstName,
+		LastName:                 lastName,
+		SchoolName:               schoolName,
+		ClassLevel:               classLevel,
+		RoleID:                   studentRole.ID,
+		IsActive:                 false,
+		VerificationToken:        verificationToken,
+		VerificationTokenExpires: &verificationExpires,
+	}
+
+	if schoolName != "" {
+		var school models.School
+		if err := s.db.Where("LOWER(name) = LOWER(?)", strings.TrimSpace(schoolName)).First(&school).Error; err == nil {
+			user.SchoolID = &school.ID
+		}
 	}
 
 	if err := s.db.Create(user).Error; err != nil {
 		return nil, utils.NewInternalServerError("Failed to create user", err)
 	}
 
-	name := firstName
-	if name == "" {
-		name = username
-	}
-	go s.emailService.SendWelcomeEmail(email, name)
+	s.emailService.SendVerificationEmail(email, displayName(user), verificationToken)
 
 	return user, nil
 }
 
-func (s *AuthService) Login(emailOrUsername, password, ipAddress, userAgent string) (string, string, *models.User, error) {
+// VerifyEmail confirms the address a verification link was sent to.
+func (s *AuthService) VerifyEmail(token string) error {
+	var user models.User
+	if err := s.db.Where("verification_token = ?", token).First(&user).Error; err != nil {
+		return utils.NewNotFoundError("Invalid verification token")
+	}
+
+	if user.VerificationTokenExpires == nil || user.VerificationTokenExpires.Before(time.Now()) {
+		return utils.NewBadRequestError("Verification token has expired")
+	}
+
+	if err := s.db.Model(&user).Updates(map[string]interface{}{
+		"is_email_verified":          true,
+		"verification_token":         "",
+		"verification_token_expires": nil,
+	}).Error; err != nil {
+		return utils.NewInternalServerError("Failed to verify email", err)
+	}
+
+	s.emailService.SendWelcomeEmail(user.Email, displayName(&user))
+
+	return nil
+}
+
+// ResendVerification issues a fresh verification link. Unknown or already
+// verified addresses succeed silently so the endpoint can't be used to probe
+// which emails are registered.
+func (s *AuthService) ResendVerification(email string) error {
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+
+	if user.IsEmailVerified {
+		return nil
+	}
+
+	if user.VerificationTokenExpires != nil {
+		issuedAt := user.VerificationTokenExpires.Add(-verificationTokenTTL)
+		if time.Since(issuedAt) < verificationResendInterval {
+			return utils.NewBadRequestError("Please wait before requesting another verification email")
+		}
+	}
+
+	verificationToken := utils.GenerateRandomToken(32)
+	expiresAt := time.Now().Add(verificationTokenTTL)
+
+	if err := s.db.Model(&user).Updates(map[string]interface{}{
+		"verification_token":         verificationToken,
+		"verification_token_expires": expiresAt,
+	}).Error; err != nil {
+		return utils.NewInternalServerError("Failed to save verification token", err)
+	}
+
+	s.emailService.SendVerificationEmail(user.Email, displayName(&user), verificationToken)
+
+	return nil
+}
+
+func displayName(user *models.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return user.Username
+}
+
+func (s *AuthService) Login(emailOrUsername, password, deviceName, ipAddress, userAgent string) (string, string, *models.User, error) {
 	var user models.User
 	if err := s.db.Preload("Role").Where("email = ? OR username = ?", emailOrUsername, emailOrUsername).First(&user).Error; err != nil {
 		s.logAuthAttempt(0, "login", ipAddress, userAgent, false)
@@ -80,14 +175,19 @@ func (s *AuthService) Login(emailOrUsername, password, ipAddress, userAgent stri
 		return "", "", nil, utils.NewForbiddenError("Account is not active")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Username, user.RoleID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
+	if !user.IsEmailVerified && s.settingsService.GetBool(RequireEmailVerificationSetting, false) {
+		s.logAuthAttempt(user.ID, "login", ipAddress, userAgent, false)
+		return "", "", nil, utils.NewForbiddenError("Please verify your email address before logging in")
+	}
+
+	refreshToken, session, err := s.userSessionService.CreateSession(user.ID, deviceName, ipAddress, userAgent)
 	if err != nil {
-		return "", "", nil, utils.NewInternalServerError("Failed to generate access token", err)
+		return "", "", nil, err
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, s.cfg.JWT.Secret, s.cfg.JWT.RefreshExpireDays)
+	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Username, user.RoleID, session.FamilyID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
 	if err != nil {
-		return "", "", nil, utils.NewInternalServerError("Failed to generate refresh token", err)
+		return "", "", nil, utils.NewInternalServerError("Failed to generate access token", err)
 	}
 
 	now := time.Now()
@@ -99,27 +199,29 @@ func (s *AuthService) Login(emailOrUsername, password, ipAddress, userAgent stri
 	return accessToken, refreshToken, &user, nil
 }
 
-func (s *AuthService) RefreshToken(refreshToken string) (string, error) {
-	claims, err := utils.ValidateRefreshToken(refreshToken, s.cfg.JWT.Secret)
+// RefreshToken rotates the presented refresh token and returns a new access
+// and refresh token pair for the same session.
+func (s *AuthService) RefreshToken(refreshToken, ipAddress, userAgent string) (string, string, error) {
+	newRefreshToken, session, err := s.userSessionService.RotateSession(refreshToken, ipAddress, userAgent)
 	if err != nil {
-		return "", utils.NewUnauthorizedError("Invalid refresh token")
+		return "", "", err
 	}
 
 	var user models.User
-	if err := s.db.First(&user, claims.UserID).Error; err != nil {
-		return "", utils.NewUnauthorizedError("User not found")
+	if err := s.db.First(&user, session.UserID).Error; err != nil {
+		return "", "", utils.NewUnauthorizedError("User not found")
 	}
 
 	if !user.IsActive {
-		return "", utils.NewForbiddenError("Account is not active")
+		return "", "", utils.NewForbiddenError("Account is not active")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Username, user.RoleID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
+	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, user.Username, user.RoleID, session.FamilyID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
 	if err != nil {
-		return "", utils.NewInternalServerError("Failed to generate access token", err)
+		return "", "", utils.NewInternalServerError("Failed to generate access token", err)
 	}
 
-	return accessToken, nil
+	return accessToken, newRefreshToken, nil
 }
 
 func (s *AuthService) Logout(token string, userID uint) error {
@@ -137,6 +239,40 @@ func (s *AuthService) Logout(token string, userID uint) error {
 		return utils.NewInternalServerError("Failed to blacklist token", err)
 	}
 
+	if s.cache != nil {
+		if err := s.cache.RevokeToken(token, blacklist.ExpiresAt); err != nil {
+			utils.ErrorLogger.Printf("Failed to cache revoked token: %v", err)
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := s.userSessionService.RevokeFamily(claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WarmRevokedTokens loads still-valid blacklist entries into the cache so the
+// auth middleware can answer revocation checks without touching Postgres.
+func (s *AuthService) WarmRevokedTokens() error {
+	if s.cache == nil {
+		return nil
+	}
+
+	var entries []models.TokenBlacklist
+	if err := s.db.Where("expires_at > ?", time.Now()).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := s.cache.RevokeToken(entry.Token, entry.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	utils.InfoLogger.Printf("Loaded %d revoked tokens into cache", len(entries))
 	return nil
 }
 
@@ -163,8 +299,8 @@ func (s *AuthService) RequestPasswordReset(email string) (string, error) {
 	resetToken := utils.GenerateRandomToken(32)
 	expiresAt := time.Now().Add(time.Hour * 1)
 
-	user.VerificationToken = resetToken
-	user.VerificationTokenExpires = &expiresAt
+	user.PasswordResetToken = resetToken
+	user.PasswordResetExpires = &expiresAt
 
 	if err := s.db.Save(&user).Error; err != nil {
 		return "", utils.NewInternalServerError("Failed to save reset token", err)
@@ -175,11 +311,11 @@ func (s *AuthService) RequestPasswordReset(email string) (string, error) {
 
 func (s *AuthService) ResetPassword(token, newPassword string) error {
 	var user models.User
-	if err := s.db.Where("verification_token = ?", token).First(&user).Error; err != nil {
+	if err := s.db.Where("password_reset_token = ?", token).First(&user).Error; err != nil {
 		return utils.NewNotFoundError("Invalid reset token")
 	}
 
-	if user.VerificationTokenExpires == nil || user.VerificationTokenExpires.Before(time.Now()) {
+	if user.PasswordResetExpires == nil || user.PasswordResetExpires.Before(time.Now()) {
 		return utils.NewBadRequestError("Reset token has expired")
 	}
 
@@ -189,12 +325,15 @@ func (s *AuthService) ResetPassword(token, newPassword string) error {
 	}
 
 	user.PasswordHash = hashedPassword
-	user.VerificationToken = ""
-	user.VerificationTokenExpires = nil
+	user.PasswordResetToken = ""
+	user.PasswordResetExpires = nil
+	// Following the emailed link proves ownership of the address
+	user.IsEmailVerified = true
 
 	if err := s.db.Save(&user).Error; err != nil {
 		return utils.NewInternalServerError("Failed to reset password", err)
 	}
 
-	return nil
+	// Sign out every device that may have been using the old password
+	return s.userSessionService.RevokeAllSessions(user.ID)
 }
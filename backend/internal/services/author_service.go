@@ -132,6 +132,13 @@ func (s *AuthorService) UpdateAuthor(authorID uint, updates map[string]interface
 		return nil, utils.NewInternalServerError("Failed to update author", err)
 	}
 
+	// The author's name is part of each of their books' search vector
+	if _, ok := updates["business_name"]; ok {
+		if err := syncBookSearchVector(s.db, "books.author_id = ?", authorID); err != nil {
+			utils.ErrorLogger.Printf("Failed to reindex books for author %d: %v", authorID, err)
+		}
+	}
+
 	if err := s.db.Preload("User").First(&author, authorID).Error; err != nil {
 		return nil, utils.NewNotFoundError("Author not found")
 	}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+// A chapter counts as read once this share of it has been read through in
+// reading sessions; readers skim past epigraphs and closing pages.
+const chapterCompleteAt = 0.9
+
+// buildChapters turns a table of contents into chapters of a book with
+// bookPages pages. A chapter runs until the next entry at the same or a
+// higher level. Entries sharing a start (EPUB entries pointing into the
+// same content document) run until the next entry that starts later, so
+// no chapter is empty.
+func buildChapters(bookID uint, toc []BookTOCEntry, bookPages int) []models.BookChapter {
+	starts := make([]float64, len(toc))
+	last := 0.0
+	for i, entry := range toc {
+		start := entry.Progression
+		if start == 0 && entry.Page > 0 && bookPages > 0 {
+			start = float64(entry.Page-1) / float64(bookPages)
+		}
+		// TOC entries are in reading order; one pointing backwards is kept
+		// where the previous entry starts
+		last = math.Min(math.Max(start, last), 1)
+		starts[i] = last
+	}
+
+	chapters := make([]models.BookChapter, len(toc))
+	for i, entry := range toc {
+		level := max(entry.Level, 1)
+		end := 1.0
+		for j := i + 1; j < len(toc); j++ {
+			if max(toc[j].Level, 1) <= level {
+				end = starts[j]
+				break
+			}
+		}
+		if end <= starts[i] {
+			end = 1.0
+			for j := i + 1; j < len(toc); j++ {
+				if starts[j] > starts[i] {
+					end = starts[j]
+					break
+				}
+			}
+		}
+
+		chapter := models.BookChapter{
+			BookID:           bookID,
+			Position:         i + 1,
+			Title:            strings.TrimSpace(entry.Title),
+			Level:            level,
+			Href:             entry.Href,
+			SpineIndex:       entry.SpineIndex,
+			StartProgression: starts[i],
+			EndProgression:   end,
+		}
+		if bookPages > 0 {
+			chapter.StartPage = min(int(math.Round(starts[i]*float64(bookPages)))+1, bookPages)
+			chapter.EndPage = max(int(math.Round(end*float64(bookPages))), chapter.StartPage)
+		}
+		chapters[i] = chapter
+	}
+	return chapters
+}
+
+func loadChapters(db *gorm.DB, bookID uint) ([]models.BookChapter, error) {
+	var chapters []models.BookChapter
+	err := db.Where("book_id = ?", bookID).Order("position").Find(&chapters).Error
+	return chapters, err
+}
+
+// locatorFraction is how far through the book locator is, from the page for
+// fixed-layout books (the middle of it, so a page maps to the chapter that
+// starts on it) and the total progression otherwise.
+func locatorFraction(locator *models.Locator, bookPages int) float64 {
+	if locator.Page > 0 && bookPages > 0 {
+		return math.Min((float64(locator.Page)-0.5)/float64(bookPages), 1)
+	}
+	return locator.TotalProgression
+}
+
+func hrefDocument(href string) string {
+	doc, _, _ := strings.Cut(href, "#")
+	return path.Clean("/" + doc)
+}
+
+// chapterAt returns the chapter locator falls in: the innermost chapter
+// starting at or before it. Of chapters starting at the same place, entries
+// pointing at a fragment are passed over, as all that is known is that they
+// are somewhere in the document. For EPUB the search is narrowed to the
+// chapters of the locator's content document, since readers' progressions
+// are estimates.
+func chapterAt(chapters []models.BookChapter, locator *models.Locator, bookPages int) *models.BookChapter {
+	if locator == nil || len(chapters) == 0 {
+		return nil
+	}
+
+	candidates := chapters
+	if locator.Href != "" {
+		doc := hrefDocument(locator.Href)
+		var inDoc []models.BookChapter
+		for _, c := range chapters {
+			// Readers resolve hrefs against different roots, so compare
+			// the trailing path
+			if c.Href != "" {
+				chapterDoc := hrefDocument(c.Href)
+				if strings.HasSuffix(doc, chapterDoc) || strings.HasSuffix(chapterDoc, doc) {
+					inDoc = append(inDoc, c)
+				}
+			}
+		}
+		if len(inDoc) > 0 {
+			candidates = inDoc
+		}
+	}
+
+	fraction := locatorFraction(locator, bookPages)
+	found := &candidates[0]
+	for i := range candidates {
+		start := candidates[i].StartProgression
+		if start <= fraction && (start > found.StartProgression || start == found.StartProgression && !strings.Contains(candidates[i].Href, "#")) {
+			found = &candidates[i]
+		}
+	}
+	if found.StartProgression > fraction && len(candidates) == len(chapters) {
+		// Before the first chapter: front matter
+		return nil
+	}
+	return found
+}
+
+func chapterIDAt(chapters []models.BookChapter, locator *models.Locator, bookPages int) *uint {
+	if chapter := chapterAt(chapters, locator, bookPages); chapter != nil {
+		return &chapter.ID
+	}
+	return nil
+}
+
+// GetChapters returns the book's table of contents.
+func (s *BookService) GetChapters(bookID uint) ([]models.BookChapter, error) {
+	var count int64
+	if err := s.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&count).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch book", err)
+	}
+	if count == 0 {
+		return nil, utils.NewNotFoundError("Book not found")
+	}
+
+	chapters, err := loadChapters(s.db, bookID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch chapters", err)
+	}
+	return chapters, nil
+}
+
+// ReplaceChapters swaps the book's table of contents for toc, as extracted
+// again from the book file, and moves readers' positions and sessions onto
+// the new chapters.
+func (s *BookService) ReplaceChapters(bookID uint, toc []BookTOCEntry) ([]models.BookChapter, error) {
+	var book models.Book
+	if err := s.db.Select("id", "pages").First(&book, bookID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Book not found")
+	}
+
+	var chapters []models.BookChapter
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if chapters, err = saveChapters(tx, bookID, toc, book.Pages); err != nil {
+			return utils.NewInternalServerError("Failed to save chapters", err)
+		}
+		if err := remapChapters(tx, bookID, chapters, book.Pages); err != nil {
+			return utils.NewInternalServerError("Failed to map reading positions to chapters", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chapters, nil
+}
+
+// saveChapters replaces the chapters of a book. Old chapters are removed
+// for good, as positions are unique per book, so readers' positions are
+// let go of first; remapChapters puts them on the new ones.
+func saveChapters(tx *gorm.DB, bookID uint, toc []BookTOCEntry, bookPages int) ([]models.BookChapter, error) {
+	if err := tx.Model(&models.UserLibrary{}).Where("book_id = ? AND chapter_id IS NOT NULL", bookID).
+		UpdateColumn("chapter_id", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("book_id = ?", bookID).Delete(&models.BookChapter{}).Error; err != nil {
+		return nil, err
+	}
+	chapters := buildChapters(bookID, toc, bookPages)
+	if len(chapters) == 0 {
+		return chapters, nil
+	}
+	if err := tx.CreateInBatches(&chapters, 500).Error; err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+func remapChapters(tx *gorm.DB, bookID uint, chapters []models.BookChapter, bookPages int) error {
+	var library []models.UserLibrary
+	if err := tx.Select("id", "locator").Where("book_id = ?", bookID).Find(&library).Error; err != nil {
+		return err
+	}
+	for _, item := range library {
+		if err := tx.Model(&item).UpdateColumn("chapter_id", chapterIDAt(chapters, item.Locator, bookPages)).Error; err != nil {
+			return err
+		}
+	}
+
+	var sessions []models.ReadingSession
+	return tx.Select("id", "start_locator", "end_locator").Where("book_id = ?", bookID).
+		FindInBatches(&sessions, 500, func(_ *gorm.DB, _ int) error {
+			for _, session := range sessions {
+				if err := tx.Model(&session).UpdateColumns(map[string]interface{}{
+					"start_chapter_id": chapterIDAt(chapters, session.StartLocator, bookPages),
+					"end_chapter_id":   chapterIDAt(chapters, session.EndLocator, bookPages),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// ChapterProgress is how much of one chapter a reader has read. Completion
+// (0-100) is the share of the chapter read through in reading sessions and
+// TimeSpent the seconds of those sessions spent in it.
+type ChapterProgress struct {
+	ChapterID  uint    `json:"chapter_id"`
+	Position   int     `json:"position"`
+	Title      string  `json:"title"`
+	Level      int     `json:"level"`
+	StartPage  int     `json:"start_page"`
+	EndPage    int     `json:"end_page"`
+	Completion float64 `json:"completion"`
+	Completed  bool    `json:"completed"`
+	Current    bool    `json:"current"`
+	TimeSpent  int     `json:"time_spent"`
+}
+
+// ChapterReport is a reader's progress through the chapters of a book.
+type ChapterReport struct {
+	BookID            uint              `json:"book_id"`
+	CurrentChapterID  *uint             `json:"current_chapter_id"`
+	ChaptersCompleted int               `json:"chapters_completed"`
+	ChaptersTotal     int               `json:"chapters_total"`
+	Chapters          []ChapterProgress `json:"chapters"`
+}
+
+type readSpan struct {
+	start, end float64
+	seconds    int
+}
+
+// sessionSpan is the part of the book a session read through. Forward
+// movement beyond the pages the session earned was a jump (TOC, search),
+// so the span is cut down to what was actually read.
+func sessionSpan(session models.ReadingSession, bookPages int) readSpan {
+	span := readSpan{seconds: session.Duration}
+	switch {
+	case session.StartLocator != nil && session.EndLocator != nil:
+		span.start = locatorFraction(session.StartLocator, bookPages)
+		span.end = locatorFraction(session.EndLocator, bookPages)
+	case bookPages > 0:
+		span.start = float64(session.StartPage) / float64(bookPages)
+		span.end = float64(session.EndPage) / float64(bookPages)
+	}
+	if span.end < span.start {
+		span.end = span.start
+	}
+	if bookPages > 0 {
+		span.end = math.Min(span.end, span.start+float64(session.PagesRead)/float64(bookPages))
+	}
+	return span
+}
+
+// mergeSpans joins overlapping spans, so reading a passage twice doesn't
+// count twice.
+func mergeSpans(spans []readSpan) []readSpan {
+	read := make([]readSpan, 0, len(spans))
+	sorted := append([]readSpan(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	for _, span := range sorted {
+		if span.end <= span.start {
+			continue
+		}
+		if n := len(read); n > 0 && span.start <= read[n-1].end {
+			read[n-1].end = math.Max(read[n-1].end, span.end)
+			continue
+		}
+		read = append(read, span)
+	}
+	return read
+}
+
+// spanCoverage is the share (0-1) of start-end covered by merged spans.
+func spanCoverage(read []readSpan, start, end float64) float64 {
+	if end <= start {
+		return 0
+	}
+	covered := 0.0
+	for _, span := range read {
+		covered += math.Max(0, math.Min(span.end, end)-math.Max(span.start, start))
+	}
+	return math.Min(covered/(end-start), 1)
+}
+
+// readCoverage is the share (0-1) of the book the reader has read through
+// in reading sessions.
+func readCoverage(db *gorm.DB, userID, bookID uint, bookPages int) (float64, error) {
+	var sessions []models.ReadingSession
+	if err := db.Select("duration", "pages_read", "start_page", "end_page", "start_locator", "end_locator").
+		Where("user_id = ? AND book_id = ?", userID, bookID).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+
+	spans := make([]readSpan, len(sessions))
+	for i, session := range sessions {
+		spans[i] = sessionSpan(session, bookPages)
+	}
+	return spanCoverage(mergeSpans(spans), 0, 1), nil
+}
+
+// chapterReport works out per-chapter completion for one reader from their
+// reading sessions.
+func chapterReport(db *gorm.DB, userID, bookID uint) (*ChapterReport, error) {
+	var library models.UserLibrary
+	if err := db.Select("id", "chapter_id").Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
+		return nil, utils.NewNotFoundError("Book not found in library")
+	}
+
+	chapters, err := loadChapters(db, bookID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch chapters", err)
+	}
+	report := &ChapterReport{
+		BookID:           bookID,
+		CurrentChapterID: library.ChapterID,
+		ChaptersTotal:    len(chapters),
+		Chapters:         make([]ChapterProgress, len(chapters)),
+	}
+	if len(chapters) == 0 {
+		return report, nil
+	}
+
+	var pages int
+	db.Model(&models.Book{}).Where("id = ?", bookID).Select("pages").Scan(&pages)
+
+	var sessions []models.ReadingSession
+	if err := db.Where("user_id = ? AND book_id = ?", userID, bookID).Find(&sessions).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch sessions", err)
+	}
+
+	spans := make([]readSpan, len(sessions))
+	for i, session := range sessions {
+		spans[i] = sessionSpan(session, pages)
+	}
+
+	read := mergeSpans(spans)
+
+	// Nested chapters lie inside their parents, so a parent's time includes
+	// its sections'
+	timeSpent := make([]float64, len(chapters))
+	for _, span := range spans {
+		for j, chapter := range chapters {
+			if span.end <= span.start {
+				// No movement: the time goes to where the reader stayed
+				if span.start >= chapter.StartProgression && span.start < chapter.EndProgression {
+					timeSpent[j] += float64(span.seconds)
+				}
+				continue
+			}
+			if overlap := math.Min(span.end, chapter.EndProgression) - math.Max(span.start, chapter.StartProgression); overlap > 0 {
+				timeSpent[j] += float64(span.seconds) * overlap / (span.end - span.start)
+			}
+		}
+	}
+
+	for i, chapter := range chapters {
+		completion := spanCoverage(read, chapter.StartProgression, chapter.EndProgression)
+
+		progress := ChapterProgress{
+			ChapterID:  chapter.ID,
+			Position:   chapter.Position,
+			Title:      chapter.Title,
+			Level:      chapter.Level,
+			StartPage:  chapter.StartPage,
+			EndPage:    chapter.EndPage,
+			Completion: math.Round(completion*1000) / 10,
+			Completed:  completion >= chapterCompleteAt,
+			Current:    library.ChapterID != nil && *library.ChapterID == chapter.ID,
+			TimeSpent:  int(math.Round(timeSpent[i])),
+		}
+		if progress.Completed {
+			report.ChaptersCompleted++
+		}
+		report.Chapters[i] = progress
+	}
+
+	return report, nil
+}
+
+// GetChapterProgress returns the reader's progress through each chapter of
+// a book in their library.
+func (s *LibraryService) GetChapterProgress(userID, bookID uint) (*ChapterReport, error) {
+	return chapterReport(s.db, userID, bookID)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"readagain/internal/models"
+)
+
+func TestBuildChapters(t *testing.T) {
+	type span struct {
+		title              string
+		level              int
+		start, end         float64
+		startPage, endPage int
+	}
+
+	tests := []struct {
+		name      string
+		toc       []BookTOCEntry
+		bookPages int
+		want      []span
+	}{
+		{
+			name: "pdf pages with a nested entry",
+			toc: []BookTOCEntry{
+				{Title: "One", Level: 1, Page: 1},
+				{Title: "Two", Level: 1, Page: 21},
+				{Title: "Two, part one", Level: 2, Page: 41},
+				{Title: "Three", Level: 1, Page: 61},
+			},
+			bookPages: 100,
+			want: []span{
+				{"One", 1, 0, 0.2, 1, 20},
+				{"Two", 1, 0.2, 0.6, 21, 60},
+				{"Two, part one", 2, 0.4, 0.6, 41, 60},
+				{"Three", 1, 0.6, 1, 61, 100},
+			},
+		},
+		{
+			name: "entries sharing a start run to the next later one",
+			toc: []BookTOCEntry{
+				{Title: "Part One", Level: 1, Href: "part1.xhtml"},
+				{Title: "Chapter 1", Level: 1, Href: "part1.xhtml#c1"},
+				{Title: "Chapter 2", Level: 1, Href: "ch2.xhtml", Progression: 0.5},
+			},
+			want: []span{
+				{"Part One", 1, 0, 0.5, 0, 0},
+				{"Chapter 1", 1, 0, 0.5, 0, 0},
+				{"Chapter 2", 1, 0.5, 1, 0, 0},
+			},
+		},
+		{
+			name: "an entry pointing backwards starts where the previous one does",
+			toc: []BookTOCEntry{
+				{Title: "A", Level: 1, Progression: 0.3},
+				{Title: "B", Level: 1, Progression: 0.1},
+				{Title: "C", Level: 1, Progression: 0.6},
+			},
+			want: []span{
+				{"A", 1, 0.3, 0.6, 0, 0},
+				{"B", 1, 0.3, 0.6, 0, 0},
+				{"C", 1, 0.6, 1, 0, 0},
+			},
+		},
+		{
+			name: "missing level and padded title",
+			toc: []BookTOCEntry{
+				{Title: "  Preface ", Progression: 0},
+				{Title: "Body", Level: 1, Progression: 0.25},
+			},
+			want: []span{
+				{"Preface", 1, 0, 0.25, 0, 0},
+				{"Body", 1, 0.25, 1, 0, 0},
+			},
+		},
+		{
+			name: "page past the end is kept on the last page",
+			toc: []BookTOCEntry{
+				{Title: "Start", Level: 1, Page: 1},
+				{Title: "Index", Level: 1, Page: 12},
+			},
+			bookPages: 10,
+			want: []span{
+				{"Start", 1, 0, 1, 1, 10},
+				{"Index", 1, 1, 1, 10, 10},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildChapters(7, tt.toc, tt.bookPages)
+			if len(got) != len(tt.want) {
+				t.Fatalf("buildChapters() returned %d chapters, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				want := tt.want[i]
+				if c.BookID != 7 || c.Position != i+1 {
+					t.Errorf("chapter %d: book %d position %d, want book 7 position %d", i, c.BookID, c.Position, i+1)
+				}
+				if c.Title != want.title || c.Level != want.level ||
+					math.Abs(c.StartProgression-want.start) > 1e-9 || math.Abs(c.EndProgression-want.end) > 1e-9 ||
+					c.StartPage != want.startPage || c.EndPage != want.endPage {
+					t.Errorf("chapter %d = %q L%d %.2f-%.2f p%d-%d, want %q L%d %.2f-%.2f p%d-%d", i,
+						c.Title, c.Level, c.StartProgression, c.EndProgression, c.StartPage, c.EndPage,
+						want.title, want.level, want.start, want.end, want.startPage, want.endPage)
+				}
+			}
+		})
+	}
+}
+
+func TestChapterAt(t *testing.T) {
+	chapter := func(position int, href string, start, end float64) models.BookChapter {
+		return models.BookChapter{Position: position, Href: href, StartProgression: start, EndProgression: end}
+	}
+	pdf := []models.BookChapter{
+		chapter(1, "", 0.05, 0.2),
+		chapter(2, "", 0.2, 0.6),
+		chapter(3, "", 0.4, 0.6), // nested in 2
+		chapter(4, "", 0.6, 1),
+	}
+	epub := []models.BookChapter{
+		chapter(1, "Text/part1.xhtml", 0, 0.5),
+		chapter(2, "Text/part1.xhtml#c1", 0, 0.5),
+		chapter(3, "Text/ch2.xhtml", 0.5, 1),
+	}
+
+	tests := []struct {
+		name      string
+		chapters  []models.BookChapter
+		locator   *models.Locator
+		bookPages int
+		want      int // position, 0 for none
+	}{
+		{name: "no locator", chapters: pdf, want: 0},
+		{name: "no chapters", locator: &models.Locator{Page: 3}, bookPages: 100, want: 0},
+		{name: "front matter", chapters: pdf, locator: &models.Locator{Page: 2}, bookPages: 100, want: 0},
+		{name: "first page of a chapter", chapters: pdf, locator: &models.Locator{Page: 21}, bookPages: 100, want: 2},
+		{name: "innermost chapter", chapters: pdf, locator: &models.Locator{Page: 45}, bookPages: 100, want: 3},
+		{name: "last page", chapters: pdf, locator: &models.Locator{Page: 100}, bookPages: 100, want: 4},
+		{name: "progression without a page", chapters: pdf, locator: &models.Locator{TotalProgression: 0.65}, want: 4},
+		{
+			name:     "fragment entries sharing a start are passed over",
+			chapters: epub,
+			locator:  &models.Locator{Href: "OEBPS/Text/part1.xhtml", TotalProgression: 0.1},
+			want:     1,
+		},
+		{
+			name:     "document narrows a progression estimate that is off",
+			chapters: epub,
+			locator:  &models.Locator{Href: "Text/part1.xhtml", TotalProgression: 0.7},
+			want:     1,
+		},
+		{
+			name:     "estimate before the document's chapter",
+			chapters: epub,
+			locator:  &models.Locator{Href: "/ch2.xhtml", TotalProgression: 0.3},
+			want:     3,
+		},
+		{
+			name:     "unknown document falls back to progression",
+			chapters: epub,
+			locator:  &models.Locator{Href: "notes.xhtml", TotalProgression: 0.8},
+			want:     3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			if c := chapterAt(tt.chapters, tt.locator, tt.bookPages); c != nil {
+				got = c.Position
+			}
+			if got != tt.want {
+				t.Errorf("chapterAt() = chapter %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
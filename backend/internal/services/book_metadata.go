@@ -0,0 +1,100 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"readagain/internal/models"
+)
+
+// BookFileMetadata is the metadata document upload-api returns for an
+// uploaded EPUB or PDF. Admins send it back with the new book so anything
+// they left blank is taken from the file.
+type BookFileMetadata struct {
+	Format             string         `json:"format" validate:"omitempty,oneof=epub pdf"`
+	Title              string         `json:"title"`
+	Subtitle           string         `json:"subtitle"`
+	Authors            []string       `json:"authors"`
+	Publisher          string         `json:"publisher"`
+	Language           string         `json:"language"`
+	ISBN               string         `json:"isbn"`
+	Description        string         `json:"description"`
+	Subjects           []string       `json:"subjects"`
+	PublishedAt        *time.Time     `json:"published_at"`
+	PageCount          int            `json:"page_count" validate:"gte=0"`
+	PageCountEstimated bool           `json:"page_count_estimated"`
+	TOC                []BookTOCEntry `json:"toc" validate:"max=5000,dive"`
+	Cover              *struct {
+		Path string `json:"path"`
+	} `json:"cover"`
+}
+
+// BookTOCEntry is one table of contents entry from the book file. EPUB
+// entries point at a content document, PDF entries at a 1-based page;
+// Progression is the share of the book before the entry.
+type BookTOCEntry struct {
+	Title       string  `json:"title" validate:"required,max=500"`
+	Level       int     `json:"level" validate:"gte=1"`
+	Href        string  `json:"href" validate:"max=500"`
+	SpineIndex  *int    `json:"spine_index"`
+	Page        int     `json:"page" validate:"gte=0"`
+	Progression float64 `json:"progression" validate:"gte=0,lte=1"`
+}
+
+// languageNames maps the language tags found in book files to the names
+// Book.Language uses. Unknown tags are kept as they are.
+var languageNames = map[string]string{
+	"en": "English",
+	"fr": "French",
+	"es": "Spanish",
+	"pt": "Portuguese",
+	"de": "German",
+	"it": "Italian",
+	"ar": "Arabic",
+	"zh": "Chinese",
+	"sw": "Swahili",
+	"yo": "Yoruba",
+	"ha": "Hausa",
+	"ig": "Igbo",
+}
+
+func languageName(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	if name, ok := languageNames[base]; ok {
+		return name
+	}
+	return strings.TrimSpace(tag)
+}
+
+// fill copies metadata into the fields of book that are still empty.
+func (m *BookFileMetadata) fill(book *models.Book) {
+	if m == nil {
+		return
+	}
+	if book.Title == "" {
+		book.Title = m.Title
+	}
+	if book.Subtitle == "" {
+		book.Subtitle = m.Subtitle
+	}
+	if book.Description == "" {
+		book.Description = m.Description
+	}
+	if book.ISBN == "" {
+		book.ISBN = m.ISBN
+	}
+	if book.CoverImage == "" && m.Cover != nil {
+		book.CoverImage = m.Cover.Path
+	}
+	if book.Pages == 0 {
+		book.Pages = m.PageCount
+	}
+	if book.Publisher == "" {
+		book.Publisher = m.Publisher
+	}
+	if book.Language == "" && m.Language != "" {
+		book.Language = languageName(m.Language)
+	}
+	// PublishedAt is left out: PublicationDate is when the book went live
+	// here, which new releases are sorted by
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/queryspec"
+	"readagain/internal/utils"
+)
+
+// bookSearchVectorSQL weights title (A) over subtitle and author name (B)
+// over description and SEO keywords (C). It expects books joined to authors.
+const bookSearchVectorSQL = `
+	setweight(to_tsvector('english', COALESCE(books.title, '')), 'A') ||
+	setweight(to_tsvector('english', COALESCE(books.subtitle, '') || ' ' || COALESCE(authors.business_name, '')), 'B') ||
+	setweight(to_tsvector('english', COALESCE(books.description, '') || ' ' || COALESCE(books.seo_keywords, '')), 'C')`
+
+// syncBookSearchVector recomputes search_vector for the books matching where.
+func syncBookSearchVector(db *gorm.DB, where string, args ...interface{}) error {
+	return db.Exec(`
+		UPDATE books SET search_vector = `+bookSearchVectorSQL+`
+		FROM authors
+		WHERE authors.id = books.author_id AND `+where, args...).Error
+}
+
+// RebuildSearchIndex recomputes the search vector of every book, for
+// backfilling after the column is added or the weighting changes.
+func (s *BookService) RebuildSearchIndex() error {
+	if err := syncBookSearchVector(s.db, "books.deleted_at IS NULL"); err != nil {
+		return utils.NewInternalServerError("Failed to rebuild book search index", err)
+	}
+	return nil
+}
+
+// prefixTSQuery turns free text into a to_tsquery expression that requires
+// every word and treats each one as a prefix ("harr pot" -> "harr:* & pot:*").
+// It returns "" when the input has no searchable words.
+func prefixTSQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+type FacetCount struct {
+	ID    uint   `json:"id,omitempty"`
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type BookFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Languages  []FacetCount `json:"languages"`
+	Authors    []FacetCount `json:"authors"`
+}
+
+const maxFacetValues = 20
+
+// GetFacets counts the books matching the search and filters by category,
+// language and author, ignoring pagination and sort.
+func (s *BookService) GetFacets(search string, q *queryspec.Query) (*BookFacets, error) {
+	facets := &BookFacets{
+		Categories: []FacetCount{},
+		Languages:  []FacetCount{},
+		Authors:    []FacetCount{},
+	}
+
+	base := func() *gorm.DB {
+		return s.db.Model(&models.Book{}).Scopes(bookFilterScope(search, q))
+	}
+
+	if err := base().
+		Joins("JOIN categories ON categories.id = books.category_id").
+		Select("categories.id AS id, categories.name AS value, COUNT(*) AS count").
+		Group("categories.id, categories.name").
+		Order("count DESC").Limit(maxFacetValues).
+		Scan(&facets.Categories).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to count category facets", err)
+	}
+
+	if err := base().
+		Select("books.language AS value, COUNT(*) AS count").
+		Where("books.language <> ''").
+		Group("books.language").
+		Order("count DESC").Limit(maxFacetValues).
+		Scan(&facets.Languages).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to count language facets", err)
+	}
+
+	if err := base().
+		Joins("JOIN authors ON authors.id = books.author_id").
+		Select("authors.id AS id, authors.business_name AS value, COUNT(*) AS count").
+		Group("authors.id, authors.business_name").
+		Order("count DESC").Limit(maxFacetValues).
+		Scan(&facets.Authors).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to count author facets", err)
+	}
+
+	return facets, nil
+}
+
+type BookSuggestion struct {
+	Type       string `json:"type"` // book, author
+	ID         uint   `json:"id"`
+	Text       string `json:"text"`
+	CoverImage string `json:"cover_image,omitempty"`
+}
+
+// Suggest returns published titles and author names for a search box.
+// Prefix matches rank first; trigram similarity catches misspellings.
+func (s *BookService) Suggest(q string, limit int) ([]BookSuggestion, error) {
+	q = strings.TrimSpace(q)
+	suggestions := []BookSuggestion{}
+	if len([]rune(q)) < 2 {
+		return suggestions, nil
+	}
+	if limit < 1 || limit > 20 {
+		limit = 8
+	}
+
+	tsquery := prefixTSQuery(q)
+	var books []BookSuggestion
+	if err := s.db.Raw(`
+		SELECT 'book' AS type, id, title AS text, cover_image
+		FROM books
+		WHERE deleted_at IS NULL AND is_active = true AND status = 'published'
+			AND (title ILIKE ? OR (? <> '' AND search_vector @@ to_tsquery('english', ?)) OR similarity(title, ?) > 0.3)
+		ORDER BY (title ILIKE ?) DESC, similarity(title, ?) DESC, view_count DESC
+		LIMIT ?`,
+		q+"%", tsquery, tsquery, q, q+"%", q, limit).Scan(&books).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch book suggestions", err)
+	}
+
+	var authors []BookSuggestion
+	if err := s.db.Raw(`
+		SELECT 'author' AS type, id, business_name AS text
+		FROM authors
+		WHERE deleted_at IS NULL AND status = 'active'
+			AND (business_name ILIKE ? OR business_name ILIKE ? OR similarity(business_name, ?) > 0.3)
+		ORDER BY (business_name ILIKE ?) DESC, similarity(business_name, ?) DESC
+		LIMIT ?`,
+		q+"%", "% "+q+"%", q, q+"%", q, limit/2+1).Scan(&authors).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch author suggestions", err)
+	}
+
+	suggestions = append(suggestions, books...)
+	suggestions = append(suggestions, authors...)
+	return suggestions, nil
+}
@@ -1,11 +1,14 @@
 package services
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"readagain/internal/models"
+	"readagain/internal/queryspec"
 	"readagain/internal/utils"
 )
 
@@ -49,56 +52,65 @@ func (s *BookService) GetStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
-type BookFilters struct {
-	Search     string
-	CategoryID uint
-	AuthorID   uint
-	IsFeatured *bool
-	Status     string
-	SortBy     string
-	SortOrder  string
+// BookListSpec whitelists the sort and filter parameters of book listings.
+var BookListSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"relevance":        "",
+		"created_at":       "books.created_at",
+		"title":            "books.title",
+		"publication_date": "books.publication_date",
+		"view_count":       "books.view_count",
+		"library_count":    "books.library_count",
+		"pages":            "books.pages",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"category_id":    {Column: "books.category_id", Type: queryspec.Uint},
+		"author_id":      {Column: "books.author_id", Type: queryspec.Uint},
+		"language":       {Column: "books.language", Type: queryspec.String},
+		"status":         {Column: "books.status", Type: queryspec.String},
+		"is_featured":    {Column: "books.is_featured", Type: queryspec.Bool},
+		"is_bestseller":  {Column: "books.is_bestseller", Type: queryspec.Bool},
+		"is_new_release": {Column: "books.is_new_release", Type: queryspec.Bool},
+	},
+	Params: []string{"search"},
 }
 
-func (s *BookService) ListBooks(page, limit int, filters BookFilters) ([]models.Book, *utils.PaginationMeta, error) {
+// bookFilterScope applies the search and every filter but not the sort, so
+// listing and facet counts see the same set of books.
+func bookFilterScope(search string, q *queryspec.Query) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if search != "" {
+			tsquery := prefixTSQuery(search)
+			db = db.Where("((? <> '' AND books.search_vector @@ to_tsquery('english', ?)) OR books.isbn = ?)", tsquery, tsquery, strings.TrimSpace(search))
+		}
+		return q.Where(db)
+	}
+}
+
+func (s *BookService) ListBooks(page, limit int, search string, q *queryspec.Query) ([]models.Book, *utils.PaginationMeta, error) {
 	params := utils.GetPaginationParams(page, limit)
 
 	query := s.db.Model(&models.Book{}).
 		Preload("Category").
 		Preload("Author").
-		Preload("Author.User")
-
-	if filters.Search != "" {
-		query = query.Where("title ILIKE ? OR description ILIKE ?", "%"+filters.Search+"%", "%"+filters.Search+"%")
-	}
-
-	if filters.CategoryID > 0 {
-		query = query.Where("category_id = ?", filters.CategoryID)
-	}
-
-	if filters.AuthorID > 0 {
-		query = query.Where("author_id = ?", filters.AuthorID)
-	}
-
-	if filters.IsFeatured != nil {
-		query = query.Where("is_featured = ?", *filters.IsFeatured)
-	}
-
-	if filters.Status != "" {
-		query = query.Where("status = ?", filters.Status)
-	}
-
-	sortBy := "created_at"
-	if filters.SortBy != "" {
-		sortBy = filters.SortBy
-	}
-
-	sortOrder := "DESC"
-	if filters.SortOrder == "asc" {
-		sortOrder = "ASC"
+		Preload("Author.User").
+		Scopes(bookFilterScope(search, q))
+
+	// Searches rank by relevance unless the caller asked for a specific order
+	switch {
+	case search != "" && (!q.Explicit || q.Sort == "relevance"):
+		query = query.Order(clause.Expr{
+			SQL:  "ts_rank_cd(books.search_vector, to_tsquery('english', ?)) DESC, books.view_count DESC",
+			Vars: []interface{}{prefixTSQuery(search)},
+		})
+	case q.Sort == "relevance":
+		query = query.Order("books.created_at DESC")
+	default:
+		query = query.Scopes(q.OrderBy)
 	}
 
-	query = query.Order(sortBy + " " + sortOrder)
-
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
 		return nil, nil, utils.NewInternalServerError("Failed to count books", err)
@@ -125,23 +137,34 @@ func (s *BookService) GetBookByID(bookID uint) (*models.Book, error) {
 	return &book, nil
 }
 
-func (s *BookService) CreateBook(authorID uint, title, description, isbn string, categoryID uint, coverImage, fileURL string, fileSize int64, pageCount int, status string) (*models.Book, error) {
+// CreateBook creates a book; metadata extracted from the uploaded file, if
+// given, fills in whatever the admin left blank and provides the chapters.
+func (s *BookService) CreateBook(authorID uint, title, description, isbn string, categoryID uint, coverImage, fileURL string, fileSize int64, pageCount int, status string, level models.ReadingLevel, metadata *BookFileMetadata) (*models.Book, error) {
+	if err := checkReadingLevel(level); err != nil {
+		return nil, err
+	}
+
 	var catID *uint
 	if categoryID > 0 {
 		catID = &categoryID
 	}
 
 	book := models.Book{
-		AuthorID:    authorID,
-		Title:       title,
-		Description: description,
-		ISBN:        isbn,
-		CategoryID:  catID,
-		CoverImage:  coverImage,
-		FilePath:    fileURL,
-		FileSize:    fileSize,
-		Pages:       pageCount,
-		Status:      status,
+		AuthorID:     authorID,
+		Title:        title,
+		Description:  description,
+		ISBN:         isbn,
+		CategoryID:   catID,
+		CoverImage:   coverImage,
+		FilePath:     fileURL,
+		FileSize:     fileSize,
+		Pages:        pageCount,
+		Status:       status,
+		ReadingLevel: level,
+	}
+	metadata.fill(&book)
+	if book.Title == "" {
+		return nil, utils.NewBadRequestError("Title is required")
 	}
 
 	if status == "published" {
@@ -153,6 +176,16 @@ func (s *BookService) CreateBook(authorID uint, title, description, isbn string,
 		return nil, utils.NewInternalServerError("Failed to create book", err)
 	}
 
+	if err := syncBookSearchVector(s.db, "books.id = ?", book.ID); err != nil {
+		utils.ErrorLogger.Printf("Failed to index book %d for search: %v", book.ID, err)
+	}
+
+	if metadata != nil && len(metadata.TOC) > 0 {
+		if _, err := saveChapters(s.db, book.ID, metadata.TOC, book.Pages); err != nil {
+			utils.ErrorLogger.Printf("Failed to save chapters of book %d: %v", book.ID, err)
+		}
+	}
+
 	if err := s.db.Preload("Category").Preload("Author").Preload("Author.User").First(&book, book.ID).Error; err != nil {
 		return nil, utils.NewNotFoundError("Book not found")
 	}
@@ -166,6 +199,16 @@ func (s *BookService) UpdateBook(bookID uint, updates map[string]interface{}) (*
 		return nil, utils.NewNotFoundError("Book not found")
 	}
 
+	if level, ok := updates["reading_level"].(models.ReadingLevel); ok {
+		if err := checkReadingLevel(level); err != nil {
+			return nil, err
+		}
+		delete(updates, "reading_level")
+		updates["reading_grade_min"] = level.GradeMin
+		updates["reading_grade_max"] = level.GradeMax
+		updates["reading_score"] = level.Score
+	}
+
 	if status, ok := updates["status"].(string); ok && status == "published" && book.PublicationDate == nil {
 		now := time.Now()
 		updates["publication_date"] = now
@@ -175,6 +218,10 @@ func (s *BookService) UpdateBook(bookID uint, updates map[string]interface{}) (*
 		return nil, utils.NewInternalServerError("Failed to update book", err)
 	}
 
+	if err := syncBookSearchVector(s.db, "books.id = ?", bookID); err != nil {
+		utils.ErrorLogger.Printf("Failed to index book %d for search: %v", bookID, err)
+	}
+
 	if err := s.db.Preload("Category").Preload("Author").Preload("Author.User").First(&book, bookID).Error; err != nil {
 		return nil, utils.NewNotFoundError("Book not found")
 	}
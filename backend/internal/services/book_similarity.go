@@ -0,0 +1,369 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	RecommendSimilar = "similar" // close to books they enjoyed
+
+	similarityRebuildInterval = 6 * time.Hour
+	similarNeighbours         = 20  // stored per book
+	similarMinCoReaders       = 2   // fewer shared readers is coincidence
+	similarShrinkage          = 5.0 // damps scores backed by few readers
+	similarMaxBooksPerUser    = 200 // bounds the pairs one reader contributes
+)
+
+// SimilarBook is a neighbour of a book, for "readers who finished this also
+// enjoyed".
+type SimilarBook struct {
+	Book      models.Book `json:"book"`
+	Score     float64     `json:"score"`
+	CoReaders int         `json:"co_readers"`
+}
+
+// interaction is how much one reader engaged with one book.
+type interaction struct {
+	UserID    uint
+	BookID    uint
+	Progress  float64
+	Completed bool
+	Rating    *float64
+	Seconds   int64
+}
+
+// weight scores an interaction: how far they got, up to half as much again
+// for time spent (reaching it at five hours), scaled by their rating where
+// they left one so a one-star finish counts for little.
+func (i interaction) weight() float64 {
+	w := i.Progress / 100
+	if i.Completed {
+		w = 1
+	}
+	w += 0.5 * math.Min(1, math.Log1p(float64(i.Seconds)/60)/math.Log1p(300))
+	if i.Rating != nil {
+		w *= *i.Rating / 3
+	}
+	return w
+}
+
+// interactions loads reading activity on published books, for one reader
+// or, with a nil userID, for everyone.
+func (s *RecommendationService) interactions(userID *uint) ([]interaction, error) {
+	userFilter := ""
+	args := []interface{}{}
+	if userID != nil {
+		userFilter = "AND ul.user_id = ?"
+		args = append(args, *userID)
+	}
+
+	var rows []interaction
+	err := s.db.Raw(`
+		SELECT ul.user_id, ul.book_id, ul.progress,
+		       ul.completed_at IS NOT NULL AS completed,
+		       r.rating,
+		       COALESCE(rs.seconds, 0) AS seconds
+		FROM user_libraries ul
+		JOIN books b ON b.id = ul.book_id AND b.deleted_at IS NULL
+		     AND b.status = 'published' AND b.is_active
+		LEFT JOIN (
+			SELECT user_id, book_id, AVG(rating) AS rating
+			FROM reviews
+			WHERE status = 'approved' AND deleted_at IS NULL
+			GROUP BY user_id, book_id
+		) r ON r.user_id = ul.user_id AND r.book_id = ul.book_id
+		LEFT JOIN (
+			SELECT user_id, book_id, SUM(duration) AS seconds
+			FROM reading_sessions
+			WHERE deleted_at IS NULL
+			GROUP BY user_id, book_id
+		) rs ON rs.user_id = ul.user_id AND rs.book_id = ul.book_id
+		WHERE ul.deleted_at IS NULL `+userFilter+`
+		  AND (ul.progress > 0 OR r.rating IS NOT NULL OR rs.seconds > 0)
+	`, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// RebuildSimilarities recomputes every book's nearest neighbours and
+// replaces the stored ones. It returns the number of books given neighbours.
+func (s *RecommendationService) RebuildSimilarities() (int, error) {
+	rows, err := s.interactions(nil)
+	if err != nil {
+		return 0, err
+	}
+	similarities, books := computeSimilarities(rows)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("1 = 1").Delete(&models.BookSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(similarities) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&similarities, 500).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return books, nil
+}
+
+// computeSimilarities ranks each book's nearest neighbours: cosine
+// similarity between books' reader vectors, damped when few readers
+// overlap. It also returns the number of books given neighbours.
+func computeSimilarities(rows []interaction) ([]models.BookSimilarity, int) {
+	type weighted struct {
+		bookID uint
+		weight float64
+	}
+	byUser := map[uint][]weighted{}
+	norms := map[uint]float64{}
+	for _, row := range rows {
+		w := row.weight()
+		if w <= 0 {
+			continue
+		}
+		byUser[row.UserID] = append(byUser[row.UserID], weighted{row.BookID, w})
+		norms[row.BookID] += w * w
+	}
+
+	type pair struct{ a, b uint }
+	type overlap struct {
+		dot     float64
+		readers int
+	}
+	pairs := map[pair]*overlap{}
+	for _, books := range byUser {
+		if len(books) > similarMaxBooksPerUser {
+			sort.Slice(books, func(i, j int) bool { return books[i].weight > books[j].weight })
+			books = books[:similarMaxBooksPerUser]
+		}
+		for i := 0; i < len(books); i++ {
+			for j := i + 1; j < len(books); j++ {
+				key := pair{books[i].bookID, books[j].bookID}
+				if key.a > key.b {
+					key.a, key.b = key.b, key.a
+				}
+				o := pairs[key]
+				if o == nil {
+					o = &overlap{}
+					pairs[key] = o
+				}
+				o.dot += books[i].weight * books[j].weight
+				o.readers++
+			}
+		}
+	}
+
+	neighbours := map[uint][]models.BookSimilarity{}
+	for key, o := range pairs {
+		if o.readers < similarMinCoReaders {
+			continue
+		}
+		score := o.dot / math.Sqrt(norms[key.a]*norms[key.b])
+		score *= float64(o.readers) / (float64(o.readers) + similarShrinkage)
+		score = math.Round(score*10000) / 10000
+		neighbours[key.a] = append(neighbours[key.a], models.BookSimilarity{BookID: key.a, SimilarBookID: key.b, Score: score, CoReaders: o.readers})
+		neighbours[key.b] = append(neighbours[key.b], models.BookSimilarity{BookID: key.b, SimilarBookID: key.a, Score: score, CoReaders: o.readers})
+	}
+
+	var similarities []models.BookSimilarity
+	for _, list := range neighbours {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].CoReaders > list[j].CoReaders
+		})
+		if len(list) > similarNeighbours {
+			list = list[:similarNeighbours]
+		}
+		for i := range list {
+			list[i].Rank = i + 1
+		}
+		similarities = append(similarities, list...)
+	}
+	return similarities, len(neighbours)
+}
+
+// RunSimilarityWorker rebuilds the neighbours on startup and then every
+// similarityRebuildInterval until the process exits.
+func (s *RecommendationService) RunSimilarityWorker() {
+	rebuild := func() {
+		started := time.Now()
+		books, err := s.RebuildSimilarities()
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to rebuild book similarities: %v", err)
+			return
+		}
+		utils.InfoLogger.Printf("Rebuilt similarities for %d books in %s", books, time.Since(started).Round(time.Millisecond))
+	}
+
+	rebuild()
+	ticker := time.NewTicker(similarityRebuildInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rebuild()
+	}
+}
+
+// GetSimilarBooks returns the book's stored neighbours that are still
+// published. Until it has any, popular books from its category stand in.
+func (s *RecommendationService) GetSimilarBooks(bookID uint, limit int) ([]SimilarBook, error) {
+	if limit <= 0 || limit > similarNeighbours {
+		limit = 10
+	}
+
+	var book models.Book
+	if err := s.db.Select("id", "category_id").First(&book, bookID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Book not found")
+	}
+
+	var neighbours []models.BookSimilarity
+	if err := s.db.
+		Joins("JOIN books ON books.id = book_similarities.similar_book_id AND books.deleted_at IS NULL").
+		Where("book_similarities.book_id = ? AND books.status = ? AND books.is_active = ?", bookID, "published", true).
+		Preload("SimilarBook.Category").
+		Preload("SimilarBook.Author.User").
+		Order("book_similarities.rank").
+		Limit(limit).
+		Find(&neighbours).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch similar books", err)
+	}
+
+	result := make([]SimilarBook, 0, limit)
+	for _, n := range neighbours {
+		if n.SimilarBook != nil {
+			result = append(result, SimilarBook{Book: *n.SimilarBook, Score: n.Score, CoReaders: n.CoReaders})
+		}
+	}
+	if len(result) > 0 || book.CategoryID == nil {
+		return result, nil
+	}
+
+	var books []models.Book
+	if err := s.db.Model(&models.Book{}).
+		Preload("Category").
+		Preload("Author").
+		Preload("Author.User").
+		Where("books.category_id = ? AND books.id <> ? AND books.status = ? AND books.is_active = ?", *book.CategoryID, bookID, "published", true).
+		Order("books.library_count DESC").
+		Limit(limit).
+		Find(&books).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch similar books", err)
+	}
+	for _, b := range books {
+		result = append(result, SimilarBook{Book: b})
+	}
+	return result, nil
+}
+
+// GetPicks personalises recommendations for the dashboard: books close to
+// the ones the reader enjoyed, weighted by how much they enjoyed them. The
+// level-matched recommendations make up the rest.
+func (s *RecommendationService) GetPicks(userID uint, limit int) ([]RecommendedBook, error) {
+	if limit <= 0 || limit > maxRecommendations {
+		limit = 10
+	}
+
+	rows, err := s.interactions(&userID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch picks", err)
+	}
+	weights := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		if w := row.weight(); w > 0 {
+			weights[row.BookID] = w
+		}
+	}
+
+	picks := []RecommendedBook{}
+	seen := map[uint]bool{}
+	if len(weights) > 0 {
+		readIDs := make([]uint, 0, len(weights))
+		for id := range weights {
+			readIDs = append(readIDs, id)
+		}
+
+		var neighbours []models.BookSimilarity
+		if err := s.db.Where("book_id IN ? AND similar_book_id NOT IN (?)", readIDs, s.libraryBooks(userID)).
+			Find(&neighbours).Error; err != nil {
+			return nil, utils.NewInternalServerError("Failed to fetch picks", err)
+		}
+
+		// Score each candidate, remembering the read book that did most for it
+		scores := map[uint]float64{}
+		because := map[uint]uint{}
+		best := map[uint]float64{}
+		for _, n := range neighbours {
+			contribution := weights[n.BookID] * n.Score
+			scores[n.SimilarBookID] += contribution
+			if contribution > best[n.SimilarBookID] {
+				best[n.SimilarBookID] = contribution
+				because[n.SimilarBookID] = n.BookID
+			}
+		}
+		candidates := make([]uint, 0, len(scores))
+		for id := range scores {
+			candidates = append(candidates, id)
+		}
+		sort.Slice(candidates, func(i, j int) bool { return scores[candidates[i]] > scores[candidates[j]] })
+		if len(candidates) > limit*2 {
+			candidates = candidates[:limit*2]
+		}
+
+		var books []models.Book
+		if len(candidates) > 0 {
+			if err := s.unread(userID).Where("books.id IN ?", candidates).Find(&books).Error; err != nil {
+				return nil, utils.NewInternalServerError("Failed to fetch picks", err)
+			}
+		}
+		titles := map[uint]string{}
+		if len(books) > 0 {
+			var read []models.Book
+			s.db.Select("id", "title").Where("id IN ?", readIDs).Find(&read)
+			for _, b := range read {
+				titles[b.ID] = b.Title
+			}
+		}
+
+		byID := make(map[uint]models.Book, len(books))
+		for _, book := range books {
+			byID[book.ID] = book
+		}
+		for _, id := range candidates {
+			if len(picks) == limit {
+				break
+			}
+			book, ok := byID[id]
+			if !ok {
+				continue
+			}
+			picks = append(picks, RecommendedBook{Book: book, Reason: RecommendSimilar, BecauseOf: titles[because[id]]})
+			seen[id] = true
+		}
+	}
+
+	if len(picks) < limit {
+		more, _, err := s.GetRecommended(userID, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range more {
+			if !seen[rec.Book.ID] && len(picks) < limit {
+				picks = append(picks, rec)
+				seen[rec.Book.ID] = true
+			}
+		}
+	}
+
+	return picks, nil
+}
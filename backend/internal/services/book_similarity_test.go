@@ -0,0 +1,157 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestInteractionWeight(t *testing.T) {
+	rating := func(r float64) *float64 { return &r }
+
+	tests := []struct {
+		name string
+		in   interaction
+		want float64
+	}{
+		{name: "nothing", in: interaction{}, want: 0},
+		{name: "half read", in: interaction{Progress: 50}, want: 0.5},
+		{name: "completed", in: interaction{Progress: 80, Completed: true}, want: 1},
+		{name: "five hours adds half", in: interaction{Completed: true, Seconds: 5 * 60 * 60}, want: 1.5},
+		{name: "time bonus is capped", in: interaction{Completed: true, Seconds: 50 * 60 * 60}, want: 1.5},
+		{name: "low rating scales down", in: interaction{Completed: true, Rating: rating(1.5)}, want: 0.5},
+		{name: "high rating scales up", in: interaction{Progress: 50, Rating: rating(4.5)}, want: 0.75},
+		{name: "rating alone", in: interaction{Rating: rating(5)}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.weight(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("weight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeSimilarities(t *testing.T) {
+	// read gives one reader finishing each of the books, for a weight of 1
+	read := func(user uint, books ...uint) []interaction {
+		rows := make([]interaction, len(books))
+		for i, book := range books {
+			rows[i] = interaction{UserID: user, BookID: book, Completed: true}
+		}
+		return rows
+	}
+	join := func(parts ...[]interaction) []interaction {
+		var rows []interaction
+		for _, p := range parts {
+			rows = append(rows, p...)
+		}
+		return rows
+	}
+
+	type neighbour struct {
+		book, similar uint
+		rank          int
+		score         float64
+		coReaders     int
+	}
+
+	tests := []struct {
+		name  string
+		rows  []interaction
+		want  []neighbour // by book, then rank
+		books int
+	}{
+		{name: "no reading", rows: nil, want: []neighbour{}, books: 0},
+		{
+			name: "one shared reader is coincidence",
+			rows: join(read(1, 1, 2), read(2, 1, 3)),
+			want: []neighbour{},
+		},
+		{
+			// cos = 2/sqrt(3*2), damped by 2/(2+5)
+			name: "pair read together twice",
+			rows: join(read(1, 1, 2), read(2, 1, 2), read(3, 1, 3)),
+			want: []neighbour{
+				{1, 2, 1, 0.2333, 2},
+				{2, 1, 1, 0.2333, 2},
+			},
+			books: 2,
+		},
+		{
+			// Reader 3 opening book 2 makes no third co-reader
+			name: "readers who only opened a book don't count",
+			rows: join(read(1, 1, 2), read(2, 1, 2), []interaction{{UserID: 3, BookID: 2}}, read(3, 1)),
+			want: []neighbour{
+				{1, 2, 1, 0.2333, 2},
+				{2, 1, 1, 0.2333, 2},
+			},
+			books: 2,
+		},
+		{
+			// 1-2: cos = 4/sqrt(6*4) * 4/9; 1-3: cos = 2/sqrt(6*2) * 2/7
+			name: "neighbours ranked by score",
+			rows: join(read(1, 1, 2), read(2, 1, 2), read(3, 1, 2), read(4, 1, 2), read(5, 1, 3), read(6, 1, 3)),
+			want: []neighbour{
+				{1, 2, 1, 0.3629, 4},
+				{1, 3, 2, 0.165, 2},
+				{2, 1, 1, 0.3629, 4},
+				{3, 1, 1, 0.165, 2},
+			},
+			books: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similarities, books := computeSimilarities(tt.rows)
+			got := make([]neighbour, len(similarities))
+			for i, s := range similarities {
+				got[i] = neighbour{s.BookID, s.SimilarBookID, s.Rank, s.Score, s.CoReaders}
+			}
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].book != got[j].book {
+					return got[i].book < got[j].book
+				}
+				return got[i].rank < got[j].rank
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("computeSimilarities() = %+v, want %+v", got, tt.want)
+			}
+			if books != tt.books {
+				t.Errorf("computeSimilarities() books = %d, want %d", books, tt.books)
+			}
+		})
+	}
+}
+
+func TestComputeSimilaritiesKeepsNearest(t *testing.T) {
+	// Book 1 is read alongside books 2 to 26 by two readers each
+	var rows []interaction
+	for book := uint(2); book <= similarNeighbours+6; book++ {
+		for _, user := range []uint{book * 10, book*10 + 1} {
+			rows = append(rows,
+				interaction{UserID: user, BookID: 1, Completed: true},
+				interaction{UserID: user, BookID: book, Completed: true})
+		}
+	}
+
+	similarities, books := computeSimilarities(rows)
+	if books != similarNeighbours+6 {
+		t.Errorf("computeSimilarities() books = %d, want %d", books, similarNeighbours+6)
+	}
+	ranks := 0
+	for _, s := range similarities {
+		if s.BookID == 1 {
+			ranks++
+			if s.Rank < 1 || s.Rank > similarNeighbours {
+				t.Errorf("book 1 neighbour %d has rank %d", s.SimilarBookID, s.Rank)
+			}
+		}
+	}
+	if ranks != similarNeighbours {
+		t.Errorf("book 1 has %d neighbours, want %d", ranks, similarNeighbours)
+	}
+}
@@ -3,42 +3,69 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"readagain/internal/utils"
 )
 
+// ErrCacheMiss is returned by Get when the key is not cached.
+var ErrCacheMiss = errors.New("cache miss")
+
 type CacheService struct {
 	client *redis.Client
+	memory *memoryStore
 	ctx    context.Context
 }
 
+// NewCacheService connects to Redis when redisURL is set and reachable, and
+// otherwise falls back to an in-process store so callers never get a nil cache.
 func NewCacheService(redisURL string) *CacheService {
-	opt, err := redis.ParseURL(redisURL)
-	if err != nil {
-		return nil
-	}
-
-	client := redis.NewClient(opt)
 	ctx := context.Background()
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil
+	if redisURL != "" {
+		opt, err := redis.ParseURL(redisURL)
+		if err == nil {
+			client := redis.NewClient(opt)
+			if err := client.Ping(ctx).Err(); err == nil {
+				utils.InfoLogger.Println("Cache backend: redis")
+				return &CacheService{client: client, ctx: ctx}
+			}
+			client.Close()
+			utils.ErrorLogger.Printf("Redis unreachable, falling back to in-process cache: %v", err)
+		} else {
+			utils.ErrorLogger.Printf("Invalid REDIS_URL, falling back to in-process cache: %v", err)
+		}
 	}
 
-	return &CacheService{
-		client: client,
-		ctx:    ctx,
-	}
+	utils.InfoLogger.Println("Cache backend: in-process")
+	return &CacheService{memory: newMemoryStore(), ctx: ctx}
 }
 
 func (s *CacheService) Get(key string, dest interface{}) error {
-	val, err := s.client.Get(s.ctx, key).Result()
-	if err != nil {
-		return err
+	var data []byte
+	if s.client == nil {
+		val, ok := s.memory.get(key)
+		if !ok {
+			return ErrCacheMiss
+		}
+		data = val
+	} else {
+		val, err := s.client.Get(s.ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrCacheMiss
+		}
+		if err != nil {
+			return err
+		}
+		data = val
 	}
 
-	return json.Unmarshal([]byte(val), dest)
+	return json.Unmarshal(data, dest)
 }
 
 func (s *CacheService) Set(key string, value interface{}, ttl int) error {
@@ -47,14 +74,28 @@ func (s *CacheService) Set(key string, value interface{}, ttl int) error {
 		return err
 	}
 
+	if s.client == nil {
+		s.memory.set(key, data, time.Duration(ttl)*time.Second)
+		return nil
+	}
+
 	return s.client.Set(s.ctx, key, data, time.Duration(ttl)*time.Second).Err()
 }
 
 func (s *CacheService) Delete(key string) error {
+	if s.client == nil {
+		s.memory.delete(key)
+		return nil
+	}
 	return s.client.Del(s.ctx, key).Err()
 }
 
 func (s *CacheService) DeletePattern(pattern string) error {
+	if s.client == nil {
+		s.memory.deletePattern(pattern)
+		return nil
+	}
+
 	iter := s.client.Scan(s.ctx, 0, pattern, 0).Iterator()
 	for iter.Next(s.ctx) {
 		if err := s.client.Del(s.ctx, iter.Val()).Err(); err != nil {
@@ -65,6 +106,11 @@ func (s *CacheService) DeletePattern(pattern string) error {
 }
 
 func (s *CacheService) Exists(key string) (bool, error) {
+	if s.client == nil {
+		_, ok := s.memory.get(key)
+		return ok, nil
+	}
+
 	result, err := s.client.Exists(s.ctx, key).Result()
 	if err != nil {
 		return false, err
@@ -73,6 +119,13 @@ func (s *CacheService) Exists(key string) (bool, error) {
 }
 
 func (s *CacheService) GetStats() (map[string]interface{}, error) {
+	if s.client == nil {
+		return map[string]interface{}{
+			"backend": "memory",
+			"db_size": s.memory.size(),
+		}, nil
+	}
+
 	info, err := s.client.Info(s.ctx, "stats").Result()
 	if err != nil {
 		return nil, err
@@ -84,12 +137,17 @@ func (s *CacheService) GetStats() (map[string]interface{}, error) {
 	}
 
 	return map[string]interface{}{
+		"backend": "redis",
 		"info":    info,
 		"db_size": dbSize,
 	}, nil
 }
 
 func (s *CacheService) FlushAll() error {
+	if s.client == nil {
+		s.memory.flush()
+		return nil
+	}
 	return s.client.FlushAll(s.ctx).Err()
 }
 
@@ -122,5 +180,117 @@ func (s *CacheService) InvalidateSettings() error {
 }
 
 func (s *CacheService) InvalidateUserPermissions(userID uint) error {
-	return s.Delete("user:permissions:" + string(rune(userID)))
+	return s.Delete(utils.UserPermissionsKey(userID))
+}
+
+// InvalidateAllUserPermissions drops every cached permission set, used when a
+// role's permissions change and any number of users may be affected.
+func (s *CacheService) InvalidateAllUserPermissions() error {
+	return s.DeletePattern("user:permissions:*")
+}
+
+// RevokeToken marks a token as revoked until it would have expired anyway.
+func (s *CacheService) RevokeToken(token string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	seconds := int(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return s.Set(utils.RevokedTokenKey(token), true, seconds)
+}
+
+func (s *CacheService) IsTokenRevoked(token string) (bool, error) {
+	return s.Exists(utils.RevokedTokenKey(token))
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryStore is the single-instance stand-in for Redis when REDIS_URL is not
+// configured. Expired entries are dropped lazily and by a periodic sweep.
+type memoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+func newMemoryStore() *memoryStore {
+	m := &memoryStore{entries: make(map[string]memoryEntry)}
+	go m.sweep(time.Minute)
+	return m
+}
+
+func (m *memoryStore) get(key string) ([]byte, bool) {
+	m.mu.RLock()
+	entry, ok := m.entries[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		m.delete(key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (m *memoryStore) set(key string, value []byte, ttl time.Duration) {
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	m.entries[key] = entry
+	m.mu.Unlock()
+}
+
+func (m *memoryStore) delete(key string) {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+}
+
+func (m *memoryStore) deletePattern(pattern string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if matched, _ := path.Match(pattern, key); matched {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryStore) size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+func (m *memoryStore) flush() {
+	m.mu.Lock()
+	m.entries = make(map[string]memoryEntry)
+	m.mu.Unlock()
+}
+
+func (m *memoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		m.mu.Lock()
+		for key, entry := range m.entries {
+			if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+				delete(m.entries, key)
+			}
+		}
+		m.mu.Unlock()
+	}
 }
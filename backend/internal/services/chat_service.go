@@ -18,6 +18,9 @@ func NewChatService(db *gorm.DB) *ChatService {
 
 // Room Management
 func (s *ChatService) CreateRoom(room *models.ChatRoom) error {
+	if room.SchoolID == nil {
+		room.SchoolID = schoolIDForUser(s.db, room.CreatedBy)
+	}
 	return s.db.Create(room).Error
 }
 
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSending = "sending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+
+	emailOutboxMaxAttempts  = 5
+	emailOutboxBatchSize    = 20
+	emailOutboxPollInterval = 15 * time.Second
+	emailOutboxBaseBackoff  = 30 * time.Second
+	emailOutboxMaxBackoff   = time.Hour
+	// A row left in "sending" this long belongs to a worker that died mid-send.
+	emailOutboxStaleAfter = 10 * time.Minute
+)
+
+// enqueue persists a rendered message for the outbox worker to deliver.
+func (s *EmailService) enqueue(toEmail, subject, templateName, html string) error {
+	entry := models.EmailOutbox{
+		ToEmail:       toEmail,
+		Subject:       subject,
+		HTML:          html,
+		Template:      templateName,
+		Status:        EmailStatusPending,
+		MaxAttempts:   emailOutboxMaxAttempts,
+		NextAttemptAt: time.Now(),
+	}
+
+	if err := s.db.Create(&entry).Error; err != nil {
+		return utils.NewInternalServerError("Failed to queue email", err)
+	}
+
+	utils.InfoLogger.Printf("Email %d (%s) queued for %s", entry.ID, templateName, toEmail)
+	return nil
+}
+
+// RunOutboxWorker delivers queued email until the process exits. Rows are
+// claimed with SKIP LOCKED so several API instances can run it side by side.
+func (s *EmailService) RunOutboxWorker() {
+	ticker := time.NewTicker(emailOutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processOutbox()
+		<-ticker.C
+	}
+}
+
+func (s *EmailService) processOutbox() {
+	if err := s.db.Model(&models.EmailOutbox{}).
+		Where("status = ? AND updated_at < ?", EmailStatusSending, time.Now().Add(-emailOutboxStaleAfter)).
+		Update("status", EmailStatusPending).Error; err != nil {
+		utils.ErrorLogger.Printf("Failed to reclaim stale outbox emails: %v", err)
+	}
+
+	for {
+		var batch []models.EmailOutbox
+		err := s.db.Raw(`
+			UPDATE email_outboxes SET status = ?, updated_at = NOW()
+			WHERE id IN (
+				SELECT id FROM email_outboxes
+				WHERE status = ? AND next_attempt_at <= NOW() AND deleted_at IS NULL
+				ORDER BY next_attempt_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`, EmailStatusSending, EmailStatusPending, emailOutboxBatchSize).Scan(&batch).Error
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to claim outbox emails: %v", err)
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		settings := s.emailSettings()
+		for i := range batch {
+			s.deliver(&batch[i], settings)
+		}
+
+		if len(batch) < emailOutboxBatchSize {
+			return
+		}
+	}
+}
+
+func (s *EmailService) deliver(entry *models.EmailOutbox, settings map[string]string) {
+	driver := settings["driver"]
+	sendErr := s.sendWith(driver, settings, entry.ToEmail, entry.Subject, entry.HTML)
+
+	attempts := entry.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts": attempts,
+		"driver":   driver,
+	}
+
+	if sendErr == nil {
+		now := time.Now()
+		updates["status"] = EmailStatusSent
+		updates["sent_at"] = &now
+		updates["last_error"] = ""
+		// The body can hold sign-in links, so it isn't kept once delivered
+		updates["html"] = ""
+		utils.InfoLogger.Printf("Email %d (%s) sent to %s via %s", entry.ID, entry.Template, entry.ToEmail, driver)
+	} else {
+		updates["last_error"] = errorDetail(sendErr)
+		if attempts >= entry.MaxAttempts {
+			updates["status"] = EmailStatusFailed
+			utils.ErrorLogger.Printf("Email %d to %s failed permanently after %d attempts: %v", entry.ID, entry.ToEmail, attempts, sendErr)
+		} else {
+			updates["status"] = EmailStatusPending
+			updates["next_attempt_at"] = time.Now().Add(outboxBackoff(attempts))
+			utils.ErrorLogger.Printf("Email %d to %s failed (attempt %d/%d): %v", entry.ID, entry.ToEmail, attempts, entry.MaxAttempts, sendErr)
+		}
+	}
+
+	if err := s.db.Model(&models.EmailOutbox{}).Where("id = ?", entry.ID).Updates(updates).Error; err != nil {
+		utils.ErrorLogger.Printf("Failed to record delivery of email %d: %v", entry.ID, err)
+	}
+}
+
+// outboxBackoff doubles the retry delay per attempt: 30s, 1m, 2m, ... capped at 1h.
+func outboxBackoff(attempts int) time.Duration {
+	delay := emailOutboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= emailOutboxMaxBackoff {
+			return emailOutboxMaxBackoff
+		}
+	}
+	return delay
+}
+
+// errorDetail keeps the underlying transport error, which AppError hides
+// behind its user-facing message.
+func errorDetail(err error) string {
+	if appErr, ok := err.(*utils.AppError); ok && appErr.Err != nil {
+		return fmt.Sprintf("%s: %v", appErr.Message, appErr.Err)
+	}
+	return err.Error()
+}
+
+func (s *EmailService) ListOutbox(page, limit int, status, search string) ([]models.EmailOutbox, *utils.PaginationMeta, error) {
+	params := utils.GetPaginationParams(page, limit)
+
+	query := s.db.Model(&models.EmailOutbox{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if search != "" {
+		query = query.Where("to_email ILIKE ? OR subject ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to count outbox emails", err)
+	}
+
+	var entries []models.EmailOutbox
+	if err := query.Omit("html").Scopes(utils.Paginate(params)).Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch outbox emails", err)
+	}
+
+	meta := utils.GetPaginationMeta(params.Page, params.Limit, total)
+	return entries, &meta, nil
+}
+
+// ResendOutbox puts a message back on the queue with a fresh attempt budget.
+// Sent messages can't be resent: their body is dropped on delivery.
+func (s *EmailService) ResendOutbox(id uint) (*models.EmailOutbox, error) {
+	var entry models.EmailOutbox
+	if err := s.db.First(&entry, id).Error; err != nil {
+		return nil, utils.NewNotFoundError("Email not found")
+	}
+	if entry.Status == EmailStatusSent || entry.HTML == "" {
+		return nil, utils.NewBadRequestError("Sent emails can't be resent")
+	}
+
+	result := s.db.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status <> ?", id, EmailStatusSending).
+		Updates(map[string]interface{}{
+			"status":          EmailStatusPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return nil, utils.NewInternalServerError("Failed to requeue email", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.NewBadRequestError("Email is currently being sent")
+	}
+
+	if err := s.db.Omit("html").First(&entry, id).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch email", err)
+	}
+	return &entry, nil
+}
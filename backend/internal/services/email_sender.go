@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+
+	"readagain/internal/utils"
+)
+
+const (
+	EmailDriverResend = "resend"
+	EmailDriverSMTP   = "smtp"
+	EmailDriverFile   = "file"
+)
+
+type EmailMessage struct {
+	FromName  string
+	FromEmail string
+	To        []string
+	Subject   string
+	HTML      string
+}
+
+// EmailSender delivers a rendered message through one transport.
+type EmailSender interface {
+	Name() string
+	Send(msg *EmailMessage) error
+}
+
+// NewEmailSender builds the sender for driver from email.* settings values
+// (keys without the "email." prefix, e.g. "smtp_host").
+func NewEmailSender(driver string, settings map[string]string) (EmailSender, error) {
+	switch driver {
+	case "", EmailDriverResend:
+		if settings["resend_api_key"] == "" {
+			return nil, fmt.Errorf("resend driver requires resend_api_key")
+		}
+		return &ResendSender{client: resend.NewClient(settings["resend_api_key"])}, nil
+	case EmailDriverSMTP:
+		if settings["smtp_host"] == "" {
+			return nil, fmt.Errorf("smtp driver requires smtp_host")
+		}
+		port := settings["smtp_port"]
+		if port == "" {
+			port = "587"
+		}
+		encryption := strings.ToLower(settings["smtp_encryption"])
+		if encryption == "" {
+			encryption = "starttls"
+		}
+		return &SMTPSender{
+			host:       settings["smtp_host"],
+			port:       port,
+			username:   settings["smtp_username"],
+			password:   settings["smtp_password"],
+			encryption: encryption,
+		}, nil
+	case EmailDriverFile:
+		dir := settings["file_dir"]
+		if dir == "" {
+			dir = "./storage/mail"
+		}
+		return &FileSender{dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown email driver %q", driver)
+	}
+}
+
+type ResendSender struct {
+	client *resend.Client
+}
+
+func (s *ResendSender) Name() string {
+	return EmailDriverResend
+}
+
+func (s *ResendSender) Send(msg *EmailMessage) error {
+	params := &resend.SendEmailRequest{
+		From:    formatAddress(msg.FromName, msg.FromEmail),
+		To:      msg.To,
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+	}
+
+	_, err := s.client.Emails.Send(params)
+	return err
+}
+
+// SMTPSender speaks plain SMTP. Encryption is "starttls" (upgrade after
+// connect), "tls" (implicit TLS, usually port 465) or "none".
+type SMTPSender struct {
+	host       string
+	port       string
+	username   string
+	password   string
+	encryption string
+}
+
+func (s *SMTPSender) Name() string {
+	return EmailDriverSMTP
+}
+
+func (s *SMTPSender) Send(msg *EmailMessage) error {
+	addr := net.JoinHostPort(s.host, s.port)
+	tlsConfig := &tls.Config{ServerName: s.host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	if s.encryption == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if s.encryption == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not support STARTTLS", s.host)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(msg.FromEmail); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(buildMIMEMessage(msg)); err != nil {
+		w.Close()
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA close: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// FileSender writes each message as an .eml file instead of delivering it,
+// for local development and tests.
+type FileSender struct {
+	dir string
+}
+
+func (s *FileSender) Name() string {
+	return EmailDriverFile
+}
+
+func (s *FileSender) Send(msg *EmailMessage) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("create mail dir: %w", err)
+	}
+
+	filename := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), utils.GenerateRandomToken(4))
+	path := filepath.Join(s.dir, filename)
+	if err := os.WriteFile(path, buildMIMEMessage(msg), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	utils.InfoLogger.Printf("Email written to %s", path)
+	return nil
+}
+
+func formatAddress(name, email string) string {
+	return (&mail.Address{Name: name, Address: email}).String()
+}
+
+func buildMIMEMessage(msg *EmailMessage) []byte {
+	var buf bytes.Buffer
+
+	domain := "localhost"
+	if at := strings.LastIndex(msg.FromEmail, "@"); at >= 0 {
+		domain = msg.FromEmail[at+1:]
+	}
+
+	headers := []string{
+		"From: " + formatAddress(msg.FromName, msg.FromEmail),
+		"To: " + strings.Join(msg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", utils.GenerateRandomToken(16), domain),
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+		"Content-Transfer-Encoding: quoted-printable",
+	}
+	for _, h := range headers {
+		buf.WriteString(h + "\r\n")
+	}
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(msg.HTML))
+	qp.Close()
+
+	return buf.Bytes()
+}
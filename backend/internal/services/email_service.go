@@ -5,31 +5,107 @@ import (
 	"fmt"
 	"html/template"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/resend/resend-go/v2"
+	"gorm.io/gorm"
 
 	"readagain/internal/utils"
 )
 
 type EmailService struct {
-	client       *resend.Client
-	fromEmail    string
-	fromName     string
-	appURL       string
-	templatesDir string
+	db              *gorm.DB
+	settingsService *SettingsService
+	defaults        map[string]string
+	appURL          string
+	templatesDir    string
 }
 
-func NewEmailService(apiKey, fromEmail, fromName, appURL string) *EmailService {
-	client := resend.NewClient(apiKey)
+// NewEmailService takes the environment config as defaults; any non-empty
+// email.* system setting overrides them at send time, so switching drivers
+// takes effect without a restart.
+func NewEmailService(db *gorm.DB, settingsService *SettingsService, apiKey, fromEmail, fromName, appURL string) *EmailService {
 	return &EmailService{
-		client:       client,
-		fromEmail:    fromEmail,
-		fromName:     fromName,
+		db:              db,
+		settingsService: settingsService,
+		defaults: map[string]string{
+			"driver":         EmailDriverResend,
+			"resend_api_key": apiKey,
+			"from_email":     fromEmail,
+			"from_name":      fromName,
+		},
 		appURL:       appURL,
 		templatesDir: "./templates/emails",
 	}
 }
 
+// emailSettings merges the email.* system settings over the env defaults and
+// returns them keyed without the "email." prefix.
+func (s *EmailService) emailSettings() map[string]string {
+	settings := make(map[string]string, len(s.defaults))
+	for key, value := range s.defaults {
+		settings[key] = value
+	}
+
+	stored, err := s.settingsService.GetEmailSettings()
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to load email settings, using defaults: %v", err)
+		return settings
+	}
+
+	for key, value := range stored {
+		if value != "" {
+			settings[strings.TrimPrefix(key, "email.")] = value
+		}
+	}
+	return settings
+}
+
+func (s *EmailService) sendWith(driver string, settings map[string]string, toEmail, subject, html string) error {
+	sender, err := NewEmailSender(driver, settings)
+	if err != nil {
+		return utils.NewBadRequestError("Email driver is not configured: " + err.Error())
+	}
+
+	msg := &EmailMessage{
+		FromName:  settings["from_name"],
+		FromEmail: settings["from_email"],
+		To:        []string{toEmail},
+		Subject:   subject,
+		HTML:      html,
+	}
+
+	if err := sender.Send(msg); err != nil {
+		return utils.NewInternalServerError(fmt.Sprintf("Failed to send email via %s", sender.Name()), err)
+	}
+	return nil
+}
+
+// SendTestEmail sends a test message through driver, using the stored email
+// settings with overrides applied on top (e.g. unsaved values from the admin form).
+func (s *EmailService) SendTestEmail(driver string, overrides map[string]string, toEmail string) error {
+	settings := s.emailSettings()
+	for key, value := range overrides {
+		if value != "" {
+			settings[strings.TrimPrefix(key, "email.")] = value
+		}
+	}
+	if driver == "" {
+		driver = settings["driver"]
+	}
+
+	html := fmt.Sprintf("<p>This is a test email from ReadAgain sent with the <strong>%s</strong> driver at %s.</p>",
+		template.HTMLEscapeString(driver), time.Now().Format(time.RFC1123))
+
+	if err := s.sendWith(driver, settings, toEmail, "ReadAgain email gateway test", html); err != nil {
+		utils.ErrorLogger.Printf("Test email via %s failed: %v", driver, err)
+		return err
+	}
+
+	utils.InfoLogger.Printf("Test email sent to %s via %s", toEmail, driver)
+	return nil
+}
+
 type WelcomeEmailData struct {
 	Name   string
 	AppURL string
@@ -40,6 +116,56 @@ type PasswordResetData struct {
 	ResetURL string
 }
 
+type VerifyEmailData struct {
+	Name      string
+	VerifyURL string
+}
+
+type AccountSetupData struct {
+	Name       string
+	Email      string
+	Username   string
+	SchoolName string
+	SetupURL   string
+	ExpiresAt  string
+}
+
+type GuardianInvitationData struct {
+	StudentName  string
+	InviterName  string
+	Relationship string
+	AcceptURL    string
+	ExpiresAt    string
+}
+
+// GuardianDigestData is a guardian's weekly summary of their children's
+// reading.
+type GuardianDigestData struct {
+	Name      string
+	WeekStart string
+	WeekEnd   string
+	Children  []GuardianDigestChild
+	AppURL    string
+}
+
+type GuardianDigestChild struct {
+	Name               string
+	MinutesRead        int
+	Sessions           int
+	DaysRead           int
+	CurrentStreak      int
+	LongestStreak      int
+	BooksFinished      []string
+	Reading            []GuardianDigestBook
+	Achievements       []string
+	OverdueAssignments int
+}
+
+type GuardianDigestBook struct {
+	Title    string
+	Progress int
+}
+
 func (s *EmailService) SendWelcomeEmail(toEmail, name string) error {
 	data := WelcomeEmailData{
 		Name:   name,
@@ -51,20 +177,11 @@ func (s *EmailService) SendWelcomeEmail(toEmail, name string) error {
 		return err
 	}
 
-	params := &resend.SendEmailRequest{
-		From:    fmt.Sprintf("%s <%s>", s.fromName, s.fromEmail),
-		To:      []string{toEmail},
-		Subject: "Welcome to ReadAgain!",
-		Html:    html,
-	}
-
-	_, err = s.client.Emails.Send(params)
-	if err != nil {
-		utils.ErrorLogger.Printf("Failed to send welcome email: %v", err)
-		return utils.NewInternalServerError("Failed to send email", err)
+	if err := s.enqueue(toEmail, "Welcome to ReadAgain!", "welcome.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue welcome email: %v", err)
+		return err
 	}
 
-	utils.InfoLogger.Printf("Welcome email sent to %s", toEmail)
 	return nil
 }
 
@@ -81,20 +198,104 @@ func (s *EmailService) SendPasswordReset(toEmail, name, resetToken string) error
 		return err
 	}
 
-	params := &resend.SendEmailRequest{
-		From:    fmt.Sprintf("%s <%s>", s.fromName, s.fromEmail),
-		To:      []string{toEmail},
-		Subject: "Reset Your Password - ReadAgain",
-		Html:    html,
+	if err := s.enqueue(toEmail, "Reset Your Password - ReadAgain", "password_reset.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue password reset email: %v", err)
+		return err
 	}
 
-	_, err = s.client.Emails.Send(params)
+	return nil
+}
+
+func (s *EmailService) SendVerificationEmail(toEmail, name, verificationToken string) error {
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s", s.appURL, verificationToken)
+
+	data := VerifyEmailData{
+		Name:      name,
+		VerifyURL: verifyURL,
+	}
+
+	html, err := s.renderTemplate("verify_email.html", data)
 	if err != nil {
-		utils.ErrorLogger.Printf("Failed to send password reset email: %v", err)
-		return utils.NewInternalServerError("Failed to send email", err)
+		return err
+	}
+
+	if err := s.enqueue(toEmail, "Verify Your Email - ReadAgain", "verify_email.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue verification email: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// AccountSetupURL is where a user chooses the password for an account an
+// admin created; it is the password reset page.
+func (s *EmailService) AccountSetupURL(token string) string {
+	return fmt.Sprintf("%s/reset-password?token=%s", s.appURL, token)
+}
+
+// SendAccountSetup tells a user an admin created their account and links
+// to where they set their password. No password is ever emailed.
+func (s *EmailService) SendAccountSetup(toEmail, name, username, setupToken, schoolName string, expiresAt time.Time) error {
+	data := AccountSetupData{
+		Name:       name,
+		Email:      toEmail,
+		Username:   username,
+		SchoolName: schoolName,
+		SetupURL:   s.AccountSetupURL(setupToken),
+		ExpiresAt:  expiresAt.Format("January 2, 2006"),
+	}
+
+	html, err := s.renderTemplate("account_setup.html", data)
+	if err != nil {
+		return err
+	}
+
+	if err := s.enqueue(toEmail, "Your ReadAgain Account", "account_setup.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue account setup email: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// SendGuardianInvitation asks someone to follow a student's reading. The
+// link carries the only copy of the token.
+func (s *EmailService) SendGuardianInvitation(toEmail, studentName, inviterName, relationship, token string, expiresAt time.Time) error {
+	data := GuardianInvitationData{
+		StudentName:  studentName,
+		InviterName:  inviterName,
+		Relationship: relationship,
+		AcceptURL:    fmt.Sprintf("%s/guardian/accept?token=%s", s.appURL, token),
+		ExpiresAt:    expiresAt.Format("January 2, 2006"),
+	}
+
+	html, err := s.renderTemplate("guardian_invitation.html", data)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Follow %s's reading on ReadAgain", studentName)
+	if err := s.enqueue(toEmail, subject, "guardian_invitation.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue guardian invitation email: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *EmailService) SendGuardianDigest(toEmail string, data GuardianDigestData) error {
+	data.AppURL = s.appURL
+
+	html, err := s.renderTemplate("guardian_digest.html", data)
+	if err != nil {
+		return err
+	}
+
+	if err := s.enqueue(toEmail, "This week's reading - ReadAgain", "guardian_digest.html", html); err != nil {
+		utils.ErrorLogger.Printf("Failed to queue guardian digest email: %v", err)
+		return err
 	}
 
-	utils.InfoLogger.Printf("Password reset email sent to %s", toEmail)
 	return nil
 }
 
@@ -1,18 +1,79 @@
 package services
 
 import (
+	"time"
+
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
 	"readagain/internal/utils"
 )
 
+// newAnnotationSync stamps an annotation created through the single-item
+// endpoints so that sync clients see it like one of their own.
+func newAnnotationSync() models.AnnotationSync {
+	return models.AnnotationSync{ClientID: uuid.NewString(), ClientUpdatedAt: time.Now()}
+}
+
+// annotationTombstone soft-deletes an annotation and bumps its sync version.
+func annotationTombstone() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"deleted_at":        now,
+		"client_updated_at": now,
+		"sync_version":      nextSyncVersion,
+	}
+}
+
 type EReaderService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	fileSigner *FileURLSigner
+	watermarks *WatermarkService
+}
+
+func NewEReaderService(db *gorm.DB, fileSigner *FileURLSigner, watermarks *WatermarkService) *EReaderService {
+	return &EReaderService{db: db, fileSigner: fileSigner, watermarks: watermarks}
+}
+
+// createAnnotation saves a new annotation of the given kind under the sync
+// lock for its book.
+func (s *EReaderService) createAnnotation(userID, bookID uint, annotation interface{}, kind string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAnnotations(tx, userID, bookID); err != nil {
+			return utils.NewForbiddenError("You don't own this book")
+		}
+		if err := tx.Create(annotation).Error; err != nil {
+			return utils.NewInternalServerError("Failed to create "+kind, err)
+		}
+		return nil
+	})
 }
 
-func NewEReaderService(db *gorm.DB) *EReaderService {
-	return &EReaderService{db: db}
+// updateAnnotation applies updates to one of the user's annotations under
+// the sync lock for its book. It reports false when there is no such
+// annotation.
+func (s *EReaderService) updateAnnotation(model interface{}, id, userID uint, updates map[string]interface{}) (bool, error) {
+	var bookIDs []uint
+	if err := s.db.Model(model).Where("id = ? AND user_id = ?", id, userID).Pluck("book_id", &bookIDs).Error; err != nil {
+		return false, err
+	}
+	if len(bookIDs) == 0 {
+		return false, nil
+	}
+
+	var found bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Without the book in their library the reader can't sync it, so
+		// there is nothing to serialize with
+		if err := lockAnnotations(tx, userID, bookIDs[0]); err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		result := tx.Model(model).Where("id = ? AND user_id = ?", id, userID).Updates(updates)
+		found = result.RowsAffected > 0
+		return result.Error
+	})
+	return found, err
 }
 
 func (s *EReaderService) ValidateBookAccess(userID, bookID uint) (*models.Book, error) {
@@ -29,22 +90,41 @@ func (s *EReaderService) ValidateBookAccess(userID, bookID uint) (*models.Book,
 	return &book, nil
 }
 
-func (s *EReaderService) CreateBookmark(userID, bookID uint, page int, location, note string) (*models.Bookmark, error) {
-	var library models.UserLibrary
-	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
-		return nil, utils.NewForbiddenError("You don't own this book")
+// GetBookFileURL checks the reader may open the book and returns a signed,
+// expiring URL for its file on upload-api. PDFs are watermarked for them.
+func (s *EReaderService) GetBookFileURL(userID, bookID uint) (*SignedURL, error) {
+	book, err := s.ValidateBookAccess(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if book.FilePath == "" {
+		return nil, utils.NewNotFoundError("Book file not found")
+	}
+
+	mark, err := s.watermarks.Issue(userID, book)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := s.fileSigner.SignBook(book.FilePath, userID, mark)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to create book file URL", err)
 	}
+	return signed, nil
+}
 
+func (s *EReaderService) CreateBookmark(userID, bookID uint, page int, location, note string) (*models.Bookmark, error) {
 	bookmark := models.Bookmark{
-		UserID:   userID,
-		BookID:   bookID,
-		Page:     page,
-		Location: location,
-		Note:     note,
+		AnnotationSync: newAnnotationSync(),
+		UserID:         userID,
+		BookID:         bookID,
+		Page:           page,
+		Location:       location,
+		Note:           note,
 	}
 
-	if err := s.db.Create(&bookmark).Error; err != nil {
-		return nil, utils.NewInternalServerError("Failed to create bookmark", err)
+	if err := s.createAnnotation(userID, bookID, &bookmark, AnnotationBookmark); err != nil {
+		return nil, err
 	}
 
 	return &bookmark, nil
@@ -61,31 +141,27 @@ func (s *EReaderService) GetBookmarks(userID, bookID uint) ([]models.Bookmark, e
 }
 
 func (s *EReaderService) DeleteBookmark(bookmarkID, userID uint) error {
-	result := s.db.Where("id = ? AND user_id = ?", bookmarkID, userID).Delete(&models.Bookmark{})
-	if result.Error != nil {
-		return utils.NewInternalServerError("Failed to delete bookmark", result.Error)
+	found, err := s.updateAnnotation(&models.Bookmark{}, bookmarkID, userID, annotationTombstone())
+	if err != nil {
+		return utils.NewInternalServerError("Failed to delete bookmark", err)
 	}
-	if result.RowsAffected == 0 {
+	if !found {
 		return utils.NewNotFoundError("Bookmark not found")
 	}
 	return nil
 }
 
 func (s *EReaderService) CreateNote(userID, bookID uint, page int, content, highlight string) (*models.Note, error) {
-	var library models.UserLibrary
-	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
-		return nil, utils.NewForbiddenError("You don't own this book")
-	}
-
 	note := models.Note{
-		UserID:  userID,
-		BookID:  bookID,
-		Page:    page,
-		Content: content,
+		AnnotationSync: newAnnotationSync(),
+		UserID:         userID,
+		BookID:         bookID,
+		Page:           page,
+		Content:        content,
 	}
 
-	if err := s.db.Create(&note).Error; err != nil {
-		return nil, utils.NewInternalServerError("Failed to create note", err)
+	if err := s.createAnnotation(userID, bookID, &note, AnnotationNote); err != nil {
+		return nil, err
 	}
 
 	return &note, nil
@@ -102,14 +178,20 @@ func (s *EReaderService) GetNotes(userID, bookID uint) ([]models.Note, error) {
 }
 
 func (s *EReaderService) UpdateNote(noteID, userID uint, content string) (*models.Note, error) {
-	var note models.Note
-	if err := s.db.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+	found, err := s.updateAnnotation(&models.Note{}, noteID, userID, map[string]interface{}{
+		"content":           content,
+		"client_updated_at": time.Now(),
+		"sync_version":      nextSyncVersion,
+	})
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to update note", err)
+	}
+	if !found {
 		return nil, utils.NewNotFoundError("Note not found")
 	}
 
-	note.Content = content
-
-	if err := s.db.Save(&note).Error; err != nil {
+	var note models.Note
+	if err := s.db.First(&note, noteID).Error; err != nil {
 		return nil, utils.NewInternalServerError("Failed to update note", err)
 	}
 
@@ -117,35 +199,31 @@ func (s *EReaderService) UpdateNote(noteID, userID uint, content string) (*model
 }
 
 func (s *EReaderService) DeleteNote(noteID, userID uint) error {
-	result := s.db.Where("id = ? AND user_id = ?", noteID, userID).Delete(&models.Note{})
-	if result.Error != nil {
-		return utils.NewInternalServerError("Failed to delete note", result.Error)
+	found, err := s.updateAnnotation(&models.Note{}, noteID, userID, annotationTombstone())
+	if err != nil {
+		return utils.NewInternalServerError("Failed to delete note", err)
 	}
-	if result.RowsAffected == 0 {
+	if !found {
 		return utils.NewNotFoundError("Note not found")
 	}
 	return nil
 }
 
 func (s *EReaderService) CreateHighlight(userID, bookID uint, text, color, context, cfiRange string, startOffset, endOffset int) (*models.Highlight, error) {
-	var library models.UserLibrary
-	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
-		return nil, utils.NewForbiddenError("You don't own this book")
-	}
-
 	highlight := models.Highlight{
-		UserID:      userID,
-		BookID:      bookID,
-		Text:        text,
-		Color:       color,
-		Context:     context,
-		CFIRange:    cfiRange,
-		StartOffset: startOffset,
-		EndOffset:   endOffset,
+		AnnotationSync: newAnnotationSync(),
+		UserID:         userID,
+		BookID:         bookID,
+		Text:           text,
+		Color:          color,
+		Context:        context,
+		CFIRange:       cfiRange,
+		StartOffset:    startOffset,
+		EndOffset:      endOffset,
 	}
 
-	if err := s.db.Create(&highlight).Error; err != nil {
-		return nil, utils.NewInternalServerError("Failed to create highlight", err)
+	if err := s.createAnnotation(userID, bookID, &highlight, AnnotationHighlight); err != nil {
+		return nil, err
 	}
 
 	return &highlight, nil
@@ -162,11 +240,11 @@ func (s *EReaderService) GetHighlights(userID, bookID uint) ([]models.Highlight,
 }
 
 func (s *EReaderService) DeleteHighlight(highlightID, userID uint) error {
-	result := s.db.Where("id = ? AND user_id = ?", highlightID, userID).Delete(&models.Highlight{})
-	if result.Error != nil {
-		return utils.NewInternalServerError("Failed to delete highlight", result.Error)
+	found, err := s.updateAnnotation(&models.Highlight{}, highlightID, userID, annotationTombstone())
+	if err != nil {
+		return utils.NewInternalServerError("Failed to delete highlight", err)
 	}
-	if result.RowsAffected == 0 {
+	if !found {
 		return utils.NewNotFoundError("Highlight not found")
 	}
 	return nil
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"readagain/internal/models"
+)
+
+var errFileURLSecretMissing = errors.New("FILE_URL_SECRET is not set")
+
+// FileURLSigner mints the short-lived URLs book files are downloaded from.
+// upload-api serves files in its books directory only to requests carrying
+// a valid signature: an HMAC-SHA256, keyed with the secret both services
+// share, of the file path, the expiry, the reader it was issued to and the
+// watermark upload-api stamps on their copy. The watermark's printed line
+// names the reader, so it travels sealed rather than in the clear.
+type FileURLSigner struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+}
+
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewFileURLSigner(baseURL, secret string, ttl time.Duration) *FileURLSigner {
+	return &FileURLSigner{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+		ttl:     ttl,
+	}
+}
+
+// bookFileName reduces a stored Book.FilePath ("books/<name>", or a full
+// upload-api URL for older books) to the file name in the books directory.
+func bookFileName(filePath string) string {
+	if u, err := url.Parse(filePath); err == nil && u.Path != "" {
+		filePath = u.Path
+	}
+	return path.Base(filePath)
+}
+
+// fileSignature must match upload-api's verification byte for byte.
+func fileSignature(secret []byte, filePath string, expires int64, userID uint, code, seal string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%d\n%d\n%s\n%s", filePath, expires, userID, code, seal)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sealText encrypts a watermark line for upload-api with AES-256-GCM under
+// a key derived from the shared secret: the nonce followed by the
+// ciphertext, base64url encoded without padding.
+func sealText(secret []byte, text string) (string, error) {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("watermark-text"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(text), nil)), nil
+}
+
+// SignBook returns a URL for the book file at filePath that only userID's
+// reader should hold and that stops working after the signer's TTL. When
+// mark is set the file is served stamped with it.
+func (s *FileURLSigner) SignBook(filePath string, userID uint, mark *models.BookWatermark) (*SignedURL, error) {
+	if len(s.secret) == 0 {
+		return nil, errFileURLSecretMissing
+	}
+
+	name := bookFileName(filePath)
+	expiresAt := time.Now().Add(s.ttl).Truncate(time.Second)
+	expires := expiresAt.Unix()
+
+	var code, seal string
+	if mark != nil {
+		if mark.Invisible {
+			code = mark.Code
+		}
+		if mark.Text != "" {
+			var err error
+			if seal, err = sealText(s.secret, mark.Text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("uid", strconv.FormatUint(uint64(userID), 10))
+	if code != "" {
+		query.Set("wm", code)
+	}
+	if seal != "" {
+		query.Set("wmseal", seal)
+	}
+	query.Set("sig", fileSignature(s.secret, "books/"+name, expires, userID, code, seal))
+
+	return &SignedURL{
+		URL:       s.baseURL + "/api/files/" + url.PathEscape(name) + "?" + query.Encode(),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// fileActionRoutes are upload-api's signed file actions and the route each
+// is requested on: "metadata" re-extracts a book's metadata (POST) and
+// "delete" removes a stored file (DELETE).
+var fileActionRoutes = map[string]string{
+	"metadata": "/api/metadata/",
+	"delete":   "/api/files/",
+}
+
+// SignAction returns a URL for one of upload-api's signed file actions. The
+// signature covers "<action>/<name>" with no watermark.
+func (s *FileURLSigner) SignAction(action, filePath string, userID uint) (*SignedURL, error) {
+	if len(s.secret) == 0 {
+		return nil, errFileURLSecretMissing
+	}
+	route, ok := fileActionRoutes[action]
+	if !ok {
+		return nil, fmt.Errorf("unknown file action %q", action)
+	}
+
+	name := bookFileName(filePath)
+	expiresAt := time.Now().Add(s.ttl).Truncate(time.Second)
+	expires := expiresAt.Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("uid", strconv.FormatUint(uint64(userID), 10))
+	query.Set("sig", fileSignature(s.secret, action+"/"+name, expires, userID, "", ""))
+
+	return &SignedURL{
+		URL:       s.baseURL + route + url.PathEscape(name) + "?" + query.Encode(),
+		ExpiresAt: expiresAt,
+	}, nil
+}
@@ -41,6 +41,9 @@ func (s *GroupService) GetByID(id uint) (*models.Group, error) {
 }
 
 func (s *GroupService) Create(group *models.Group) error {
+	if group.SchoolID == nil {
+		group.SchoolID = schoolIDForUser(s.db, group.CreatedBy)
+	}
 	return s.db.Create(group).Error
 }
 
@@ -78,6 +81,9 @@ func (s *GroupService) AddMember(groupID, userID uint) error {
 		if err := tx.Create(member).Error; err != nil {
 			return err
 		}
+		if err := enrollInGroupAssignments(tx, groupID, []uint{userID}); err != nil {
+			return err
+		}
 		return tx.Model(&models.Group{}).Where("id = ?", groupID).UpdateColumn("member_count", gorm.Expr("member_count + ?", 1)).Error
 	})
 }
@@ -103,34 +109,53 @@ func (s *GroupService) AddMembers(groupID uint, userIDs []uint) error {
 				}
 			}
 		}
+		if err := enrollInGroupAssignments(tx, groupID, userIDs); err != nil {
+			return err
+		}
 		var count int64
 		tx.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Count(&count)
 		return tx.Model(&models.Group{}).Where("id = ?", groupID).Update("member_count", count).Error
 	})
 }
 
-func (s *GroupService) AssignBooks(groupID uint, bookIDs []uint) error {
+// AssignBooks adds the books to every member's library and records the
+// assignment so annotations shared with the group reach its members.
+func (s *GroupService) AssignBooks(groupID uint, bookIDs []uint, assignedBy uint) error {
 	var members []models.GroupMember
 	if err := s.db.Where("group_id = ?", groupID).Find(&members).Error; err != nil {
 		return err
 	}
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, bookID := range bookIDs {
+			assignment := models.GroupBook{GroupID: groupID, BookID: bookID, AssignedBy: assignedBy}
+			if err := tx.Where(models.GroupBook{GroupID: groupID, BookID: bookID}).FirstOrCreate(&assignment).Error; err != nil {
+				return err
+			}
+		}
+
 		for _, member := range members {
 			for _, bookID := range bookIDs {
-				var exists int64
-				tx.Model(&models.UserLibrary{}).Where("user_id = ? AND book_id = ?", member.UserID, bookID).Count(&exists)
-				if exists == 0 {
-					library := &models.UserLibrary{
-						UserID: member.UserID,
-						BookID: bookID,
-					}
-					if err := tx.Create(library).Error; err != nil {
-						return err
-					}
+				if err := addToLibrary(tx, member.UserID, bookID); err != nil {
+					return err
 				}
 			}
 		}
 		return nil
 	})
 }
+
+// addToLibrary puts a book on a user's shelf unless it is already there.
+func addToLibrary(tx *gorm.DB, userID, bookID uint) error {
+	var exists int64
+	tx.Model(&models.UserLibrary{}).Where("user_id = ? AND book_id = ?", userID, bookID).Count(&exists)
+	if exists > 0 {
+		return nil
+	}
+	library := &models.UserLibrary{
+		UserID:   userID,
+		BookID:   bookID,
+		SchoolID: schoolIDForUser(tx, userID),
+	}
+	return tx.Create(library).Error
+}
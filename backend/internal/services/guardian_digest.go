@@ -0,0 +1,156 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	// guardianDigestEvery is how often a guardian gets the digest, and
+	// guardianDigestInterval how often the worker looks for ones that are due.
+	guardianDigestEvery    = 7 * 24 * time.Hour
+	guardianDigestInterval = time.Hour
+	// guardianDigestReading is how many books in progress the digest lists.
+	guardianDigestReading = 3
+)
+
+// SetDigest turns the weekly digest on or off for all of a guardian's
+// children.
+func (s *GuardianService) SetDigest(guardianID uint, enabled bool) error {
+	if err := s.db.Model(&models.GuardianLink{}).Where("guardian_id = ?", guardianID).
+		Update("digest_enabled", enabled).Error; err != nil {
+		return utils.NewInternalServerError("Failed to update digest", err)
+	}
+	return nil
+}
+
+// digestChild summarises a student's reading between since and until.
+func (s *GuardianService) digestChild(student *models.User, since, until time.Time, streak Streak) GuardianDigestChild {
+	child := GuardianDigestChild{
+		Name:          authorName(student),
+		CurrentStreak: streak.Current,
+		LongestStreak: streak.Longest,
+	}
+
+	var sessions struct {
+		Sessions int
+		Seconds  int64
+		Days     int
+	}
+	s.db.Model(&models.ReadingSession{}).
+		Select("COUNT(*) AS sessions, COALESCE(SUM(duration), 0) AS seconds, COUNT(DISTINCT DATE(start_time)) AS days").
+		Where("user_id = ? AND start_time >= ? AND start_time < ?", student.ID, since, until).
+		Scan(&sessions)
+	child.Sessions = sessions.Sessions
+	child.DaysRead = sessions.Days
+	child.MinutesRead = int(math.Round(float64(sessions.Seconds) / 60))
+
+	s.db.Model(&models.UserLibrary{}).
+		Joins("JOIN books ON books.id = user_libraries.book_id").
+		Where("user_libraries.user_id = ? AND user_libraries.completed_at >= ? AND user_libraries.completed_at < ?", student.ID, since, until).
+		Order("user_libraries.completed_at").
+		Pluck("books.title", &child.BooksFinished)
+
+	var reading []models.UserLibrary
+	s.db.Preload("Book").
+		Where("user_id = ? AND progress > 0 AND completed_at IS NULL AND last_read_at >= ?", student.ID, since).
+		Order("last_read_at DESC").Limit(guardianDigestReading).
+		Find(&reading)
+	for _, item := range reading {
+		if item.Book != nil {
+			child.Reading = append(child.Reading, GuardianDigestBook{Title: item.Book.Title, Progress: int(item.Progress)})
+		}
+	}
+
+	s.db.Model(&models.UserAchievement{}).
+		Joins("JOIN achievements ON achievements.id = user_achievements.achievement_id").
+		Where("user_achievements.user_id = ? AND user_achievements.is_unlocked AND user_achievements.updated_at >= ?", student.ID, since).
+		Pluck("achievements.name", &child.Achievements)
+
+	var overdue int64
+	s.db.Model(&models.ReadingAssignmentStudent{}).
+		Where("user_id = ? AND status = ?", student.ID, AssignmentLate).
+		Count(&overdue)
+	child.OverdueAssignments = int(overdue)
+
+	return child
+}
+
+// SendWeeklyDigests emails every guardian whose last digest is a week old
+// a summary of their children's week. It returns the digests queued.
+func (s *GuardianService) SendWeeklyDigests() (int, error) {
+	now := time.Now()
+	var guardianIDs []uint
+	if err := s.db.Model(&models.GuardianLink{}).
+		Where("digest_enabled AND (digest_sent_at IS NULL OR digest_sent_at <= ?)", now.Add(-guardianDigestEvery)).
+		Distinct("guardian_id").
+		Pluck("guardian_id", &guardianIDs).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, guardianID := range guardianIDs {
+		var guardian models.User
+		if err := s.db.Select("id", "email", "first_name", "last_name", "username", "is_active").First(&guardian, guardianID).Error; err != nil || !guardian.IsActive {
+			continue
+		}
+		var links []models.GuardianLink
+		if err := s.db.Preload("Student").Where("guardian_id = ? AND digest_enabled", guardianID).Find(&links).Error; err != nil {
+			return sent, err
+		}
+
+		studentIDs := make([]uint, len(links))
+		for i, link := range links {
+			studentIDs[i] = link.StudentID
+		}
+		streaks, err := s.streakService.GetStreaks(studentIDs)
+		if err != nil {
+			return sent, err
+		}
+
+		since := now.Add(-guardianDigestEvery)
+		data := GuardianDigestData{
+			Name:      displayName(&guardian),
+			WeekStart: since.Format("Jan 2"),
+			WeekEnd:   now.Format("Jan 2, 2006"),
+		}
+		for _, link := range links {
+			if link.Student != nil {
+				data.Children = append(data.Children, s.digestChild(link.Student, since, now, streaks[link.StudentID]))
+			}
+		}
+		if len(data.Children) == 0 {
+			continue
+		}
+
+		if err := s.emailService.SendGuardianDigest(guardian.Email, data); err != nil {
+			utils.ErrorLogger.Printf("Failed to send digest to guardian %d: %v", guardianID, err)
+			continue
+		}
+		if err := s.db.Model(&models.GuardianLink{}).Where("guardian_id = ? AND digest_enabled", guardianID).
+			Update("digest_sent_at", now).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// RunDigestWorker sends due digests every guardianDigestInterval until the
+// process exits.
+func (s *GuardianService) RunDigestWorker() {
+	ticker := time.NewTicker(guardianDigestInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sent, err := s.SendWeeklyDigests()
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to send guardian digests: %v", err)
+		} else if sent > 0 {
+			utils.InfoLogger.Printf("Sent %d guardian digests", sent)
+		}
+	}
+}
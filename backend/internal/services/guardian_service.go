@@ -0,0 +1,498 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const guardianInvitationTTL = 7 * 24 * time.Hour
+
+// GuardianInvitationInfo is what the person following an invitation link
+// sees before accepting it.
+type GuardianInvitationInfo struct {
+	StudentName  string    `json:"student_name"`
+	InviterName  string    `json:"inviter_name"`
+	Email        string    `json:"email"`
+	Relationship string    `json:"relationship"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	// HasAccount tells the page to ask the guardian to sign in and accept
+	// rather than create an account.
+	HasAccount bool `json:"has_account"`
+}
+
+// GuardianChild is a linked student as their guardian sees them.
+type GuardianChild struct {
+	LinkID          uint       `json:"link_id"`
+	StudentID       uint       `json:"student_id"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	SchoolName      string     `json:"school_name"`
+	ClassLevel      string     `json:"class_level"`
+	Relationship    string     `json:"relationship"`
+	DigestEnabled   bool       `json:"digest_enabled"`
+	Books           int64      `json:"books"`
+	BooksCompleted  int64      `json:"books_completed"`
+	MinutesThisWeek float64    `json:"minutes_this_week"`
+	LastReadAt      *time.Time `json:"last_read_at"`
+	CurrentStreak   int        `json:"current_streak"`
+}
+
+// GuardianChildOverview is one child's reading in more detail.
+type GuardianChildOverview struct {
+	GuardianChild
+	LongestStreak      int                  `json:"longest_streak"`
+	TotalMinutes       float64              `json:"total_minutes"`
+	Reading            []models.UserLibrary `json:"reading"`
+	OpenAssignments    int64                `json:"open_assignments"`
+	OverdueAssignments int64                `json:"overdue_assignments"`
+}
+
+// GuardianService links parents and guardians to students and gives them a
+// read-only view of their reading.
+type GuardianService struct {
+	db                 *gorm.DB
+	emailService       *EmailService
+	libraryService     *LibraryService
+	sessionService     *ReadingSessionService
+	streakService      *StreakService
+	achievementService *AchievementService
+}
+
+func NewGuardianService(db *gorm.DB, emailService *EmailService, libraryService *LibraryService, sessionService *ReadingSessionService, streakService *StreakService, achievementService *AchievementService) *GuardianService {
+	return &GuardianService{
+		db:                 db,
+		emailService:       emailService,
+		libraryService:     libraryService,
+		sessionService:     sessionService,
+		streakService:      streakService,
+		achievementService: achievementService,
+	}
+}
+
+func (s *GuardianService) findStudent(studentID uint) (*models.User, error) {
+	var student models.User
+	if err := s.db.Joins("Role").Where("users.id = ?", studentID).First(&student).Error; err != nil {
+		return nil, utils.NewNotFoundError("Student not found")
+	}
+	if student.Role == nil || student.Role.Name != "student" {
+		return nil, utils.NewBadRequestError("Guardians can only be linked to students")
+	}
+	return &student, nil
+}
+
+// Invite emails an invitation to follow the student's reading. Inviting the
+// same address again replaces the earlier invitation.
+func (s *GuardianService) Invite(studentID, invitedBy uint, email, relationship string) (*models.GuardianInvitation, error) {
+	student, err := s.findStudent(studentID)
+	if err != nil {
+		return nil, err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if strings.EqualFold(email, student.Email) {
+		return nil, utils.NewBadRequestError("A student can't be their own guardian")
+	}
+
+	var linked int64
+	s.db.Model(&models.GuardianLink{}).
+		Joins("JOIN users ON users.id = guardian_links.guardian_id").
+		Where("guardian_links.student_id = ? AND LOWER(users.email) = ?", studentID, email).
+		Count(&linked)
+	if linked > 0 {
+		return nil, utils.NewBadRequestError("This guardian is already linked")
+	}
+
+	var inviter models.User
+	if err := s.db.Select("id", "first_name", "last_name").First(&inviter, invitedBy).Error; err != nil {
+		return nil, utils.NewNotFoundError("User not found")
+	}
+
+	token := utils.GenerateRandomToken(32)
+	invitation := models.GuardianInvitation{
+		StudentID:    studentID,
+		InvitedBy:    invitedBy,
+		Email:        email,
+		Relationship: relationship,
+		TokenHash:    utils.HashToken(token),
+		ExpiresAt:    time.Now().Add(guardianInvitationTTL),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("student_id = ? AND email = ? AND accepted_at IS NULL", studentID, email).
+			Delete(&models.GuardianInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to create invitation", err)
+	}
+
+	if err := s.emailService.SendGuardianInvitation(email, authorName(student), authorName(&inviter), relationship, token, invitation.ExpiresAt); err != nil {
+		return nil, err
+	}
+	utils.InfoLogger.Printf("User %d invited a guardian for student %d", invitedBy, studentID)
+	return &invitation, nil
+}
+
+// InviteForStudent is Invite on behalf of staff, who may only invite
+// guardians for students in their scope: their school's, or for a teacher,
+// members of the groups they run.
+func (s *GuardianService) InviteForStudent(studentID, invitedBy uint, scope ClassScope, email, relationship string) (*models.GuardianInvitation, error) {
+	if err := studentInScope(s.db, studentID, scope); err != nil {
+		return nil, err
+	}
+	return s.Invite(studentID, invitedBy, email, relationship)
+}
+
+func (s *GuardianService) findInvitation(token string) (*models.GuardianInvitation, error) {
+	var invitation models.GuardianInvitation
+	if err := s.db.Preload("Student").Preload("Inviter").
+		Where("token_hash = ?", utils.HashToken(token)).
+		First(&invitation).Error; err != nil {
+		return nil, utils.NewNotFoundError("Invalid invitation")
+	}
+	if invitation.AcceptedAt != nil {
+		return nil, utils.NewBadRequestError("This invitation has already been accepted")
+	}
+	if invitation.ExpiresAt.Before(time.Now()) {
+		return nil, utils.NewBadRequestError("This invitation has expired")
+	}
+	return &invitation, nil
+}
+
+func (s *GuardianService) GetInvitation(token string) (*GuardianInvitationInfo, error) {
+	invitation, err := s.findInvitation(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts int64
+	s.db.Model(&models.User{}).Where("LOWER(email) = ?", invitation.Email).Count(&accounts)
+
+	return &GuardianInvitationInfo{
+		StudentName:  authorName(invitation.Student),
+		InviterName:  authorName(invitation.Inviter),
+		Email:        invitation.Email,
+		Relationship: invitation.Relationship,
+		ExpiresAt:    invitation.ExpiresAt,
+		HasAccount:   accounts > 0,
+	}, nil
+}
+
+// Accept links the signed-in user to the invitation's student. The
+// invitation must have been sent to their address.
+func (s *GuardianService) Accept(token string, userID uint) (*models.GuardianLink, error) {
+	invitation, err := s.findInvitation(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "email").First(&user, userID).Error; err != nil {
+		return nil, utils.NewNotFoundError("User not found")
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, utils.NewForbiddenError("This invitation was sent to a different email address")
+	}
+	if user.ID == invitation.StudentID {
+		return nil, utils.NewBadRequestError("A student can't be their own guardian")
+	}
+
+	var link *models.GuardianLink
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		link, err = acceptGuardianInvitation(tx, invitation, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// AcceptWithAccount creates a guardian account for the invited address and
+// links it. Following the emailed link proves the address, so the account
+// is verified and active straight away.
+func (s *GuardianService) AcceptWithAccount(token, firstName, lastName, password string) (*models.User, error) {
+	invitation, err := s.findInvitation(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	s.db.Model(&models.User{}).Where("LOWER(email) = ?", invitation.Email).Count(&existing)
+	if existing > 0 {
+		return nil, utils.NewBadRequestError("An account with this email already exists; sign in to accept the invitation")
+	}
+
+	var role models.Role
+	if err := s.db.Where("name = ?", "guardian").First(&role).Error; err != nil {
+		return nil, utils.NewInternalServerError("Guardian role is not set up", err)
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to hash password", err)
+	}
+
+	user := models.User{
+		Email:           invitation.Email,
+		Username:        s.guardianUsername(invitation.Email),
+		PasswordHash:    hashedPassword,
+		FirstName:       firstName,
+		LastName:        lastName,
+		RoleID:          role.ID,
+		IsActive:        true,
+		IsEmailVerified: true,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return utils.NewInternalServerError("Failed to create guardian account", err)
+		}
+		_, err := acceptGuardianInvitation(tx, invitation, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.InfoLogger.Printf("Guardian account %d created for student %d", user.ID, invitation.StudentID)
+	return &user, nil
+}
+
+// guardianUsername derives a free username from the email address.
+func (s *GuardianService) guardianUsername(email string) string {
+	base := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		s.db.Model(&models.User{}).Where("LOWER(username) = ?", username).Count(&count)
+		if count == 0 {
+			return username
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// acceptGuardianInvitation claims the invitation and links the guardian.
+// The invitation was read outside tx, so it is only claimed if it is still
+// open; of two concurrent accepts, the second finds it taken and rolls back.
+func acceptGuardianInvitation(tx *gorm.DB, invitation *models.GuardianInvitation, guardianID uint) (*models.GuardianLink, error) {
+	now := time.Now()
+	result := tx.Model(&models.GuardianInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND expires_at > ?", invitation.ID, now).
+		Updates(map[string]interface{}{
+			"accepted_at": now,
+			"accepted_by": guardianID,
+		})
+	if result.Error != nil {
+		return nil, utils.NewInternalServerError("Failed to accept invitation", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.NewBadRequestError("This invitation has already been accepted")
+	}
+
+	// The first digest goes out a week after linking
+	link := models.GuardianLink{GuardianID: guardianID, StudentID: invitation.StudentID}
+	if err := tx.Where(link).Attrs(models.GuardianLink{
+		Relationship:  invitation.Relationship,
+		DigestEnabled: true,
+		DigestSentAt:  &now,
+	}).FirstOrCreate(&link).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to accept invitation", err)
+	}
+	return &link, nil
+}
+
+// GetStudentGuardians returns the guardians linked to a student and the
+// invitations still waiting for an answer.
+func (s *GuardianService) GetStudentGuardians(studentID uint) ([]models.GuardianLink, []models.GuardianInvitation, error) {
+	var links []models.GuardianLink
+	if err := s.db.Preload("Guardian", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "first_name", "last_name", "email")
+	}).Where("student_id = ?", studentID).Order("created_at").Find(&links).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch guardians", err)
+	}
+
+	var invitations []models.GuardianInvitation
+	if err := s.db.Where("student_id = ? AND accepted_at IS NULL AND expires_at > ?", studentID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch invitations", err)
+	}
+	return links, invitations, nil
+}
+
+// GetStudentGuardiansScoped is GetStudentGuardians for staff.
+func (s *GuardianService) GetStudentGuardiansScoped(studentID uint, scope ClassScope) ([]models.GuardianLink, []models.GuardianInvitation, error) {
+	if err := studentInScope(s.db, studentID, scope); err != nil {
+		return nil, nil, err
+	}
+	return s.GetStudentGuardians(studentID)
+}
+
+// RemoveGuardian unlinks a guardian from a student. The link is deleted
+// outright so the guardian can be invited again.
+func (s *GuardianService) RemoveGuardian(studentID, linkID uint) error {
+	result := s.db.Unscoped().Where("id = ? AND student_id = ?", linkID, studentID).Delete(&models.GuardianLink{})
+	if result.Error != nil {
+		return utils.NewInternalServerError("Failed to remove guardian", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.NewNotFoundError("Guardian not found")
+	}
+	return nil
+}
+
+func (s *GuardianService) CancelInvitation(studentID, invitationID uint) error {
+	result := s.db.Where("id = ? AND student_id = ? AND accepted_at IS NULL", invitationID, studentID).Delete(&models.GuardianInvitation{})
+	if result.Error != nil {
+		return utils.NewInternalServerError("Failed to cancel invitation", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.NewNotFoundError("Invitation not found")
+	}
+	return nil
+}
+
+// link returns the guardian's link to the student, which is what grants
+// access to anything about them.
+func (s *GuardianService) link(guardianID, studentID uint) (*models.GuardianLink, error) {
+	var link models.GuardianLink
+	if err := s.db.Preload("Student.School").
+		Where("guardian_id = ? AND student_id = ?", guardianID, studentID).
+		First(&link).Error; err != nil {
+		return nil, utils.NewNotFoundError("Student not found")
+	}
+	return &link, nil
+}
+
+func (s *GuardianService) children(links []models.GuardianLink) ([]GuardianChild, error) {
+	studentIDs := make([]uint, len(links))
+	for i, link := range links {
+		studentIDs[i] = link.StudentID
+	}
+	streaks, err := s.streakService.GetStreaks(studentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	weekAgo := time.Now().Add(-guardianDigestEvery)
+	children := make([]GuardianChild, len(links))
+	for i, link := range links {
+		student := link.Student
+		child := GuardianChild{
+			LinkID:        link.ID,
+			StudentID:     link.StudentID,
+			Relationship:  link.Relationship,
+			DigestEnabled: link.DigestEnabled,
+			CurrentStreak: streaks[link.StudentID].Current,
+		}
+		if student != nil {
+			child.FirstName = student.FirstName
+			child.LastName = student.LastName
+			child.ClassLevel = student.ClassLevel
+			child.SchoolName = student.SchoolName
+			if student.School != nil {
+				child.SchoolName = student.School.Name
+			}
+		}
+
+		s.db.Model(&models.UserLibrary{}).Where("user_id = ?", link.StudentID).Count(&child.Books)
+		s.db.Model(&models.UserLibrary{}).Where("user_id = ? AND completed_at IS NOT NULL", link.StudentID).Count(&child.BooksCompleted)
+		var lastRead struct{ LastReadAt *time.Time }
+		s.db.Model(&models.UserLibrary{}).Where("user_id = ?", link.StudentID).Select("MAX(last_read_at) AS last_read_at").Scan(&lastRead)
+		child.LastReadAt = lastRead.LastReadAt
+
+		var seconds int64
+		s.db.Model(&models.ReadingSession{}).Where("user_id = ? AND start_time >= ?", link.StudentID, weekAgo).
+			Select("COALESCE(SUM(duration), 0)").Scan(&seconds)
+		child.MinutesThisWeek = math.Round(float64(seconds)/6) / 10
+
+		children[i] = child
+	}
+	return children, nil
+}
+
+// GetChildren returns the students the guardian follows.
+func (s *GuardianService) GetChildren(guardianID uint) ([]GuardianChild, error) {
+	var links []models.GuardianLink
+	if err := s.db.Preload("Student.School").Where("guardian_id = ?", guardianID).Order("created_at").Find(&links).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch children", err)
+	}
+	children, err := s.children(links)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch children", err)
+	}
+	return children, nil
+}
+
+func (s *GuardianService) GetChildOverview(guardianID, studentID uint) (*GuardianChildOverview, error) {
+	link, err := s.link(guardianID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := s.children([]models.GuardianLink{*link})
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch reading", err)
+	}
+	overview := GuardianChildOverview{GuardianChild: children[0]}
+
+	streak, err := s.streakService.GetStreak(studentID)
+	if err != nil {
+		return nil, err
+	}
+	overview.LongestStreak = streak.Longest
+
+	var seconds int64
+	s.db.Model(&models.ReadingSession{}).Where("user_id = ?", studentID).Select("COALESCE(SUM(duration), 0)").Scan(&seconds)
+	overview.TotalMinutes = math.Round(float64(seconds)/6) / 10
+
+	if err := s.db.Preload("Book").
+		Where("user_id = ? AND progress > 0 AND completed_at IS NULL", studentID).
+		Order("last_read_at DESC NULLS LAST").Limit(5).
+		Find(&overview.Reading).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch reading", err)
+	}
+
+	now := time.Now()
+	assignments := s.db.Model(&models.ReadingAssignmentStudent{}).
+		Joins("JOIN reading_assignments ON reading_assignments.id = reading_assignment_students.assignment_id AND reading_assignments.deleted_at IS NULL").
+		Where("reading_assignment_students.user_id = ? AND reading_assignment_students.status <> ?", studentID, AssignmentCompleted)
+	assignments.Session(&gorm.Session{}).Where("reading_assignments.due_at >= ?", now).Count(&overview.OpenAssignments)
+	assignments.Session(&gorm.Session{}).Where("reading_assignments.due_at < ?", now).Count(&overview.OverdueAssignments)
+
+	return &overview, nil
+}
+
+func (s *GuardianService) GetChildLibrary(guardianID, studentID uint, page, limit int) ([]models.UserLibrary, *utils.PaginationMeta, error) {
+	if _, err := s.link(guardianID, studentID); err != nil {
+		return nil, nil, err
+	}
+	return s.libraryService.GetUserLibrary(studentID, page, limit, "")
+}
+
+func (s *GuardianService) GetChildSessions(guardianID, studentID uint, page, limit int) ([]models.ReadingSession, *utils.PaginationMeta, error) {
+	if _, err := s.link(guardianID, studentID); err != nil {
+		return nil, nil, err
+	}
+	return s.sessionService.GetUserSessions(studentID, page, limit)
+}
+
+func (s *GuardianService) GetChildStreak(guardianID, studentID uint) (*Streak, error) {
+	if _, err := s.link(guardianID, studentID); err != nil {
+		return nil, err
+	}
+	return s.streakService.GetStreak(studentID)
+}
+
+func (s *GuardianService) GetChildAchievements(guardianID, studentID uint) ([]models.UserAchievement, error) {
+	if _, err := s.link(guardianID, studentID); err != nil {
+		return nil, err
+	}
+	return s.achievementService.GetUserAchievements(studentID)
+}
@@ -2,20 +2,23 @@ package services
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
+	"readagain/internal/queryspec"
 	"readagain/internal/utils"
 )
 
 type LibraryService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	streakService *StreakService
 }
 
-func NewLibraryService(db *gorm.DB) *LibraryService {
-	return &LibraryService{db: db}
+func NewLibraryService(db *gorm.DB, streakService *StreakService) *LibraryService {
+	return &LibraryService{db: db, streakService: streakService}
 }
 
 func (s *LibraryService) GetUserLibrary(userID uint, page, limit int, search string) ([]models.UserLibrary, *utils.PaginationMeta, error) {
@@ -24,7 +27,8 @@ func (s *LibraryService) GetUserLibrary(userID uint, page, limit int, search str
 	query := s.db.Model(&models.UserLibrary{}).
 		Where("user_id = ?", userID).
 		Preload("Book.Author.User").
-		Preload("Book.Category")
+		Preload("Book.Category").
+		Preload("Chapter")
 
 	if search != "" {
 		query = query.Joins("JOIN books ON books.id = user_libraries.book_id").
@@ -50,31 +54,28 @@ func (s *LibraryService) GetLibraryItem(userID, bookID uint) (*models.UserLibrar
 	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).
 		Preload("Book.Author.User").
 		Preload("Book.Category").
+		Preload("Chapter").
 		First(&library).Error; err != nil {
 		return nil, utils.NewNotFoundError("Book not found in library")
 	}
 	return &library, nil
 }
 
+// UpdateProgress serves clients that predate locators. Their numbers are
+// turned into a locator stamped with the server clock and stored through
+// UpdatePosition, so progress is still computed here.
 func (s *LibraryService) UpdateProgress(userID, bookID uint, currentPage, totalPages int, progress float64) error {
-	var library models.UserLibrary
-	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
-		return utils.NewNotFoundError("Book not found in library")
-	}
-
-	library.CurrentPage = currentPage
-	library.Progress = progress
-
-	if progress >= 100 && library.CompletedAt == nil {
-		now := time.Now()
-		library.CompletedAt = &now
+	locator := &models.Locator{TotalProgression: progress / 100}
+	if currentPage > 0 && totalPages > 0 {
+		locator.Page = currentPage
+		locator.TotalProgression = math.Min(float64(currentPage)/float64(totalPages), 1)
 	}
 
-	if err := s.db.Save(&library).Error; err != nil {
-		return utils.NewInternalServerError("Failed to update progress", err)
-	}
-
-	return nil
+	_, err := s.UpdatePosition(userID, bookID, PositionUpdate{
+		Locator:   locator,
+		UpdatedAt: time.Now(),
+	})
+	return err
 }
 
 func (s *LibraryService) GetReadingStatistics(userID uint) (map[string]interface{}, error) {
@@ -87,31 +88,17 @@ func (s *LibraryService) GetReadingStatistics(userID uint) (map[string]interface
 	var totalReadingTime int64
 	s.db.Model(&models.ReadingSession{}).Where("user_id = ?", userID).Select("COALESCE(SUM(duration), 0)").Scan(&totalReadingTime)
 
-	var currentStreak int
-	var lastReadDate *time.Time
-	s.db.Model(&models.ReadingSession{}).
-		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Limit(1).
-		Pluck("DATE(created_at)", &lastReadDate)
-
-	if lastReadDate != nil {
-		daysSince := int(time.Since(*lastReadDate).Hours() / 24)
-		if daysSince <= 1 {
-			s.db.Raw(`
-				SELECT COUNT(DISTINCT DATE(created_at))
-				FROM reading_sessions
-				WHERE user_id = ?
-				AND created_at >= NOW() - INTERVAL '30 days'
-			`, userID).Scan(&currentStreak)
-		}
+	streak, err := s.streakService.GetStreak(userID)
+	if err != nil {
+		return nil, err
 	}
 
 	return map[string]interface{}{
 		"total_books":        totalBooks,
 		"completed_books":    completedBooks,
 		"total_reading_time": totalReadingTime,
-		"current_streak":     currentStreak,
+		"current_streak":     streak.Current,
+		"longest_streak":     streak.Longest,
 	}, nil
 }
 
@@ -125,10 +112,17 @@ func (s *LibraryService) GetDashboardStats(userID uint) (map[string]interface{},
 	var reviewsCount int64
 	s.db.Model(&models.Review{}).Where("user_id = ?", userID).Count(&reviewsCount)
 
+	streak, err := s.streakService.GetStreak(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"books_read":       booksRead,
 		"books_in_library": booksInLibrary,
 		"reviews_count":    reviewsCount,
+		"current_streak":   streak.Current,
+		"longest_streak":   streak.Longest,
 	}, nil
 }
 
@@ -145,18 +139,46 @@ func (s *LibraryService) GetUserAnalytics(userID uint) (map[string]interface{},
 	var totalPagesRead int64
 	s.db.Model(&models.ReadingSession{}).Where("user_id = ?", userID).Select("COALESCE(SUM(pages_read), 0)").Scan(&totalPagesRead)
 
+	streak, err := s.streakService.GetStreak(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"total_books":        totalBooks,
 		"completed_books":    completedBooks,
 		"total_reading_time": totalReadingTime,
 		"total_pages_read":   totalPagesRead,
-		"current_streak":     0,
+		"current_streak":     streak.Current,
+		"longest_streak":     streak.Longest,
 	}, nil
 }
 
 
+// AssignmentListSpec whitelists the sort and filter parameters of the admin
+// library assignment list. user_name, book_title and status are select aliases.
+var AssignmentListSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"created_at":  "ul.created_at",
+		"assigned_at": "ul.created_at",
+		"progress":    "ul.progress",
+		"user_name":   "user_name",
+		"book_title":  "book_title",
+		"status":      "status",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"user_id":   {Column: "ul.user_id", Type: queryspec.Uint},
+		"book_id":   {Column: "ul.book_id", Type: queryspec.Uint},
+		"date_from": {Column: "DATE(ul.created_at)", Type: queryspec.Date, Op: ">="},
+		"date_to":   {Column: "DATE(ul.created_at)", Type: queryspec.Date, Op: "<="},
+	},
+	Params: []string{"search", "status"},
+}
+
 // Admin methods
-func (s *LibraryService) GetAllAssignments(skip, limit int, search, status, userID, sortBy, sortOrder string) ([]map[string]interface{}, int, error) {
+func (s *LibraryService) GetAllAssignments(skip, limit int, search, status string, q *queryspec.Query, scope ClassScope) ([]map[string]interface{}, int, error) {
 	query := s.db.Table("user_libraries ul").
 		Select(`ul.id, ul.user_id, ul.book_id, ul.progress, ul.created_at as assigned_at,
 			CONCAT(u.first_name, ' ', u.last_name) as user_name, u.email as user_email,
@@ -168,7 +190,9 @@ func (s *LibraryService) GetAllAssignments(skip, limit int, search, status, user
 			END as status`).
 		Joins("JOIN users u ON ul.user_id = u.id").
 		Joins("JOIN books b ON ul.book_id = b.id").
-		Joins("LEFT JOIN authors a ON b.author_id = a.id")
+		Joins("LEFT JOIN authors a ON b.author_id = a.id").
+		Scopes(q.Where)
+	query = scope.applyToLibraries(query, "ul")
 
 	if search != "" {
 		query = query.Where("u.first_name ILIKE ? OR u.last_name ILIKE ? OR u.email ILIKE ? OR b.title ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%", "%"+search+"%")
@@ -182,19 +206,11 @@ func (s *LibraryService) GetAllAssignments(skip, limit int, search, status, user
 			query = query.Where("ul.progress > 0 AND ul.progress < 100")
 		}
 	}
-	if userID != "" {
-		query = query.Where("ul.user_id = ?", userID)
-	}
 
 	var total int64
 	query.Count(&total)
 
-	// Prefix sortBy with table alias if it's created_at
-	if sortBy == "created_at" {
-		sortBy = "ul.created_at"
-	}
-	orderClause := sortBy + " " + sortOrder
-	query = query.Order(orderClause).Offset(skip).Limit(limit)
+	query = query.Scopes(q.OrderBy).Offset(skip).Limit(limit)
 
 	var assignments []map[string]interface{}
 	if err := query.Scan(&assignments).Error; err != nil {
@@ -204,16 +220,19 @@ func (s *LibraryService) GetAllAssignments(skip, limit int, search, status, user
 	return assignments, int(total), nil
 }
 
-func (s *LibraryService) GetLibraryStats() (map[string]interface{}, error) {
+// GetLibraryStats summarises the libraries in scope: a school's, or for a
+// teacher only their students'.
+func (s *LibraryService) GetLibraryStats(scope ClassScope) (map[string]interface{}, error) {
 	var totalAssignments, activeReaders int64
 	var avgProgress, completionRate float64
 
-	s.db.Model(&models.UserLibrary{}).Count(&totalAssignments)
-	s.db.Model(&models.UserLibrary{}).Distinct("user_id").Count(&activeReaders)
-	s.db.Model(&models.UserLibrary{}).Select("COALESCE(AVG(progress), 0)").Scan(&avgProgress)
+	libraries := func(db *gorm.DB) *gorm.DB { return scope.applyToLibraries(db, "user_libraries") }
+	s.db.Model(&models.UserLibrary{}).Scopes(libraries).Count(&totalAssignments)
+	s.db.Model(&models.UserLibrary{}).Scopes(libraries).Distinct("user_id").Count(&activeReaders)
+	s.db.Model(&models.UserLibrary{}).Scopes(libraries).Select("COALESCE(AVG(progress), 0)").Scan(&avgProgress)
 
 	var completed int64
-	s.db.Model(&models.UserLibrary{}).Where("progress = 100").Count(&completed)
+	s.db.Model(&models.UserLibrary{}).Scopes(libraries).Where("progress = 100").Count(&completed)
 	if totalAssignments > 0 {
 		completionRate = float64(completed) / float64(totalAssignments) * 100
 	}
@@ -234,8 +253,9 @@ func (s *LibraryService) AssignBook(userID, bookID uint, format string) error {
 	}
 
 	library := &models.UserLibrary{
-		UserID: userID,
-		BookID: bookID,
+		UserID:   userID,
+		BookID:   bookID,
+		SchoolID: schoolIDForUser(s.db, userID),
 	}
 
 	if err := s.db.Create(library).Error; err != nil {
@@ -255,8 +275,9 @@ func (s *LibraryService) BulkAssignBook(userIDs []uint, bookID uint) (int, error
 		s.db.Model(&models.UserLibrary{}).Where("user_id = ? AND book_id = ?", userID, bookID).Count(&exists)
 		if exists == 0 {
 			library := &models.UserLibrary{
-				UserID: userID,
-				BookID: bookID,
+				UserID:   userID,
+				BookID:   bookID,
+				SchoolID: schoolIDForUser(s.db, userID),
 			}
 			if err := s.db.Create(library).Error; err == nil {
 				count++
@@ -272,12 +293,16 @@ func (s *LibraryService) BulkAssignBook(userIDs []uint, bookID uint) (int, error
 	return count, nil
 }
 
-func (s *LibraryService) BulkRemoveAssignments(assignmentIDs []uint) (int, error) {
+// BulkRemoveAssignments deletes library rows, leaving out any outside the
+// caller's school.
+func (s *LibraryService) BulkRemoveAssignments(assignmentIDs []uint, schoolID *uint) (int, error) {
+	scope := ScopeToSchool("school_id", schoolID)
+
 	// Get book IDs before deletion
 	var assignments []models.UserLibrary
-	s.db.Where("id IN ?", assignmentIDs).Find(&assignments)
+	s.db.Scopes(scope).Where("id IN ?", assignmentIDs).Find(&assignments)
 	
-	result := s.db.Where("id IN ?", assignmentIDs).Delete(&models.UserLibrary{})
+	result := s.db.Scopes(scope).Where("id IN ?", assignmentIDs).Delete(&models.UserLibrary{})
 	
 	// Decrement library_count for each book
 	bookCounts := make(map[uint]int)
@@ -291,9 +316,9 @@ func (s *LibraryService) BulkRemoveAssignments(assignmentIDs []uint) (int, error
 	return int(result.RowsAffected), result.Error
 }
 
-func (s *LibraryService) RemoveAssignment(id uint) error {
+func (s *LibraryService) RemoveAssignment(id uint, schoolID *uint) error {
 	var assignment models.UserLibrary
-	if err := s.db.First(&assignment, id).Error; err != nil {
+	if err := s.db.Scopes(ScopeToSchool("school_id", schoolID)).First(&assignment, id).Error; err != nil {
 		return err
 	}
 	
@@ -307,14 +332,21 @@ func (s *LibraryService) RemoveAssignment(id uint) error {
 	return nil
 }
 
-func (s *LibraryService) GetBooksWithStudents(search string) ([]map[string]interface{}, error) {
+func (s *LibraryService) GetBooksWithStudents(search string, scope ClassScope) ([]map[string]interface{}, error) {
+	scoped := scope.SchoolID != nil || scope.TeacherID != nil
+	join := "LEFT JOIN user_libraries ul ON b.id = ul.book_id"
+	if scoped {
+		// Only the books the caller's students have
+		join = "JOIN user_libraries ul ON b.id = ul.book_id"
+	}
 	query := s.db.Table("books b").
 		Select(`b.id, b.title, b.author, b.cover_image,
 			COUNT(DISTINCT ul.user_id) as student_count,
 			COALESCE(AVG(ul.progress), 0) as avg_progress,
 			COUNT(CASE WHEN ul.progress = 100 THEN 1 END) as completed_count`).
-		Joins("LEFT JOIN user_libraries ul ON b.id = ul.book_id").
+		Joins(join).
 		Group("b.id, b.title, b.author, b.cover_image")
+	query = scope.applyToLibraries(query, "ul")
 
 	if search != "" {
 		query = query.Where("b.title ILIKE ? OR b.author ILIKE ?", "%"+search+"%", "%"+search+"%")
@@ -329,7 +361,7 @@ func (s *LibraryService) GetBooksWithStudents(search string) ([]map[string]inter
 	for i, book := range books {
 		bookID := book["id"]
 		var students []map[string]interface{}
-		s.db.Table("user_libraries ul").
+		scope.applyToLibraries(s.db.Table("user_libraries ul"), "ul").
 			Select(`ul.id as assignment_id, ul.progress, 
 				CASE 
 					WHEN ul.progress = 0 THEN 'unread'
@@ -346,9 +378,9 @@ func (s *LibraryService) GetBooksWithStudents(search string) ([]map[string]inter
 	return books, nil
 }
 
-func (s *LibraryService) GetAssignmentDetails(id uint) (map[string]interface{}, error) {
+func (s *LibraryService) GetAssignmentDetails(id uint, scope ClassScope) (map[string]interface{}, error) {
 	var assignment map[string]interface{}
-	err := s.db.Table("user_libraries ul").
+	err := scope.applyToLibraries(s.db.Table("user_libraries ul"), "ul").
 		Select(`ul.*, CONCAT(u.first_name, ' ', u.last_name) as user_name, u.email as user_email, 
 			b.title as book_title, a.business_name as book_author,
 			CASE 
@@ -363,7 +395,10 @@ func (s *LibraryService) GetAssignmentDetails(id uint) (map[string]interface{},
 		Scan(&assignment).Error
 
 	if err != nil {
-		return nil, err
+		return nil, utils.NewInternalServerError("Failed to fetch assignment", err)
+	}
+	if assignment == nil {
+		return nil, utils.NewNotFoundError("Assignment not found")
 	}
 
 	// Get user_id and book_id from assignment
@@ -385,10 +420,13 @@ func (s *LibraryService) GetAssignmentDetails(id uint) (map[string]interface{},
 	}, nil
 }
 
-func (s *LibraryService) GetAssignmentAnalytics(id uint) (map[string]interface{}, error) {
+func (s *LibraryService) GetAssignmentAnalytics(id uint, scope ClassScope) (map[string]interface{}, error) {
 	var assignment models.UserLibrary
-	if err := s.db.First(&assignment, id).Error; err != nil {
-		return nil, err
+	if err := scope.applyToLibraries(s.db, "user_libraries").First(&assignment, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, utils.NewNotFoundError("Assignment not found")
+		}
+		return nil, utils.NewInternalServerError("Failed to fetch assignment", err)
 	}
 
 	var totalSessions, totalReadingTime int64
@@ -404,11 +442,25 @@ func (s *LibraryService) GetAssignmentAnalytics(id uint) (map[string]interface{}
 	var goals []models.ReadingGoal
 	s.db.Where("user_id = ? AND book_id = ?", assignment.UserID, assignment.BookID).Find(&goals)
 
+	streak, err := s.streakService.GetStreak(assignment.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters, err := chapterReport(s.db, assignment.UserID, assignment.BookID)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"total_sessions":      totalSessions,
 		"total_reading_time":  totalReadingTime / 60,
 		"avg_session_time":    avgSessionTime / 60,
-		"reading_streak":      0,
+		"reading_streak":      streak.Current,
 		"goals":               goals,
+		"current_chapter_id":  chapters.CurrentChapterID,
+		"chapters_completed":  chapters.ChaptersCompleted,
+		"chapters_total":      chapters.ChaptersTotal,
+		"chapters":            chapters.Chapters,
 	}, nil
 }
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	AssignmentNotStarted = "not_started"
+	AssignmentInProgress = "in_progress"
+	AssignmentCompleted  = "completed"
+	AssignmentLate       = "late"
+
+	// isStudentUser keeps users holding the student role. Reading is only
+	// assigned to them, whoever else is in a group or was picked.
+	isStudentUser = "role_id IN (SELECT id FROM roles WHERE name = 'student' AND deleted_at IS NULL)"
+)
+
+// AssignmentInput is a new reading assignment. It goes to the members of
+// GroupID, to StudentIDs, or both. The required range is a chapter range
+// (one chapter when only StartChapterID is set), a page range, or the whole
+// book when neither is given.
+type AssignmentInput struct {
+	BookID         uint       `json:"book_id" validate:"required"`
+	GroupID        *uint      `json:"group_id"`
+	StudentIDs     []uint     `json:"student_ids"`
+	Title          string     `json:"title" validate:"max=255"`
+	Instructions   string     `json:"instructions" validate:"max=5000"`
+	StartChapterID *uint      `json:"start_chapter_id"`
+	EndChapterID   *uint      `json:"end_chapter_id"`
+	StartPage      int        `json:"start_page" validate:"gte=0"`
+	EndPage        int        `json:"end_page" validate:"gte=0"`
+	StartsAt       *time.Time `json:"starts_at"`
+	DueAt          time.Time  `json:"due_at" validate:"required"`
+}
+
+// AssignmentUpdate changes the details of an assignment; nil fields are
+// left as they are. The book, range and students are fixed once set.
+type AssignmentUpdate struct {
+	Title        *string    `json:"title" validate:"omitempty,max=255"`
+	Instructions *string    `json:"instructions" validate:"omitempty,max=5000"`
+	StartsAt     *time.Time `json:"starts_at"`
+	DueAt        *time.Time `json:"due_at"`
+}
+
+// AssignmentOverview is an assignment with how many of its students are in
+// each status.
+type AssignmentOverview struct {
+	models.ReadingAssignment
+	StatusCounts  map[string]int `json:"status_counts"`
+	TotalStudents int            `json:"total_students"`
+}
+
+type ReadingAssignmentService struct {
+	db                  *gorm.DB
+	notificationService *NotificationService
+}
+
+func NewReadingAssignmentService(db *gorm.DB, notificationService *NotificationService) *ReadingAssignmentService {
+	return &ReadingAssignmentService{db: db, notificationService: notificationService}
+}
+
+func assignmentTitle(assignment *models.ReadingAssignment) string {
+	if assignment.Title != "" {
+		return assignment.Title
+	}
+	if assignment.Book != nil {
+		return assignment.Book.Title
+	}
+	return "Reading assignment"
+}
+
+// assignmentRange resolves the required part of the book to fractions of
+// the whole book.
+func assignmentRange(db *gorm.DB, book *models.Book, input *AssignmentInput) (float64, float64, error) {
+	usesChapters := input.StartChapterID != nil || input.EndChapterID != nil
+	usesPages := input.StartPage > 0 || input.EndPage > 0
+
+	switch {
+	case usesChapters && usesPages:
+		return 0, 0, utils.NewBadRequestError("Give either a chapter range or a page range, not both")
+
+	case usesChapters:
+		if input.StartChapterID == nil {
+			return 0, 0, utils.NewBadRequestError("start_chapter_id is required with end_chapter_id")
+		}
+		var first, last models.BookChapter
+		if err := db.Where("id = ? AND book_id = ?", *input.StartChapterID, book.ID).First(&first).Error; err != nil {
+			return 0, 0, utils.NewBadRequestError("Start chapter not found in this book")
+		}
+		last = first
+		if input.EndChapterID != nil {
+			if err := db.Where("id = ? AND book_id = ?", *input.EndChapterID, book.ID).First(&last).Error; err != nil {
+				return 0, 0, utils.NewBadRequestError("End chapter not found in this book")
+			}
+		}
+		if last.EndProgression <= first.StartProgression {
+			return 0, 0, utils.NewBadRequestError("The end chapter comes before the start chapter")
+		}
+		return first.StartProgression, last.EndProgression, nil
+
+	case usesPages:
+		if book.Pages <= 0 {
+			return 0, 0, utils.NewBadRequestError("This book has no page count; assign chapters instead")
+		}
+		start, end := max(input.StartPage, 1), input.EndPage
+		if end == 0 {
+			end = book.Pages
+		}
+		if end > book.Pages || start > end {
+			return 0, 0, utils.NewBadRequestError(fmt.Sprintf("Pages must run from 1 to %d", book.Pages))
+		}
+		return float64(start-1) / float64(book.Pages), float64(end) / float64(book.Pages), nil
+	}
+	return 0, 1, nil
+}
+
+// assignmentStudents returns the ids of everyone the assignment goes to,
+// checking the caller may assign to them.
+func (s *ReadingAssignmentService) assignmentStudents(scope ClassScope, input *AssignmentInput) ([]uint, *uint, error) {
+	var schoolID *uint
+	seen := map[uint]bool{}
+	var userIDs []uint
+	add := func(ids []uint) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+
+	if input.GroupID != nil {
+		var group models.Group
+		if err := s.db.First(&group, *input.GroupID).Error; err != nil {
+			return nil, nil, utils.NewNotFoundError("Group not found")
+		}
+		if scope.TeacherID != nil && group.CreatedBy != *scope.TeacherID {
+			return nil, nil, utils.NewForbiddenError("You can only assign reading to your own groups")
+		}
+		if scope.SchoolID != nil && (group.SchoolID == nil || *group.SchoolID != *scope.SchoolID) {
+			return nil, nil, utils.NewNotFoundError("Group not found")
+		}
+		schoolID = group.SchoolID
+
+		var members []uint
+		if err := s.db.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL AND "+isStudentUser+")", group.ID).
+			Pluck("user_id", &members).Error; err != nil {
+			return nil, nil, utils.NewInternalServerError("Failed to fetch group members", err)
+		}
+		add(members)
+	}
+
+	if len(input.StudentIDs) > 0 {
+		query := s.db.Model(&models.User{}).Where("id IN ? AND "+isStudentUser, input.StudentIDs)
+		if scope.SchoolID != nil {
+			query = query.Where("school_id = ?", *scope.SchoolID)
+		}
+		var found []uint
+		if err := query.Pluck("id", &found).Error; err != nil {
+			return nil, nil, utils.NewInternalServerError("Failed to fetch students", err)
+		}
+		if len(found) != len(uniqueIDs(input.StudentIDs)) {
+			return nil, nil, utils.NewBadRequestError("Some students were not found")
+		}
+		add(found)
+	}
+
+	if len(userIDs) == 0 {
+		return nil, nil, utils.NewBadRequestError("The assignment needs a group with members or at least one student")
+	}
+	if schoolID == nil {
+		schoolID = scope.SchoolID
+	}
+	return userIDs, schoolID, nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// Create sets a book for students to read, puts it on their shelves and
+// notifies them.
+func (s *ReadingAssignmentService) Create(teacherID uint, scope ClassScope, input AssignmentInput) (*models.ReadingAssignment, error) {
+	var book models.Book
+	if err := s.db.First(&book, input.BookID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Book not found")
+	}
+
+	startsAt := time.Now()
+	if input.StartsAt != nil {
+		startsAt = *input.StartsAt
+	}
+	if !input.DueAt.After(startsAt) {
+		return nil, utils.NewBadRequestError("The due date must be after the start date")
+	}
+
+	start, end, err := assignmentRange(s.db, &book, &input)
+	if err != nil {
+		return nil, err
+	}
+	userIDs, schoolID, err := s.assignmentStudents(scope, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := models.ReadingAssignment{
+		BookID:           book.ID,
+		GroupID:          input.GroupID,
+		SchoolID:         schoolID,
+		AssignedBy:       teacherID,
+		Title:            input.Title,
+		Instructions:     input.Instructions,
+		StartChapterID:   input.StartChapterID,
+		EndChapterID:     input.EndChapterID,
+		StartPage:        input.StartPage,
+		EndPage:          input.EndPage,
+		StartProgression: start,
+		EndProgression:   end,
+		StartsAt:         startsAt,
+		DueAt:            input.DueAt,
+	}
+	for _, userID := range userIDs {
+		assignment.Students = append(assignment.Students, models.ReadingAssignmentStudent{UserID: userID, Status: AssignmentNotStarted})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+		if input.GroupID != nil {
+			// Lets annotations shared with the group reach its members
+			groupBook := models.GroupBook{GroupID: *input.GroupID, BookID: book.ID, AssignedBy: teacherID}
+			if err := tx.Where(models.GroupBook{GroupID: *input.GroupID, BookID: book.ID}).FirstOrCreate(&groupBook).Error; err != nil {
+				return err
+			}
+		}
+		for _, userID := range userIDs {
+			if err := addToLibrary(tx, userID, book.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to create assignment", err)
+	}
+
+	assignment.Book = &book
+	for _, userID := range userIDs {
+		s.notifyAssigned(userID, &assignment)
+	}
+	utils.InfoLogger.Printf("User %d assigned book %d to %d students (assignment %d)", teacherID, book.ID, len(userIDs), assignment.ID)
+	return &assignment, nil
+}
+
+func (s *ReadingAssignmentService) notifyAssigned(userID uint, assignment *models.ReadingAssignment) {
+	message := fmt.Sprintf("Read %s by %s.", assignmentTitle(assignment), assignment.DueAt.Format("Jan 2, 2006"))
+	if err := s.notificationService.Notify(userID, "assignment", "New reading assignment", message, fmt.Sprintf("/reading/%d", assignment.BookID)); err != nil {
+		utils.ErrorLogger.Printf("Failed to notify user %d of assignment %d: %v", userID, assignment.ID, err)
+	}
+}
+
+// enrollInGroupAssignments adds students who join a group to the group's
+// assignments that aren't due yet. Other members are left out.
+func enrollInGroupAssignments(tx *gorm.DB, groupID uint, userIDs []uint) error {
+	var assignments []models.ReadingAssignment
+	if err := tx.Where("group_id = ? AND due_at > ?", groupID, time.Now()).Find(&assignments).Error; err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	var students []uint
+	if err := tx.Model(&models.User{}).Where("id IN ? AND "+isStudentUser, userIDs).Pluck("id", &students).Error; err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		for _, userID := range students {
+			student := models.ReadingAssignmentStudent{AssignmentID: assignment.ID, UserID: userID}
+			if err := tx.Where(student).Attrs(models.ReadingAssignmentStudent{Status: AssignmentNotStarted}).FirstOrCreate(&student).Error; err != nil {
+				return err
+			}
+			if err := addToLibrary(tx, userID, assignment.BookID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ReadingAssignmentService) find(id uint, scope ClassScope) (*models.ReadingAssignment, error) {
+	var assignment models.ReadingAssignment
+	if err := scope.apply(s.db).Preload("Book").First(&assignment, id).Error; err != nil {
+		return nil, utils.NewNotFoundError("Assignment not found")
+	}
+	return &assignment, nil
+}
+
+func (s *ReadingAssignmentService) statusCounts(assignmentIDs []uint) (map[uint]map[string]int, error) {
+	var rows []struct {
+		AssignmentID uint
+		Status       string
+		Count        int
+	}
+	err := s.db.Model(&models.ReadingAssignmentStudent{}).
+		Select("assignment_id, status, COUNT(*) AS count").
+		Where("assignment_id IN ?", assignmentIDs).
+		Group("assignment_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[uint]map[string]int{}
+	for _, id := range assignmentIDs {
+		counts[id] = map[string]int{AssignmentNotStarted: 0, AssignmentInProgress: 0, AssignmentCompleted: 0, AssignmentLate: 0}
+	}
+	for _, row := range rows {
+		counts[row.AssignmentID][row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func overview(assignment models.ReadingAssignment, counts map[string]int) AssignmentOverview {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return AssignmentOverview{ReadingAssignment: assignment, StatusCounts: counts, TotalStudents: total}
+}
+
+// List returns the assignments in scope, newest due date first, with
+// status counts. groupID narrows them to one group.
+func (s *ReadingAssignmentService) List(scope ClassScope, groupID *uint, page, limit int) ([]AssignmentOverview, *utils.PaginationMeta, error) {
+	params := utils.GetPaginationParams(page, limit)
+	query := scope.apply(s.db.Model(&models.ReadingAssignment{}))
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to count assignments", err)
+	}
+
+	var assignments []models.ReadingAssignment
+	if err := query.Preload("Book").Preload("Group").Scopes(utils.Paginate(params)).Order("due_at DESC").Find(&assignments).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch assignments", err)
+	}
+
+	ids := make([]uint, len(assignments))
+	for i, assignment := range assignments {
+		ids[i] = assignment.ID
+	}
+	counts, err := s.statusCounts(ids)
+	if err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to count assignment statuses", err)
+	}
+
+	result := make([]AssignmentOverview, len(assignments))
+	for i, assignment := range assignments {
+		result[i] = overview(assignment, counts[assignment.ID])
+	}
+	meta := utils.GetPaginationMeta(params.Page, params.Limit, total)
+	return result, &meta, nil
+}
+
+// Get returns an assignment with every student's status.
+func (s *ReadingAssignmentService) Get(id uint, scope ClassScope) (*AssignmentOverview, error) {
+	assignment, err := s.find(id, scope)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Preload("Group").Preload("Assigner", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "first_name", "last_name", "email")
+	}).Preload("Students", func(db *gorm.DB) *gorm.DB {
+		return db.Order("status, user_id")
+	}).Preload("Students.User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "first_name", "last_name", "email", "class_level")
+	}).First(assignment, id).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch assignment", err)
+	}
+
+	counts, err := s.statusCounts([]uint{id})
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to count assignment statuses", err)
+	}
+	result := overview(*assignment, counts[id])
+	return &result, nil
+}
+
+// Update changes an assignment's details. Moving the due date later gives
+// late students time again and re-arms the overdue reminders.
+func (s *ReadingAssignmentService) Update(id uint, scope ClassScope, update AssignmentUpdate) (*models.ReadingAssignment, error) {
+	assignment, err := s.find(id, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.Title != nil {
+		updates["title"] = *update.Title
+	}
+	if update.Instructions != nil {
+		updates["instructions"] = *update.Instructions
+	}
+	startsAt, dueAt := assignment.StartsAt, assignment.DueAt
+	if update.StartsAt != nil {
+		startsAt = *update.StartsAt
+		updates["starts_at"] = startsAt
+	}
+	if update.DueAt != nil {
+		dueAt = *update.DueAt
+		updates["due_at"] = dueAt
+		if dueAt.After(time.Now()) {
+			updates["overdue_notified_at"] = nil
+		}
+	}
+	if !dueAt.After(startsAt) {
+		return nil, utils.NewBadRequestError("The due date must be after the start date")
+	}
+	if len(updates) == 0 {
+		return assignment, nil
+	}
+
+	if err := s.db.Model(assignment).Updates(updates).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to update assignment", err)
+	}
+	// Statuses depend on the dates, so work them out again from scratch
+	if update.StartsAt != nil || update.DueAt != nil {
+		if err := s.db.Model(&models.ReadingAssignmentStudent{}).
+			Where("assignment_id = ? AND status <> ?", id, AssignmentCompleted).
+			Updates(map[string]interface{}{"status": AssignmentNotStarted, "last_reminded_at": nil}).Error; err != nil {
+			return nil, utils.NewInternalServerError("Failed to update assignment", err)
+		}
+	}
+	return s.find(id, scope)
+}
+
+// Delete removes an assignment. The book stays on the students' shelves.
+func (s *ReadingAssignmentService) Delete(id uint, scope ClassScope) error {
+	assignment, err := s.find(id, scope)
+	if err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("assignment_id = ?", assignment.ID).Delete(&models.ReadingAssignmentStudent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(assignment).Error
+	})
+	if err != nil {
+		return utils.NewInternalServerError("Failed to delete assignment", err)
+	}
+	return nil
+}
+
+// GetStudentAssignments returns a student's assignments, soonest due first,
+// bringing the student's own unfinished ones up to date first.
+func (s *ReadingAssignmentService) GetStudentAssignments(userID uint, includeCompleted bool) ([]models.ReadingAssignmentStudent, error) {
+	var assignmentIDs []uint
+	if err := s.db.Model(&models.ReadingAssignmentStudent{}).Where("user_id = ? AND status <> ?", userID, AssignmentCompleted).Pluck("assignment_id", &assignmentIDs).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch assignments", err)
+	}
+	var assignments []models.ReadingAssignment
+	if len(assignmentIDs) > 0 {
+		if err := s.db.Where("id IN ?", assignmentIDs).Find(&assignments).Error; err != nil {
+			return nil, utils.NewInternalServerError("Failed to fetch assignments", err)
+		}
+	}
+	if err := s.refresh(assignments, &userID); err != nil {
+		utils.ErrorLogger.Printf("Failed to refresh assignment statuses: %v", err)
+	}
+
+	query := s.db.Joins("JOIN reading_assignments ON reading_assignments.id = reading_assignment_students.assignment_id AND reading_assignments.deleted_at IS NULL").
+		Where("reading_assignment_students.user_id = ?", userID)
+	if !includeCompleted {
+		query = query.Where("reading_assignment_students.status <> ?", AssignmentCompleted)
+	}
+
+	var students []models.ReadingAssignmentStudent
+	if err := query.Preload("Assignment.Book").Preload("Assignment.Assigner", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "first_name", "last_name")
+	}).Order("reading_assignments.due_at").Find(&students).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch assignments", err)
+	}
+	return students, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	// assignmentReminderInterval is how often overdue assignments are
+	// checked, and assignmentRemindEvery how often one student is reminded.
+	assignmentReminderInterval = time.Hour
+	assignmentRemindEvery      = 24 * time.Hour
+	// Students stop being reminded this long after the due date.
+	assignmentRemindFor = 14 * 24 * time.Hour
+)
+
+// studentReading is what a student read of an assignment's range.
+type studentReading struct {
+	coverage  float64
+	seconds   float64
+	startedAt *time.Time
+}
+
+// readingFor works out each student's reading of the assignment's range
+// from their reading sessions since it started.
+func (s *ReadingAssignmentService) readingFor(assignment *models.ReadingAssignment, userIDs []uint) (map[uint]*studentReading, error) {
+	var pages int
+	s.db.Model(&models.Book{}).Where("id = ?", assignment.BookID).Select("pages").Scan(&pages)
+
+	var sessions []models.ReadingSession
+	if err := s.db.Where("user_id IN ? AND book_id = ? AND start_time >= ?", userIDs, assignment.BookID, assignment.StartsAt).
+		Order("start_time").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	spans := map[uint][]readSpan{}
+	readings := map[uint]*studentReading{}
+	for _, session := range sessions {
+		reading, ok := readings[session.UserID]
+		if !ok {
+			startedAt := session.StartTime
+			reading = &studentReading{startedAt: &startedAt}
+			readings[session.UserID] = reading
+		}
+		span := sessionSpan(session, pages)
+		spans[session.UserID] = append(spans[session.UserID], span)
+
+		start, end := assignment.StartProgression, assignment.EndProgression
+		if span.end <= span.start {
+			if span.start >= start && span.start < end {
+				reading.seconds += float64(span.seconds)
+			}
+		} else if overlap := math.Min(span.end, end) - math.Max(span.start, start); overlap > 0 {
+			reading.seconds += float64(span.seconds) * overlap / (span.end - span.start)
+		}
+	}
+	for userID, reading := range readings {
+		reading.coverage = spanCoverage(mergeSpans(spans[userID]), assignment.StartProgression, assignment.EndProgression)
+	}
+	return readings, nil
+}
+
+// refresh brings the stored status of the unfinished students on the
+// assignments up to date: all of them, or only userID when it is set.
+// Completed stays completed. Reads don't call it; statuses move on when a
+// session ends and in RunReminderWorker.
+func (s *ReadingAssignmentService) refresh(assignments []models.ReadingAssignment, userID *uint) error {
+	now := time.Now()
+	for i := range assignments {
+		assignment := &assignments[i]
+		query := s.db.Where("assignment_id = ? AND status <> ?", assignment.ID, AssignmentCompleted)
+		if userID != nil {
+			query = query.Where("user_id = ?", *userID)
+		}
+		var students []models.ReadingAssignmentStudent
+		if err := query.Find(&students).Error; err != nil {
+			return err
+		}
+		if len(students) == 0 {
+			continue
+		}
+
+		userIDs := make([]uint, len(students))
+		for j, student := range students {
+			userIDs[j] = student.UserID
+		}
+		readings, err := s.readingFor(assignment, userIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, student := range students {
+			reading := readings[student.UserID]
+			if reading == nil {
+				reading = &studentReading{}
+			}
+
+			status := AssignmentNotStarted
+			switch {
+			case reading.coverage >= chapterCompleteAt:
+				status = AssignmentCompleted
+			case now.After(assignment.DueAt):
+				status = AssignmentLate
+			case reading.startedAt != nil:
+				status = AssignmentInProgress
+			}
+
+			updates := map[string]interface{}{}
+			if status != student.Status {
+				updates["status"] = status
+			}
+			if status == AssignmentCompleted {
+				updates["completed_at"] = now
+			}
+			if progress := math.Round(reading.coverage*1000) / 10; progress != student.Progress {
+				updates["progress"] = progress
+			}
+			if seconds := int(math.Round(reading.seconds)); seconds != student.TimeSpent {
+				updates["time_spent"] = seconds
+			}
+			if reading.startedAt != nil && (student.StartedAt == nil || !student.StartedAt.Equal(*reading.startedAt)) {
+				updates["started_at"] = *reading.startedAt
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := s.db.Model(&student).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RefreshStudent brings a student's unfinished assignments of a book up to
+// date, once a reading session of it has ended.
+func (s *ReadingAssignmentService) RefreshStudent(userID, bookID uint) error {
+	var assignments []models.ReadingAssignment
+	if err := s.db.Where("book_id = ? AND starts_at <= ?", bookID, time.Now()).
+		Where("id IN (?)", s.db.Model(&models.ReadingAssignmentStudent{}).
+			Select("assignment_id").
+			Where("user_id = ? AND status <> ?", userID, AssignmentCompleted)).
+		Find(&assignments).Error; err != nil {
+		return err
+	}
+	return s.refresh(assignments, &userID)
+}
+
+// refreshOpen brings every assignment that has started, and isn't past the
+// reminder window, up to date. Students turn late here, and reading from
+// sessions the sweeper closed is picked up.
+func (s *ReadingAssignmentService) refreshOpen() error {
+	now := time.Now()
+	var assignments []models.ReadingAssignment
+	if err := s.db.Where("starts_at <= ? AND due_at > ?", now, now.Add(-assignmentRemindFor)).
+		Find(&assignments).Error; err != nil {
+		return err
+	}
+	return s.refresh(assignments, nil)
+}
+
+// SendOverdueReminders reminds each late student of assignments past their
+// due date once a day for assignmentRemindFor and tells the teacher once
+// when the due date passes. It returns the reminders sent.
+func (s *ReadingAssignmentService) SendOverdueReminders() (int, error) {
+	now := time.Now()
+	var assignments []models.ReadingAssignment
+	if err := s.db.Preload("Book").
+		Where("due_at < ? AND due_at > ?", now, now.Add(-assignmentRemindFor)).
+		Find(&assignments).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range assignments {
+		assignment := &assignments[i]
+		title := assignmentTitle(assignment)
+
+		var late []models.ReadingAssignmentStudent
+		if err := s.db.Where("assignment_id = ? AND status = ? AND (last_reminded_at IS NULL OR last_reminded_at < ?)",
+			assignment.ID, AssignmentLate, now.Add(-assignmentRemindEvery)).Find(&late).Error; err != nil {
+			return sent, err
+		}
+		for _, student := range late {
+			message := fmt.Sprintf("%s was due %s and you've read %.0f%% of it.", title, assignment.DueAt.Format("Jan 2"), student.Progress)
+			if err := s.notificationService.Notify(student.UserID, "assignment_overdue", "Reading assignment overdue", message, fmt.Sprintf("/reading/%d", assignment.BookID)); err != nil {
+				utils.ErrorLogger.Printf("Failed to remind user %d of assignment %d: %v", student.UserID, assignment.ID, err)
+				continue
+			}
+			s.db.Model(&student).Update("last_reminded_at", now)
+			sent++
+		}
+
+		if assignment.OverdueNotifiedAt == nil {
+			var lateCount int64
+			s.db.Model(&models.ReadingAssignmentStudent{}).Where("assignment_id = ? AND status = ?", assignment.ID, AssignmentLate).Count(&lateCount)
+			if lateCount > 0 {
+				message := fmt.Sprintf("%d students haven't finished %s, which was due %s.", lateCount, title, assignment.DueAt.Format("Jan 2"))
+				if err := s.notificationService.Notify(assignment.AssignedBy, "assignment_overdue", "Students behind on an assignment", message, "/admin/library"); err != nil {
+					utils.ErrorLogger.Printf("Failed to notify teacher of assignment %d: %v", assignment.ID, err)
+					continue
+				}
+			}
+			s.db.Model(assignment).Update("overdue_notified_at", now)
+		}
+	}
+	return sent, nil
+}
+
+// RunReminderWorker refreshes open assignments and sends overdue reminders
+// every assignmentReminderInterval until the process exits.
+func (s *ReadingAssignmentService) RunReminderWorker() {
+	ticker := time.NewTicker(assignmentReminderInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.refreshOpen(); err != nil {
+			utils.ErrorLogger.Printf("Failed to refresh assignment statuses: %v", err)
+		}
+		sent, err := s.SendOverdueReminders()
+		if err != nil {
+			utils.ErrorLogger.Printf("Failed to send assignment reminders: %v", err)
+		} else if sent > 0 {
+			utils.InfoLogger.Printf("Sent %d overdue assignment reminders", sent)
+		}
+	}
+}
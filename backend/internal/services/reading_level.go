@@ -0,0 +1,86 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	ReadingLevelAssessed = "assessed"
+	ReadingLevelClass    = "class"
+)
+
+// StudentReadingLevel is the level books are matched against: the one a
+// teacher assessed, or failing that the grade implied by the class level.
+type StudentReadingLevel struct {
+	Grade  *int   `json:"grade"`
+	Score  *int   `json:"score"`
+	Source string `json:"source,omitempty"`
+}
+
+type ReadingLevelInput struct {
+	Grade *int `json:"grade" validate:"omitempty,min=0,max=12"`
+	Score *int `json:"score" validate:"omitempty,min=0,max=2000"`
+}
+
+var classLevelPattern = regexp.MustCompile(`(?i)^\s*(jss|jhs|sss|ss|shs|grade|primary|basic|class|year|p)\s*(\d{1,2})`)
+
+// gradeFromClassLevel reads a grade out of the free-form class level
+// students enter at signup: "Grade 5", "Primary 4", "JSS 2" and the like.
+// Junior and senior secondary years continue after primary 6.
+func gradeFromClassLevel(classLevel string) (int, bool) {
+	level := strings.ToLower(strings.TrimSpace(classLevel))
+	switch {
+	case level == "":
+		return 0, false
+	case strings.HasPrefix(level, "kg"), strings.HasPrefix(level, "kindergarten"), strings.HasPrefix(level, "nursery"), strings.HasPrefix(level, "reception"):
+		return 0, true
+	}
+
+	var prefix string
+	var number int
+	if match := classLevelPattern.FindStringSubmatch(level); match != nil {
+		prefix = match[1]
+		number, _ = strconv.Atoi(match[2])
+	} else if n, err := strconv.Atoi(level); err == nil {
+		number = n
+	} else {
+		return 0, false
+	}
+
+	switch prefix {
+	case "jss", "jhs":
+		number += 6
+	case "sss", "ss", "shs":
+		number += 9
+	}
+	if number < 0 || number > 12 {
+		return 0, false
+	}
+	return number, true
+}
+
+// readingLevelFor resolves the level to recommend books at.
+func readingLevelFor(user *models.User) StudentReadingLevel {
+	if user.ReadingGrade != nil {
+		return StudentReadingLevel{Grade: user.ReadingGrade, Score: user.ReadingScore, Source: ReadingLevelAssessed}
+	}
+	if grade, ok := gradeFromClassLevel(user.ClassLevel); ok {
+		return StudentReadingLevel{Grade: &grade, Score: user.ReadingScore, Source: ReadingLevelClass}
+	}
+	return StudentReadingLevel{Score: user.ReadingScore}
+}
+
+func checkReadingLevel(level models.ReadingLevel) error {
+	if (level.GradeMin == nil) != (level.GradeMax == nil) {
+		return utils.NewBadRequestError("Reading level needs both grade_min and grade_max")
+	}
+	if level.GradeMin != nil && *level.GradeMin > *level.GradeMax {
+		return utils.NewBadRequestError("Reading level grade_min can't be above grade_max")
+	}
+	return nil
+}
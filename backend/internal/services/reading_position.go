@@ -0,0 +1,193 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	// Readers rarely land exactly on the last character of a book, so a
+	// position this close to the end counts as finished.
+	positionCompleteAt = 0.995
+	// Reaching the end completes a book only once this share of it has been
+	// read through in reading sessions, as for chapters and assignments.
+	bookCompleteAt = chapterCompleteAt
+	// positionUnreadEnd is the progress of a reader at the end of a book they
+	// haven't read through, having jumped there from the contents or search.
+	positionUnreadEnd = 99.9
+	// Device timestamps further ahead of the server than this are clamped,
+	// so one device with a wrong clock can't pin the position forever.
+	positionMaxClockSkew = 5 * time.Minute
+)
+
+// PositionUpdate is a reading position reported by a device. UpdatedAt is
+// the device's clock when the reader got there, not when the request was
+// sent, so positions queued offline don't overwrite newer ones.
+type PositionUpdate struct {
+	Locator   *models.Locator `json:"locator" validate:"required"`
+	DeviceID  string          `json:"device_id" validate:"max=100"`
+	UpdatedAt time.Time       `json:"updated_at" validate:"required"`
+}
+
+type ReadingPosition struct {
+	BookID      uint            `json:"book_id"`
+	Locator     *models.Locator `json:"locator"`
+	Progress    float64         `json:"progress"`
+	CurrentPage int             `json:"current_page"`
+	ChapterID   *uint           `json:"chapter_id"`
+	UpdatedAt   *time.Time      `json:"updated_at"`
+	DeviceID    string          `json:"device_id,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at"`
+}
+
+// PositionResult is the stored position after an update. Conflict is set
+// when the update lost to a newer position from another device, in which
+// case Position is that newer position.
+type PositionResult struct {
+	Position *ReadingPosition `json:"position"`
+	Conflict bool             `json:"conflict"`
+}
+
+func readingPosition(library *models.UserLibrary) *ReadingPosition {
+	return &ReadingPosition{
+		BookID:      library.BookID,
+		Locator:     library.Locator,
+		Progress:    library.Progress,
+		CurrentPage: library.CurrentPage,
+		ChapterID:   library.ChapterID,
+		UpdatedAt:   library.PositionUpdatedAt,
+		DeviceID:    library.PositionDevice,
+		CompletedAt: library.CompletedAt,
+	}
+}
+
+// locatorProgress is the percentage (0-100) of the book before locator,
+// from the page for fixed-layout books and the total progression otherwise.
+func locatorProgress(locator *models.Locator, bookPages int) float64 {
+	fraction := locator.TotalProgression
+	if locator.Page > 0 && bookPages > 0 {
+		fraction = math.Min(float64(locator.Page)/float64(bookPages), 1)
+	}
+	if fraction >= positionCompleteAt {
+		return 100
+	}
+	return math.Round(fraction*1000) / 10
+}
+
+func (s *LibraryService) GetPosition(userID, bookID uint) (*ReadingPosition, error) {
+	var library models.UserLibrary
+	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
+		return nil, utils.NewNotFoundError("Book not found in library")
+	}
+	return readingPosition(&library), nil
+}
+
+// finishIfRead moves progress up to reached, the furthest point the reader
+// got to. At the end of the book it marks the book completed if enough of
+// it was read through in sessions, and otherwise holds progress just short
+// of 100 so the book isn't listed as completed.
+func finishIfRead(tx *gorm.DB, library *models.UserLibrary, reached float64, bookPages int, now time.Time) error {
+	library.Progress = math.Max(library.Progress, reached)
+	if library.CompletedAt != nil || library.Progress < positionUnreadEnd {
+		return nil
+	}
+
+	read, err := readCoverage(tx, library.UserID, library.BookID, bookPages)
+	if err != nil {
+		return err
+	}
+	if read >= bookCompleteAt {
+		library.Progress = 100
+		library.CompletedAt = &now
+	} else {
+		library.Progress = positionUnreadEnd
+	}
+	return nil
+}
+
+// completeIfRead records a read at now and rechecks completion for a reader
+// whose reading sessions have moved on while their position stood still.
+func completeIfRead(tx *gorm.DB, userID, bookID uint, bookPages int, now time.Time) error {
+	var library models.UserLibrary
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		First(&library).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if err := finishIfRead(tx, &library, 0, bookPages, now); err != nil {
+		return err
+	}
+	return tx.Model(&library).UpdateColumns(map[string]interface{}{
+		"last_read_at": now,
+		"progress":     library.Progress,
+		"completed_at": library.CompletedAt,
+	}).Error
+}
+
+// UpdatePosition stores the position if it is newer than the one on record
+// (last writer wins by device timestamp) and recomputes progress from it.
+// Progress is the furthest point reached, so going back to re-read a
+// chapter moves the position without undoing progress or completion.
+func (s *LibraryService) UpdatePosition(userID, bookID uint, update PositionUpdate) (*PositionResult, error) {
+	now := time.Now()
+	updatedAt := update.UpdatedAt
+	if updatedAt.After(now.Add(positionMaxClockSkew)) {
+		updatedAt = now
+	}
+
+	var result PositionResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var library models.UserLibrary
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND book_id = ?", userID, bookID).
+			First(&library).Error; err != nil {
+			return utils.NewNotFoundError("Book not found in library")
+		}
+
+		if current := library.PositionUpdatedAt; current != nil && !updatedAt.After(*current) {
+			// A retry of the write already on record is not a conflict.
+			result.Conflict = !(updatedAt.Equal(*current) && update.DeviceID == library.PositionDevice)
+			result.Position = readingPosition(&library)
+			return nil
+		}
+
+		var pages int
+		tx.Model(&models.Book{}).Where("id = ?", bookID).Select("pages").Scan(&pages)
+		chapters, err := loadChapters(tx, bookID)
+		if err != nil {
+			return utils.NewInternalServerError("Failed to fetch chapters", err)
+		}
+
+		library.Locator = update.Locator
+		library.PositionUpdatedAt = &updatedAt
+		library.PositionDevice = update.DeviceID
+		library.CurrentPage = locatorPage(update.Locator, pages)
+		library.ChapterID = chapterIDAt(chapters, update.Locator, pages)
+		library.LastReadAt = &now
+		if err := finishIfRead(tx, &library, locatorProgress(update.Locator, pages), pages, now); err != nil {
+			return utils.NewInternalServerError("Failed to fetch sessions", err)
+		}
+
+		if err := tx.Save(&library).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update reading position", err)
+		}
+
+		result.Position = readingPosition(&library)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -1,12 +1,31 @@
 package services
 
 import (
+	"math"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"readagain/internal/models"
 	"readagain/internal/utils"
 )
 
+const (
+	ReadingSessionActive    = "active"
+	ReadingSessionEnded     = "ended"
+	ReadingSessionAbandoned = "abandoned"
+
+	// Clients heartbeat every ~30s; a longer gap means the reader walked
+	// away and the gap is not counted as reading time.
+	readingIdleGap = 2 * time.Minute
+	// Sessions without a heartbeat for this long are closed by the sweeper.
+	readingAbandonAfter = 10 * time.Minute
+	// Forward locator movement faster than this is a jump (TOC, search),
+	// not reading, and only earns pages for the time actually spent.
+	readingMinSecondsPerPage = 6
+)
+
 type ReadingSessionService struct {
 	db *gorm.DB
 }
@@ -19,15 +38,65 @@ func (s *ReadingSessionService) GetDB() *gorm.DB {
 	return s.db
 }
 
-func (s *ReadingSessionService) StartSession(userID, bookID uint) (*models.ReadingSession, error) {
+// locatorPage maps a locator to a page number, estimating it from the total
+// progression for reflowable books that have no fixed pages.
+func locatorPage(locator *models.Locator, bookPages int) int {
+	if locator == nil {
+		return 0
+	}
+	if locator.Page > 0 {
+		return locator.Page
+	}
+	return int(math.Round(locator.TotalProgression * float64(bookPages)))
+}
+
+func (s *ReadingSessionService) bookPages(bookID uint) int {
+	var pages int
+	s.db.Model(&models.Book{}).Where("id = ?", bookID).Select("pages").Scan(&pages)
+	return pages
+}
+
+// chapterID maps a locator to its chapter; a book without chapters, or one
+// that can't be read, just leaves the session unmapped.
+func (s *ReadingSessionService) chapterID(bookID uint, locator *models.Locator, bookPages int) *uint {
+	if locator == nil {
+		return nil
+	}
+	chapters, err := loadChapters(s.db, bookID)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch chapters of book %d: %v", bookID, err)
+		return nil
+	}
+	return chapterIDAt(chapters, locator, bookPages)
+}
+
+func (s *ReadingSessionService) StartSession(userID, bookID uint, locator *models.Locator) (*models.ReadingSession, error) {
 	var library models.UserLibrary
 	if err := s.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&library).Error; err != nil {
 		return nil, utils.NewNotFoundError("Book not found in library")
 	}
 
+	startPage := library.CurrentPage
+	chapterID := library.ChapterID
+	if locator != nil {
+		pages := s.bookPages(bookID)
+		startPage = locatorPage(locator, pages)
+		chapterID = s.chapterID(bookID, locator, pages)
+	}
+
+	now := time.Now()
 	session := models.ReadingSession{
-		UserID: userID,
-		BookID: bookID,
+		UserID:          userID,
+		BookID:          bookID,
+		Status:          ReadingSessionActive,
+		StartTime:       now,
+		LastHeartbeatAt: now,
+		StartPage:       startPage,
+		EndPage:         startPage,
+		StartLocator:    locator,
+		EndLocator:      locator,
+		StartChapterID:  chapterID,
+		EndChapterID:    chapterID,
 	}
 
 	if err := s.db.Create(&session).Error; err != nil {
@@ -37,19 +106,98 @@ func (s *ReadingSessionService) StartSession(userID, bookID uint) (*models.Readi
 	return &session, nil
 }
 
-func (s *ReadingSessionService) EndSession(sessionID uint, duration int) error {
+// Heartbeat records that the reader is still on the page at locator.
+func (s *ReadingSessionService) Heartbeat(userID, sessionID uint, locator *models.Locator) (*models.ReadingSession, error) {
+	return s.advance(userID, sessionID, locator, false)
+}
+
+// EndSession closes the session. The duration is the server's own count;
+// the client no longer reports one.
+func (s *ReadingSessionService) EndSession(userID, sessionID uint, locator *models.Locator) (*models.ReadingSession, error) {
+	return s.advance(userID, sessionID, locator, true)
+}
+
+func (s *ReadingSessionService) advance(userID, sessionID uint, locator *models.Locator, end bool) (*models.ReadingSession, error) {
 	var session models.ReadingSession
-	if err := s.db.First(&session, sessionID).Error; err != nil {
-		return utils.NewNotFoundError("Session not found")
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", sessionID, userID).
+			First(&session).Error; err != nil {
+			return utils.NewNotFoundError("Session not found")
+		}
+
+		if session.Status != ReadingSessionActive {
+			return utils.NewBadRequestError("Session has already ended")
+		}
+
+		now := time.Now()
+		gap := now.Sub(session.LastHeartbeatAt)
+		active := gap > 0 && gap <= readingIdleGap
+		if active {
+			session.Duration += int(gap.Seconds())
+		}
+
+		if locator != nil {
+			pages := s.bookPages(session.BookID)
+			page := locatorPage(locator, pages)
+			if delta := page - session.EndPage; delta > 0 && active {
+				if maxPages := int(gap.Seconds()) / readingMinSecondsPerPage; delta > maxPages {
+					delta = maxPages
+				}
+				session.PagesRead += delta
+			}
+			session.EndPage = page
+			session.EndLocator = locator
+			session.EndChapterID = s.chapterID(session.BookID, locator, pages)
+		}
+
+		session.LastHeartbeatAt = now
+		if end {
+			session.Status = ReadingSessionEnded
+			session.EndTime = &now
+		}
+
+		if err := tx.Save(&session).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update session", err)
+		}
+
+		if end {
+			// The session may have read the last of a book the reader is
+			// already at the end of
+			return completeIfRead(tx, userID, session.BookID, s.bookPages(session.BookID), now)
+		}
+
+		return tx.Model(&models.UserLibrary{}).
+			Where("user_id = ? AND book_id = ?", userID, session.BookID).
+			Update("last_read_at", now).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	session.Duration = duration
+	return &session, nil
+}
 
-	if err := s.db.Save(&session).Error; err != nil {
-		return utils.NewInternalServerError("Failed to end session", err)
+// RunSessionSweeper closes sessions whose reader stopped sending heartbeats
+// (closed tab, dead battery) until the process exits.
+func (s *ReadingSessionService) RunSessionSweeper() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result := s.db.Model(&models.ReadingSession{}).
+			Where("status = ? AND last_heartbeat_at < ?", ReadingSessionActive, time.Now().Add(-readingAbandonAfter)).
+			Updates(map[string]interface{}{
+				"status":   ReadingSessionAbandoned,
+				"end_time": gorm.Expr("last_heartbeat_at"),
+			})
+		if result.Error != nil {
+			utils.ErrorLogger.Printf("Failed to sweep reading sessions: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			utils.InfoLogger.Printf("Closed %d abandoned reading sessions", result.RowsAffected)
+		}
 	}
-
-	return nil
 }
 
 func (s *ReadingSessionService) GetUserSessions(userID uint, page, limit int) ([]models.ReadingSession, *utils.PaginationMeta, error) {
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	RecommendLevelCategory = "level_category" // at their level, in a category they read
+	RecommendLevel         = "level"          // at their level
+	RecommendClassmates    = "classmates"     // popular with their class
+
+	maxRecommendations  = 50
+	engagedCategories   = 5
+	classmateMinReaders = 2
+)
+
+type RecommendedBook struct {
+	Book      models.Book `json:"book"`
+	Reason    string      `json:"reason"`
+	BecauseOf string      `json:"because_of,omitempty"` // title of the book a similar pick is like
+}
+
+type RecommendationService struct {
+	db *gorm.DB
+}
+
+func NewRecommendationService(db *gorm.DB) *RecommendationService {
+	return &RecommendationService{db: db}
+}
+
+// GetRecommended suggests published books the reader doesn't have yet.
+// Books whose grade band covers the reader's level come first, those in
+// the categories they engage with most ahead of the rest, then the closest
+// in score. When that runs short, books popular with students of the same
+// class level fill the list.
+func (s *RecommendationService) GetRecommended(userID uint, limit int) ([]RecommendedBook, *StudentReadingLevel, error) {
+	if limit <= 0 || limit > maxRecommendations {
+		limit = 10
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, nil, utils.NewNotFoundError("User not found")
+	}
+	level := readingLevelFor(&user)
+
+	categories, err := s.engagedCategories(userID)
+	if err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch recommendations", err)
+	}
+
+	result := []RecommendedBook{}
+	seen := map[uint]bool{}
+
+	if level.Grade != nil {
+		query := s.unread(userID).
+			Where("books.reading_grade_min <= ? AND books.reading_grade_max >= ?", *level.Grade, *level.Grade)
+		if len(categories) > 0 {
+			query = query.Order(clause.Expr{SQL: "books.category_id IN ? DESC", Vars: []interface{}{categories}})
+		}
+		if level.Score != nil {
+			query = query.Order(clause.Expr{SQL: "ABS(books.reading_score - ?) ASC NULLS LAST", Vars: []interface{}{*level.Score}})
+		}
+
+		var books []models.Book
+		if err := query.Order("books.library_count DESC").Limit(limit).Find(&books).Error; err != nil {
+			return nil, nil, utils.NewInternalServerError("Failed to fetch recommendations", err)
+		}
+		for _, book := range books {
+			reason := RecommendLevel
+			if book.CategoryID != nil && slices.Contains(categories, *book.CategoryID) {
+				reason = RecommendLevelCategory
+			}
+			result = append(result, RecommendedBook{Book: book, Reason: reason})
+			seen[book.ID] = true
+		}
+	}
+
+	if len(result) < limit && user.ClassLevel != "" {
+		books, err := s.classDefaults(&user, limit-len(result), seen)
+		if err != nil {
+			return nil, nil, utils.NewInternalServerError("Failed to fetch recommendations", err)
+		}
+		for _, book := range books {
+			result = append(result, RecommendedBook{Book: book, Reason: RecommendClassmates})
+		}
+	}
+
+	return result, &level, nil
+}
+
+// unread is the published books not in the user's library.
+func (s *RecommendationService) unread(userID uint) *gorm.DB {
+	return s.db.Model(&models.Book{}).
+		Preload("Category").
+		Preload("Author").
+		Preload("Author.User").
+		Where("books.status = ? AND books.is_active = ?", "published", true).
+		Where("books.id NOT IN (?)", s.libraryBooks(userID))
+}
+
+func (s *RecommendationService) libraryBooks(userID uint) *gorm.DB {
+	return s.db.Model(&models.UserLibrary{}).Select("book_id").Where("user_id = ?", userID)
+}
+
+// engagedCategories ranks the categories of the books in the user's
+// library by how much they read, finished, favourited and liked them.
+func (s *RecommendationService) engagedCategories(userID uint) ([]uint, error) {
+	var categories []uint
+	err := s.db.Model(&models.UserLibrary{}).
+		Joins("JOIN books ON books.id = user_libraries.book_id AND books.deleted_at IS NULL").
+		Where("user_libraries.user_id = ? AND books.category_id IS NOT NULL", userID).
+		Group("books.category_id").
+		Order(`SUM(1 + user_libraries.progress / 100
+			+ CASE WHEN user_libraries.is_favorite THEN 1 ELSE 0 END
+			+ GREATEST(user_libraries.rating - 3, 0)) DESC`).
+		Limit(engagedCategories).
+		Pluck("books.category_id", &categories).Error
+	return categories, err
+}
+
+// classDefaults returns the books most read by other students with the
+// same class level, from the same school when the user has one.
+func (s *RecommendationService) classDefaults(user *models.User, limit int, exclude map[uint]bool) ([]models.Book, error) {
+	classmates := s.db.Model(&models.User{}).Select("id").
+		Where("LOWER(class_level) = LOWER(?) AND id <> ?", user.ClassLevel, user.ID)
+	if user.SchoolID != nil {
+		classmates = classmates.Where("school_id = ?", *user.SchoolID)
+	}
+
+	popular := s.db.Model(&models.UserLibrary{}).
+		Select("book_id").
+		Where("user_id IN (?) AND book_id NOT IN (?)", classmates, s.libraryBooks(user.ID)).
+		Group("book_id").
+		Having("COUNT(DISTINCT user_id) >= ?", classmateMinReaders).
+		Order("COUNT(DISTINCT user_id) DESC").
+		Limit(limit + len(exclude))
+
+	var ranked []uint
+	if err := popular.Pluck("book_id", &ranked).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(ranked))
+	for _, id := range ranked {
+		if !exclude[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var books []models.Book
+	if err := s.unread(user.ID).Where("books.id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+
+	// Keep the popularity order
+	byID := make(map[uint]models.Book, len(books))
+	for _, book := range books {
+		byID[book.ID] = book
+	}
+	ordered := make([]models.Book, 0, limit)
+	for _, id := range ids {
+		if book, ok := byID[id]; ok && len(ordered) < limit {
+			ordered = append(ordered, book)
+		}
+	}
+	return ordered, nil
+}
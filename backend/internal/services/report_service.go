@@ -17,26 +17,26 @@ func NewReportService(db *gorm.DB) *ReportService {
 	return &ReportService{db: db}
 }
 
-func (s *ReportService) GeneratePDF(reportType string) ([]byte, error) {
+func (s *ReportService) GeneratePDF(reportType string, schoolID *uint) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 	pdf.SetFont("Arial", "B", 16)
 
 	switch reportType {
 	case "student_reading":
-		return s.generateStudentReadingReport(pdf)
+		return s.generateStudentReadingReport(pdf, schoolID)
 	case "book_popularity":
-		return s.generateBookPopularityReport(pdf)
+		return s.generateBookPopularityReport(pdf, schoolID)
 	case "reading_completion":
-		return s.generateReadingCompletionReport(pdf)
+		return s.generateReadingCompletionReport(pdf, schoolID)
 	case "library_usage":
-		return s.generateLibraryUsageReport(pdf)
+		return s.generateLibraryUsageReport(pdf, schoolID)
 	default:
 		return nil, fmt.Errorf("unknown report type: %s", reportType)
 	}
 }
 
-func (s *ReportService) generateStudentReadingReport(pdf *gofpdf.Fpdf) ([]byte, error) {
+func (s *ReportService) generateStudentReadingReport(pdf *gofpdf.Fpdf, schoolID *uint) ([]byte, error) {
 	pdf.Cell(0, 10, "Student Reading Report")
 	pdf.Ln(15)
 	pdf.SetFont("Arial", "", 10)
@@ -52,6 +52,8 @@ func (s *ReportService) generateStudentReadingReport(pdf *gofpdf.Fpdf) ([]byte,
 		ReadingTime int64
 	}
 
+	userSchool, args := schoolFilter("u.school_id", schoolID)
+
 	var students []StudentReading
 	s.db.Raw(`
 		SELECT u.name, u.email, u.class_level, u.school_name,
@@ -60,11 +62,11 @@ func (s *ReportService) generateStudentReadingReport(pdf *gofpdf.Fpdf) ([]byte,
 		FROM users u
 		LEFT JOIN user_libraries ul ON u.id = ul.user_id
 		LEFT JOIN reading_sessions rs ON u.id = rs.user_id
-		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')
+		WHERE u.role_id = (SELECT id FROM roles WHERE name = 'student')`+userSchool+`
 		GROUP BY u.id, u.name, u.email, u.class_level, u.school_name
 		ORDER BY books_read DESC
 		LIMIT 50
-	`).Scan(&students)
+	`, args...).Scan(&students)
 
 	pdf.SetFont("Arial", "B", 10)
 	pdf.Cell(50, 8, "Student")
@@ -87,7 +89,7 @@ func (s *ReportService) generateStudentReadingReport(pdf *gofpdf.Fpdf) ([]byte,
 	return buf.Bytes(), err
 }
 
-func (s *ReportService) generateBookPopularityReport(pdf *gofpdf.Fpdf) ([]byte, error) {
+func (s *ReportService) generateBookPopularityReport(pdf *gofpdf.Fpdf, schoolID *uint) ([]byte, error) {
 	pdf.Cell(0, 10, "Book Popularity Report")
 	pdf.Ln(15)
 	pdf.SetFont("Arial", "", 10)
@@ -102,13 +104,21 @@ func (s *ReportService) generateBookPopularityReport(pdf *gofpdf.Fpdf) ([]byte,
 		Rating       float64
 	}
 
+	librarySchool, args := schoolFilter("ul.school_id", schoolID)
+
 	var books []PopularBook
 	s.db.Raw(`
-		SELECT b.title, b.author, b.library_count, b.views, b.rating
+		SELECT b.title, a.business_name as author,
+		       COUNT(DISTINCT ul.id) as library_count,
+		       b.view_count as views,
+		       COALESCE((SELECT AVG(r.rating) FROM reviews r WHERE r.book_id = b.id), 0) as rating
 		FROM books b
-		ORDER BY b.library_count DESC
+		LEFT JOIN authors a ON b.author_id = a.id
+		LEFT JOIN user_libraries ul ON b.id = ul.book_id`+librarySchool+`
+		GROUP BY b.id, b.title, a.business_name, b.view_count
+		ORDER BY library_count DESC
 		LIMIT 50
-	`).Scan(&books)
+	`, args...).Scan(&books)
 
 	pdf.SetFont("Arial", "B", 10)
 	pdf.Cell(70, 8, "Book Title")
@@ -133,7 +143,7 @@ func (s *ReportService) generateBookPopularityReport(pdf *gofpdf.Fpdf) ([]byte,
 	return buf.Bytes(), err
 }
 
-func (s *ReportService) generateReadingCompletionReport(pdf *gofpdf.Fpdf) ([]byte, error) {
+func (s *ReportService) generateReadingCompletionReport(pdf *gofpdf.Fpdf, schoolID *uint) ([]byte, error) {
 	pdf.Cell(0, 10, "Reading Completion Report")
 	pdf.Ln(15)
 	pdf.SetFont("Arial", "", 10)
@@ -147,6 +157,8 @@ func (s *ReportService) generateReadingCompletionReport(pdf *gofpdf.Fpdf) ([]byt
 		AvgProgress    float64
 	}
 
+	librarySchool, args := schoolFilter("ul.school_id", schoolID)
+
 	var stats []CompletionStats
 	s.db.Raw(`
 		SELECT b.title as book_title,
@@ -154,12 +166,12 @@ func (s *ReportService) generateReadingCompletionReport(pdf *gofpdf.Fpdf) ([]byt
 		       SUM(CASE WHEN ul.progress = 100 THEN 1 ELSE 0 END) as completed_count,
 		       AVG(ul.progress) as avg_progress
 		FROM books b
-		LEFT JOIN user_libraries ul ON b.id = ul.book_id
+		LEFT JOIN user_libraries ul ON b.id = ul.book_id`+librarySchool+`
 		GROUP BY b.id, b.title
 		HAVING COUNT(ul.id) > 0
 		ORDER BY completed_count DESC
 		LIMIT 50
-	`).Scan(&stats)
+	`, args...).Scan(&stats)
 
 	pdf.SetFont("Arial", "B", 10)
 	pdf.Cell(80, 8, "Book Title")
@@ -182,7 +194,7 @@ func (s *ReportService) generateReadingCompletionReport(pdf *gofpdf.Fpdf) ([]byt
 	return buf.Bytes(), err
 }
 
-func (s *ReportService) generateLibraryUsageReport(pdf *gofpdf.Fpdf) ([]byte, error) {
+func (s *ReportService) generateLibraryUsageReport(pdf *gofpdf.Fpdf, schoolID *uint) ([]byte, error) {
 	pdf.Cell(0, 10, "Library Usage Report")
 	pdf.Ln(15)
 	pdf.SetFont("Arial", "", 10)
@@ -197,6 +209,8 @@ func (s *ReportService) generateLibraryUsageReport(pdf *gofpdf.Fpdf) ([]byte, er
 		TotalDuration  int64
 	}
 
+	librarySchool, args := schoolFilter("ul.school_id", schoolID)
+
 	var stats []UsageStats
 	s.db.Raw(`
 		SELECT DATE(ul.created_at) as date,
@@ -205,10 +219,10 @@ func (s *ReportService) generateLibraryUsageReport(pdf *gofpdf.Fpdf) ([]byte, er
 		       0 as total_sessions,
 		       0 as total_duration
 		FROM user_libraries ul
-		WHERE ul.created_at >= NOW() - INTERVAL '30 days'
+		WHERE ul.created_at >= NOW() - INTERVAL '30 days'`+librarySchool+`
 		GROUP BY DATE(ul.created_at)
 		ORDER BY date DESC
-	`).Scan(&stats)
+	`, args...).Scan(&stats)
 
 	pdf.SetFont("Arial", "B", 10)
 	pdf.Cell(40, 8, "Date")
@@ -7,6 +7,7 @@ import (
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
+	"readagain/internal/queryspec"
 	"readagain/internal/utils"
 )
 
@@ -34,7 +35,36 @@ func (s *ReviewService) Create(review *models.Review) error {
 	return s.db.Create(review).Error
 }
 
-func (s *ReviewService) GetBookReviews(bookID uint, page, limit int, approvedOnly bool) ([]models.Review, *utils.PaginationMeta, error) {
+// BookReviewSpec whitelists the sort parameters of a book's public reviews.
+var BookReviewSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"created_at": "reviews.created_at",
+		"rating":     "reviews.rating",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+}
+
+// ReviewListSpec whitelists the sort and filter parameters of the admin review list.
+var ReviewListSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"created_at": "reviews.created_at",
+		"rating":     "reviews.rating",
+		"status":     "reviews.status",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"status":      {Column: "reviews.status", Type: queryspec.String},
+		"is_featured": {Column: "reviews.is_featured", Type: queryspec.Bool},
+		"book_id":     {Column: "reviews.book_id", Type: queryspec.Uint},
+		"user_id":     {Column: "reviews.user_id", Type: queryspec.Uint},
+		"rating":      {Column: "reviews.rating", Type: queryspec.Int},
+	},
+	Params: []string{"class_level", "search"},
+}
+
+func (s *ReviewService) GetBookReviews(bookID uint, page, limit int, approvedOnly bool, q *queryspec.Query) ([]models.Review, *utils.PaginationMeta, error) {
 	var reviews []models.Review
 	var total int64
 
@@ -49,7 +79,7 @@ func (s *ReviewService) GetBookReviews(bookID uint, page, limit int, approvedOnl
 	}
 
 	offset := (page - 1) * limit
-	if err := query.Preload("User").Order("created_at DESC").Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+	if err := query.Preload("User").Scopes(q.OrderBy).Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
 		return nil, nil, err
 	}
 
@@ -63,14 +93,14 @@ func (s *ReviewService) GetBookReviews(bookID uint, page, limit int, approvedOnl
 	return reviews, meta, nil
 }
 
-func (s *ReviewService) ListAll(page, limit int, status, classLevel string) ([]models.Review, *utils.PaginationMeta, error) {
+func (s *ReviewService) ListAll(page, limit int, classLevel, search string, q *queryspec.Query) ([]models.Review, *utils.PaginationMeta, error) {
 	var reviews []models.Review
 	var total int64
 
-	query := s.db.Model(&models.Review{})
+	query := s.db.Model(&models.Review{}).Scopes(q.Where)
 
-	if status != "" {
-		query = query.Where("status = ?", status)
+	if search != "" {
+		query = query.Where("reviews.comment ILIKE ?", "%"+search+"%")
 	}
 
 	if classLevel != "" {
@@ -82,7 +112,7 @@ func (s *ReviewService) ListAll(page, limit int, status, classLevel string) ([]m
 	}
 
 	offset := (page - 1) * limit
-	if err := query.Preload("User").Preload("Book").Order("created_at DESC").Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
+	if err := query.Preload("User").Preload("Book").Scopes(q.OrderBy).Offset(offset).Limit(limit).Find(&reviews).Error; err != nil {
 		return nil, nil, err
 	}
 
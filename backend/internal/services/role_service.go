@@ -8,11 +8,38 @@ import (
 )
 
 type RoleService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *CacheService
 }
 
-func NewRoleService(db *gorm.DB) *RoleService {
-	return &RoleService{db: db}
+func NewRoleService(db *gorm.DB, cache *CacheService) *RoleService {
+	return &RoleService{db: db, cache: cache}
+}
+
+// UserPermissions is the cached snapshot the RBAC middleware authorizes against.
+type UserPermissions struct {
+	RoleID      uint     `json:"role_id"`
+	RoleName    string   `json:"role_name"`
+	SchoolID    *uint    `json:"school_id"`
+	Permissions []string `json:"permissions"`
+}
+
+func (p *UserPermissions) Has(permission string) bool {
+	for _, name := range p.Permissions {
+		if name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RoleService) invalidatePermissions() {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.InvalidateAllUserPermissions(); err != nil {
+		utils.ErrorLogger.Printf("Failed to invalidate permission cache: %v", err)
+	}
 }
 
 func (s *RoleService) GetDB() *gorm.DB {
@@ -101,6 +128,8 @@ func (s *RoleService) UpdateRole(roleID uint, name, description string, permissi
 		}
 	}
 
+	s.invalidatePermissions()
+
 	if err := s.db.Preload("Permissions").First(&role, role.ID).Error; err != nil {
 		return nil, utils.NewInternalServerError("Failed to fetch updated role", err)
 	}
@@ -126,6 +155,7 @@ func (s *RoleService) DeleteRole(roleID uint) error {
 		return utils.NewInternalServerError("Failed to delete role", err)
 	}
 
+	s.invalidatePermissions()
 	return nil
 }
 
@@ -152,6 +182,7 @@ func (s *RoleService) AddPermission(roleID, permissionID uint) error {
 		return utils.NewInternalServerError("Failed to add permission", err)
 	}
 
+	s.invalidatePermissions()
 	return nil
 }
 
@@ -170,5 +201,6 @@ func (s *RoleService) RemovePermission(roleID, permissionID uint) error {
 		return utils.NewInternalServerError("Failed to remove permission", err)
 	}
 
+	s.invalidatePermissions()
 	return nil
 }
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+type SchoolService struct {
+	db    *gorm.DB
+	cache *CacheService
+}
+
+func NewSchoolService(db *gorm.DB, cache *CacheService) *SchoolService {
+	return &SchoolService{db: db, cache: cache}
+}
+
+func (s *SchoolService) ListSchools(page, limit int, search string) ([]models.School, *utils.PaginationMeta, error) {
+	params := utils.GetPaginationParams(page, limit)
+
+	query := s.db.Model(&models.School{})
+
+	if search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to count schools", err)
+	}
+
+	var schools []models.School
+	if err := query.Scopes(utils.Paginate(params)).Order("name ASC").Find(&schools).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch schools", err)
+	}
+
+	meta := utils.GetPaginationMeta(params.Page, params.Limit, total)
+	return schools, &meta, nil
+}
+
+func (s *SchoolService) GetSchoolByID(schoolID uint) (*models.School, error) {
+	var school models.School
+	if err := s.db.First(&school, schoolID).Error; err != nil {
+		return nil, utils.NewNotFoundError("School not found")
+	}
+	return &school, nil
+}
+
+func (s *SchoolService) CreateSchool(school *models.School) error {
+	var existing models.School
+	if err := s.db.Where("name = ?", school.Name).First(&existing).Error; err == nil {
+		return utils.NewBadRequestError("School with this name already exists")
+	}
+
+	if err := s.db.Create(school).Error; err != nil {
+		return utils.NewInternalServerError("Failed to create school", err)
+	}
+	return nil
+}
+
+func (s *SchoolService) UpdateSchool(schoolID uint, updates map[string]interface{}) (*models.School, error) {
+	school, err := s.GetSchoolByID(schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(school).Updates(updates).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to update school", err)
+	}
+
+	return s.GetSchoolByID(schoolID)
+}
+
+func (s *SchoolService) DeleteSchool(schoolID uint) error {
+	var usersCount int64
+	if err := s.db.Model(&models.User{}).Where("school_id = ?", schoolID).Count(&usersCount).Error; err != nil {
+		return utils.NewInternalServerError("Failed to check school usage", err)
+	}
+
+	if usersCount > 0 {
+		return utils.NewBadRequestError("Cannot delete school that still has users")
+	}
+
+	if err := s.db.Delete(&models.School{}, schoolID).Error; err != nil {
+		return utils.NewInternalServerError("Failed to delete school", err)
+	}
+	return nil
+}
+
+func (s *SchoolService) AssignUsers(schoolID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return utils.NewBadRequestError("No user IDs provided")
+	}
+
+	if _, err := s.GetSchoolByID(schoolID); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id IN ?", userIDs).Update("school_id", schoolID).Error; err != nil {
+			return utils.NewInternalServerError("Failed to assign users to school", err)
+		}
+		if err := tx.Model(&models.UserLibrary{}).Where("user_id IN ?", userIDs).Update("school_id", schoolID).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update library assignments", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The permission snapshot carries the school scope
+	if s.cache != nil {
+		for _, userID := range userIDs {
+			s.cache.InvalidateUserPermissions(userID)
+		}
+	}
+	return nil
+}
+
+// ScopeToSchool limits a query to rows whose column matches schoolID. A nil
+// schoolID leaves the query untouched so platform admins keep a cross-school view.
+func ScopeToSchool(column string, schoolID *uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if schoolID == nil {
+			return db
+		}
+		return db.Where(column+" = ?", *schoolID)
+	}
+}
+
+// schoolFilter is the raw SQL counterpart of ScopeToSchool.
+func schoolFilter(column string, schoolID *uint) (string, []interface{}) {
+	if schoolID == nil {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []interface{}{*schoolID}
+}
+
+func schoolIDForUser(db *gorm.DB, userID uint) *uint {
+	var user models.User
+	if err := db.Select("id", "school_id").First(&user, userID).Error; err != nil {
+		return nil
+	}
+	return user.SchoolID
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"strconv"
+	"strings"
+
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
@@ -62,6 +65,21 @@ func (s *SettingsService) Set(key, value, category, description string) error {
 	}).Error
 }
 
+// GetBool reads a "true"/"false" setting, returning fallback when the key is
+// missing or unparseable.
+func (s *SettingsService) GetBool(key string, fallback bool) bool {
+	setting, err := s.GetByKey(key)
+	if err != nil {
+		return fallback
+	}
+
+	value, err := strconv.ParseBool(strings.TrimSpace(setting.Value))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func (s *SettingsService) Delete(key string) error {
 	return s.db.Where("key = ?", key).Delete(&models.SystemSettings{}).Error
 }
@@ -80,15 +98,30 @@ func (s *SettingsService) GetEmailSettings() (map[string]string, error) {
 	return result, nil
 }
 
-func (s *SettingsService) UpdateEmailSettings(resendAPIKey, fromEmail, fromName string) error {
-	if err := s.Set("email.resend_api_key", resendAPIKey, "email", "Resend API Key"); err != nil {
-		return err
-	}
-	if err := s.Set("email.from_email", fromEmail, "email", "From Email Address"); err != nil {
-		return err
-	}
-	if err := s.Set("email.from_name", fromName, "email", "From Name"); err != nil {
-		return err
+var emailSettingDescriptions = map[string]string{
+	"email.driver":          "Email Driver (resend, smtp, file)",
+	"email.resend_api_key":  "Resend API Key",
+	"email.from_email":      "From Email Address",
+	"email.from_name":       "From Name",
+	"email.smtp_host":       "SMTP Host",
+	"email.smtp_port":       "SMTP Port",
+	"email.smtp_username":   "SMTP Username",
+	"email.smtp_password":   "SMTP Password",
+	"email.smtp_encryption": "SMTP Encryption (starttls, tls, none)",
+	"email.file_dir":        "Directory for .eml files written by the file driver",
+}
+
+// UpdateEmailSettings stores the given email.* values. Empty values are
+// skipped so secrets such as the SMTP password are kept unless replaced.
+func (s *SettingsService) UpdateEmailSettings(values map[string]string) error {
+	for key, value := range values {
+		description, ok := emailSettingDescriptions[key]
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.Set(key, value, "email", description); err != nil {
+			return err
+		}
 	}
 	return nil
 }
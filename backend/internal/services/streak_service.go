@@ -0,0 +1,297 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/queryspec"
+	"readagain/internal/utils"
+)
+
+const (
+	// StreakMinMinutesSetting is how many minutes of reading make a day count.
+	StreakMinMinutesSetting = "reading.streak_min_minutes"
+	// StreakSkipWeekendsSetting freezes every Saturday and Sunday when "true".
+	StreakSkipWeekendsSetting = "reading.streak_skip_weekends"
+
+	defaultStreakMinMinutes = 10
+	streakDateLayout        = "2006-01-02"
+)
+
+// Streak is a reader's run of consecutive reading days. Days are calendar
+// days in the reader's timezone and count when at least MinMinutes were
+// read; frozen days (holidays, weekends if configured) are skipped without
+// breaking the run. Today only counts once it is reached, so a streak isn't
+// lost before the day is over.
+type Streak struct {
+	Current       int    `json:"current"`
+	Longest       int    `json:"longest"`
+	LastReadDate  string `json:"last_read_date,omitempty"`
+	MinutesToday  int    `json:"minutes_today"`
+	TodayComplete bool   `json:"today_complete"`
+	MinMinutes    int    `json:"min_minutes"`
+	Timezone      string `json:"timezone"`
+}
+
+type StreakService struct {
+	db              *gorm.DB
+	settingsService *SettingsService
+}
+
+func NewStreakService(db *gorm.DB, settingsService *SettingsService) *StreakService {
+	return &StreakService{db: db, settingsService: settingsService}
+}
+
+func (s *StreakService) minMinutes() int {
+	setting, err := s.settingsService.GetByKey(StreakMinMinutesSetting)
+	if err != nil {
+		return defaultStreakMinMinutes
+	}
+	minutes, err := strconv.Atoi(strings.TrimSpace(setting.Value))
+	if err != nil || minutes < 1 {
+		return defaultStreakMinMinutes
+	}
+	return minutes
+}
+
+// readerTimezoneSQL resolves a reader's timezone: their own, else their
+// school's, else UTC. It expects users u LEFT JOIN schools sc.
+const readerTimezoneSQL = "COALESCE(NULLIF(u.timezone, ''), NULLIF(sc.timezone, ''), 'UTC')"
+
+type streakReader struct {
+	UserID   uint
+	SchoolID *uint
+	Timezone string
+}
+
+type streakDay struct {
+	UserID  uint
+	Day     string
+	Seconds int64
+}
+
+// GetStreak returns one reader's streak.
+func (s *StreakService) GetStreak(userID uint) (*Streak, error) {
+	streaks, err := s.GetStreaks([]uint{userID})
+	if err != nil {
+		return nil, err
+	}
+	streak := streaks[userID]
+	return &streak, nil
+}
+
+// GetStreaks computes streaks for several readers with one query per table,
+// for leaderboards and class dashboards.
+func (s *StreakService) GetStreaks(userIDs []uint) (map[uint]Streak, error) {
+	streaks := make(map[uint]Streak, len(userIDs))
+	if len(userIDs) == 0 {
+		return streaks, nil
+	}
+
+	var readers []streakReader
+	if err := s.db.Table("users u").
+		Select("u.id AS user_id, u.school_id, "+readerTimezoneSQL+" AS timezone").
+		Joins("LEFT JOIN schools sc ON sc.id = u.school_id").
+		Where("u.id IN ?", userIDs).
+		Scan(&readers).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to load reader timezones", err)
+	}
+
+	var days []streakDay
+	if err := s.db.Table("reading_sessions rs").
+		Select("rs.user_id, TO_CHAR((rs.start_time AT TIME ZONE "+readerTimezoneSQL+")::date, 'YYYY-MM-DD') AS day, SUM(rs.duration) AS seconds").
+		Joins("JOIN users u ON u.id = rs.user_id").
+		Joins("LEFT JOIN schools sc ON sc.id = u.school_id").
+		Where("rs.user_id IN ? AND rs.deleted_at IS NULL AND rs.duration > 0", userIDs).
+		Group("rs.user_id, day").
+		Scan(&days).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to load reading days", err)
+	}
+
+	var freezes []models.StreakFreeze
+	if err := s.db.Where("user_id IN ? OR (user_id IS NULL AND (school_id IS NULL OR school_id IN (SELECT school_id FROM users WHERE id IN ?)))", userIDs, userIDs).
+		Find(&freezes).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to load streak freezes", err)
+	}
+
+	minMinutes := s.minMinutes()
+	skipWeekends := s.settingsService.GetBool(StreakSkipWeekendsSetting, false)
+
+	secondsByUser := make(map[uint]map[string]int64, len(userIDs))
+	for _, d := range days {
+		if secondsByUser[d.UserID] == nil {
+			secondsByUser[d.UserID] = map[string]int64{}
+		}
+		secondsByUser[d.UserID][d.Day] = d.Seconds
+	}
+
+	for _, reader := range readers {
+		frozen := map[string]bool{}
+		for _, f := range freezes {
+			switch {
+			case f.UserID != nil:
+				if *f.UserID == reader.UserID {
+					frozen[f.Date] = true
+				}
+			case f.SchoolID != nil:
+				if reader.SchoolID != nil && *f.SchoolID == *reader.SchoolID {
+					frozen[f.Date] = true
+				}
+			default:
+				frozen[f.Date] = true
+			}
+		}
+
+		loc, err := time.LoadLocation(reader.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		streak := computeStreak(secondsByUser[reader.UserID], frozen, skipWeekends, minMinutes, time.Now().In(loc))
+		streak.Timezone = loc.String()
+		streaks[reader.UserID] = streak
+	}
+
+	return streaks, nil
+}
+
+// computeStreak walks every day from the first qualifying one to today.
+func computeStreak(seconds map[string]int64, frozen map[string]bool, skipWeekends bool, minMinutes int, now time.Time) Streak {
+	today := now.Format(streakDateLayout)
+	streak := Streak{
+		MinutesToday: int(seconds[today] / 60),
+		MinMinutes:   minMinutes,
+	}
+	streak.TodayComplete = streak.MinutesToday >= minMinutes
+
+	first := ""
+	for day, secs := range seconds {
+		if int(secs/60) >= minMinutes && (first == "" || day < first) {
+			first = day
+		}
+		if int(secs/60) >= minMinutes && day > streak.LastReadDate {
+			streak.LastReadDate = day
+		}
+	}
+	if first == "" {
+		return streak
+	}
+
+	start, err := time.ParseInLocation(streakDateLayout, first, now.Location())
+	if err != nil {
+		return streak
+	}
+
+	run := 0
+	for d := start; d.Format(streakDateLayout) <= today; d = d.AddDate(0, 0, 1) {
+		day := d.Format(streakDateLayout)
+		weekend := d.Weekday() == time.Saturday || d.Weekday() == time.Sunday
+		switch {
+		case int(seconds[day]/60) >= minMinutes:
+			run++
+		case frozen[day] || (skipWeekends && weekend) || day == today:
+			// Skipped: the run carries over
+		default:
+			run = 0
+		}
+		streak.Longest = max(streak.Longest, run)
+	}
+	streak.Current = run
+
+	return streak
+}
+
+// StreakFreezeSpec whitelists the filters of the streak freeze list.
+var StreakFreezeSpec = &queryspec.Spec{
+	Sorts:        map[string]string{"date": "date", "created_at": "created_at"},
+	DefaultSort:  "date",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"user_id":   {Column: "user_id", Type: queryspec.Uint},
+		"school_id": {Column: "school_id", Type: queryspec.Uint},
+		"date_from": {Column: "date", Type: queryspec.Date, Op: ">="},
+		"date_to":   {Column: "date", Type: queryspec.Date, Op: "<="},
+	},
+}
+
+// ListFreezes returns freezes visible to an admin; school-scoped admins see
+// their school's and platform-wide ones.
+func (s *StreakService) ListFreezes(page, limit int, q *queryspec.Query, schoolID *uint) ([]models.StreakFreeze, *utils.PaginationMeta, error) {
+	params := utils.GetPaginationParams(page, limit)
+
+	query := s.db.Model(&models.StreakFreeze{}).Scopes(q.Where)
+	if schoolID != nil {
+		query = query.Where("(school_id = ? OR (school_id IS NULL AND user_id IS NULL) OR user_id IN (SELECT id FROM users WHERE school_id = ?))", *schoolID, *schoolID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to count streak freezes", err)
+	}
+
+	var freezes []models.StreakFreeze
+	if err := query.Scopes(q.OrderBy, utils.Paginate(params)).Find(&freezes).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to fetch streak freezes", err)
+	}
+
+	meta := utils.GetPaginationMeta(params.Page, params.Limit, total)
+	return freezes, &meta, nil
+}
+
+// CreateFreeze adds a holiday or a personal freeze. School-scoped admins can
+// only freeze days for their own school or its students.
+func (s *StreakService) CreateFreeze(freeze *models.StreakFreeze, schoolID *uint) error {
+	if freeze.UserID != nil {
+		var user models.User
+		if err := s.db.Select("id, school_id").First(&user, *freeze.UserID).Error; err != nil {
+			return utils.NewNotFoundError("User not found")
+		}
+		if schoolID != nil && (user.SchoolID == nil || *user.SchoolID != *schoolID) {
+			return utils.NewNotFoundError("User not found")
+		}
+		freeze.SchoolID = nil
+	} else if schoolID != nil {
+		freeze.SchoolID = schoolID
+	}
+
+	var existing int64
+	s.db.Model(&models.StreakFreeze{}).
+		Where("date = ? AND user_id IS NOT DISTINCT FROM ? AND school_id IS NOT DISTINCT FROM ?", freeze.Date, freeze.UserID, freeze.SchoolID).
+		Count(&existing)
+	if existing > 0 {
+		return utils.NewBadRequestError("This day is already frozen")
+	}
+
+	if err := s.db.Create(freeze).Error; err != nil {
+		return utils.NewInternalServerError("Failed to create streak freeze", err)
+	}
+	return nil
+}
+
+func (s *StreakService) DeleteFreeze(id uint, schoolID *uint) error {
+	var freeze models.StreakFreeze
+	if err := s.db.First(&freeze, id).Error; err != nil {
+		return utils.NewNotFoundError("Streak freeze not found")
+	}
+
+	if schoolID != nil {
+		owned := freeze.SchoolID != nil && *freeze.SchoolID == *schoolID
+		if freeze.UserID != nil {
+			var count int64
+			s.db.Model(&models.User{}).Where("id = ? AND school_id = ?", *freeze.UserID, *schoolID).Count(&count)
+			owned = count > 0
+		}
+		if !owned {
+			return utils.NewNotFoundError("Streak freeze not found")
+		}
+	}
+
+	if err := s.db.Delete(&freeze).Error; err != nil {
+		return utils.NewInternalServerError("Failed to delete streak freeze", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStreak(t *testing.T) {
+	// A Wednesday; the 9th and 10th are a weekend
+	now := time.Date(2024, 3, 13, 18, 30, 0, 0, time.UTC)
+	const minimum = 10 * 60 // seconds in the minimum
+
+	tests := []struct {
+		name         string
+		seconds      map[string]int64
+		frozen       map[string]bool
+		skipWeekends bool
+		minMinutes   int
+		want         Streak
+	}{
+		{
+			name:       "no reading",
+			minMinutes: 10,
+			want:       Streak{MinMinutes: 10},
+		},
+		{
+			name:       "run ending today",
+			seconds:    map[string]int64{"2024-03-11": minimum, "2024-03-12": minimum, "2024-03-13": 2 * minimum},
+			minMinutes: 10,
+			want:       Streak{Current: 3, Longest: 3, LastReadDate: "2024-03-13", MinutesToday: 20, TodayComplete: true, MinMinutes: 10},
+		},
+		{
+			name:       "today still open keeps the run",
+			seconds:    map[string]int64{"2024-03-11": minimum, "2024-03-12": minimum, "2024-03-13": 5 * 60},
+			minMinutes: 10,
+			want:       Streak{Current: 2, Longest: 2, LastReadDate: "2024-03-12", MinutesToday: 5, MinMinutes: 10},
+		},
+		{
+			name:       "missed day resets the run but not the longest",
+			seconds:    map[string]int64{"2024-03-04": minimum, "2024-03-05": minimum, "2024-03-06": minimum, "2024-03-12": minimum},
+			minMinutes: 10,
+			want:       Streak{Current: 1, Longest: 3, LastReadDate: "2024-03-12", MinMinutes: 10},
+		},
+		{
+			name:       "days under the minimum don't count",
+			seconds:    map[string]int64{"2024-03-11": minimum, "2024-03-12": minimum - 1},
+			minMinutes: 10,
+			want:       Streak{Current: 0, Longest: 1, LastReadDate: "2024-03-11", MinMinutes: 10},
+		},
+		{
+			name:       "frozen day carries the run",
+			seconds:    map[string]int64{"2024-03-10": minimum, "2024-03-11": minimum},
+			frozen:     map[string]bool{"2024-03-12": true},
+			minMinutes: 10,
+			want:       Streak{Current: 2, Longest: 2, LastReadDate: "2024-03-11", MinMinutes: 10},
+		},
+		{
+			name:         "weekends skipped",
+			seconds:      map[string]int64{"2024-03-08": minimum, "2024-03-11": minimum, "2024-03-12": minimum},
+			skipWeekends: true,
+			minMinutes:   10,
+			want:         Streak{Current: 3, Longest: 3, LastReadDate: "2024-03-12", MinMinutes: 10},
+		},
+		{
+			name:       "weekends count when not skipped",
+			seconds:    map[string]int64{"2024-03-08": minimum, "2024-03-11": minimum, "2024-03-12": minimum},
+			minMinutes: 10,
+			want:       Streak{Current: 2, Longest: 2, LastReadDate: "2024-03-12", MinMinutes: 10},
+		},
+		{
+			name:         "weekend reading still counts when skipped",
+			seconds:      map[string]int64{"2024-03-08": minimum, "2024-03-09": minimum, "2024-03-11": minimum, "2024-03-12": minimum},
+			skipWeekends: true,
+			minMinutes:   10,
+			want:         Streak{Current: 4, Longest: 4, LastReadDate: "2024-03-12", MinMinutes: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeStreak(tt.seconds, tt.frozen, tt.skipWeekends, tt.minMinutes, now)
+			if got != tt.want {
+				t.Errorf("computeStreak() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStreakUsesLocalDays(t *testing.T) {
+	// 02:00 on the 13th in Auckland is still the 12th in UTC
+	loc, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	now := time.Date(2024, 3, 13, 2, 0, 0, 0, loc)
+	got := computeStreak(map[string]int64{"2024-03-12": 600, "2024-03-13": 600}, nil, false, 10, now)
+	if got.Current != 2 || !got.TodayComplete || got.LastReadDate != "2024-03-13" {
+		t.Errorf("computeStreak() = %+v, want a run of 2 ending today, the 13th", got)
+	}
+}
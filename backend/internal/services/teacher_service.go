@@ -0,0 +1,459 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+// recentAnnotationLimit is how many of a class's shared notes and
+// highlights its dashboard shows.
+const recentAnnotationLimit = 20
+
+// ClassScope limits the classes (groups) and assignments a caller may see:
+// a teacher only the ones they run, anyone else those of their school
+// (SchoolID nil for platform admins).
+type ClassScope struct {
+	SchoolID  *uint
+	TeacherID *uint
+}
+
+func (sc ClassScope) apply(db *gorm.DB) *gorm.DB {
+	if sc.TeacherID != nil {
+		db = db.Where("reading_assignments.assigned_by = ?", *sc.TeacherID)
+	}
+	if sc.SchoolID != nil {
+		db = db.Where("reading_assignments.school_id = ?", *sc.SchoolID)
+	}
+	return db
+}
+
+func (sc ClassScope) applyToGroups(db *gorm.DB) *gorm.DB {
+	if sc.TeacherID != nil {
+		db = db.Where("groups.created_by = ?", *sc.TeacherID)
+	}
+	if sc.SchoolID != nil {
+		db = db.Where("groups.school_id = ?", *sc.SchoolID)
+	}
+	return db
+}
+
+// applyToLibraries limits user_libraries rows, under the given table alias,
+// to the scope's school and, for a teacher, the students in their classes.
+func (sc ClassScope) applyToLibraries(db *gorm.DB, table string) *gorm.DB {
+	if sc.SchoolID != nil {
+		db = db.Where(table+".school_id = ?", *sc.SchoolID)
+	}
+	if sc.TeacherID != nil {
+		db = db.Where(table+`.user_id IN (SELECT gm.user_id FROM group_members gm
+			JOIN groups g ON g.id = gm.group_id AND g.deleted_at IS NULL
+			WHERE gm.deleted_at IS NULL AND g.created_by = ?)`, *sc.TeacherID)
+	}
+	return db
+}
+
+// ClassMemberProgress is one student's reading as their class dashboard
+// shows it. Minutes are for the dashboard's period, the rest all-time.
+type ClassMemberProgress struct {
+	UserID             uint       `json:"user_id"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email"`
+	ClassLevel         string     `json:"class_level"`
+	ReadingGrade       *int       `json:"reading_grade"`
+	Books              int        `json:"books"`
+	BooksCompleted     int        `json:"books_completed"`
+	AvgProgress        float64    `json:"avg_progress"`
+	MinutesRead        float64    `json:"minutes_read"`
+	LastReadAt         *time.Time `json:"last_read_at"`
+	CurrentStreak      int        `json:"current_streak"`
+	OverdueAssignments int        `json:"overdue_assignments"`
+}
+
+// ClassOverdueStudent is a student who hasn't finished an assignment in
+// time.
+type ClassOverdueStudent struct {
+	UserID   uint    `json:"user_id"`
+	Name     string  `json:"name"`
+	Progress float64 `json:"progress"`
+}
+
+// ClassOverdueAssignment is an assignment some of the class is late on.
+type ClassOverdueAssignment struct {
+	AssignmentID uint                  `json:"assignment_id"`
+	Title        string                `json:"title"`
+	BookID       uint                  `json:"book_id"`
+	DueAt        time.Time             `json:"due_at"`
+	Students     []ClassOverdueStudent `json:"students"`
+}
+
+// ClassAnnotation is a note or highlight a student shared with the class or
+// made public. Private ones never reach the dashboard.
+type ClassAnnotation struct {
+	Type       string    `json:"type"` // note, highlight
+	ID         uint      `json:"id"`
+	AuthorID   uint      `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	BookID     uint      `json:"book_id"`
+	BookTitle  string    `json:"book_title"`
+	Visibility string    `json:"visibility"`
+	Page       int       `json:"page,omitempty"`
+	Text       string    `json:"text,omitempty"`
+	Content    string    `json:"content,omitempty"`
+	Color      string    `json:"color,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ClassSummary struct {
+	Members            int     `json:"members"`
+	ActiveReaders      int     `json:"active_readers"`
+	MinutesRead        float64 `json:"minutes_read"`
+	AvgProgress        float64 `json:"avg_progress"`
+	BooksCompleted     int     `json:"books_completed"`
+	OverdueAssignments int     `json:"overdue_assignments"`
+}
+
+type ClassDashboard struct {
+	Class             models.Group             `json:"class"`
+	Period            string                   `json:"period"`
+	Since             time.Time                `json:"since"`
+	Summary           ClassSummary             `json:"summary"`
+	Members           []ClassMemberProgress    `json:"members"`
+	Overdue           []ClassOverdueAssignment `json:"overdue"`
+	RecentAnnotations []ClassAnnotation        `json:"recent_annotations"`
+	Analytics         map[string]interface{}   `json:"analytics"`
+}
+
+// TeacherService serves the class views of teachers, whose classes are the
+// groups they created.
+type TeacherService struct {
+	db               *gorm.DB
+	analyticsService *AnalyticsService
+	streakService    *StreakService
+}
+
+func NewTeacherService(db *gorm.DB, analyticsService *AnalyticsService, streakService *StreakService) *TeacherService {
+	return &TeacherService{
+		db:               db,
+		analyticsService: analyticsService,
+		streakService:    streakService,
+	}
+}
+
+// ListClasses returns the groups in scope, by name.
+func (s *TeacherService) ListClasses(scope ClassScope) ([]models.Group, error) {
+	var groups []models.Group
+	if err := scope.applyToGroups(s.db).Order("name").Find(&groups).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch classes", err)
+	}
+	return groups, nil
+}
+
+// GetClass returns a group the caller may see. Groups out of scope are
+// reported as not found rather than forbidden.
+func (s *TeacherService) GetClass(groupID uint, scope ClassScope) (*models.Group, error) {
+	var group models.Group
+	if err := scope.applyToGroups(s.db).Preload("Creator", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "first_name", "last_name", "email")
+	}).First(&group, groupID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Class not found")
+	}
+	return &group, nil
+}
+
+// GetClassDashboard aggregates a class's reading over a period ("week",
+// "month", "quarter" or "year"): each member's progress and minutes read,
+// overdue assignments and recently shared notes and highlights.
+func (s *TeacherService) GetClassDashboard(groupID uint, scope ClassScope, period string) (*ClassDashboard, error) {
+	group, err := s.GetClass(groupID, scope)
+	if err != nil {
+		return nil, err
+	}
+	since := readingPeriodStart(period)
+
+	members, err := s.memberProgress(group.ID, since)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch class progress", err)
+	}
+	userIDs := make([]uint, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+
+	overdue, err := s.overdueAssignments(userIDs, scope)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch overdue assignments", err)
+	}
+	annotations, err := s.recentAnnotations(group.ID, userIDs)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch class annotations", err)
+	}
+	analytics, err := s.analyticsService.GetGroupReadingAnalytics(period, group.ID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch class analytics", err)
+	}
+
+	lateBy := map[uint]int{}
+	for _, assignment := range overdue {
+		for _, student := range assignment.Students {
+			lateBy[student.UserID]++
+		}
+	}
+	streaks, err := s.streakService.GetStreaks(userIDs)
+	if err != nil {
+		utils.ErrorLogger.Printf("Failed to fetch streaks for class %d: %v", group.ID, err)
+	}
+
+	summary := ClassSummary{Members: len(members), OverdueAssignments: len(overdue)}
+	progressTotal := 0.0
+	for i := range members {
+		member := &members[i]
+		member.OverdueAssignments = lateBy[member.UserID]
+		member.CurrentStreak = streaks[member.UserID].Current
+		if member.MinutesRead > 0 {
+			summary.ActiveReaders++
+		}
+		summary.MinutesRead += member.MinutesRead
+		summary.BooksCompleted += member.BooksCompleted
+		progressTotal += member.AvgProgress
+	}
+	if len(members) > 0 {
+		summary.AvgProgress = math.Round(progressTotal/float64(len(members))*10) / 10
+	}
+
+	normalized := period
+	switch period {
+	case "week", "month", "quarter", "year":
+	default:
+		normalized = "month"
+	}
+
+	return &ClassDashboard{
+		Class:             *group,
+		Period:            normalized,
+		Since:             since,
+		Summary:           summary,
+		Members:           members,
+		Overdue:           overdue,
+		RecentAnnotations: annotations,
+		Analytics:         analytics,
+	}, nil
+}
+
+func (s *TeacherService) memberProgress(groupID uint, since time.Time) ([]ClassMemberProgress, error) {
+	var rows []struct {
+		UserID         uint
+		Name           string
+		Email          string
+		ClassLevel     string
+		ReadingGrade   *int
+		Books          int
+		BooksCompleted int
+		AvgProgress    float64
+		SecondsRead    int64
+		LastReadAt     *time.Time
+	}
+	err := s.db.Raw(`
+		SELECT u.id AS user_id, CONCAT(u.first_name, ' ', u.last_name) AS name, u.email, u.class_level, u.reading_grade,
+		       COALESCE(lib.books, 0) AS books,
+		       COALESCE(lib.books_completed, 0) AS books_completed,
+		       COALESCE(lib.avg_progress, 0) AS avg_progress,
+		       COALESCE(rs.seconds_read, 0) AS seconds_read,
+		       lib.last_read_at
+		FROM group_members gm
+		JOIN users u ON u.id = gm.user_id AND u.deleted_at IS NULL
+		LEFT JOIN (
+			SELECT user_id, COUNT(*) AS books,
+			       COUNT(CASE WHEN progress = 100 THEN 1 END) AS books_completed,
+			       AVG(progress) AS avg_progress,
+			       MAX(last_read_at) AS last_read_at
+			FROM user_libraries
+			WHERE deleted_at IS NULL
+			GROUP BY user_id
+		) lib ON lib.user_id = u.id
+		LEFT JOIN (
+			SELECT user_id, SUM(duration) AS seconds_read
+			FROM reading_sessions
+			WHERE start_time >= ? AND deleted_at IS NULL
+			GROUP BY user_id
+		) rs ON rs.user_id = u.id
+		WHERE gm.group_id = ? AND gm.deleted_at IS NULL
+		ORDER BY u.last_name, u.first_name
+	`, since, groupID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]ClassMemberProgress, len(rows))
+	for i, row := range rows {
+		members[i] = ClassMemberProgress{
+			UserID:         row.UserID,
+			Name:           row.Name,
+			Email:          row.Email,
+			ClassLevel:     row.ClassLevel,
+			ReadingGrade:   row.ReadingGrade,
+			Books:          row.Books,
+			BooksCompleted: row.BooksCompleted,
+			AvgProgress:    math.Round(row.AvgProgress*10) / 10,
+			MinutesRead:    math.Round(float64(row.SecondsRead)/6) / 10,
+			LastReadAt:     row.LastReadAt,
+		}
+	}
+	return members, nil
+}
+
+// overdueAssignments returns the assignments in scope that members are late
+// on, as of the last status refresh.
+func (s *TeacherService) overdueAssignments(userIDs []uint, scope ClassScope) ([]ClassOverdueAssignment, error) {
+	result := []ClassOverdueAssignment{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var assignments []models.ReadingAssignment
+	err := scope.apply(s.db.Model(&models.ReadingAssignment{})).Preload("Book").
+		Where("due_at < ?", time.Now()).
+		Where("id IN (?)", s.db.Model(&models.ReadingAssignmentStudent{}).
+			Select("assignment_id").
+			Where("user_id IN ? AND status <> ?", userIDs, AssignmentCompleted)).
+		Order("due_at").
+		Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		var late []models.ReadingAssignmentStudent
+		if err := s.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "first_name", "last_name")
+		}).Where("assignment_id = ? AND user_id IN ? AND status = ?", assignment.ID, userIDs, AssignmentLate).
+			Find(&late).Error; err != nil {
+			return nil, err
+		}
+		if len(late) == 0 {
+			continue
+		}
+
+		overdue := ClassOverdueAssignment{
+			AssignmentID: assignment.ID,
+			Title:        assignmentTitle(&assignment),
+			BookID:       assignment.BookID,
+			DueAt:        assignment.DueAt,
+		}
+		for _, student := range late {
+			overdue.Students = append(overdue.Students, ClassOverdueStudent{
+				UserID:   student.UserID,
+				Name:     authorName(student.User),
+				Progress: student.Progress,
+			})
+		}
+		result = append(result, overdue)
+	}
+	return result, nil
+}
+
+// recentAnnotations returns the newest notes and highlights members shared
+// with the class or made public.
+func (s *TeacherService) recentAnnotations(groupID uint, userIDs []uint) ([]ClassAnnotation, error) {
+	result := []ClassAnnotation{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	shared := func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id IN ?", userIDs).
+			Where("visibility = ? OR (visibility = ? AND group_id = ?)", VisibilityPublic, VisibilityGroup, groupID).
+			Preload("User").Preload("Book").
+			Order("created_at DESC").
+			Limit(recentAnnotationLimit)
+	}
+
+	var notes []models.Note
+	if err := s.db.Scopes(shared).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	var highlights []models.Highlight
+	if err := s.db.Scopes(shared).Find(&highlights).Error; err != nil {
+		return nil, err
+	}
+
+	bookTitle := func(book *models.Book) string {
+		if book == nil {
+			return ""
+		}
+		return book.Title
+	}
+	for _, n := range notes {
+		result = append(result, ClassAnnotation{
+			Type: AnnotationNote, ID: n.ID, AuthorID: n.UserID, AuthorName: authorName(n.User),
+			BookID: n.BookID, BookTitle: bookTitle(n.Book), Visibility: n.Visibility,
+			Page: n.Page, Content: n.Content, CreatedAt: n.CreatedAt,
+		})
+	}
+	for _, h := range highlights {
+		result = append(result, ClassAnnotation{
+			Type: AnnotationHighlight, ID: h.ID, AuthorID: h.UserID, AuthorName: authorName(h.User),
+			BookID: h.BookID, BookTitle: bookTitle(h.Book), Visibility: h.Visibility,
+			Text: h.Text, Color: h.Color, CreatedAt: h.CreatedAt,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	if len(result) > recentAnnotationLimit {
+		result = result[:recentAnnotationLimit]
+	}
+	return result, nil
+}
+
+// SetStudentReadingLevel records a teacher's assessment of a student in
+// their scope. A nil grade clears it, so the class level applies again.
+func (s *TeacherService) SetStudentReadingLevel(studentID, assessedBy uint, scope ClassScope, input ReadingLevelInput) (*models.User, error) {
+	if err := studentInScope(s.db, studentID, scope); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"reading_grade":       input.Grade,
+		"reading_score":       input.Score,
+		"reading_assessed_at": nil,
+		"reading_assessed_by": nil,
+	}
+	if input.Grade != nil || input.Score != nil {
+		updates["reading_assessed_at"] = time.Now()
+		updates["reading_assessed_by"] = assessedBy
+	}
+	if err := s.db.Model(&models.User{}).Where("id = ?", studentID).Updates(updates).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to update reading level", err)
+	}
+
+	var student models.User
+	if err := s.db.First(&student, studentID).Error; err != nil {
+		return nil, utils.NewNotFoundError("Student not found")
+	}
+	return &student, nil
+}
+
+// studentInScope checks that staff may act on the student: one of their
+// school's, or for a teacher, a member of a group they run.
+func studentInScope(db *gorm.DB, studentID uint, scope ClassScope) error {
+	query := db.Model(&models.User{}).Where("users.id = ?", studentID)
+	if scope.SchoolID != nil {
+		query = query.Where("users.school_id = ?", *scope.SchoolID)
+	}
+	if scope.TeacherID != nil {
+		query = query.Where("users.id IN (?)", db.Model(&models.GroupMember{}).
+			Select("group_members.user_id").
+			Joins("JOIN groups ON groups.id = group_members.group_id AND groups.deleted_at IS NULL").
+			Where("groups.created_by = ?", *scope.TeacherID))
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return utils.NewInternalServerError("Failed to fetch student", err)
+	}
+	if count == 0 {
+		return utils.NewNotFoundError("Student not found")
+	}
+	return nil
+}
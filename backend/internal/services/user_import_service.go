@@ -0,0 +1,577 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	maxImportRows = 1000
+
+	ImportStatusPending = "pending"
+	ImportStatusRunning = "running"
+	ImportStatusDone    = "done"
+	ImportStatusFailed  = "failed"
+
+	// accountSetupTTL is how long the link to choose a first password works.
+	accountSetupTTL = 7 * 24 * time.Hour
+)
+
+// importColumns maps accepted header spellings to the canonical column name.
+var importColumns = map[string]string{
+	"name":          "name",
+	"full_name":     "name",
+	"first_name":    "first_name",
+	"firstname":     "first_name",
+	"last_name":     "last_name",
+	"lastname":      "last_name",
+	"surname":       "last_name",
+	"email":         "email",
+	"email_address": "email",
+	"username":      "username",
+	"class_level":   "class_level",
+	"class":         "class_level",
+	"grade":         "class_level",
+	"department":    "department",
+	"groups":        "groups",
+	"group":         "groups",
+	"password":      "password",
+}
+
+type UserImportRow struct {
+	Row        int      `json:"row"`
+	Email      string   `json:"email"`
+	Username   string   `json:"username"`
+	FirstName  string   `json:"first_name"`
+	LastName   string   `json:"last_name"`
+	ClassLevel string   `json:"class_level"`
+	Department string   `json:"department"`
+	Groups     []string `json:"groups"`
+	Errors     []string `json:"errors,omitempty"`
+	UserID     uint     `json:"user_id,omitempty"`
+
+	password string
+}
+
+type UserImportResult struct {
+	DryRun    bool            `json:"dry_run"`
+	JobID     uint            `json:"job_id,omitempty"`
+	Total     int             `json:"total"`
+	Valid     int             `json:"valid"`
+	Invalid   int             `json:"invalid"`
+	Created   int             `json:"created"`
+	NewGroups []string        `json:"new_groups"`
+	Rows      []UserImportRow `json:"rows"`
+}
+
+// UserImportJobStatus is a background import and, once it is done, its
+// report.
+type UserImportJobStatus struct {
+	models.UserImportJob
+	Report *UserImportResult `json:"report,omitempty"`
+}
+
+type UserImportOptions struct {
+	SchoolID        *uint
+	ImportedBy      uint
+	DryRun          bool
+	SendCredentials bool
+}
+
+type UserImportService struct {
+	db           *gorm.DB
+	emailService *EmailService
+}
+
+func NewUserImportService(db *gorm.DB, emailService *EmailService) *UserImportService {
+	return &UserImportService{db: db, emailService: emailService}
+}
+
+// Import validates every row of a CSV or XLSX roster and, unless this is a
+// dry run, starts a background job creating the students and their group
+// memberships in a single transaction; hashing up to maxImportRows
+// passwords takes too long for a request. Nothing is written if any row is
+// invalid. The result carries the job to poll.
+func (s *UserImportService) Import(filename string, file io.Reader, opts UserImportOptions) (*UserImportResult, error) {
+	records, err := readImportFile(filename, file)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := parseImportRows(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var school *models.School
+	if opts.SchoolID != nil {
+		school = &models.School{}
+		if err := s.db.First(school, *opts.SchoolID).Error; err != nil {
+			return nil, utils.NewBadRequestError("School not found")
+		}
+	}
+
+	var studentRole models.Role
+	if err := s.db.Where("name = ?", "student").First(&studentRole).Error; err != nil {
+		return nil, utils.NewInternalServerError("Student role not found", err)
+	}
+
+	if err := s.validateRows(rows); err != nil {
+		return nil, err
+	}
+
+	groupIDs, newGroups, err := s.resolveGroups(rows, opts.SchoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UserImportResult{
+		DryRun:    opts.DryRun,
+		Total:     len(rows),
+		NewGroups: newGroups,
+		Rows:      rows,
+	}
+	for _, row := range rows {
+		if len(row.Errors) == 0 {
+			result.Valid++
+		}
+	}
+	result.Invalid = result.Total - result.Valid
+
+	if opts.DryRun || result.Invalid > 0 {
+		return result, nil
+	}
+
+	job := models.UserImportJob{
+		SchoolID:   opts.SchoolID,
+		ImportedBy: opts.ImportedBy,
+		Filename:   filename,
+		Status:     ImportStatusPending,
+		Total:      len(rows),
+	}
+	if err := s.db.Create(&job).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to start import", err)
+	}
+	result.JobID = job.ID
+
+	// The job fills in its own copy; this one goes back to the caller now
+	jobResult := *result
+	jobResult.Rows = append([]UserImportRow(nil), rows...)
+	go s.runImport(job.ID, &jobResult, groupIDs, school, studentRole.ID, opts)
+
+	utils.InfoLogger.Printf("Import job %d started for %d users from %s", job.ID, len(rows), filename)
+	return result, nil
+}
+
+// runImport creates the accounts of a validated import and records the
+// report on its job. Rows without a password, and every row when
+// opts.SendCredentials is set, get a link to choose one by email only: the
+// report outlives the links and is readable by other admins.
+func (s *UserImportService) runImport(jobID uint, result *UserImportResult, groupIDs map[string]uint, school *models.School, studentRoleID uint, opts UserImportOptions) {
+	// Nothing recovers panics off the request path; don't take the API down
+	// or leave the job running forever
+	defer func() {
+		if r := recover(); r != nil {
+			s.failImport(jobID, "Import stopped unexpectedly", fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	s.db.Model(&models.UserImportJob{}).Where("id = ?", jobID).Update("status", ImportStatusRunning)
+
+	rows := result.Rows
+	setupExpires := time.Now().Add(accountSetupTTL)
+	setupTokens := make([]string, len(rows))
+	users := make([]models.User, len(rows))
+	for i, row := range rows {
+		// Imported accounts are vouched for by the school and skip email verification
+		users[i] = models.User{
+			Email:           row.Email,
+			Username:        row.Username,
+			FirstName:       row.FirstName,
+			LastName:        row.LastName,
+			ClassLevel:      row.ClassLevel,
+			Department:      row.Department,
+			SchoolID:        opts.SchoolID,
+			RoleID:          studentRoleID,
+			IsActive:        true,
+			IsEmailVerified: true,
+		}
+		if school != nil {
+			users[i].SchoolName = school.Name
+			users[i].SchoolCategory = school.Category
+		}
+
+		if row.password != "" {
+			hash, err := utils.HashPassword(row.password)
+			if err != nil {
+				s.failImport(jobID, "Failed to hash password", err)
+				return
+			}
+			users[i].PasswordHash = hash
+		}
+		// Until they follow the link, accounts without a password can't sign in
+		if row.password == "" || opts.SendCredentials {
+			setupTokens[i] = utils.GenerateRandomToken(32)
+			users[i].PasswordResetToken = setupTokens[i]
+			users[i].PasswordResetExpires = &setupExpires
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range result.NewGroups {
+			group := models.Group{Name: name, SchoolID: opts.SchoolID, CreatedBy: opts.ImportedBy}
+			if err := tx.Create(&group).Error; err != nil {
+				return err
+			}
+			groupIDs[strings.ToLower(name)] = group.ID
+		}
+
+		if err := tx.CreateInBatches(&users, 100).Error; err != nil {
+			return err
+		}
+
+		touched := make(map[uint]bool)
+		var members []models.GroupMember
+		for i, row := range rows {
+			for _, name := range row.Groups {
+				groupID := groupIDs[strings.ToLower(name)]
+				members = append(members, models.GroupMember{GroupID: groupID, UserID: users[i].ID})
+				touched[groupID] = true
+			}
+		}
+		if len(members) > 0 {
+			if err := tx.CreateInBatches(&members, 100).Error; err != nil {
+				return err
+			}
+		}
+
+		for groupID := range touched {
+			var count int64
+			if err := tx.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Group{}).Where("id = ?", groupID).Update("member_count", count).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.failImport(jobID, "Failed to import users", err)
+		return
+	}
+
+	schoolName := ""
+	if school != nil {
+		schoolName = school.Name
+	}
+
+	for i := range rows {
+		rows[i].UserID = users[i].ID
+		result.Created++
+
+		if setupTokens[i] == "" {
+			continue
+		}
+		if err := s.emailService.SendAccountSetup(rows[i].Email, displayName(&users[i]), rows[i].Username, setupTokens[i], schoolName, setupExpires); err != nil {
+			utils.ErrorLogger.Printf("Failed to queue account setup for %s: %v", rows[i].Email, err)
+		}
+	}
+
+	report, err := json.Marshal(result)
+	if err != nil {
+		s.failImport(jobID, "Failed to record import report", err)
+		return
+	}
+	now := time.Now()
+	if err := s.db.Model(&models.UserImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":      ImportStatusDone,
+		"created":     result.Created,
+		"report":      string(report),
+		"finished_at": &now,
+	}).Error; err != nil {
+		utils.ErrorLogger.Printf("Failed to finish import job %d: %v", jobID, err)
+	}
+
+	utils.InfoLogger.Printf("Import job %d created %d users", jobID, result.Created)
+}
+
+func (s *UserImportService) failImport(jobID uint, message string, err error) {
+	utils.ErrorLogger.Printf("Import job %d failed: %s: %v", jobID, message, err)
+	now := time.Now()
+	s.db.Model(&models.UserImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":      ImportStatusFailed,
+		"error":       message,
+		"finished_at": &now,
+	})
+}
+
+// GetJob returns an import job and its report, within the caller's school.
+func (s *UserImportService) GetJob(jobID uint, schoolID *uint) (*UserImportJobStatus, error) {
+	query := s.db.Where("id = ?", jobID)
+	if schoolID != nil {
+		query = query.Where("school_id = ?", *schoolID)
+	}
+
+	var job models.UserImportJob
+	if err := query.First(&job).Error; err != nil {
+		return nil, utils.NewNotFoundError("Import job not found")
+	}
+
+	status := &UserImportJobStatus{UserImportJob: job}
+	if job.Report != "" {
+		status.Report = &UserImportResult{}
+		if err := json.Unmarshal([]byte(job.Report), status.Report); err != nil {
+			return nil, utils.NewInternalServerError("Failed to read import report", err)
+		}
+	}
+	return status, nil
+}
+
+// validateRows applies the same rules as admin user creation to each row and
+// checks uniqueness both inside the file and against existing accounts.
+func (s *UserImportService) validateRows(rows []UserImportRow) error {
+	emails := make([]string, 0, len(rows))
+	usernames := make([]string, 0, len(rows))
+	for _, row := range rows {
+		emails = append(emails, row.Email)
+		usernames = append(usernames, strings.ToLower(row.Username))
+	}
+
+	// Unscoped: soft-deleted accounts still hold their unique email/username
+	var existing []models.User
+	if err := s.db.Unscoped().Select("email", "username").
+		Where("LOWER(email) IN ? OR LOWER(username) IN ?", emails, usernames).
+		Find(&existing).Error; err != nil {
+		return utils.NewInternalServerError("Failed to check existing users", err)
+	}
+
+	takenEmails := make(map[string]bool, len(existing))
+	takenUsernames := make(map[string]bool, len(existing))
+	for _, user := range existing {
+		takenEmails[strings.ToLower(user.Email)] = true
+		takenUsernames[strings.ToLower(user.Username)] = true
+	}
+
+	seenEmails := make(map[string]int)
+	seenUsernames := make(map[string]int)
+
+	for i := range rows {
+		row := &rows[i]
+
+		if row.FirstName == "" {
+			row.Errors = append(row.Errors, "first_name is required")
+		}
+		if row.LastName == "" {
+			row.Errors = append(row.Errors, "last_name is required")
+		}
+
+		if row.Email == "" {
+			row.Errors = append(row.Errors, "email is required")
+		} else if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+			row.Errors = append(row.Errors, "email is not a valid email address")
+		} else if takenEmails[row.Email] {
+			row.Errors = append(row.Errors, "email is already registered")
+		} else if first, ok := seenEmails[row.Email]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("email duplicates row %d", first))
+		} else {
+			seenEmails[row.Email] = row.Row
+		}
+
+		if len(row.Username) < 3 {
+			row.Errors = append(row.Errors, "username must be at least 3 characters")
+		} else if takenUsernames[strings.ToLower(row.Username)] {
+			row.Errors = append(row.Errors, "username is already taken")
+		} else if first, ok := seenUsernames[strings.ToLower(row.Username)]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("username duplicates row %d", first))
+		} else {
+			seenUsernames[strings.ToLower(row.Username)] = row.Row
+		}
+
+		if row.password != "" && len(row.password) < 8 {
+			row.Errors = append(row.Errors, "password must be at least 8 characters")
+		}
+	}
+
+	return nil
+}
+
+// resolveGroups maps every group name in the file to an existing group in the
+// school (case-insensitive) and lists the names that will have to be created.
+func (s *UserImportService) resolveGroups(rows []UserImportRow, schoolID *uint) (map[string]uint, []string, error) {
+	groupIDs := make(map[string]uint)
+	newGroups := []string{}
+
+	names := make(map[string]string)
+	for _, row := range rows {
+		for _, name := range row.Groups {
+			key := strings.ToLower(name)
+			if _, ok := names[key]; !ok {
+				names[key] = name
+			}
+		}
+	}
+	if len(names) == 0 {
+		return groupIDs, newGroups, nil
+	}
+
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+
+	query := s.db.Model(&models.Group{}).Where("LOWER(name) IN ?", keys)
+	if schoolID == nil {
+		query = query.Where("school_id IS NULL")
+	} else {
+		query = query.Where("school_id = ?", *schoolID)
+	}
+
+	var groups []models.Group
+	if err := query.Order("id").Find(&groups).Error; err != nil {
+		return nil, nil, utils.NewInternalServerError("Failed to look up groups", err)
+	}
+	for _, group := range groups {
+		key := strings.ToLower(group.Name)
+		if _, ok := groupIDs[key]; !ok {
+			groupIDs[key] = group.ID
+		}
+	}
+
+	for key, name := range names {
+		if _, ok := groupIDs[key]; !ok {
+			newGroups = append(newGroups, name)
+		}
+	}
+	return groupIDs, newGroups, nil
+}
+
+// readImportFile returns the raw cell grid of a .csv or the first sheet of an .xlsx.
+func readImportFile(filename string, file io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, utils.NewBadRequestError("Invalid CSV file: " + err.Error())
+		}
+		return records, nil
+	case ".xlsx":
+		workbook, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, utils.NewBadRequestError("Invalid XLSX file: " + err.Error())
+		}
+		defer workbook.Close()
+
+		sheets := workbook.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, utils.NewBadRequestError("XLSX file has no sheets")
+		}
+		records, err := workbook.GetRows(sheets[0])
+		if err != nil {
+			return nil, utils.NewBadRequestError("Invalid XLSX file: " + err.Error())
+		}
+		return records, nil
+	default:
+		return nil, utils.NewBadRequestError("Unsupported file type, upload a .csv or .xlsx file")
+	}
+}
+
+// parseImportRows reads the header row, then one UserImportRow per non-blank
+// line. Row numbers match the spreadsheet (header is row 1).
+func parseImportRows(records [][]string) ([]UserImportRow, error) {
+	if len(records) == 0 {
+		return nil, utils.NewBadRequestError("File is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+		key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		if name, ok := importColumns[key]; ok {
+			if _, dup := columns[name]; !dup {
+				columns[name] = i
+			}
+		}
+	}
+
+	if _, ok := columns["email"]; !ok {
+		return nil, utils.NewBadRequestError("Missing required column: email")
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, utils.NewBadRequestError("Missing required column: username")
+	}
+	_, hasName := columns["name"]
+	_, hasFirst := columns["first_name"]
+	if !hasName && !hasFirst {
+		return nil, utils.NewBadRequestError("Missing required column: name or first_name")
+	}
+
+	var rows []UserImportRow
+	for i, record := range records[1:] {
+		cell := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := UserImportRow{
+			Row:        i + 2,
+			Email:      strings.ToLower(cell("email")),
+			Username:   cell("username"),
+			FirstName:  cell("first_name"),
+			LastName:   cell("last_name"),
+			ClassLevel: cell("class_level"),
+			Department: cell("department"),
+			Groups:     []string{},
+			password:   cell("password"),
+		}
+
+		if row.FirstName == "" && row.LastName == "" {
+			if parts := strings.Fields(cell("name")); len(parts) > 0 {
+				row.FirstName = parts[0]
+				row.LastName = strings.Join(parts[1:], " ")
+			}
+		}
+
+		seenGroups := make(map[string]bool)
+		for _, name := range strings.FieldsFunc(cell("groups"), func(r rune) bool { return r == ';' || r == ',' || r == '|' }) {
+			name = strings.TrimSpace(name)
+			if name != "" && !seenGroups[strings.ToLower(name)] {
+				seenGroups[strings.ToLower(name)] = true
+				row.Groups = append(row.Groups, name)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, utils.NewBadRequestError("File has no data rows")
+	}
+	if len(rows) > maxImportRows {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("File has %d rows, the limit is %d per import", len(rows), maxImportRows))
+	}
+
+	return rows, nil
+}
@@ -4,25 +4,93 @@ import (
 	"gorm.io/gorm"
 
 	"readagain/internal/models"
+	"readagain/internal/queryspec"
 	"readagain/internal/utils"
 )
 
 type UserService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *CacheService
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(db *gorm.DB, cache *CacheService) *UserService {
+	return &UserService{db: db, cache: cache}
+}
+
+// invalidatePermissions drops the cached role/school snapshot for the given
+// users so the next request re-reads it from the database.
+func (s *UserService) invalidatePermissions(userIDs ...uint) {
+	if s.cache == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		if err := s.cache.InvalidateUserPermissions(userID); err != nil {
+			utils.ErrorLogger.Printf("Failed to invalidate permission cache for user %d: %v", userID, err)
+		}
+	}
+}
+
+// SchoolRoles are the roles whose access is confined to one school. An
+// account holding one without a school would be refused by every route its
+// permissions open, so it is turned away here instead.
+var SchoolRoles = map[string]bool{"school_admin": true, "teacher": true}
+
+// scopedRoles are the only roles a school-scoped admin may hand out. Any
+// other would lift the account, or the admin themselves, out of the school.
+var scopedRoles = map[string]bool{"student": true, "teacher": true, "guardian": true}
+
+// requireSchoolForRole checks that roleID can go to an account in schoolID
+// and that the caller, limited to scope unless it is nil, may grant it.
+func requireSchoolForRole(db *gorm.DB, roleID uint, schoolID, scope *uint) error {
+	var role models.Role
+	if err := db.Select("name").First(&role, roleID).Error; err != nil {
+		return utils.NewNotFoundError("Role not found")
+	}
+	if scope != nil && !scopedRoles[role.Name] {
+		return utils.NewForbiddenError("You can't assign the " + role.Name + " role")
+	}
+	if schoolID == nil && SchoolRoles[role.Name] {
+		return utils.NewBadRequestError("A " + role.Name + " must be assigned to a school")
+	}
+	return nil
 }
 
 func (s *UserService) GetUserByID(userID uint) (*models.User, error) {
 	var user models.User
-	if err := s.db.Preload("Role").First(&user, userID).Error; err != nil {
+	if err := s.db.Preload("Role").Preload("School").First(&user, userID).Error; err != nil {
 		return nil, utils.NewNotFoundError("User not found")
 	}
 	return &user, nil
 }
 
+// EnsureUserInSchool reports a not-found error when a school-scoped admin
+// tries to reach a user from another school.
+func (s *UserService) EnsureUserInSchool(userID uint, schoolID *uint) error {
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Scopes(ScopeToSchool("school_id", schoolID)).Count(&count).Error; err != nil {
+		return utils.NewInternalServerError("Failed to check user", err)
+	}
+	if count == 0 {
+		return utils.NewNotFoundError("User not found")
+	}
+	return nil
+}
+
+// EnsureUsersInSchool is the bulk variant of EnsureUserInSchool.
+func (s *UserService) EnsureUsersInSchool(userIDs []uint, schoolID *uint) error {
+	if schoolID == nil {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("id IN ?", userIDs).Scopes(ScopeToSchool("school_id", schoolID)).Count(&count).Error; err != nil {
+		return utils.NewInternalServerError("Failed to check users", err)
+	}
+	if int(count) != len(userIDs) {
+		return utils.NewForbiddenError("Some users do not belong to your school")
+	}
+	return nil
+}
+
 func (s *UserService) UpdateProfile(userID uint, firstName, lastName, phoneNumber, schoolName, schoolCategory, classLevel, department string) (*models.User, error) {
 	var user models.User
 	if err := s.db.First(&user, userID).Error; err != nil {
@@ -99,13 +167,53 @@ func (s *UserService) DeleteUser(userID uint) error {
 	if err := s.db.Unscoped().Delete(&models.User{}, userID).Error; err != nil {
 		return utils.NewInternalServerError("Failed to delete user", err)
 	}
+	s.invalidatePermissions(userID)
 	return nil
 }
 
-func (s *UserService) ListUsers(page, limit int, search string) ([]models.User, *utils.PaginationMeta, error) {
+// UserListSpec whitelists the sort and filter parameters of the user list.
+var UserListSpec = &queryspec.Spec{
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"email":      "email",
+		"username":   "username",
+		"first_name": "first_name",
+		"last_name":  "last_name",
+		"last_login": "last_login",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	Filters: map[string]queryspec.Filter{
+		"role_id":           {Column: "role_id", Type: queryspec.Uint},
+		"is_active":         {Column: "is_active", Type: queryspec.Bool},
+		"is_email_verified": {Column: "is_email_verified", Type: queryspec.Bool},
+		"class_level":       {Column: "class_level", Type: queryspec.String},
+		"department":        {Column: "department", Type: queryspec.String},
+	},
+	Params: []string{"search", "school_id", "role", "status"},
+}
+
+// ListUsers filters by role name and by status ("active" or "suspended")
+// in addition to the UserListSpec filters.
+func (s *UserService) ListUsers(page, limit int, search, role, status string, schoolID *uint, q *queryspec.Query) ([]models.User, *utils.PaginationMeta, error) {
 	params := utils.GetPaginationParams(page, limit)
 
-	query := s.db.Model(&models.User{}).Preload("Role")
+	query := s.db.Model(&models.User{}).Preload("Role").Preload("School").
+		Scopes(ScopeToSchool("school_id", schoolID), q.Where)
+
+	if role != "" {
+		query = query.Where("role_id IN (?)", s.db.Model(&models.Role{}).Select("id").Where("name = ?", role))
+	}
+
+	switch status {
+	case "":
+	case "active":
+		query = query.Where("is_active = ?", true)
+	case "suspended", "inactive":
+		query = query.Where("is_active = ?", false)
+	default:
+		return nil, nil, utils.NewBadRequestError("Invalid status filter: " + status)
+	}
 
 	if search != "" {
 		query = query.Where("email ILIKE ? OR username ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?",
@@ -118,7 +226,7 @@ func (s *UserService) ListUsers(page, limit int, search string) ([]models.User,
 	}
 
 	var users []models.User
-	if err := query.Scopes(utils.Paginate(params)).Find(&users).Error; err != nil {
+	if err := query.Scopes(q.OrderBy, utils.Paginate(params)).Find(&users).Error; err != nil {
 		return nil, nil, utils.NewInternalServerError("Failed to fetch users", err)
 	}
 
@@ -126,15 +234,42 @@ func (s *UserService) ListUsers(page, limit int, search string) ([]models.User,
 	return users, &meta, nil
 }
 
-func (s *UserService) UpdateUser(userID uint, updates map[string]interface{}) (*models.User, error) {
+// UpdateUser applies an admin's or the user's own edits. scope is the
+// caller's school scope and limits the roles role_id may be set to.
+func (s *UserService) UpdateUser(userID uint, updates map[string]interface{}, scope *uint) (*models.User, error) {
 	var user models.User
 	if err := s.db.First(&user, userID).Error; err != nil {
 		return nil, utils.NewNotFoundError("User not found")
 	}
 
-	if err := s.db.Model(&user).Updates(updates).Error; err != nil {
-		return nil, utils.NewInternalServerError("Failed to update user", err)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update user", err)
+		}
+		_, moved := updates["school_id"]
+		if _, reassigned := updates["role_id"]; !moved && !reassigned {
+			return nil
+		}
+		var updated models.User
+		if err := tx.Select("id", "role_id", "school_id").First(&updated, userID).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update user", err)
+		}
+		if err := requireSchoolForRole(tx, updated.RoleID, updated.SchoolID, scope); err != nil {
+			return err
+		}
+		if !moved {
+			return nil
+		}
+		// Library rows carry the school for scoped reporting; move them along
+		if err := tx.Model(&models.UserLibrary{}).Where("user_id = ?", userID).Update("school_id", updated.SchoolID).Error; err != nil {
+			return utils.NewInternalServerError("Failed to update user", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.invalidatePermissions(userID)
 
 	if err := s.db.Preload("Role").First(&user, userID).Error; err != nil {
 		return nil, utils.NewNotFoundError("User not found")
@@ -143,15 +278,14 @@ func (s *UserService) UpdateUser(userID uint, updates map[string]interface{}) (*
 	return &user, nil
 }
 
-func (s *UserService) AssignRole(userID, roleID uint) error {
+func (s *UserService) AssignRole(userID, roleID uint, scope *uint) error {
 	var user models.User
 	if err := s.db.First(&user, userID).Error; err != nil {
 		return utils.NewNotFoundError("User not found")
 	}
 
-	var role models.Role
-	if err := s.db.First(&role, roleID).Error; err != nil {
-		return utils.NewNotFoundError("Role not found")
+	if err := requireSchoolForRole(s.db, roleID, user.SchoolID, scope); err != nil {
+		return err
 	}
 
 	user.RoleID = roleID
@@ -159,6 +293,7 @@ func (s *UserService) AssignRole(userID, roleID uint) error {
 		return utils.NewInternalServerError("Failed to assign role", err)
 	}
 
+	s.invalidatePermissions(userID)
 	return nil
 }
 
@@ -177,6 +312,7 @@ func (s *UserService) RemoveRole(userID, roleID uint) error {
 		return utils.NewInternalServerError("Failed to remove role", err)
 	}
 
+	s.invalidatePermissions(userID)
 	return nil
 }
 
@@ -197,7 +333,20 @@ func (s *UserService) GetUserStats(userID uint) (map[string]interface{}, error)
 	}, nil
 }
 
-func (s *UserService) CreateUserByAdmin(email, username, password, firstName, lastName string, roleID uint, isActive bool) (*models.User, error) {
+// CreateUserByAdmin creates an account on an admin's behalf. A zero roleID
+// creates a student; scope is the caller's school scope.
+func (s *UserService) CreateUserByAdmin(email, username, password, firstName, lastName string, roleID uint, isActive bool, schoolID, scope *uint) (*models.User, error) {
+	if roleID == 0 {
+		var studentRole models.Role
+		if err := s.db.Where("name = ?", "student").First(&studentRole).Error; err != nil {
+			return nil, utils.NewInternalServerError("Student role not found", err)
+		}
+		roleID = studentRole.ID
+	}
+	if err := requireSchoolForRole(s.db, roleID, schoolID, scope); err != nil {
+		return nil, err
+	}
+
 	var existingUser models.User
 	if err := s.db.Where("email = ? OR username = ?", email, username).First(&existingUser).Error; err == nil {
 		return nil, utils.NewBadRequestError("User with this email or username already exists")
@@ -208,21 +357,24 @@ func (s *UserService) CreateUserByAdmin(email, username, password, firstName, la
 		return nil, utils.NewInternalServerError("Failed to hash password", err)
 	}
 
+	// Accounts created by an admin are vouched for and skip email verification
 	user := models.User{
-		Email:        email,
-		Username:     username,
-		PasswordHash: hashedPassword,
-		FirstName:    firstName,
-		LastName:     lastName,
-		RoleID:       roleID,
-		IsActive:     isActive,
+		Email:           email,
+		Username:        username,
+		PasswordHash:    hashedPassword,
+		FirstName:       firstName,
+		LastName:        lastName,
+		RoleID:          roleID,
+		SchoolID:        schoolID,
+		IsActive:        isActive,
+		IsEmailVerified: true,
 	}
 
 	if err := s.db.Create(&user).Error; err != nil {
 		return nil, utils.NewInternalServerError("Failed to create user", err)
 	}
 
-	if err := s.db.Preload("Role").First(&user, user.ID).Error; err != nil {
+	if err := s.db.Preload("Role").Preload("School").First(&user, user.ID).Error; err != nil {
 		return nil, utils.NewNotFoundError("User not found")
 	}
 
@@ -299,6 +451,7 @@ func (s *UserService) BulkDelete(userIDs []uint) error {
 		return utils.NewInternalServerError("Failed to delete users", err)
 	}
 
+	s.invalidatePermissions(userIDs...)
 	return nil
 }
 
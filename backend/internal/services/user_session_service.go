@@ -0,0 +1,244 @@
+package services
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/config"
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+// refreshReuseGrace is how long after a rotation the old refresh token
+// still gets back the pair it was rotated into, so that tabs refreshing at
+// the same moment aren't taken for a stolen token.
+const refreshReuseGrace = 10 * time.Second
+
+type UserSessionService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	cache *CacheService
+}
+
+func NewUserSessionService(db *gorm.DB, cfg *config.Config, cache *CacheService) *UserSessionService {
+	return &UserSessionService{db: db, cfg: cfg, cache: cache}
+}
+
+// CreateSession starts a new session family for a fresh login and returns the
+// raw refresh token; only its hash is stored.
+func (s *UserSessionService) CreateSession(userID uint, deviceName, ipAddress, userAgent string) (string, *models.UserSession, error) {
+	refreshToken := utils.GenerateRandomToken(32)
+	now := time.Now()
+
+	session := &models.UserSession{
+		UserID:     userID,
+		FamilyID:   utils.GenerateRandomToken(16),
+		TokenHash:  utils.HashToken(refreshToken),
+		DeviceName: deviceName,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(time.Hour * 24 * time.Duration(s.cfg.JWT.RefreshExpireDays)),
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		return "", nil, utils.NewInternalServerError("Failed to create session", err)
+	}
+
+	return refreshToken, session, nil
+}
+
+// RotateSession exchanges a refresh token for a new one in the same family.
+// A token presented again within refreshReuseGrace of its rotation gets the
+// same successor back; any other token that was already rotated or revoked
+// is treated as stolen and the whole family is revoked.
+func (s *UserSessionService) RotateSession(refreshToken, ipAddress, userAgent string) (string, *models.UserSession, error) {
+	var current models.UserSession
+	if err := s.db.Where("token_hash = ?", utils.HashToken(refreshToken)).First(&current).Error; err != nil {
+		return "", nil, utils.NewUnauthorizedError("Invalid refresh token")
+	}
+
+	if current.RotatedAt != nil || current.RevokedAt != nil {
+		if token, next := s.recentRotation(&current); next != nil {
+			return token, next, nil
+		}
+		s.revokeReusedFamily(&current, ipAddress, userAgent)
+		return "", nil, utils.NewUnauthorizedError("Refresh token has been revoked")
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		return "", nil, utils.NewUnauthorizedError("Refresh token has expired")
+	}
+
+	newToken := utils.GenerateRandomToken(32)
+	now := time.Now()
+	next := &models.UserSession{
+		UserID:     current.UserID,
+		FamilyID:   current.FamilyID,
+		TokenHash:  utils.HashToken(newToken),
+		DeviceName: current.DeviceName,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		LastUsedAt: now,
+		ExpiresAt:  current.ExpiresAt,
+	}
+
+	reused := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Guard against two concurrent refreshes with the same token
+		result := tx.Model(&models.UserSession{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		// Cached before commit, so a concurrent refresh that waited on the
+		// row lock above finds it
+		if err := s.cache.Set(rotationGraceKey(current.TokenHash), newToken, int(refreshReuseGrace.Seconds())); err != nil {
+			utils.ErrorLogger.Printf("Failed to cache rotation of session %d: %v", current.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, utils.NewInternalServerError("Failed to rotate session", err)
+	}
+
+	if reused {
+		if token, next := s.recentRotation(&current); next != nil {
+			return token, next, nil
+		}
+		s.revokeReusedFamily(&current, ipAddress, userAgent)
+		return "", nil, utils.NewUnauthorizedError("Refresh token has been revoked")
+	}
+
+	return newToken, next, nil
+}
+
+func rotationGraceKey(tokenHash string) string {
+	return "session:rotated:" + tokenHash
+}
+
+// recentRotation returns the successor a session was rotated into moments
+// ago, while that successor is still the live head of the family.
+func (s *UserSessionService) recentRotation(current *models.UserSession) (string, *models.UserSession) {
+	// Re-read: a concurrent refresh may have rotated it since it was loaded
+	var latest models.UserSession
+	if err := s.db.Select("id", "rotated_at", "revoked_at").First(&latest, current.ID).Error; err != nil ||
+		latest.RotatedAt == nil || latest.RevokedAt != nil || time.Since(*latest.RotatedAt) > refreshReuseGrace {
+		return "", nil
+	}
+
+	var token string
+	if err := s.cache.Get(rotationGraceKey(current.TokenHash), &token); err != nil {
+		return "", nil
+	}
+
+	var next models.UserSession
+	if err := s.db.Where("token_hash = ? AND family_id = ? AND rotated_at IS NULL AND revoked_at IS NULL", utils.HashToken(token), current.FamilyID).
+		First(&next).Error; err != nil {
+		return "", nil
+	}
+	return token, &next
+}
+
+// ListActiveSessions returns one entry per signed-in device: the live head of
+// each unrevoked session family.
+func (s *UserSessionService) ListActiveSessions(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	if err := s.db.Where("user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to fetch sessions", err)
+	}
+	return sessions, nil
+}
+
+func (s *UserSessionService) RevokeSession(userID, sessionID uint) error {
+	var session models.UserSession
+	if err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return utils.NewNotFoundError("Session not found")
+	}
+
+	return s.RevokeFamily(session.FamilyID)
+}
+
+func (s *UserSessionService) RevokeAllSessions(userID uint) error {
+	var familyIDs []string
+	if err := s.db.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Distinct().Pluck("family_id", &familyIDs).Error; err != nil {
+		return utils.NewInternalServerError("Failed to fetch sessions", err)
+	}
+
+	for _, familyID := range familyIDs {
+		if err := s.RevokeFamily(familyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeFamily ends every token in a session family, including access tokens
+// already issued for it.
+func (s *UserSessionService) RevokeFamily(familyID string) error {
+	now := time.Now()
+	if err := s.db.Model(&models.UserSession{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		return utils.NewInternalServerError("Failed to revoke session", err)
+	}
+
+	if s.cache != nil {
+		ttl := s.cfg.JWT.ExpireHours * 3600
+		if err := s.cache.Set(utils.RevokedSessionKey(familyID), true, ttl); err != nil {
+			utils.ErrorLogger.Printf("Failed to cache revoked session: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// WarmRevokedSessions caches families revoked recently enough that access
+// tokens issued for them may still be unexpired.
+func (s *UserSessionService) WarmRevokedSessions() error {
+	if s.cache == nil {
+		return nil
+	}
+
+	window := time.Duration(s.cfg.JWT.ExpireHours) * time.Hour
+	var familyIDs []string
+	if err := s.db.Model(&models.UserSession{}).
+		Where("revoked_at > ?", time.Now().Add(-window)).
+		Distinct().Pluck("family_id", &familyIDs).Error; err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if err := s.cache.Set(utils.RevokedSessionKey(familyID), true, int(window/time.Second)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UserSessionService) revokeReusedFamily(session *models.UserSession, ipAddress, userAgent string) {
+	utils.ErrorLogger.Printf("Refresh token reuse detected for user %d, revoking session family %s", session.UserID, session.FamilyID)
+	if err := s.RevokeFamily(session.FamilyID); err != nil {
+		utils.ErrorLogger.Printf("Failed to revoke session family %s: %v", session.FamilyID, err)
+	}
+	s.db.Create(&models.AuthLog{
+		UserID:    session.UserID,
+		Action:    "refresh_token_reuse",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Success:   false,
+	})
+}
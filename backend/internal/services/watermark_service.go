@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/base32"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"readagain/internal/models"
+	"readagain/internal/utils"
+)
+
+const (
+	// WatermarkVisibleSetting prints the template on every page when "true".
+	WatermarkVisibleSetting = "watermark.visible"
+	// WatermarkInvisibleSetting embeds the watermark code, hidden, when "true".
+	WatermarkInvisibleSetting = "watermark.invisible"
+	// WatermarkTemplateSetting is the visible line. {name}, {email},
+	// {school}, {date} and {code} are replaced for each reader.
+	WatermarkTemplateSetting = "watermark.template"
+
+	defaultWatermarkTemplate = "Licensed to {name}, {school} on {date} · {code}"
+	maxWatermarkTextLength   = 300
+	watermarkDateLayout      = "2006-01-02 15:04 UTC"
+
+	// watermarkMarker prefixes the code where upload-api hides it in a PDF:
+	// the document information dictionary and invisible text on each page.
+	watermarkMarker = "readagain-wm:"
+	// maxWatermarkStreamSize bounds how much of one stream is inflated while
+	// looking for the code in a leaked copy.
+	maxWatermarkStreamSize = 64 * 1024 * 1024
+)
+
+var (
+	watermarkCodePattern   = regexp.MustCompile(regexp.QuoteMeta(watermarkMarker) + `([A-Z2-7]{16})`)
+	watermarkStreamPattern = regexp.MustCompile(`stream\r?\n`)
+)
+
+// WatermarkService decides what is stamped on the PDFs readers download and
+// traces leaked copies back to them.
+type WatermarkService struct {
+	db              *gorm.DB
+	settingsService *SettingsService
+}
+
+func NewWatermarkService(db *gorm.DB, settingsService *SettingsService) *WatermarkService {
+	return &WatermarkService{db: db, settingsService: settingsService}
+}
+
+// WatermarkTrace is the result of looking up a leaked copy.
+type WatermarkTrace struct {
+	Codes   []string               `json:"codes"`
+	Matches []models.BookWatermark `json:"matches"`
+}
+
+func isPDFBook(filePath string) bool {
+	return strings.EqualFold(path.Ext(bookFileName(filePath)), ".pdf")
+}
+
+func newWatermarkCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+func (s *WatermarkService) template() string {
+	setting, err := s.settingsService.GetByKey(WatermarkTemplateSetting)
+	if err != nil || strings.TrimSpace(setting.Value) == "" {
+		return defaultWatermarkTemplate
+	}
+	return setting.Value
+}
+
+func renderWatermark(template string, user *models.User, code string, issuedAt time.Time) string {
+	school := user.SchoolName
+	if user.School != nil {
+		school = user.School.Name
+	}
+	text := strings.NewReplacer(
+		"{name}", strings.TrimSpace(user.FirstName+" "+user.LastName),
+		"{email}", user.Email,
+		"{school}", school,
+		"{date}", issuedAt.UTC().Format(watermarkDateLayout),
+		"{code}", code,
+	).Replace(template)
+
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxWatermarkTextLength {
+		text = string(runes[:maxWatermarkTextLength])
+	}
+	return text
+}
+
+// Issue returns the watermark for userID's copy of book, or nil when the
+// book isn't a PDF or watermarking is switched off. A reader keeps the same
+// watermark, and so the same cached copy, until the settings change.
+func (s *WatermarkService) Issue(userID uint, book *models.Book) (*models.BookWatermark, error) {
+	visible := s.settingsService.GetBool(WatermarkVisibleSetting, true)
+	invisible := s.settingsService.GetBool(WatermarkInvisibleSetting, true)
+	if !isPDFBook(book.FilePath) || (!visible && !invisible) {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := s.db.Preload("School").First(&user, userID).Error; err != nil {
+		return nil, utils.NewNotFoundError("User not found")
+	}
+	template := s.template()
+	text := func(code string, issuedAt time.Time) string {
+		if !visible {
+			return ""
+		}
+		return renderWatermark(template, &user, code, issuedAt)
+	}
+
+	var existing models.BookWatermark
+	err := s.db.Where("user_id = ? AND book_id = ? AND invisible = ?", userID, book.ID, invisible).
+		Order("id DESC").First(&existing).Error
+	if err == nil && existing.Text == text(existing.Code, existing.CreatedAt) {
+		return &existing, nil
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, utils.NewInternalServerError("Failed to load watermark", err)
+	}
+
+	code, err := newWatermarkCode()
+	if err != nil {
+		return nil, utils.NewInternalServerError("Failed to create watermark", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	mark := models.BookWatermark{
+		BaseModel: models.BaseModel{CreatedAt: now},
+		UserID:    userID,
+		BookID:    book.ID,
+		Code:      code,
+		Text:      text(code, now),
+		Invisible: invisible,
+	}
+	if err := s.db.Create(&mark).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to create watermark", err)
+	}
+	utils.InfoLogger.Printf("Issued watermark %s for user %d, book %d", code, userID, book.ID)
+	return &mark, nil
+}
+
+// findWatermarkCodes collects the codes hidden in a PDF, looking at its
+// raw bytes and inside every Flate stream, as tools that re-save a file
+// often compress what was stored plain.
+func findWatermarkCodes(data []byte) []string {
+	seen := map[string]bool{}
+	var codes []string
+	collect := func(b []byte) {
+		for _, m := range watermarkCodePattern.FindAllSubmatch(b, -1) {
+			if code := string(m[1]); !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	collect(data)
+	for _, loc := range watermarkStreamPattern.FindAllIndex(data, -1) {
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[start : start+end]))
+		if err != nil {
+			continue
+		}
+		// Truncated streams still yield what was inflated before the error
+		inflated, _ := io.ReadAll(io.LimitReader(zr, maxWatermarkStreamSize))
+		collect(inflated)
+	}
+	return codes
+}
+
+// normalizeWatermarkCode accepts a code as read off a page, in any case
+// and with spaces or dashes.
+func normalizeWatermarkCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// Trace finds who a leaked copy was issued to, from the file itself, from a
+// code read off its pages, or both.
+func (s *WatermarkService) Trace(data []byte, code string) (*WatermarkTrace, error) {
+	trace := &WatermarkTrace{Codes: []string{}, Matches: []models.BookWatermark{}}
+	if data != nil {
+		trace.Codes = append(trace.Codes, findWatermarkCodes(data)...)
+	}
+	if code = normalizeWatermarkCode(code); code != "" {
+		trace.Codes = append(trace.Codes, code)
+	}
+	if len(trace.Codes) == 0 {
+		return trace, nil
+	}
+
+	if err := s.db.Preload("User.School").Preload("Book").
+		Where("code IN ?", trace.Codes).
+		Order("created_at DESC").
+		Find(&trace.Matches).Error; err != nil {
+		return nil, utils.NewInternalServerError("Failed to look up watermarks", err)
+	}
+	return trace, nil
+}
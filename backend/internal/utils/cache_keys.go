@@ -74,3 +74,12 @@ func FAQListKey(category string) string {
 func TestimonialListKey() string {
 	return "testimonials:list"
 }
+
+// RevokedTokenKey hashes the token so raw JWTs never sit in the cache.
+func RevokedTokenKey(token string) string {
+	return "auth:revoked:" + HashToken(token)
+}
+
+func RevokedSessionKey(familyID string) string {
+	return fmt.Sprintf("auth:revoked-session:%s", familyID)
+}
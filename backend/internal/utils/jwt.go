@@ -9,24 +9,21 @@ import (
 )
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	RoleID   uint   `json:"role_id"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	RoleID    uint   `json:"role_id"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-type RefreshClaims struct {
-	UserID uint `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
-func GenerateAccessToken(userID uint, email, username string, roleID uint, secret string, expireHours int) (string, error) {
+func GenerateAccessToken(userID uint, email, username string, roleID uint, sessionID, secret string, expireHours int) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
-		RoleID:   roleID,
+		UserID:    userID,
+		Email:     email,
+		Username:  username,
+		RoleID:    roleID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -37,19 +34,6 @@ func GenerateAccessToken(userID uint, email, username string, roleID uint, secre
 	return token.SignedString([]byte(secret))
 }
 
-func GenerateRefreshToken(userID uint, secret string, expireDays int) (string, error) {
-	claims := RefreshClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * time.Duration(expireDays))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
 func ValidateAccessToken(tokenString string, secret string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -69,25 +53,6 @@ func ValidateAccessToken(tokenString string, secret string) (*Claims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
-func ValidateRefreshToken(tokenString string, secret string) (*RefreshClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &RefreshClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(*RefreshClaims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, fmt.Errorf("invalid refresh token")
-}
-
 // ValidateToken validates a token using the JWT secret from environment
 func ValidateToken(tokenString string) (*Claims, error) {
 	secret := os.Getenv("JWT_SECRET")
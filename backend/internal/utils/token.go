@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
@@ -10,3 +11,9 @@ func GenerateRandomToken(length int) string {
 	rand.Read(bytes)
 	return hex.EncodeToString(bytes)
 }
+
+// HashToken returns the hex SHA-256 of a secret token for storage and lookup.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,12 +3,29 @@ package utils
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
 var Validate = validator.New()
 
+func init() {
+	Validate.RegisterValidation("iana_timezone", validateIANATimezone)
+}
+
+// validateIANATimezone accepts a zone name such as Africa/Lagos. The
+// built-in timezone tag also takes "" and "Local", which time.LoadLocation
+// reads as UTC and the server's own zone.
+func validateIANATimezone(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
 func FormatValidationError(err error) string {
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		var messages []string
@@ -10,25 +10,40 @@ import (
 func RunMigrations(db *gorm.DB) error {
 	utils.InfoLogger.Println("Running database migrations...")
 
+	// Annotation columns default to the next sync version
+	if err := db.Exec("CREATE SEQUENCE IF NOT EXISTS annotation_sync_seq").Error; err != nil {
+		utils.ErrorLogger.Printf("Migration failed: %v", err)
+		return err
+	}
+
 	err := db.AutoMigrate(
+		&models.School{},
 		&models.User{},
 		&models.Role{},
 		&models.Permission{},
 		&models.AuthLog{},
 		&models.TokenBlacklist{},
+		&models.UserSession{},
+		&models.UserImportJob{},
 		&models.Author{},
 		&models.Book{},
+		&models.BookChapter{},
+		&models.BookSimilarity{},
+		&models.BookWatermark{},
 		&models.Category{},
-		&models.Cart{},
-		&models.Order{},
-		&models.OrderItem{},
 		&models.UserLibrary{},
 		&models.ReadingSession{},
+		&models.ReadingAssignment{},
+		&models.ReadingAssignmentStudent{},
+		&models.GuardianInvitation{},
+		&models.GuardianLink{},
 		&models.ReadingGoal{},
+		&models.StreakFreeze{},
 		&models.Blog{},
 		&models.FAQ{},
 		&models.Review{},
 		&models.SystemSettings{},
+		&models.EmailOutbox{},
 		&models.AuditLog{},
 		&models.Notification{},
 		&models.Achievement{},
@@ -40,6 +55,19 @@ func RunMigrations(db *gorm.DB) error {
 		return err
 	}
 
+	// Book suggestions use trigram similarity for typo tolerance
+	searchStatements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_books_title_trgm ON books USING gin (title gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_authors_business_name_trgm ON authors USING gin (business_name gin_trgm_ops)",
+	}
+	for _, stmt := range searchStatements {
+		if err := db.Exec(stmt).Error; err != nil {
+			utils.ErrorLogger.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
 	utils.InfoLogger.Println("✅ Migrations completed successfully")
 	return nil
 }
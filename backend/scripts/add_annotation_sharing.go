@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Existing notes and highlights default to private. Books assigned to a
+	// group before group_books existed need assigning again before
+	// annotations can be shared with that group.
+	if err := database.DB.AutoMigrate(
+		&models.Note{},
+		&models.Highlight{},
+		&models.GroupBook{},
+		&models.AnnotationReply{},
+	); err != nil {
+		log.Fatal("Failed to migrate annotation sharing tables:", err)
+	}
+
+	log.Println("✅ Annotation sharing tables migrated successfully")
+}
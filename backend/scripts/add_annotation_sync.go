@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	db := database.DB
+
+	// sync_version defaults to the next value of this sequence
+	if err := db.Exec("CREATE SEQUENCE IF NOT EXISTS annotation_sync_seq").Error; err != nil {
+		log.Fatal("Failed to create annotation sync sequence:", err)
+	}
+
+	if err := db.AutoMigrate(&models.Bookmark{}, &models.Note{}, &models.Highlight{}); err != nil {
+		log.Fatal("Failed to migrate annotation tables:", err)
+	}
+
+	// Existing annotations get a client ID so devices can refer to them,
+	// and their last edit time as the conflict timestamp.
+	for _, table := range []string{"bookmarks", "notes", "highlights"} {
+		if err := db.Exec("UPDATE " + table + " SET client_id = gen_random_uuid()::text WHERE client_id IS NULL OR client_id = ''").Error; err != nil {
+			log.Fatal("Failed to backfill client IDs for "+table+":", err)
+		}
+		if err := db.Exec("UPDATE " + table + " SET client_updated_at = COALESCE(deleted_at, updated_at) WHERE client_updated_at IS NULL").Error; err != nil {
+			log.Fatal("Failed to backfill client timestamps for "+table+":", err)
+		}
+	}
+
+	log.Println("✅ Annotation sync columns added successfully")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Adds book_chapters plus the chapter columns on user_libraries and
+	// reading_sessions. Existing books get chapters when their table of
+	// contents is sent to PUT /api/admin/books/:id/toc.
+	if err := database.DB.AutoMigrate(&models.BookChapter{}, &models.UserLibrary{}, &models.ReadingSession{}); err != nil {
+		log.Fatal("Failed to migrate chapter tables:", err)
+	}
+
+	log.Println("✅ Book chapter tables created successfully")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+	"readagain/internal/services"
+)
+
+func main() {
+	cfg := config.Load()
+
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	log.Println("✅ Database connected")
+
+	if err := database.DB.AutoMigrate(&models.Book{}); err != nil {
+		log.Fatal("Failed to add search_vector column:", err)
+	}
+
+	indexes := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_books_title_trgm ON books USING gin (title gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_authors_business_name_trgm ON authors USING gin (business_name gin_trgm_ops)",
+	}
+
+	for _, idx := range indexes {
+		if err := database.DB.Exec(idx).Error; err != nil {
+			log.Printf("❌ Failed to create index: %v", err)
+		}
+	}
+
+	if err := services.NewBookService(database.DB).RebuildSearchIndex(); err != nil {
+		log.Fatal("Failed to backfill search vectors:", err)
+	}
+
+	log.Println("✅ Book search index created successfully!")
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+	"readagain/internal/services"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.BookSimilarity{}); err != nil {
+		log.Fatal("Failed to migrate book similarities:", err)
+	}
+	log.Println("✅ Book similarity table created successfully")
+
+	// The API rebuilds these on startup too; building now fills the table
+	// straight away.
+	books, err := services.NewRecommendationService(database.DB).RebuildSimilarities()
+	if err != nil {
+		log.Fatal("Failed to build book similarities:", err)
+	}
+	log.Printf("✅ Built similarities for %d books", books)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Watermarks are issued the next time each reader opens a PDF. Run
+	// seed_permissions.go afterwards for books.trace_leaks.
+	if err := database.DB.AutoMigrate(&models.BookWatermark{}); err != nil {
+		log.Fatal("Failed to migrate book watermarks:", err)
+	}
+
+	log.Println("✅ Book watermarks table created successfully")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Run seed_roles.go, seed_permissions.go and assign_role_permissions.go
+	// afterwards for the guardian role and guardians.manage.
+	if err := database.DB.AutoMigrate(&models.GuardianInvitation{}, &models.GuardianLink{}); err != nil {
+		log.Fatal("Failed to migrate guardians:", err)
+	}
+
+	log.Println("✅ Guardian tables created successfully")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Run seed_permissions.go and assign_role_permissions.go afterwards for
+	// assignments.manage.
+	if err := database.DB.AutoMigrate(&models.ReadingAssignment{}, &models.ReadingAssignmentStudent{}); err != nil {
+		log.Fatal("Failed to migrate reading assignments:", err)
+	}
+
+	log.Println("✅ Reading assignment tables created successfully")
+}
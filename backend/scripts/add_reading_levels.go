@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Run seed_permissions.go and assign_role_permissions.go afterwards for
+	// reading_levels.manage.
+	if err := database.DB.AutoMigrate(&models.Book{}, &models.User{}); err != nil {
+		log.Fatal("Failed to migrate reading levels:", err)
+	}
+
+	log.Println("✅ Reading level columns added successfully")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Adds locator, position_updated_at and position_device. Existing rows
+	// keep their progress; readers fall back to it until the first sync.
+	if err := database.DB.AutoMigrate(&models.UserLibrary{}); err != nil {
+		log.Fatal("Failed to migrate user libraries table:", err)
+	}
+
+	log.Println("✅ Reading position columns added successfully")
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	// Adds users.timezone and schools.timezone; empty means "use the
+	// school's" and UTC respectively, so existing rows need no backfill.
+	if err := database.DB.AutoMigrate(&models.User{}, &models.School{}, &models.StreakFreeze{}); err != nil {
+		log.Fatal("Failed to migrate streak tables:", err)
+	}
+
+	if err := database.DB.Exec("CREATE INDEX IF NOT EXISTS idx_reading_sessions_user_start ON reading_sessions (user_id, start_time)").Error; err != nil {
+		log.Fatal("Failed to create reading session index:", err)
+	}
+
+	log.Println("✅ Reading streak tables created successfully")
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.UserImportJob{}); err != nil {
+		log.Fatal("Failed to migrate user import jobs:", err)
+	}
+
+	log.Println("✅ User import jobs table created successfully")
+}
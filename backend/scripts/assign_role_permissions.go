@@ -40,12 +40,15 @@ func main() {
 
 	// School admin permissions (manage school-level resources)
 	schoolAdminPerms := []string{
-		"users.view", "users.create", "users.update",
-		"books.view", "books.create", "books.update",
+		"analytics.view",
+		"users.view", "users.create", "users.edit", "users.delete",
+		"books.view", "books.create", "books.edit",
+		"authors.view",
 		"library.view", "library.manage",
-		"reading.view_analytics",
+		"reading.view_analytics", "reading.manage", "assignments.manage", "classes.view", "guardians.manage", "reading_levels.manage",
+		"annotations.share",
 		"reviews.view", "reviews.moderate",
-		"reports.view",
+		"reports.view", "reports.generate", "reports.export",
 		"blog.view",
 		"about.view",
 		"contact.view",
@@ -78,7 +81,12 @@ func main() {
 		"books.view",
 		"library.view",
 		"reading.view_analytics",
+		"assignments.manage",
+		"classes.view",
+		"guardians.manage",
+		"reading_levels.manage",
 		"reviews.view",
+		"annotations.share",
 	}
 
 	var teacherPermissions []models.Permission
@@ -102,10 +110,10 @@ func main() {
 		log.Fatal("Failed to find student role:", err)
 	}
 
-	// Student permissions (basic reading access)
+	// Student permissions (basic reading access). Their own library needs
+	// no permission; library.view opens the admin assignment views.
 	studentPerms := []string{
 		"books.view",
-		"library.view",
 	}
 
 	var studentPermissions []models.Permission
@@ -123,5 +131,31 @@ func main() {
 	}
 	log.Printf("✓ Assigned %d permissions to student role", len(studentPermissions))
 
+	// Get guardian role
+	var guardianRole models.Role
+	if err := database.DB.Where("name = ?", "guardian").First(&guardianRole).Error; err != nil {
+		log.Fatal("Failed to find guardian role:", err)
+	}
+
+	// Guardian permissions (children are reached through guardian links)
+	guardianPerms := []string{
+		"books.view",
+	}
+
+	var guardianPermissions []models.Permission
+	for _, permName := range guardianPerms {
+		for _, perm := range allPermissions {
+			if perm.Name == permName {
+				guardianPermissions = append(guardianPermissions, perm)
+				break
+			}
+		}
+	}
+
+	if err := database.DB.Model(&guardianRole).Association("Permissions").Replace(&guardianPermissions); err != nil {
+		log.Fatal("Failed to assign permissions to guardian:", err)
+	}
+	log.Printf("✓ Assigned %d permissions to guardian role", len(guardianPermissions))
+
 	log.Println("\n✅ All role permissions assigned successfully!")
 }
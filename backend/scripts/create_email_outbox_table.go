@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.EmailOutbox{}); err != nil {
+		log.Fatal("Failed to migrate email outbox table:", err)
+	}
+
+	// Sent messages no longer keep their body, which can carry sign-in links
+	result := database.DB.Model(&models.EmailOutbox{}).
+		Where("status = ? AND html <> ''", "sent").
+		Update("html", "")
+	if result.Error != nil {
+		log.Fatal("Failed to purge sent email bodies:", result.Error)
+	}
+
+	log.Printf("✅ Email outbox table created successfully (purged %d sent bodies)", result.RowsAffected)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.School{}, &models.User{}, &models.Group{}, &models.ChatRoom{}, &models.UserLibrary{}); err != nil {
+		log.Fatal("Failed to migrate schools table:", err)
+	}
+
+	// Backfill schools from the free-text school names users registered with
+	backfill := []string{
+		`INSERT INTO schools (name, category, is_active, created_at, updated_at)
+		 SELECT DISTINCT ON (LOWER(TRIM(school_name))) TRIM(school_name), COALESCE(school_category, ''), true, NOW(), NOW()
+		 FROM users
+		 WHERE school_name IS NOT NULL AND TRIM(school_name) <> '' AND deleted_at IS NULL
+		 ORDER BY LOWER(TRIM(school_name))
+		 ON CONFLICT (name) DO NOTHING`,
+		`UPDATE users u SET school_id = s.id
+		 FROM schools s
+		 WHERE u.school_id IS NULL AND LOWER(TRIM(u.school_name)) = LOWER(s.name)`,
+		`UPDATE user_libraries ul SET school_id = u.school_id
+		 FROM users u
+		 WHERE ul.user_id = u.id AND ul.school_id IS NULL AND u.school_id IS NOT NULL`,
+		`UPDATE groups g SET school_id = u.school_id
+		 FROM users u
+		 WHERE g.created_by = u.id AND g.school_id IS NULL AND u.school_id IS NOT NULL`,
+		`UPDATE chat_rooms cr SET school_id = u.school_id
+		 FROM users u
+		 WHERE cr.created_by = u.id AND cr.school_id IS NULL AND u.school_id IS NOT NULL`,
+	}
+
+	for _, stmt := range backfill {
+		if err := database.DB.Exec(stmt).Error; err != nil {
+			log.Fatal("Failed to backfill school data:", err)
+		}
+	}
+
+	log.Println("✅ Schools table created and backfilled successfully")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"log"
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.UserSession{}); err != nil {
+		log.Fatal("Failed to migrate user sessions table:", err)
+	}
+
+	log.Println("✅ User sessions table created successfully")
+}
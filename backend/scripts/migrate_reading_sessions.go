@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.ReadingSession{}); err != nil {
+		log.Fatal("Failed to migrate reading sessions table:", err)
+	}
+
+	// Sessions from before heartbeats never recorded a start time or status;
+	// treat them as finished so the sweeper leaves them alone.
+	statements := []string{
+		"UPDATE reading_sessions SET start_time = created_at WHERE start_time < '1970-01-01'",
+		"UPDATE reading_sessions SET status = 'ended', last_heartbeat_at = COALESCE(end_time, updated_at), end_time = COALESCE(end_time, updated_at) WHERE last_heartbeat_at IS NULL",
+	}
+	for _, stmt := range statements {
+		if err := database.DB.Exec(stmt).Error; err != nil {
+			log.Fatal("Failed to backfill reading sessions:", err)
+		}
+	}
+
+	log.Println("✅ Reading sessions migrated successfully")
+}
@@ -29,6 +29,8 @@ func main() {
 		{Name: "First Session", Description: "Complete your first reading session", Icon: "🎯", Type: "reading_sessions", Target: 1, Points: 5},
 		{Name: "Consistent Reader", Description: "Complete 50 reading sessions", Icon: "📅", Type: "reading_sessions", Target: 50, Points: 150},
 		{Name: "Reading Habit", Description: "Complete 200 reading sessions", Icon: "🔥", Type: "reading_sessions", Target: 200, Points: 400},
+		{Name: "On a Roll", Description: "Read 7 days in a row", Icon: "🔥", Type: "reading_streak", Target: 7, Points: 50},
+		{Name: "Unstoppable", Description: "Read 30 days in a row", Icon: "⚡", Type: "reading_streak", Target: 30, Points: 250},
 	}
 
 	for _, achievement := range achievements {
@@ -47,6 +47,22 @@ func main() {
 		{Name: "books.edit", Description: "Edit books", Category: "books"},
 		{Name: "books.delete", Description: "Delete books", Category: "books"},
 		{Name: "books.manage", Description: "Full book management", Category: "books"},
+		{Name: "books.trace_leaks", Description: "Identify who a leaked book copy was issued to", Category: "books"},
+
+		// Categories & Authors
+		{Name: "categories.manage", Description: "Manage book categories", Category: "books"},
+		{Name: "authors.view", Description: "View authors", Category: "books"},
+		{Name: "authors.manage", Description: "Manage authors", Category: "books"},
+
+		// Library
+		{Name: "library.view", Description: "View library assignments", Category: "library"},
+		{Name: "library.manage", Description: "Assign and remove library books", Category: "library"},
+
+		// Schools
+		{Name: "schools.manage", Description: "Manage schools and their members", Category: "schools"},
+
+		// Annotations
+		{Name: "annotations.share", Description: "Share notes and highlights and moderate their replies", Category: "annotations"},
 
 		// Reviews
 		{Name: "reviews.view", Description: "View reviews", Category: "reviews"},
@@ -67,12 +83,19 @@ func main() {
 		// Reading Analytics
 		{Name: "reading.view_analytics", Description: "View reading analytics", Category: "reading"},
 		{Name: "reading.manage", Description: "Manage reading data", Category: "reading"},
+		{Name: "assignments.manage", Description: "Set and monitor reading assignments", Category: "reading"},
+		{Name: "classes.view", Description: "View class dashboards", Category: "reading"},
+		{Name: "guardians.manage", Description: "Invite and view guardians of students", Category: "reading"},
+		{Name: "reading_levels.manage", Description: "Assess students' reading levels", Category: "reading"},
 
 		// Reports
 		{Name: "reports.view", Description: "View reports", Category: "reports"},
 		{Name: "reports.generate", Description: "Generate reports", Category: "reports"},
 		{Name: "reports.export", Description: "Export reports", Category: "reports"},
 
+		// Achievements
+		{Name: "achievements.manage", Description: "Manage achievements", Category: "achievements"},
+
 		// Email Templates
 		{Name: "email_templates.view", Description: "View email templates", Category: "email"},
 		{Name: "email_templates.create", Description: "Create email templates", Category: "email"},
@@ -107,6 +130,10 @@ func main() {
 		{Name: "faq.edit", Description: "Edit FAQs", Category: "faq"},
 		{Name: "faq.delete", Description: "Delete FAQs", Category: "faq"},
 
+		// Testimonials
+		{Name: "testimonials.view", Description: "View testimonials", Category: "testimonials"},
+		{Name: "testimonials.manage", Description: "Manage testimonials", Category: "testimonials"},
+
 		// Settings
 		{Name: "settings.view", Description: "View settings", Category: "settings"},
 		{Name: "settings.edit", Description: "Edit settings", Category: "settings"},
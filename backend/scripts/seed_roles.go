@@ -22,6 +22,7 @@ func main() {
 		{Name: "teacher", Description: "Teacher who assigns books and tracks student progress"},
 		{Name: "school_admin", Description: "School administrator who manages school users and library"},
 		{Name: "platform_admin", Description: "Platform administrator with full system access"},
+		{Name: "guardian", Description: "Parent or guardian who follows linked students' reading"},
 	}
 
 	for _, role := range roles {
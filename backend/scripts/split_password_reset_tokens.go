@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.User{}); err != nil {
+		log.Fatal("Failed to migrate users table:", err)
+	}
+
+	// Until now verification_token only ever held password reset tokens
+	result := database.DB.Exec(`
+		UPDATE users
+		SET password_reset_token = verification_token,
+		    password_reset_expires = verification_token_expires,
+		    verification_token = '',
+		    verification_token_expires = NULL
+		WHERE verification_token IS NOT NULL AND verification_token <> ''
+	`)
+	if result.Error != nil {
+		log.Fatal("Failed to move password reset tokens:", result.Error)
+	}
+
+	// Accounts from before verification existed never got a link, so they
+	// count as verified. Only on the first run, which is the one that seeds
+	// the setting, so later signups keep needing to verify.
+	var seeded int64
+	if err := database.DB.Table("system_settings").Where("key = ?", "auth.require_email_verification").Count(&seeded).Error; err != nil {
+		log.Fatal("Failed to check email verification setting:", err)
+	}
+	var backfilled int64
+	if seeded == 0 {
+		verified := database.DB.Model(&models.User{}).Where("is_email_verified = ?", false).Update("is_email_verified", true)
+		if verified.Error != nil {
+			log.Fatal("Failed to mark existing users verified:", verified.Error)
+		}
+		backfilled = verified.RowsAffected
+	}
+
+	if err := database.DB.Exec(`
+		INSERT INTO system_settings (key, value, data_type, category, description, created_at, updated_at)
+		VALUES ('auth.require_email_verification', 'false', 'boolean', 'auth', 'Block login until the email address is verified', NOW(), NOW())
+		ON CONFLICT (key) DO NOTHING
+	`).Error; err != nil {
+		log.Fatal("Failed to seed email verification setting:", err)
+	}
+
+	log.Printf("✅ Moved %d password reset tokens out of verification_token, marked %d existing users verified", result.RowsAffected, backfilled)
+}
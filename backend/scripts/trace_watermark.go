@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"readagain/internal/config"
+	"readagain/internal/database"
+	"readagain/internal/services"
+)
+
+// Traces a leaked PDF too large to upload to POST /admin/watermarks/trace:
+//
+//	go run scripts/trace_watermark.go leaked.pdf
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("Usage: go run scripts/trace_watermark.go <file.pdf>")
+	}
+	data, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		log.Fatal("Failed to read file:", err)
+	}
+
+	cfg := config.Load()
+	if err := database.Connect(cfg.Database.URL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	watermarkService := services.NewWatermarkService(database.DB, services.NewSettingsService(database.DB))
+	trace, err := watermarkService.Trace(data, "")
+	if err != nil {
+		log.Fatal("Failed to trace watermark:", err)
+	}
+	if len(trace.Codes) == 0 {
+		log.Fatal("❌ No watermark found in the file")
+	}
+
+	log.Printf("Codes found: %v", trace.Codes)
+	for _, mark := range trace.Matches {
+		log.Printf("✅ %s issued %s", mark.Code, mark.CreatedAt.Format("2006-01-02 15:04 MST"))
+		if mark.User != nil {
+			log.Printf("   Reader: %s %s <%s> (ID: %d)", mark.User.FirstName, mark.User.LastName, mark.User.Email, mark.User.ID)
+			if mark.User.School != nil {
+				log.Printf("   School: %s", mark.User.School.Name)
+			}
+		}
+		if mark.Book != nil {
+			log.Printf("   Book: %s (ID: %d)", mark.Book.Title, mark.Book.ID)
+		}
+	}
+	if len(trace.Matches) == 0 {
+		log.Println("❌ The codes found don't match any issued watermark")
+	}
+}
@@ -1,7 +1,7 @@
 import { useState, useEffect, useCallback, useRef } from 'react';
 import { motion } from 'framer-motion';
 import api from '../lib/api';
-import { queueProgressUpdate, syncQueuedUpdates, isOnline } from '../lib/offlineSync';
+import { queueProgressUpdate, syncQueuedUpdates, isOnline, buildPositionUpdate } from '../lib/offlineSync';
 
 export default function EReader({ bookId, onClose }) {
   const [book, setBook] = useState(null);
@@ -29,10 +29,9 @@ export default function EReader({ bookId, onClose }) {
   const [totalHeight, setTotalHeight] = useState(0);
 
   // Session tracking
-  const [sessionId, setSessionId] = useState(null);
-  const sessionStartTimeRef = useRef(null);
-  const sessionDurationRef = useRef(0);
-  const sessionIntervalRef = useRef(null);
+  const sessionIdRef = useRef(null);
+  const heartbeatIntervalRef = useRef(null);
+  const progressRef = useRef(0);
 
   const contentRef = useRef(null);
   const saveTimeoutRef = useRef(null);
@@ -48,13 +47,15 @@ export default function EReader({ bookId, onClose }) {
         const response = await api.post('/reading/sessions/start', {
           book_id: parseInt(bookId)
         });
-        setSessionId(response.data.session.id);
-        sessionStartTimeRef.current = Date.now();
-        
-        // Track duration every second
-        sessionIntervalRef.current = setInterval(() => {
-          sessionDurationRef.current = Math.floor((Date.now() - sessionStartTimeRef.current) / 1000);
-        }, 1000);
+        sessionIdRef.current = response.data.session.id;
+
+        // The server counts reading time from heartbeats, so keep them coming
+        const newSessionId = response.data.session.id;
+        heartbeatIntervalRef.current = setInterval(() => {
+          api.post(`/reading/sessions/${newSessionId}/heartbeat`, {
+            locator: { total_progression: progressRef.current }
+          }).catch(err => console.error('Session heartbeat failed:', err));
+        }, 30000);
         
         console.log('📊 Reading session started:', response.data.session.id);
       } catch (err) {
@@ -66,20 +67,26 @@ export default function EReader({ bookId, onClose }) {
 
     // Cleanup: End session on unmount
     return () => {
-      if (sessionIntervalRef.current) {
-        clearInterval(sessionIntervalRef.current);
+      if (heartbeatIntervalRef.current) {
+        clearInterval(heartbeatIntervalRef.current);
       }
       
-      if (sessionId && sessionDurationRef.current > 0) {
+      const endingSessionId = sessionIdRef.current;
+      sessionIdRef.current = null;
+      if (endingSessionId) {
         // End session (fire and forget)
         api.post('/reading/sessions/end', {
-          session_id: sessionId,
-          duration: sessionDurationRef.current
+          session_id: endingSessionId,
+          locator: { total_progression: progressRef.current }
         }).catch(err => console.error('Failed to end session:', err));
       }
     };
   }, [bookId]);
 
+  useEffect(() => {
+    progressRef.current = progress;
+  }, [progress]);
+
   useEffect(() => {
     loadBook();
     loadHighlights();
@@ -160,7 +167,7 @@ export default function EReader({ bookId, onClose }) {
       // Use libraryItem.book if it exists, otherwise use libraryItem itself
       const bookData = libraryItem.book || libraryItem;
       setBook(bookData);
-      setProgress(libraryItem.progress || 0);
+      setProgress(libraryItem.locator?.total_progression ?? (libraryItem.progress || 0) / 100);
 
       const contentResponse = await api.get(`/ereader/${bookId}/content`);
       let htmlContent = contentResponse.data.content || contentResponse.data.html_content || '';
@@ -245,22 +252,23 @@ export default function EReader({ bookId, onClose }) {
     }
 
     saveTimeoutRef.current = setTimeout(async () => {
-      const progressData = {
-        current_page: 0,
-        total_pages: 100,
-        progress: newProgress * 100
-      };
+      const positionData = buildPositionUpdate({
+        progression: newProgress,
+        total_progression: newProgress
+      });
 
       try {
         if (isOnline()) {
-          await api.put(`/library/${bookId}/progress`, progressData);
+          await api.put(`/library/${bookId}/position`, positionData);
         } else {
-          queueProgressUpdate(bookId, progressData);
+          queueProgressUpdate(bookId, positionData);
         }
         setProgress(newProgress);
       } catch (err) {
+        // 409: another device saved a newer position, which wins
+        if (err.response?.status === 409) return;
         console.error('Error updating progress:', err);
-        queueProgressUpdate(bookId, progressData);
+        queueProgressUpdate(bookId, positionData);
       }
     }, 2000);
   }, [bookId]);
@@ -284,11 +292,16 @@ export default function EReader({ bookId, onClose }) {
   }, [progress, updateProgress]);
 
   const handleClose = async () => {
-    if (sessionId && sessionDurationRef.current > 0) {
+    const endingSessionId = sessionIdRef.current;
+    sessionIdRef.current = null;
+    if (endingSessionId) {
+      if (heartbeatIntervalRef.current) {
+        clearInterval(heartbeatIntervalRef.current);
+      }
       try {
         await api.post('/reading/sessions/end', {
-          session_id: sessionId,
-          duration: sessionDurationRef.current
+          session_id: endingSessionId,
+          locator: { total_progression: progressRef.current }
         });
       } catch (err) {
         console.error('Failed to end session:', err);
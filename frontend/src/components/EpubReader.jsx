@@ -2,7 +2,7 @@ import { useState, useEffect, useRef } from 'react';
 import ePub from 'epubjs';
 import api from '../lib/api';
 import { getCachedEpub, cacheEpub, cacheLocations } from '../lib/epubCache';
-import { queueProgressUpdate, syncQueuedUpdates, isOnline } from '../lib/offlineSync';
+import { queueProgressUpdate, syncQueuedUpdates, isOnline, buildPositionUpdate } from '../lib/offlineSync';
 import { getBookFileUrl } from '../lib/fileService';
 import EReaderTour from './EReaderTour';
 
@@ -44,11 +44,11 @@ export default function EpubReader({ bookId, onClose }) {
     timeRemaining: 0
   });
   
-  // Session tracking
-  const [sessionId, setSessionId] = useState(null);
-  const sessionStartTimeRef = useRef(null);
-  const sessionDurationRef = useRef(0);
-  const sessionIntervalRef = useRef(null);
+  // Session tracking: the server counts reading time from heartbeats, so
+  // the id lives in a ref the intervals and the unmount cleanup can read
+  const sessionIdRef = useRef(null);
+  const heartbeatIntervalRef = useRef(null);
+  const locatorRef = useRef({ total_progression: 0 });
 
   const viewerRef = useRef(null);
   const bookRef = useRef(null);
@@ -57,22 +57,43 @@ export default function EpubReader({ bookId, onClose }) {
   const touchStartX = useRef(0);
   const touchEndX = useRef(0);
 
+  const endSession = () => {
+    if (heartbeatIntervalRef.current) {
+      clearInterval(heartbeatIntervalRef.current);
+      heartbeatIntervalRef.current = null;
+    }
+    const id = sessionIdRef.current;
+    sessionIdRef.current = null;
+    if (!id) return Promise.resolve();
+    return api.post('/reading/sessions/end', {
+      session_id: id,
+      locator: locatorRef.current
+    }).catch(err => console.error('Failed to end session:', err));
+  };
+
   // Start reading session
   useEffect(() => {
+    let cancelled = false;
+
     const startSession = async () => {
       try {
         const response = await api.post('/reading/sessions/start', {
           book_id: parseInt(bookId)
         });
-        setSessionId(response.data.session.id);
-        sessionStartTimeRef.current = Date.now();
-        
-        // Track duration every second
-        sessionIntervalRef.current = setInterval(() => {
-          sessionDurationRef.current = Math.floor((Date.now() - sessionStartTimeRef.current) / 1000);
-        }, 1000);
-        
-        console.log('📊 Reading session started:', response.data.session.id);
+        const newSessionId = response.data.session.id;
+        sessionIdRef.current = newSessionId;
+        if (cancelled) {
+          endSession();
+          return;
+        }
+
+        heartbeatIntervalRef.current = setInterval(() => {
+          api.post(`/reading/sessions/${newSessionId}/heartbeat`, {
+            locator: locatorRef.current
+          }).catch(err => console.error('Session heartbeat failed:', err));
+        }, 30000);
+
+        console.log('📊 Reading session started:', newSessionId);
       } catch (err) {
         console.error('Failed to start reading session:', err);
       }
@@ -80,19 +101,10 @@ export default function EpubReader({ bookId, onClose }) {
 
     startSession();
 
-    // Cleanup: End session on unmount
+    // Cleanup: End session on unmount (fire and forget)
     return () => {
-      if (sessionIntervalRef.current) {
-        clearInterval(sessionIntervalRef.current);
-      }
-      
-      if (sessionId && sessionDurationRef.current > 0) {
-        // End session (fire and forget)
-        api.post('/reading/sessions/end', {
-          session_id: sessionId,
-          duration: sessionDurationRef.current
-        }).catch(err => console.error('Failed to end session:', err));
-      }
+      cancelled = true;
+      endSession();
     };
   }, [bookId]);
 
@@ -172,9 +184,9 @@ export default function EpubReader({ bookId, onClose }) {
         blob = cachedData.blob;
       } else {
         console.log('📥 Downloading EPUB from server');
-        // Get book file URL from upload API
-        const bookFileUrl = getBookFileUrl(libraryItem.book.file_path);
-        
+        // Get a short-lived signed URL for the book file on the upload API
+        const bookFileUrl = await getBookFileUrl(bookId);
+
         // Fetch EPUB file as blob
         const response = await fetch(bookFileUrl);
 
@@ -243,7 +255,12 @@ export default function EpubReader({ bookId, onClose }) {
 
       // Load saved location or start from beginning
       const savedProgress = libraryItem.progress || 0;
-      if (savedProgress > 0) {
+      const savedCfi = libraryItem.locator?.cfi;
+      if (savedCfi) {
+        // Resume at the exact spot synced from any device
+        await renditionInstance.display(savedCfi);
+        console.log('📖 Restored to saved position');
+      } else if (savedProgress > 0) {
         // Calculate location from progress percentage
         const targetLocation = Math.floor((savedProgress / 100) * epubBook.locations.total);
         const cfi = epubBook.locations.cfiFromLocation(targetLocation);
@@ -262,18 +279,23 @@ export default function EpubReader({ bookId, onClose }) {
         // Calculate progress data
         updateProgressData(epubBook, location);
 
+        // Calculate progress using book locations
+        const currentLocation = epubBook.locations.locationFromCfi(location.start.cfi);
+        const totalLocations = epubBook.locations.total;
+        const progress = currentLocation / totalLocations;
+        locatorRef.current = {
+          cfi: location.start.cfi,
+          href: location.start.href,
+          total_progression: Math.min(Math.max(progress || 0, 0), 1)
+        };
+
         // Skip saving on initial load
         if (isInitialLoadRef.current) {
           isInitialLoadRef.current = false;
           return;
         }
 
-        // Calculate progress using book locations
-        const currentLocation = epubBook.locations.locationFromCfi(location.start.cfi);
-        const totalLocations = epubBook.locations.total;
-        const progress = currentLocation / totalLocations;
-
-        saveProgress(location.start.cfi, progress);
+        saveProgress(location.start.cfi, location.start.href, progress);
       });
 
       // Handle text selection for highlights and notes
@@ -346,22 +368,24 @@ export default function EpubReader({ bookId, onClose }) {
     renditionInstance.themes.override('padding', '20px', true);
   };
 
-  const saveProgress = async (cfi, percentage, immediate = false) => {
-    const progressData = {
-      current_page: 0,
-      total_pages: 100,
-      progress: (percentage || 0) * 100
-    };
+  const saveProgress = async (cfi, href, percentage, immediate = false) => {
+    const progressData = buildPositionUpdate({
+      cfi,
+      href,
+      total_progression: Math.min(Math.max(percentage || 0, 0), 1)
+    });
 
     // If immediate save (on load), don't throttle
     if (immediate) {
       try {
         if (isOnline()) {
-          await api.put(`/library/${bookId}/progress`, progressData);
+          await api.put(`/library/${bookId}/position`, progressData);
         } else {
           queueProgressUpdate(bookId, progressData);
         }
       } catch (err) {
+        // 409: another device saved a newer position, which wins
+        if (err.response?.status === 409) return;
         console.error('Error saving progress:', err);
         queueProgressUpdate(bookId, progressData);
       }
@@ -379,11 +403,13 @@ export default function EpubReader({ bookId, onClose }) {
       try {
         setIsSaving(true);
         if (isOnline()) {
-          await api.put(`/library/${bookId}/progress`, progressData);
+          await api.put(`/library/${bookId}/position`, progressData);
         } else {
           queueProgressUpdate(bookId, progressData);
         }
       } catch (err) {
+        // 409: another device saved a newer position, which wins
+        if (err.response?.status === 409) return;
         console.error('Error saving progress:', err);
         queueProgressUpdate(bookId, progressData);
       } finally {
@@ -498,16 +524,7 @@ export default function EpubReader({ bookId, onClose }) {
   };
 
   const handleClose = async () => {
-    if (sessionId && sessionDurationRef.current > 0) {
-      try {
-        await api.post('/reading/sessions/end', {
-          session_id: sessionId,
-          duration: sessionDurationRef.current
-        });
-      } catch (err) {
-        console.error('Failed to end session:', err);
-      }
-    }
+    await endSession();
     onClose();
   };
 
@@ -21,7 +21,8 @@ const BookAddModal = ({ isOpen, onClose, categories, authors, onSuccess }) => {
     status: 'published',
     cover_image: '',
     book_file: '',
-    file_size: 0
+    file_size: 0,
+    metadata: null
   });
   const [errors, setErrors] = useState({});
   const [dragActive, setDragActive] = useState({ cover: false, ebook: false });
@@ -63,8 +64,10 @@ const BookAddModal = ({ isOpen, onClose, categories, authors, onSuccess }) => {
       console.log('Book file upload result:', result);
       setFormData(prev => ({ 
         ...prev, 
+        ...prefillFromMetadata(prev, result.metadata),
         book_file: result.path,
-        file_size: result.size
+        file_size: result.size,
+        metadata: result.metadata || null
       }));
     } catch (error) {
       console.error('Book file upload error:', error);
@@ -74,6 +77,24 @@ const BookAddModal = ({ isOpen, onClose, categories, authors, onSuccess }) => {
     }
   };
 
+  // Fill the fields the admin left blank from the uploaded file's metadata
+  const prefillFromMetadata = (prev, metadata) => {
+    if (!metadata) return {};
+    const fill = {};
+    if (!prev.title && metadata.title) fill.title = metadata.title;
+    if (!prev.isbn && metadata.isbn) fill.isbn = metadata.isbn;
+    if (!prev.description && metadata.description) fill.description = metadata.description;
+    if (!prev.pages && metadata.page_count) fill.pages = String(metadata.page_count);
+    if (!prev.publisher && metadata.publisher) fill.publisher = metadata.publisher;
+    if (!prev.cover_image && metadata.cover?.path) fill.cover_image = metadata.cover.path;
+    if (!prev.author_id && metadata.authors?.length) {
+      const names = metadata.authors.map(name => name.toLowerCase());
+      const match = authors.find(author => names.includes(author.business_name?.toLowerCase()));
+      if (match) fill.author_id = match.id.toString();
+    }
+    return fill;
+  };
+
   const handleDrag = (e, type) => {
     e.preventDefault();
     e.stopPropagation();
@@ -145,7 +166,8 @@ const BookAddModal = ({ isOpen, onClose, categories, authors, onSuccess }) => {
         status: formData.status || 'published',
         cover_image: formData.cover_image,
         book_file: formData.book_file,
-        file_size: formData.file_size
+        file_size: formData.file_size,
+        metadata: formData.metadata || undefined
       };
 
       const response = await api.post('/books', submitData);
@@ -202,7 +224,8 @@ const BookAddModal = ({ isOpen, onClose, categories, authors, onSuccess }) => {
       status: 'published',
       cover_image: '',
       book_file: '',
-      file_size: 0
+      file_size: 0,
+      metadata: null
     });
     setErrors({});
     setCurrentStep(1);
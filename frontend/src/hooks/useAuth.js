@@ -97,6 +97,20 @@ export const useAuth = () => {
     }
   };
 
+  const verifyEmail = async (token) => {
+    try {
+      setLoading(true);
+      setError('');
+      await api.post('/auth/verify-email', { token });
+      return true;
+    } catch (err) {
+      setError(err.response?.data?.error || 'Failed to verify email.');
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  };
+
   const logout = () => {
     localStorage.removeItem('token');
     localStorage.removeItem('user');
@@ -123,6 +137,7 @@ export const useAuth = () => {
     signup,
     forgotPassword,
     resetPassword,
+    verifyEmail,
     logout,
     isAuthenticated,
     getUser,
@@ -64,6 +64,29 @@ api.interceptors.request.use(
   }
 );
 
+// One refresh at a time: requests that fail together wait on the same
+// refresh instead of each spending the refresh token
+let refreshPromise = null;
+
+const refreshTokens = (refreshToken) => {
+  if (!refreshPromise) {
+    refreshPromise = axios.post(`${API_BASE_URL}/api/v1/auth/refresh`, {
+      refresh_token: refreshToken
+    }).then((response) => {
+      if (response.data.access_token) {
+        localStorage.setItem('token', response.data.access_token);
+        if (response.data.refresh_token) {
+          localStorage.setItem('refresh_token', response.data.refresh_token);
+        }
+      }
+      return response;
+    }).finally(() => {
+      refreshPromise = null;
+    });
+  }
+  return refreshPromise;
+};
+
 // Response interceptor
 api.interceptors.response.use(
   (response) => response,
@@ -83,17 +106,9 @@ api.interceptors.response.use(
         originalRequest._retry = true;
         
         try {
-          const response = await axios.post(`${API_BASE_URL}/api/v1/auth/refresh`, {
-            refresh_token: refreshToken
-          });
+          const response = await refreshTokens(refreshToken);
           
           if (response.data.access_token) {
-            // Update tokens
-            localStorage.setItem('token', response.data.access_token);
-            if (response.data.refresh_token) {
-              localStorage.setItem('refresh_token', response.data.refresh_token);
-            }
-            
             // Retry original request with new token
             originalRequest.headers.Authorization = `Bearer ${response.data.access_token}`;
             return api(originalRequest);
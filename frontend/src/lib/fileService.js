@@ -1,3 +1,5 @@
+import api from "./api";
+
 const UPLOAD_API_URL =
   import.meta.env.VITE_UPLOAD_API_URL || "https://upload-api.estateman.online";
 
@@ -25,8 +27,10 @@ export const uploadCover = async (file) => {
 
 /**
  * Upload book file (PDF, EPUB, HTML)
+ * EPUB and PDF uploads also return the title, authors, ISBN, page count,
+ * table of contents and extracted cover in `metadata`.
  * @param {File} file - Book file to upload
- * @returns {Promise<{filename: string, path: string, url: string, size: number}>}
+ * @returns {Promise<{filename: string, path: string, url: string, size: number, metadata?: object}>}
  */
 export const uploadBook = async (file) => {
   const formData = new FormData();
@@ -68,12 +72,15 @@ export const uploadProfile = async (file) => {
 };
 
 /**
- * Delete file from upload API
+ * Delete file from upload API, through a URL the backend signs for admins
  * @param {string} filename - Filename to delete
  * @returns {Promise<{message: string}>}
  */
 export const deleteFile = async (filename) => {
-  const response = await fetch(`${UPLOAD_API_URL}/api/files/${filename}`, {
+  const signed = await api.post(
+    `/admin/books/files/${encodeURIComponent(filename)}/delete-url`
+  );
+  const response = await fetch(signed.data.url, {
     method: "DELETE",
   });
 
@@ -118,10 +125,13 @@ export const getImageUrl = (filename, fallback = "/placeholder-book.png") => {
 };
 
 /**
- * Get book file URL from upload API
- * @param {string} filePath - File path from backend (e.g., 'books/abc-123.epub')
- * @returns {string} Full URL to access the book file
+ * Get a signed book file URL from the backend. The upload API only serves
+ * book files through these URLs, which expire after a few minutes, so fetch
+ * a new one every time the book is opened.
+ * @param {number|string} bookId - Book ID in the user's library
+ * @returns {Promise<string>} Signed URL to download the book file
  */
-export const getBookFileUrl = (filePath) => {
-  return getFileUrl(filePath);
+export const getBookFileUrl = async (bookId) => {
+  const response = await api.get(`/library/${bookId}/access`);
+  return response.data.url;
 };
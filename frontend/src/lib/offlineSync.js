@@ -4,6 +4,28 @@
  */
 
 const QUEUE_KEY = 'readagain_offline_queue';
+const DEVICE_KEY = 'readagain_device_id';
+
+/**
+ * Stable id for this browser, sent with reading positions
+ */
+export const getDeviceId = () => {
+  let deviceId = localStorage.getItem(DEVICE_KEY);
+  if (!deviceId) {
+    deviceId = `web-${Date.now().toString(36)}-${Math.random().toString(36).slice(2, 10)}`;
+    localStorage.setItem(DEVICE_KEY, deviceId);
+  }
+  return deviceId;
+};
+
+/**
+ * Build a reading position update stamped with the current device time
+ */
+export const buildPositionUpdate = (locator) => ({
+  locator,
+  device_id: getDeviceId(),
+  updated_at: new Date().toISOString()
+});
 
 /**
  * Add progress update to offline queue
@@ -61,11 +83,25 @@ export const syncQueuedUpdates = async (api) => {
       const item = queue[key];
       
       try {
-        await api.put(`/library/${item.bookId}/progress`, {
-          current_page: 0,
-          total_pages: 100,
-          progress: item.progress || 0
-        });
+        if (item.locator) {
+          // Positions keep their original timestamp; a 409 means another
+          // device has since saved a newer one, so there is nothing to retry
+          try {
+            await api.put(`/library/${item.bookId}/position`, {
+              locator: item.locator,
+              device_id: item.device_id,
+              updated_at: item.updated_at
+            });
+          } catch (err) {
+            if (err.response?.status !== 409) throw err;
+          }
+        } else {
+          await api.put(`/library/${item.bookId}/progress`, {
+            current_page: 0,
+            total_pages: 100,
+            progress: item.progress || 0
+          });
+        }
         
         // Remove from queue on success
         delete queue[key];
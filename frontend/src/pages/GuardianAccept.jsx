@@ -0,0 +1,164 @@
+import { useState, useEffect } from 'react';
+import { useSearchParams, Link } from 'react-router-dom';
+import { motion } from 'framer-motion';
+import api from '../lib/api';
+
+export default function GuardianAccept() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const signedIn = !!localStorage.getItem('token');
+
+  const [invitation, setInvitation] = useState(null);
+  const [loading, setLoading] = useState(!!token);
+  const [submitting, setSubmitting] = useState(false);
+  const [error, setError] = useState(token ? '' : 'The invitation link is missing or invalid.');
+  const [accepted, setAccepted] = useState(false);
+  const [form, setForm] = useState({ first_name: '', last_name: '', password: '' });
+
+  useEffect(() => {
+    if (!token) return;
+    api.get(`/auth/guardian-invitations/${encodeURIComponent(token)}`)
+      .then((response) => setInvitation(response.data.invitation))
+      .catch((err) => setError(err.response?.data?.error || 'Failed to load invitation.'))
+      .finally(() => setLoading(false));
+  }, [token]);
+
+  const accept = async (e) => {
+    e.preventDefault();
+    try {
+      setSubmitting(true);
+      setError('');
+      if (signedIn) {
+        await api.post(`/guardian/invitations/${encodeURIComponent(token)}/accept`);
+      } else {
+        await api.post(`/auth/guardian-invitations/${encodeURIComponent(token)}/accept`, form);
+      }
+      setAccepted(true);
+    } catch (err) {
+      setError(err.response?.data?.error || 'Failed to accept invitation.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const loginPath = `/login?redirect=${encodeURIComponent(`/guardian/accept?token=${token}`)}`;
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-primary-600 to-primary-700 flex items-center justify-center px-4">
+      <motion.div
+        initial={{ opacity: 0, y: 30 }}
+        animate={{ opacity: 1, y: 0 }}
+        className="max-w-md w-full bg-white rounded-2xl shadow-2xl p-8"
+      >
+        <div className="text-center mb-6">
+          <h1 className="text-4xl font-bold text-gray-900 mb-2">ReadAgain</h1>
+          <p className="text-gray-600">Guardian invitation</p>
+        </div>
+
+        {loading ? (
+          <div className="text-center py-6">
+            <div className="animate-spin inline-block w-8 h-8 border-4 border-primary-600 border-t-transparent rounded-full"></div>
+          </div>
+        ) : accepted ? (
+          <div className="text-center py-6">
+            <div className="w-16 h-16 bg-green-100 rounded-full flex items-center justify-center mx-auto mb-4">
+              <i className="ri-check-line text-3xl text-green-600"></i>
+            </div>
+            <h3 className="text-xl font-semibold text-gray-900 mb-2">Invitation Accepted</h3>
+            <p className="text-gray-600 mb-6">
+              You can now follow {invitation?.student_name || 'your child'}'s reading.
+            </p>
+            <Link
+              to={signedIn ? '/dashboard' : '/login'}
+              className="inline-block bg-gradient-to-r from-primary-600 to-primary-700 text-white px-6 py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700"
+            >
+              {signedIn ? 'Go to Dashboard' : 'Sign In'}
+            </Link>
+          </div>
+        ) : !invitation ? (
+          <div className="text-center">
+            <div className="w-16 h-16 bg-red-100 rounded-full flex items-center justify-center mx-auto mb-4">
+              <i className="ri-error-warning-line text-3xl text-red-600"></i>
+            </div>
+            <h2 className="text-2xl font-bold text-gray-900 mb-2">Invalid Invitation</h2>
+            <p className="text-gray-600 mb-6">{error}</p>
+            <Link to="/" className="text-primary-600 hover:text-purple-600 font-semibold">
+              Back to Home
+            </Link>
+          </div>
+        ) : (
+          <>
+            <p className="text-gray-700 mb-6 text-center">
+              {invitation.inviter_name} invited <strong>{invitation.email}</strong> to follow{' '}
+              <strong>{invitation.student_name}</strong>'s reading
+              {invitation.relationship ? ` as their ${invitation.relationship}` : ''}.
+            </p>
+
+            {error && (
+              <div className="mb-6 p-4 bg-red-100 text-red-700 rounded-lg">
+                <i className="ri-error-warning-line mr-2"></i>
+                {error}
+              </div>
+            )}
+
+            {signedIn ? (
+              <form onSubmit={accept}>
+                <button
+                  type="submit"
+                  disabled={submitting}
+                  className="w-full bg-gradient-to-r from-primary-600 to-primary-700 text-white py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700 transition-all disabled:opacity-50"
+                >
+                  {submitting ? 'Accepting...' : 'Accept Invitation'}
+                </button>
+              </form>
+            ) : invitation.has_account ? (
+              <Link
+                to={loginPath}
+                className="block text-center w-full bg-gradient-to-r from-primary-600 to-primary-700 text-white py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700"
+              >
+                Sign In to Accept
+              </Link>
+            ) : (
+              <form onSubmit={accept} className="space-y-4">
+                <div className="grid grid-cols-2 gap-4">
+                  <input
+                    type="text"
+                    value={form.first_name}
+                    onChange={(e) => setForm({ ...form, first_name: e.target.value })}
+                    required
+                    className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    placeholder="First name"
+                  />
+                  <input
+                    type="text"
+                    value={form.last_name}
+                    onChange={(e) => setForm({ ...form, last_name: e.target.value })}
+                    required
+                    className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    placeholder="Last name"
+                  />
+                </div>
+                <input
+                  type="password"
+                  value={form.password}
+                  onChange={(e) => setForm({ ...form, password: e.target.value })}
+                  required
+                  minLength={8}
+                  className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+                  placeholder="Choose a password"
+                />
+                <button
+                  type="submit"
+                  disabled={submitting}
+                  className="w-full bg-gradient-to-r from-primary-600 to-primary-700 text-white py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700 transition-all disabled:opacity-50"
+                >
+                  {submitting ? 'Creating Account...' : 'Create Account and Accept'}
+                </button>
+              </form>
+            )}
+          </>
+        )}
+      </motion.div>
+    </div>
+  );
+}
@@ -0,0 +1,78 @@
+import { useState, useEffect, useRef } from 'react';
+import { useSearchParams, Link } from 'react-router-dom';
+import { motion } from 'framer-motion';
+import { useAuth } from '../hooks/useAuth';
+
+export default function VerifyEmail() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+
+  const [status, setStatus] = useState(token ? 'verifying' : 'invalid');
+  const requested = useRef(false);
+
+  const { verifyEmail, error } = useAuth();
+
+  useEffect(() => {
+    // Tokens are single use, so don't send it twice
+    if (!token || requested.current) return;
+    requested.current = true;
+
+    verifyEmail(token).then((ok) => setStatus(ok ? 'verified' : 'failed'));
+  }, [token]);
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-primary-600 to-primary-700 flex items-center justify-center px-4">
+      <motion.div
+        initial={{ opacity: 0, y: 30 }}
+        animate={{ opacity: 1, y: 0 }}
+        className="max-w-md w-full bg-white rounded-2xl shadow-2xl p-8"
+      >
+        <div className="text-center">
+          {status === 'verifying' && (
+            <>
+              <div className="animate-spin inline-block w-10 h-10 border-4 border-primary-600 border-t-transparent rounded-full mb-4"></div>
+              <h2 className="text-2xl font-bold text-gray-900 mb-2">Verifying your email</h2>
+              <p className="text-gray-600">This only takes a moment.</p>
+            </>
+          )}
+
+          {status === 'verified' && (
+            <>
+              <div className="w-16 h-16 bg-green-100 rounded-full flex items-center justify-center mx-auto mb-4">
+                <i className="ri-check-line text-3xl text-green-600"></i>
+              </div>
+              <h2 className="text-2xl font-bold text-gray-900 mb-2">Email Verified</h2>
+              <p className="text-gray-600 mb-6">Your email address is confirmed. You can now sign in.</p>
+              <Link
+                to="/login"
+                className="inline-block bg-gradient-to-r from-primary-600 to-primary-700 text-white px-6 py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700"
+              >
+                Go to Login
+              </Link>
+            </>
+          )}
+
+          {(status === 'invalid' || status === 'failed') && (
+            <>
+              <div className="w-16 h-16 bg-red-100 rounded-full flex items-center justify-center mx-auto mb-4">
+                <i className="ri-error-warning-line text-3xl text-red-600"></i>
+              </div>
+              <h2 className="text-2xl font-bold text-gray-900 mb-2">Invalid Verification Link</h2>
+              <p className="text-gray-600 mb-6">
+                {status === 'failed' && error
+                  ? error
+                  : 'The verification link is missing or invalid.'}
+              </p>
+              <Link
+                to="/login"
+                className="inline-block bg-gradient-to-r from-primary-600 to-primary-700 text-white px-6 py-3 rounded-lg font-semibold hover:from-blue-700 hover:to-purple-700"
+              >
+                Back to Login
+              </Link>
+            </>
+          )}
+        </div>
+      </motion.div>
+    </div>
+  );
+}
@@ -5,6 +5,7 @@ import { useSettingsManagement } from '../../hooks/useSettingsManagement';
 import EmailGatewayManagement from './settings-components/EmailGatewayManagement';
 import ImageCacheManager from './settings-components/ImageCacheManager';
 import RedisManagement from './settings-components/RedisManagement';
+import WatermarkManagement from './settings-components/WatermarkManagement';
 import ImageOptimization from '../../components/admin/ImageOptimization';
 
 export default function SystemSettings() {
@@ -271,7 +272,8 @@ export default function SystemSettings() {
     { id: 'security', label: 'Security', icon: 'ri-shield-check-line' },
     { id: 'redis', label: 'Redis', icon: 'ri-database-line' },
     { id: 'images', label: 'Images', icon: 'ri-image-line' },
-    { id: 'email', label: 'Email Gateway', icon: 'ri-mail-line' }
+    { id: 'email', label: 'Email Gateway', icon: 'ri-mail-line' },
+    { id: 'watermarks', label: 'Watermarks', icon: 'ri-shield-user-line' }
   ];
 
   return (
@@ -311,6 +313,7 @@ export default function SystemSettings() {
             {activeTab === 'redis' && <RedisManagement />}
             {activeTab === 'images' && <ImageOptimization />}
             {activeTab === 'email' && <EmailGatewayManagement />}
+            {activeTab === 'watermarks' && <WatermarkManagement />}
           </div>
           
           <div className="mt-6 sm:mt-8 pt-4 sm:pt-6 border-t border-gray-200">
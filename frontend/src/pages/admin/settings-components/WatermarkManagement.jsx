@@ -0,0 +1,192 @@
+import { useState, useEffect } from 'react';
+import api from '../../../lib/api';
+
+const DEFAULT_TEMPLATE = 'Licensed to {name}, {school} on {date} · {code}';
+
+export default function WatermarkManagement() {
+  const [settings, setSettings] = useState({ visible: true, invisible: true, template: DEFAULT_TEMPLATE });
+  const [loading, setLoading] = useState(true);
+  const [saving, setSaving] = useState(false);
+  const [traceFile, setTraceFile] = useState(null);
+  const [traceCode, setTraceCode] = useState('');
+  const [tracing, setTracing] = useState(false);
+  const [trace, setTrace] = useState(null);
+
+  const fetchSettings = async () => {
+    try {
+      setLoading(true);
+      const response = await api.get('/admin/settings', { params: { category: 'watermark' } });
+      const values = Object.fromEntries((response.data.data || []).map((s) => [s.key, s.value]));
+      setSettings({
+        visible: values['watermark.visible'] !== 'false',
+        invisible: values['watermark.invisible'] !== 'false',
+        template: values['watermark.template'] || DEFAULT_TEMPLATE,
+      });
+    } catch (error) {
+      console.error('Error fetching watermark settings:', error);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const saveSettings = async () => {
+    const entries = [
+      ['watermark.visible', String(settings.visible), 'Print the watermark on every page of delivered PDFs'],
+      ['watermark.invisible', String(settings.invisible), 'Hide a traceable code in delivered PDFs'],
+      ['watermark.template', settings.template, 'Visible watermark: {name}, {email}, {school}, {date}, {code}'],
+    ];
+    try {
+      setSaving(true);
+      for (const [key, value, description] of entries) {
+        await api.post('/admin/settings', { key, value, category: 'watermark', description });
+      }
+      alert('Watermark settings saved');
+    } catch (error) {
+      console.error('Error saving watermark settings:', error);
+      alert(`Error saving watermark settings: ${error.response?.data?.error || error.message}`);
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  const traceLeak = async (e) => {
+    e.preventDefault();
+    const form = new FormData();
+    if (traceFile) form.append('file', traceFile);
+    if (traceCode) form.append('code', traceCode);
+
+    try {
+      setTracing(true);
+      const response = await api.post('/admin/watermarks/trace', form, {
+        headers: { 'Content-Type': 'multipart/form-data' },
+      });
+      setTrace(response.data.data);
+    } catch (error) {
+      console.error('Error tracing watermark:', error);
+      alert(`Error tracing watermark: ${error.response?.data?.error || error.message}`);
+    } finally {
+      setTracing(false);
+    }
+  };
+
+  useEffect(() => {
+    fetchSettings();
+  }, []);
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center p-4">
+        <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary-600"></div>
+      </div>
+    );
+  }
+
+  return (
+    <div className="space-y-6">
+      {/* Watermark settings */}
+      <div className="bg-gray-50 rounded-lg p-4 space-y-4">
+        <div>
+          <h4 className="font-medium text-gray-900 mb-1">PDF Watermarks</h4>
+          <p className="text-sm text-gray-600">
+            PDFs are stamped for each reader when they open them. A reader keeps the same copy until these settings change.
+          </p>
+        </div>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={settings.visible}
+            onChange={(e) => setSettings({ ...settings, visible: e.target.checked })}
+          />
+          Print the watermark at the foot of every page
+        </label>
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={settings.invisible}
+            onChange={(e) => setSettings({ ...settings, invisible: e.target.checked })}
+          />
+          Hide a traceable code in the file
+        </label>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-1">Template</label>
+          <input
+            type="text"
+            value={settings.template}
+            onChange={(e) => setSettings({ ...settings, template: e.target.value })}
+            disabled={!settings.visible}
+            className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 disabled:bg-gray-100"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Placeholders: {'{name}'}, {'{email}'}, {'{school}'}, {'{date}'}, {'{code}'}
+          </p>
+        </div>
+
+        <button
+          onClick={saveSettings}
+          disabled={saving}
+          className="px-4 py-2 bg-primary-600 text-white rounded-lg hover:bg-primary-700 disabled:opacity-50"
+        >
+          {saving ? 'Saving...' : 'Save Watermark Settings'}
+        </button>
+      </div>
+
+      {/* Leak tracing */}
+      <form onSubmit={traceLeak} className="bg-gray-50 rounded-lg p-4 space-y-4">
+        <div>
+          <h4 className="font-medium text-gray-900 mb-1">Trace a Leaked Copy</h4>
+          <p className="text-sm text-gray-600">
+            Upload the leaked PDF, or enter the code printed on its pages. Files too large to upload can be traced with
+            scripts/trace_watermark.go.
+          </p>
+        </div>
+
+        <input
+          type="file"
+          accept="application/pdf"
+          onChange={(e) => setTraceFile(e.target.files[0] || null)}
+          className="block text-sm text-gray-700"
+        />
+        <input
+          type="text"
+          value={traceCode}
+          onChange={(e) => setTraceCode(e.target.value)}
+          placeholder="Watermark code"
+          className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500"
+        />
+        <button
+          type="submit"
+          disabled={tracing || (!traceFile && !traceCode)}
+          className="px-4 py-2 bg-primary-600 text-white rounded-lg hover:bg-primary-700 disabled:opacity-50"
+        >
+          {tracing ? 'Tracing...' : 'Trace'}
+        </button>
+
+        {trace && (
+          <div className="space-y-2">
+            {trace.matches.length === 0 ? (
+              <p className="text-sm text-gray-600">
+                {trace.codes.length === 0 ? 'No watermark was found.' : `No reader matches ${trace.codes.join(', ')}.`}
+              </p>
+            ) : (
+              trace.matches.map((match) => (
+                <div key={match.id} className="bg-white rounded-lg border border-gray-200 p-3 text-sm">
+                  <div className="font-medium text-gray-900">
+                    {match.user?.first_name} {match.user?.last_name} ({match.user?.email})
+                  </div>
+                  <div className="text-gray-600">
+                    {match.user?.school?.name || match.user?.school_name} · {match.book?.title}
+                  </div>
+                  <div className="text-gray-500 text-xs">
+                    Code {match.code}, issued {new Date(match.created_at).toLocaleString()}
+                  </div>
+                </div>
+              ))
+            )}
+          </div>
+        )}
+      </form>
+    </div>
+  );
+}
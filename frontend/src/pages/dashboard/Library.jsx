@@ -200,8 +200,11 @@ export default function Library() {
                   </p>
                   
                   {/* Reading Stats */}
-                  <div className="flex items-center justify-between text-xs text-gray-500 mb-2">
+                  <div className="flex items-center justify-between gap-2 text-xs text-gray-500 mb-2">
                     <span>{Math.round(book.progress || 0)}%</span>
+                    {book.chapter?.title && (
+                      <span className="truncate" title={book.chapter.title}>{book.chapter.title}</span>
+                    )}
                   </div>
 
                   {/* Action Buttons */}
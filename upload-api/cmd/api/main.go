@@ -9,6 +9,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 )
 
@@ -22,6 +23,10 @@ func main() {
 	port := getEnv("PORT", "8001")
 	storagePath := getEnv("COOLIFY_STORAGE_PATH", "/app/storage")
 	corsOrigin := getEnv("CORS_ORIGIN", "*")
+	fileURLSecret := getEnv("FILE_URL_SECRET", "")
+	if fileURLSecret == "" {
+		log.Println("⚠️  FILE_URL_SECRET is not set: book files will not be served")
+	}
 
 	// Create storage directories
 	if err := os.MkdirAll(storagePath+"/covers", 0755); err != nil {
@@ -45,15 +50,17 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: corsOrigin,
-		AllowMethods: "GET,POST,DELETE",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowOrigins:  corsOrigin,
+		AllowMethods:  "GET,HEAD,POST,DELETE",
+		AllowHeaders:  "Origin, Content-Type, Accept, Range, If-Range, If-None-Match",
+		ExposeHeaders: "Accept-Ranges, Content-Length, Content-Range, ETag",
 	}))
 
 	// Setup routes
-	routes.SetupRoutes(app, storagePath)
+	routes.SetupRoutes(app, storagePath, fileURLSecret)
 
 	// Start server
 	log.Printf("🚀 Upload API starting on port %s", port)
@@ -0,0 +1,586 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxEntrySize caps how much of a single EPUB entry is read into memory.
+const maxEntrySize = 50 * 1024 * 1024
+
+type opfText struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:",chardata"`
+}
+
+type opfCreator struct {
+	ID    string `xml:"id,attr"`
+	Role  string `xml:"role,attr"`
+	Value string `xml:",chardata"`
+}
+
+type opfIdentifier struct {
+	ID     string `xml:"id,attr"`
+	Scheme string `xml:"scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type opfDate struct {
+	Event string `xml:"event,attr"`
+	Value string `xml:",chardata"`
+}
+
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []opfText       `xml:"title"`
+		Creators    []opfCreator    `xml:"creator"`
+		Publishers  []string        `xml:"publisher"`
+		Languages   []string        `xml:"language"`
+		Identifiers []opfIdentifier `xml:"identifier"`
+		Description []string        `xml:"description"`
+		Dates       []opfDate       `xml:"date"`
+		Subjects    []string        `xml:"subject"`
+		Metas       []opfMeta       `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []opfItem `xml:"manifest>item"`
+	Spine    struct {
+		TOC   string `xml:"toc,attr"`
+		Items []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+	Guide []struct {
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"guide>reference"`
+}
+
+type epubBook struct {
+	files    map[string]*zip.File
+	opfDir   string
+	pkg      opfPackage
+	items    map[string]opfItem // by id
+	spineIdx map[string]int     // by full zip path
+}
+
+// ParseEPUB reads the OPF metadata, the EPUB 3 nav or EPUB 2 NCX table of
+// contents and the cover image of an EPUB.
+func ParseEPUB(filePath string) (*Metadata, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open epub: %w", err)
+	}
+	defer r.Close()
+
+	book := &epubBook{files: map[string]*zip.File{}, items: map[string]opfItem{}, spineIdx: map[string]int{}}
+	for _, f := range r.File {
+		book.files[f.Name] = f
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := book.decode("META-INF/container.xml", &container); err != nil {
+		return nil, fmt.Errorf("read container.xml: %w", err)
+	}
+	opfPath := ""
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			opfPath = rf.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, fmt.Errorf("container.xml has no package document")
+	}
+	if err := book.decode(opfPath, &book.pkg); err != nil {
+		return nil, fmt.Errorf("read package document: %w", err)
+	}
+	book.opfDir = path.Dir(opfPath)
+
+	for _, item := range book.pkg.Manifest {
+		book.items[item.ID] = item
+	}
+	for i, ref := range book.pkg.Spine.Items {
+		if item, ok := book.items[ref.IDRef]; ok {
+			book.spineIdx[book.resolve(book.opfDir, item.Href)] = i
+		}
+	}
+
+	meta := newMetadata(FormatEPUB)
+	book.readMetadata(meta)
+
+	pages := book.readTOC(meta)
+	words := book.spineWords()
+	if pages > 0 {
+		meta.PageCount = pages
+	} else {
+		meta.PageCount = estimatePages(words)
+		meta.PageCountEstimated = true
+	}
+	setProgression(meta.TOC, words)
+
+	cover, err := book.cover()
+	switch {
+	case err != nil:
+		meta.warn("Cover image could not be read: " + err.Error())
+	case cover == nil:
+		meta.warn("No cover image found in the EPUB")
+	default:
+		meta.coverData, meta.coverSource = cover, CoverFromEPUB
+	}
+
+	return meta, nil
+}
+
+func (b *epubBook) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxEntrySize))
+}
+
+func (b *epubBook) decode(name string, v interface{}) error {
+	data, err := b.read(name)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	return dec.Decode(v)
+}
+
+// resolve turns an href found in the document at dir into a zip path.
+func (b *epubBook) resolve(dir, href string) string {
+	if u, err := url.PathUnescape(href); err == nil {
+		href = u
+	}
+	return strings.TrimPrefix(path.Join(dir, href), "/")
+}
+
+// relative turns a zip path back into an href relative to the package
+// document, the form readers use for spine items.
+func (b *epubBook) relative(full string) string {
+	if b.opfDir == "." || b.opfDir == "" {
+		return full
+	}
+	return strings.TrimPrefix(full, b.opfDir+"/")
+}
+
+func (b *epubBook) readMetadata(meta *Metadata) {
+	md := &b.pkg.Metadata
+
+	// EPUB 3 attaches roles and title types through refining meta elements
+	refines := map[string]map[string]string{}
+	for _, m := range md.Metas {
+		if m.Refines == "" || m.Property == "" {
+			continue
+		}
+		id := strings.TrimPrefix(m.Refines, "#")
+		if refines[id] == nil {
+			refines[id] = map[string]string{}
+		}
+		refines[id][m.Property] = strings.TrimSpace(m.Value)
+	}
+
+	for _, t := range md.Titles {
+		value := strings.TrimSpace(t.Value)
+		if value == "" {
+			continue
+		}
+		switch refines[t.ID]["title-type"] {
+		case "subtitle":
+			if meta.Subtitle == "" {
+				meta.Subtitle = value
+			}
+		case "main":
+			meta.Title = value
+		default:
+			if meta.Title == "" {
+				meta.Title = value
+			}
+		}
+	}
+
+	var others []string
+	for _, c := range md.Creators {
+		name := strings.TrimSpace(c.Value)
+		if name == "" {
+			continue
+		}
+		role := c.Role
+		if role == "" {
+			role = refines[c.ID]["role"]
+		}
+		if role == "" || role == "aut" {
+			meta.Authors = append(meta.Authors, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+	if len(meta.Authors) == 0 {
+		meta.Authors = append(meta.Authors, others...)
+	}
+
+	if len(md.Publishers) > 0 {
+		meta.Publisher = strings.TrimSpace(md.Publishers[0])
+	}
+	if len(md.Languages) > 0 {
+		meta.Language = strings.TrimSpace(md.Languages[0])
+	}
+	if len(md.Description) > 0 {
+		meta.Description = cleanText(md.Description[0])
+	}
+	for _, s := range md.Subjects {
+		if s = strings.TrimSpace(s); s != "" {
+			meta.Subjects = append(meta.Subjects, s)
+		}
+	}
+
+	for _, id := range md.Identifiers {
+		scheme := strings.ToLower(id.Scheme)
+		if scheme == "" {
+			scheme = strings.ToLower(refines[id.ID]["identifier-type"])
+		}
+		value := strings.TrimPrefix(strings.TrimSpace(id.Value), "urn:isbn:")
+		if isbn := normalizeISBN(value); isbn != "" && (scheme == "isbn" || scheme == "" || scheme == "15") {
+			meta.ISBN = isbn
+			break
+		}
+	}
+
+	for _, d := range md.Dates {
+		if t := parseDate(d.Value); t != nil && (meta.PublishedAt == nil || d.Event == "publication") {
+			meta.PublishedAt = t
+		}
+	}
+}
+
+// readTOC fills meta.TOC from the EPUB 3 nav document, falling back to the
+// EPUB 2 NCX, and returns the size of the page list if the book has one.
+func (b *epubBook) readTOC(meta *Metadata) int {
+	for _, item := range b.pkg.Manifest {
+		if hasProperty(item.Properties, "nav") {
+			navPath := b.resolve(b.opfDir, item.Href)
+			data, err := b.read(navPath)
+			if err != nil {
+				meta.warn("Navigation document could not be read: " + err.Error())
+				break
+			}
+			toc, pages := b.parseNav(data, path.Dir(navPath))
+			if len(toc) > 0 {
+				meta.TOC = toc
+				return pages
+			}
+		}
+	}
+
+	ncxItem, ok := b.items[b.pkg.Spine.TOC]
+	if !ok {
+		for _, item := range b.pkg.Manifest {
+			if item.MediaType == "application/x-dtbncx+xml" {
+				ncxItem, ok = item, true
+				break
+			}
+		}
+	}
+	if !ok {
+		meta.warn("The EPUB has no table of contents")
+		return 0
+	}
+
+	var ncx struct {
+		NavMap   []ncxNavPoint `xml:"navMap>navPoint"`
+		PageList []struct{}    `xml:"pageList>pageTarget"`
+	}
+	ncxPath := b.resolve(b.opfDir, ncxItem.Href)
+	if err := b.decode(ncxPath, &ncx); err != nil {
+		meta.warn("NCX table of contents could not be read: " + err.Error())
+		return 0
+	}
+	b.flattenNCX(ncx.NavMap, path.Dir(ncxPath), 1, &meta.TOC)
+	return len(ncx.PageList)
+}
+
+type ncxNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []ncxNavPoint `xml:"navPoint"`
+}
+
+func (b *epubBook) flattenNCX(points []ncxNavPoint, dir string, level int, toc *[]TOCEntry) {
+	for _, p := range points {
+		if title := cleanText(p.Label); title != "" {
+			*toc = append(*toc, b.entry(title, p.Content.Src, dir, level))
+		}
+		b.flattenNCX(p.Children, dir, level+1, toc)
+	}
+}
+
+func (b *epubBook) entry(title, href, dir string, level int) TOCEntry {
+	entry := TOCEntry{Title: title, Level: level}
+	if href == "" {
+		return entry
+	}
+	doc, fragment, _ := strings.Cut(href, "#")
+	full := b.resolve(dir, doc)
+	entry.Href = b.relative(full)
+	if fragment != "" {
+		entry.Href += "#" + fragment
+	}
+	if idx, ok := b.spineIdx[full]; ok {
+		entry.SpineIndex = &idx
+	}
+	return entry
+}
+
+// parseNav walks the toc and page-list nav elements of an EPUB 3 navigation
+// document. Nesting of ol elements gives the entry level.
+func (b *epubBook) parseNav(data []byte, dir string) ([]TOCEntry, int) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	toc := []TOCEntry{}
+	pages := 0
+	navType := ""
+	navDepth, olDepth := 0, 0
+	var label *strings.Builder
+	href := ""
+	labelDepth := 0
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "nav" && navType == "":
+				for _, a := range t.Attr {
+					if a.Name.Local == "type" {
+						navType = a.Value
+					}
+				}
+				navDepth = 1
+				continue
+			case navType == "":
+				continue
+			}
+			navDepth++
+			switch t.Name.Local {
+			case "ol":
+				olDepth++
+			case "a", "span":
+				if label == nil {
+					label, href, labelDepth = &strings.Builder{}, "", navDepth
+					for _, a := range t.Attr {
+						if a.Name.Local == "href" {
+							href = a.Value
+						}
+					}
+				}
+			}
+		case xml.CharData:
+			if label != nil {
+				label.Write(t)
+			}
+		case xml.EndElement:
+			if navType == "" {
+				continue
+			}
+			if label != nil && navDepth == labelDepth {
+				title := cleanText(label.String())
+				label = nil
+				switch {
+				case hasProperty(navType, "toc") && title != "":
+					toc = append(toc, b.entry(title, href, dir, max(olDepth, 1)))
+				case hasProperty(navType, "page-list"):
+					pages++
+				}
+			}
+			if t.Name.Local == "ol" {
+				olDepth--
+			}
+			navDepth--
+			if navDepth == 0 {
+				navType, olDepth = "", 0
+			}
+		}
+	}
+	return toc, pages
+}
+
+// spineWords counts the words of each spine document, in spine order.
+func (b *epubBook) spineWords() []int {
+	words := make([]int, len(b.pkg.Spine.Items))
+	for i, ref := range b.pkg.Spine.Items {
+		item, ok := b.items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		data, err := b.read(b.resolve(b.opfDir, item.Href))
+		if err != nil {
+			continue
+		}
+		words[i] = len(strings.Fields(cleanText(string(bodyOf(data)))))
+	}
+	return words
+}
+
+func estimatePages(words []int) int {
+	total := 0
+	for _, n := range words {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return max(1, (total+wordsPerPage-1)/wordsPerPage)
+}
+
+// setProgression places each TOC entry at the share of the book's words
+// before its spine document. Entries pointing into the middle of a document
+// get the document's start; entries outside the spine inherit the previous
+// entry's position.
+func setProgression(toc []TOCEntry, words []int) {
+	before := make([]int, len(words)+1)
+	for i, n := range words {
+		before[i+1] = before[i] + n
+	}
+	total := before[len(words)]
+	if total == 0 {
+		return
+	}
+	last := 0.0
+	for i := range toc {
+		if idx := toc[i].SpineIndex; idx != nil && *idx < len(words) {
+			last = float64(before[*idx]) / float64(total)
+		}
+		toc[i].Progression = last
+	}
+}
+
+// bodyOf drops the head of an XHTML document so titles and styles aren't
+// counted as words.
+func bodyOf(data []byte) []byte {
+	if i := bytes.Index(data, []byte("<body")); i >= 0 {
+		return data[i:]
+	}
+	return data
+}
+
+// cover finds the cover image the way reading systems do: the EPUB 3
+// cover-image property, the EPUB 2 cover meta, then the guide's cover page.
+func (b *epubBook) cover() ([]byte, error) {
+	for _, item := range b.pkg.Manifest {
+		if hasProperty(item.Properties, "cover-image") {
+			return b.read(b.resolve(b.opfDir, item.Href))
+		}
+	}
+
+	for _, m := range b.pkg.Metadata.Metas {
+		if m.Name != "cover" || m.Content == "" {
+			continue
+		}
+		if item, ok := b.items[m.Content]; ok && strings.HasPrefix(item.MediaType, "image/") {
+			return b.read(b.resolve(b.opfDir, item.Href))
+		}
+		// Some packages put the href in the meta rather than an id
+		if data, err := b.read(b.resolve(b.opfDir, m.Content)); err == nil {
+			return data, nil
+		}
+	}
+
+	for _, ref := range b.pkg.Guide {
+		if !strings.EqualFold(ref.Type, "cover") {
+			continue
+		}
+		doc, _, _ := strings.Cut(ref.Href, "#")
+		pagePath := b.resolve(b.opfDir, doc)
+		data, err := b.read(pagePath)
+		if err != nil {
+			return nil, err
+		}
+		if src := firstImage(data); src != "" {
+			return b.read(b.resolve(path.Dir(pagePath), src))
+		}
+	}
+
+	for _, item := range b.pkg.Manifest {
+		if strings.HasPrefix(item.MediaType, "image/") &&
+			(strings.Contains(strings.ToLower(item.ID), "cover") || strings.Contains(strings.ToLower(item.Href), "cover")) {
+			return b.read(b.resolve(b.opfDir, item.Href))
+		}
+	}
+
+	return nil, nil
+}
+
+// firstImage returns the source of the first img or SVG image in a page.
+func firstImage(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || (start.Name.Local != "img" && start.Name.Local != "image") {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Local == "src" || a.Name.Local == "href" {
+				return a.Value
+			}
+		}
+	}
+}
+
+func hasProperty(properties, property string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
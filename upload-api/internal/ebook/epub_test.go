@@ -0,0 +1,245 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// epubFixture zips files, keyed by path, into an EPUB.
+func epubFixture(files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range names {
+		w, _ := zw.Create(name)
+		w.Write([]byte(files[name]))
+	}
+	zw.Close()
+	return b.Bytes()
+}
+
+func chapterXHTML(title string, words int) string {
+	return "<html><head><title>" + title + " heading words</title></head><body><p>" +
+		strings.TrimSpace(strings.Repeat("word ", words)) + "</p></body></html>"
+}
+
+// sampleEPUB is an EPUB 3 book of 500 words, 300 in the first chapter.
+func sampleEPUB() map[string]string {
+	return map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": testContainer,
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title id="t1">A Test Book</dc:title>
+    <dc:title id="t2">Being a Subtitle</dc:title>
+    <meta refines="#t2" property="title-type">subtitle</meta>
+    <dc:creator id="c1">Ann Author</dc:creator>
+    <dc:creator id="c2">Ed Itor</dc:creator>
+    <meta refines="#c2" property="role">edt</meta>
+    <dc:identifier id="uid">urn:uuid:0a1b2c3d</dc:identifier>
+    <dc:identifier id="isbn">urn:isbn:978-0-306-40615-7</dc:identifier>
+    <dc:language>en</dc:language>
+    <dc:subject>Fiction</dc:subject>
+    <dc:description>&lt;p&gt;A  short &lt;b&gt;book&lt;/b&gt;.&lt;/p&gt;</dc:description>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="Text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="Text/ch%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="Images/front.jpg" media-type="image/jpeg" properties="cover-image"/>
+  </manifest>
+  <spine><itemref idref="ch1"/><itemref idref="ch2"/></spine>
+</package>`,
+		"OEBPS/nav.xhtml": `<html xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<nav epub:type="toc"><ol>
+  <li><a href="Text/ch1.xhtml">Chapter One</a>
+    <ol><li><a href="Text/ch1.xhtml#s1">A Section</a></li></ol></li>
+  <li><a href="Text/ch%202.xhtml">Chapter Two &amp; After</a></li>
+</ol></nav>
+<nav epub:type="landmarks"><ol><li><a href="Text/ch1.xhtml">Start</a></li></ol></nav>
+</body></html>`,
+		"OEBPS/Text/ch1.xhtml":   chapterXHTML("One", 300),
+		"OEBPS/Text/ch 2.xhtml":  chapterXHTML("Two", 200),
+		"OEBPS/Images/front.jpg": "front cover",
+	}
+}
+
+// ncxEPUB is an EPUB 2 book with an NCX table of contents and page list.
+func ncxEPUB() map[string]string {
+	return map[string]string{
+		"META-INF/container.xml": testContainer,
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:opf="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Old Book</dc:title>
+    <dc:creator opf:role="edt">Ed Itor</dc:creator>
+    <dc:identifier opf:scheme="ISBN">0-306-40615-2</dc:identifier>
+    <meta name="cover" content="cover-img"/>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="ch1" href="ch1.html" media-type="application/xhtml+xml"/>
+    <item id="cover-img" href="c.png" media-type="image/png"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="ch1"/></spine>
+</package>`,
+		"OEBPS/toc.ncx": `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/">
+  <navMap>
+    <navPoint><navLabel><text>Part I</text></navLabel><content src="ch1.html"/>
+      <navPoint><navLabel><text>Chapter 1</text></navLabel><content src="ch1.html#c1"/></navPoint>
+    </navPoint>
+    <navPoint><navLabel><text>Notes</text></navLabel><content src="notes.html"/></navPoint>
+  </navMap>
+  <pageList>
+    <pageTarget><content src="ch1.html#p1"/></pageTarget>
+    <pageTarget><content src="ch1.html#p2"/></pageTarget>
+    <pageTarget><content src="ch1.html#p3"/></pageTarget>
+  </pageList>
+</ncx>`,
+		"OEBPS/ch1.html": chapterXHTML("One", 40),
+		"OEBPS/c.png":    "png cover",
+	}
+}
+
+func spine(i int) *int { return &i }
+
+func TestParseEPUB(t *testing.T) {
+	withoutNav := sampleEPUB()
+	delete(withoutNav, "OEBPS/nav.xhtml")
+	withoutContainer := sampleEPUB()
+	delete(withoutContainer, "META-INF/container.xml")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		title     string
+		subtitle  string
+		authors   []string
+		isbn      string
+		pages     int
+		estimated bool
+		toc       []TOCEntry
+		cover     string
+		warnings  []string // substrings, one per expected warning
+		err       bool
+	}{
+		{
+			name:      "epub 3 nav",
+			data:      epubFixture(sampleEPUB()),
+			title:     "A Test Book",
+			subtitle:  "Being a Subtitle",
+			authors:   []string{"Ann Author"},
+			isbn:      "9780306406157",
+			pages:     2,
+			estimated: true,
+			toc: []TOCEntry{
+				{Title: "Chapter One", Level: 1, Href: "Text/ch1.xhtml", SpineIndex: spine(0), Progression: 0},
+				{Title: "A Section", Level: 2, Href: "Text/ch1.xhtml#s1", SpineIndex: spine(0), Progression: 0},
+				{Title: "Chapter Two & After", Level: 1, Href: "Text/ch 2.xhtml", SpineIndex: spine(1), Progression: 0.6},
+			},
+			cover: "front cover",
+		},
+		{
+			name:    "epub 2 ncx and page list",
+			data:    epubFixture(ncxEPUB()),
+			title:   "Old Book",
+			authors: []string{"Ed Itor"},
+			isbn:    "0306406152",
+			pages:   3,
+			toc: []TOCEntry{
+				{Title: "Part I", Level: 1, Href: "ch1.html", SpineIndex: spine(0), Progression: 0},
+				{Title: "Chapter 1", Level: 2, Href: "ch1.html#c1", SpineIndex: spine(0), Progression: 0},
+				{Title: "Notes", Level: 1, Href: "notes.html", Progression: 0},
+			},
+			cover: "png cover",
+		},
+		{
+			name:      "missing navigation document",
+			data:      epubFixture(withoutNav),
+			title:     "A Test Book",
+			subtitle:  "Being a Subtitle",
+			authors:   []string{"Ann Author"},
+			isbn:      "9780306406157",
+			pages:     2,
+			estimated: true,
+			toc:       []TOCEntry{},
+			cover:     "front cover",
+			warnings:  []string{"Navigation document could not be read", "no table of contents"},
+		},
+		{name: "missing container", data: epubFixture(withoutContainer), err: true},
+		{name: "not a zip", data: []byte("PK not really"), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParseEPUB(writeFixture(t, "book.epub", tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseEPUB() = %+v, want an error", meta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEPUB() error = %v", err)
+			}
+			if meta.Title != tt.title || meta.Subtitle != tt.subtitle || meta.ISBN != tt.isbn {
+				t.Errorf("ParseEPUB() title %q subtitle %q isbn %q, want %q %q %q", meta.Title, meta.Subtitle, meta.ISBN, tt.title, tt.subtitle, tt.isbn)
+			}
+			if meta.PageCount != tt.pages || meta.PageCountEstimated != tt.estimated {
+				t.Errorf("ParseEPUB() pages %d estimated %v, want %d %v", meta.PageCount, meta.PageCountEstimated, tt.pages, tt.estimated)
+			}
+			if !reflect.DeepEqual(meta.Authors, tt.authors) {
+				t.Errorf("ParseEPUB() authors = %q, want %q", meta.Authors, tt.authors)
+			}
+			if !reflect.DeepEqual(meta.TOC, tt.toc) {
+				t.Errorf("ParseEPUB() toc = %+v, want %+v", meta.TOC, tt.toc)
+			}
+			if data, source := meta.CoverData(); string(data) != tt.cover || source != CoverFromEPUB {
+				t.Errorf("ParseEPUB() cover = %q from %q, want %q from %q", data, source, tt.cover, CoverFromEPUB)
+			}
+			for _, want := range tt.warnings {
+				found := false
+				for _, w := range meta.Warnings {
+					found = found || strings.Contains(w, want)
+				}
+				if !found {
+					t.Errorf("ParseEPUB() warnings = %q, want one containing %q", meta.Warnings, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEPUBMetadata(t *testing.T) {
+	meta, err := ParseEPUB(writeFixture(t, "book.epub", epubFixture(sampleEPUB())))
+	if err != nil {
+		t.Fatalf("ParseEPUB() error = %v", err)
+	}
+	if meta.Language != "en" || meta.Description != "A short book ." || !reflect.DeepEqual(meta.Subjects, []string{"Fiction"}) {
+		t.Errorf("ParseEPUB() language %q description %q subjects %q", meta.Language, meta.Description, meta.Subjects)
+	}
+}
+
+func FuzzParseEPUB(f *testing.F) {
+	f.Add(epubFixture(sampleEPUB()))
+	f.Add(epubFixture(ncxEPUB()))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseEPUB(writeFixture(t, "book.epub", data))
+	})
+}
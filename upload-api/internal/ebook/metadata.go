@@ -0,0 +1,188 @@
+package ebook
+
+import (
+	"errors"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FormatEPUB = "epub"
+	FormatPDF  = "pdf"
+)
+
+// wordsPerPage turns an EPUB's word count into a print-like page count
+// when the book has no page list of its own.
+const wordsPerPage = 250
+
+var ErrUnsupportedFormat = errors.New("unsupported book format")
+
+// Metadata is everything that could be read from an uploaded book file. The
+// backend uses it to prefill or create the Book record and its chapters.
+type Metadata struct {
+	Format             string     `json:"format"`
+	Title              string     `json:"title,omitempty"`
+	Subtitle           string     `json:"subtitle,omitempty"`
+	Authors            []string   `json:"authors"`
+	Publisher          string     `json:"publisher,omitempty"`
+	Language           string     `json:"language,omitempty"` // as found in the file, usually a BCP 47 tag
+	ISBN               string     `json:"isbn,omitempty"`     // digits only, checksum verified
+	Description        string     `json:"description,omitempty"`
+	Subjects           []string   `json:"subjects"`
+	PublishedAt        *time.Time `json:"published_at,omitempty"`
+	PageCount          int        `json:"page_count"`
+	PageCountEstimated bool       `json:"page_count_estimated"`
+	TOC                []TOCEntry `json:"toc"`
+	Cover              *Cover     `json:"cover,omitempty"`
+	Warnings           []string   `json:"warnings"`
+
+	coverData   []byte
+	coverSource string
+}
+
+// TOCEntry is one table of contents entry in reading order. Level starts at
+// 1; EPUB entries point at a content document (Href, relative to the package
+// document like spine hrefs), PDF entries at a 1-based page. Progression is
+// the fraction of the book before the entry, by words for EPUB and pages for
+// PDF, so chapters line up with the readers' total progression.
+type TOCEntry struct {
+	Title       string  `json:"title"`
+	Level       int     `json:"level"`
+	Href        string  `json:"href,omitempty"`
+	SpineIndex  *int    `json:"spine_index,omitempty"`
+	Page        int     `json:"page,omitempty"`
+	Progression float64 `json:"progression"`
+}
+
+// Cover is the cover image saved to the covers directory by the handler.
+type Cover struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+	Source   string `json:"source"` // epub, pdf-render, pdf-image
+}
+
+const (
+	CoverFromEPUB      = "epub"
+	CoverFromPDFRender = "pdf-render"
+	CoverFromPDFImage  = "pdf-image"
+)
+
+// CoverData returns the raw cover image found in the file, if any, and
+// where it came from.
+func (m *Metadata) CoverData() ([]byte, string) {
+	return m.coverData, m.coverSource
+}
+
+func (m *Metadata) warn(msg string) {
+	m.Warnings = append(m.Warnings, msg)
+}
+
+// Parse reads the metadata of the book at path. The format is taken from
+// the file extension.
+func Parse(path string) (*Metadata, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".epub":
+		return ParseEPUB(path)
+	case ".pdf":
+		return ParsePDF(path)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func newMetadata(format string) *Metadata {
+	return &Metadata{
+		Format:   format,
+		Authors:  []string{},
+		Subjects: []string{},
+		TOC:      []TOCEntry{},
+		Warnings: []string{},
+	}
+}
+
+var (
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// cleanText strips markup and collapses whitespace, for descriptions that
+// publishers often ship as HTML.
+func cleanText(s string) string {
+	s = tagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// normalizeISBN returns the ISBN-10 or ISBN-13 in s without separators, or
+// "" when s isn't one or its check digit is wrong.
+func normalizeISBN(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(strings.ToLower(s), "isbn"); i >= 0 {
+		s = s[i+4:]
+	}
+	s = strings.TrimLeft(s, ": ")
+
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == 'X' || c == 'x':
+			digits = append(digits, 'X')
+		case c == '-' || c == ' ':
+		default:
+			return ""
+		}
+	}
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, c := range digits {
+			v := int(c - '0')
+			if c == 'X' {
+				if i != 9 {
+					return ""
+				}
+				v = 10
+			}
+			sum += v * (10 - i)
+		}
+		if sum%11 == 0 {
+			return string(digits)
+		}
+	case 13:
+		sum := 0
+		for i, c := range digits {
+			if c == 'X' {
+				return ""
+			}
+			v := int(c - '0')
+			if i%2 == 1 {
+				v *= 3
+			}
+			sum += v
+		}
+		if sum%10 == 0 {
+			return string(digits)
+		}
+	}
+	return ""
+}
+
+// parseDate accepts the date shapes found in OPF files: a year, a month, a
+// day or a full timestamp.
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,731 @@
+package ebook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxObjectWindow caps how far an object is read while looking for its
+	// end; dictionaries are small, stream data is read separately.
+	maxObjectWindow = 4 * 1024 * 1024
+	// maxScanSize is the largest file the damaged-xref fallback will load.
+	maxScanSize = 200 * 1024 * 1024
+	// maxOutlineEntries guards against cyclic or absurdly large outlines.
+	maxOutlineEntries = 5000
+	// minCoverWidth is the smallest embedded image taken as a cover.
+	minCoverWidth = 300
+	// maxXrefFieldWidth is the widest xref stream field that fits an int64.
+	maxXrefFieldWidth = 8
+	// maxStreamObjects caps the objects an object stream may claim to hold.
+	maxStreamObjects = 100000
+
+	coverRenderTimeout = 30 * time.Second
+)
+
+type xrefEntry struct {
+	Offset int64 // byte offset, or the object stream number when Stream
+	Index  int   // index inside the object stream
+	Stream bool
+}
+
+type pdfFile struct {
+	f       *os.File
+	size    int64
+	xref    map[int]xrefEntry
+	trailer pdfDict
+	cache   map[int]interface{}
+	objStms map[int]*objectStream
+}
+
+type objectStream struct {
+	data    []byte
+	offsets []int // by index
+}
+
+// ParsePDF reads the info dictionary, page count and outline of a PDF and
+// takes its first page as the cover: rendered with pdftoppm when that is
+// installed, otherwise the largest JPEG drawn on it.
+func ParsePDF(filePath string) (*Metadata, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := &pdfFile{f: f, size: stat.Size(), xref: map[int]xrefEntry{}, cache: map[int]interface{}{}, objStms: map[int]*objectStream{}}
+	if err := pdf.loadXref(); err != nil {
+		pdf.xref = map[int]xrefEntry{}
+		if err := pdf.reconstructXref(); err != nil {
+			return nil, fmt.Errorf("read pdf cross-reference data: %w", err)
+		}
+	}
+
+	meta := newMetadata(FormatPDF)
+	encrypted := pdf.trailer["Encrypt"] != nil
+	if encrypted {
+		meta.warn("The PDF is encrypted; only the page count could be read")
+	} else {
+		pdf.readInfo(meta)
+	}
+
+	catalog, _ := pdf.resolve(pdf.trailer["Root"]).(pdfDict)
+	if catalog == nil {
+		return nil, fmt.Errorf("pdf has no document catalog")
+	}
+	if lang, ok := pdf.resolve(catalog["Lang"]).(pdfString); ok && !encrypted {
+		meta.Language = strings.TrimSpace(pdfText(lang))
+	}
+
+	pages := pdf.pages(catalog)
+	meta.PageCount = len(pages)
+	if meta.PageCount == 0 {
+		if root, ok := pdf.resolve(catalog["Pages"]).(pdfDict); ok {
+			meta.PageCount = pdfInt(pdf.resolve(root["Count"]), 0)
+		}
+	}
+
+	if !encrypted {
+		pdf.readOutline(catalog, pages, meta)
+		if len(meta.TOC) == 0 {
+			meta.warn("The PDF has no outline to build a table of contents from")
+		}
+		last := 0.0
+		for i := range meta.TOC {
+			if page := meta.TOC[i].Page; page > 0 && meta.PageCount > 0 {
+				last = float64(page-1) / float64(meta.PageCount)
+			}
+			meta.TOC[i].Progression = last
+		}
+	}
+
+	if cover, err := renderPDFCover(filePath); err == nil {
+		meta.coverData, meta.coverSource = cover, CoverFromPDFRender
+	} else if image := pdf.firstPageImage(pages); image != nil {
+		meta.coverData, meta.coverSource = image, CoverFromPDFImage
+	} else {
+		meta.warn("No cover could be taken from the first page: " + err.Error())
+	}
+
+	return meta, nil
+}
+
+func (p *pdfFile) readAt(offset int64, n int) ([]byte, error) {
+	if offset < 0 || offset >= p.size {
+		return nil, fmt.Errorf("offset %d outside file", offset)
+	}
+	n = int(min(int64(n), p.size-offset))
+	buf := make([]byte, n)
+	if _, err := p.f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// parseAt parses what starts at offset with fn, growing the window while
+// the object runs past its end.
+func (p *pdfFile) parseAt(offset int64, fn func(l *pdfLexer) (interface{}, error)) (interface{}, *pdfLexer, error) {
+	for window := 16 * 1024; ; window *= 4 {
+		buf, err := p.readAt(offset, window)
+		if err != nil {
+			return nil, nil, err
+		}
+		l := &pdfLexer{buf: buf}
+		v, err := fn(l)
+		if errors.Is(err, errPDFTruncated) && len(buf) == window && window < maxObjectWindow {
+			continue
+		}
+		return v, l, err
+	}
+}
+
+var startxrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+
+func (p *pdfFile) loadXref() error {
+	tail, err := p.readAt(max(0, p.size-2048), 2048)
+	if err != nil {
+		return err
+	}
+	matches := startxrefPattern.FindAllSubmatch(tail, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("startxref not found")
+	}
+	offset, _ := strconv.ParseInt(string(matches[len(matches)-1][1]), 10, 64)
+
+	seen := map[int64]bool{}
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := p.loadXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if p.trailer == nil {
+			p.trailer = trailer
+		}
+		// Hybrid files keep their compressed objects in a second xref stream
+		if stm, ok := trailer["XRefStm"]; ok && !seen[int64(pdfInt(stm, 0))] {
+			seen[int64(pdfInt(stm, 0))] = true
+			if _, err := p.loadXrefSection(int64(pdfInt(stm, 0))); err != nil {
+				return err
+			}
+		}
+		offset = int64(pdfInt(trailer["Prev"], 0))
+	}
+	if p.trailer == nil || p.trailer["Root"] == nil {
+		return fmt.Errorf("trailer has no Root")
+	}
+	return nil
+}
+
+// loadXrefSection reads a classic xref table or an xref stream. Entries
+// already known come from a newer section and are kept.
+func (p *pdfFile) loadXrefSection(offset int64) (pdfDict, error) {
+	head, err := p.readAt(offset, 4)
+	if err != nil {
+		return nil, err
+	}
+	if string(head) == "xref" {
+		v, _, err := p.parseAt(offset+4, func(l *pdfLexer) (interface{}, error) {
+			for {
+				l.skipSpace()
+				if bytes.HasPrefix(l.buf[l.pos:], []byte("trailer")) {
+					l.pos += len("trailer")
+					return l.object()
+				}
+				first, err1 := l.object()
+				count, err2 := l.object()
+				if err1 != nil || err2 != nil {
+					return nil, errPDFTruncated
+				}
+				start, n := pdfInt(first, -1), pdfInt(count, -1)
+				if start < 0 || n < 0 {
+					return nil, fmt.Errorf("bad xref subsection")
+				}
+				for i := 0; i < n; i++ {
+					off, err1 := l.object()
+					_, err2 := l.object()
+					l.skipSpace()
+					kind := l.keyword()
+					if err1 != nil || err2 != nil || kind == "" {
+						return nil, errPDFTruncated
+					}
+					if _, known := p.xref[start+i]; !known && kind == "n" {
+						p.xref[start+i] = xrefEntry{Offset: int64(pdfInt(off, 0))}
+					} else if !known {
+						p.xref[start+i] = xrefEntry{Offset: -1}
+					}
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		trailer, ok := v.(pdfDict)
+		if !ok {
+			return nil, fmt.Errorf("bad trailer")
+		}
+		return trailer, nil
+	}
+
+	obj, err := p.objectAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*pdfStream)
+	if !ok || stream.Dict["Type"] != pdfName("XRef") {
+		return nil, fmt.Errorf("no xref at %d", offset)
+	}
+	data, err := p.streamData(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	widths, _ := stream.Dict["W"].(pdfArray)
+	if len(widths) != 3 {
+		return nil, fmt.Errorf("bad xref stream widths")
+	}
+	w := [3]int{pdfInt(widths[0], -1), pdfInt(widths[1], -1), pdfInt(widths[2], -1)}
+	for _, width := range w {
+		if width < 0 || width > maxXrefFieldWidth {
+			return nil, fmt.Errorf("bad xref stream widths")
+		}
+	}
+	index, _ := stream.Dict["Index"].(pdfArray)
+	if len(index) == 0 {
+		index = pdfArray{int64(0), stream.Dict["Size"]}
+	}
+
+	field := func(row []byte, i int, fallback int64) int64 {
+		start := 0
+		for j := 0; j < i; j++ {
+			start += w[j]
+		}
+		if w[i] == 0 {
+			return fallback
+		}
+		var v int64
+		for _, b := range row[start : start+w[i]] {
+			v = v<<8 | int64(b)
+		}
+		return v
+	}
+
+	rowLen := w[0] + w[1] + w[2]
+	if rowLen == 0 {
+		return nil, fmt.Errorf("bad xref stream widths")
+	}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, n := pdfInt(index[i], 0), pdfInt(index[i+1], 0)
+		for j := 0; j < n && pos+rowLen <= len(data); j++ {
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			if _, known := p.xref[start+j]; known {
+				continue
+			}
+			switch field(row, 0, 1) {
+			case 1:
+				p.xref[start+j] = xrefEntry{Offset: field(row, 1, 0)}
+			case 2:
+				p.xref[start+j] = xrefEntry{Offset: field(row, 1, 0), Index: int(field(row, 2, 0)), Stream: true}
+			default:
+				p.xref[start+j] = xrefEntry{Offset: -1}
+			}
+		}
+	}
+	return stream.Dict, nil
+}
+
+var objPattern = regexp.MustCompile(`(?m)(?:^|[\s>])(\d+)\s+(\d+)\s+obj\b`)
+
+// reconstructXref indexes every "n g obj" in the file for PDFs whose cross
+// reference data is missing or broken, the way viewers repair them.
+func (p *pdfFile) reconstructXref() error {
+	if p.size > maxScanSize {
+		return fmt.Errorf("file too large to repair")
+	}
+	data, err := p.readAt(0, int(p.size))
+	if err != nil {
+		return err
+	}
+	for _, m := range objPattern.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		p.xref[num] = xrefEntry{Offset: int64(m[2])}
+	}
+
+	p.trailer = pdfDict{}
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		l := &pdfLexer{buf: data[i+len("trailer"):]}
+		if d, err := l.object(); err == nil {
+			if dict, ok := d.(pdfDict); ok {
+				p.trailer = dict
+			}
+		}
+	}
+	if p.trailer["Root"] == nil {
+		for num := range p.xref {
+			if d, ok := p.resolve(pdfRef{Num: num}).(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+				p.trailer["Root"] = pdfRef{Num: num}
+				break
+			}
+		}
+	}
+	if p.trailer["Root"] == nil {
+		return fmt.Errorf("no document catalog found")
+	}
+	return nil
+}
+
+// objectAt parses "n g obj ... endobj" at offset.
+func (p *pdfFile) objectAt(offset int64) (interface{}, error) {
+	v, l, err := p.parseAt(offset, func(l *pdfLexer) (interface{}, error) {
+		for i := 0; i < 2; i++ {
+			if _, err := l.object(); err != nil {
+				return nil, err
+			}
+		}
+		l.skipSpace()
+		if l.keyword() != "obj" {
+			return nil, fmt.Errorf("no object at %d", offset)
+		}
+		return l.object()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := v.(pdfDict)
+	if !ok {
+		return v, nil
+	}
+	l.skipSpace()
+	if !bytes.HasPrefix(l.buf[l.pos:], []byte("stream")) {
+		return dict, nil
+	}
+	l.pos += len("stream")
+	if l.pos < len(l.buf) && l.buf[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+		l.pos++
+	}
+	return &pdfStream{Dict: dict, Offset: offset + int64(l.pos)}, nil
+}
+
+func (p *pdfFile) streamData(s *pdfStream) ([]byte, error) {
+	data, err := p.rawStreamData(s)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStream(s.Dict, data)
+}
+
+// rawStreamData reads a stream's data as stored, still encoded.
+func (p *pdfFile) rawStreamData(s *pdfStream) ([]byte, error) {
+	length := pdfInt(p.resolve(s.Dict["Length"]), -1)
+	if length < 0 {
+		return nil, fmt.Errorf("stream without length")
+	}
+	return p.readAt(s.Offset, length)
+}
+
+func (p *pdfFile) object(num int) interface{} {
+	if v, ok := p.cache[num]; ok {
+		return v
+	}
+	// Guard against reference cycles while this object is being read
+	p.cache[num] = nil
+
+	var v interface{}
+	entry, ok := p.xref[num]
+	switch {
+	case !ok || entry.Offset < 0:
+	case entry.Stream:
+		v = p.compressedObject(int(entry.Offset), entry.Index)
+	default:
+		v, _ = p.objectAt(entry.Offset)
+	}
+	p.cache[num] = v
+	return v
+}
+
+func (p *pdfFile) compressedObject(stmNum, index int) interface{} {
+	stm, ok := p.objStms[stmNum]
+	if !ok {
+		stm = p.loadObjectStream(stmNum)
+		p.objStms[stmNum] = stm
+	}
+	if stm == nil || index < 0 || index >= len(stm.offsets) {
+		return nil
+	}
+	if offset := stm.offsets[index]; offset < 0 || offset >= len(stm.data) {
+		return nil
+	}
+	l := &pdfLexer{buf: stm.data[stm.offsets[index]:]}
+	v, _ := l.object()
+	return v
+}
+
+func (p *pdfFile) loadObjectStream(num int) *objectStream {
+	stream, ok := p.object(num).(*pdfStream)
+	if !ok {
+		return nil
+	}
+	data, err := p.streamData(stream)
+	if err != nil {
+		return nil
+	}
+	n, first := pdfInt(stream.Dict["N"], 0), pdfInt(stream.Dict["First"], 0)
+	if n < 0 || first < 0 || first > len(data) {
+		return nil
+	}
+	// Every entry takes at least two numbers and a space each
+	n = min(n, maxStreamObjects, len(data)/4+1)
+	l := &pdfLexer{buf: data}
+	stm := &objectStream{data: data, offsets: make([]int, 0, n)}
+	for i := 0; i < n; i++ {
+		_, err1 := l.object()
+		off, err2 := l.object()
+		if err1 != nil || err2 != nil {
+			break
+		}
+		stm.offsets = append(stm.offsets, first+pdfInt(off, 0))
+	}
+	return stm
+}
+
+// resolve follows indirect references.
+func (p *pdfFile) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = p.object(ref.Num)
+	}
+	return nil
+}
+
+func (p *pdfFile) text(d pdfDict, key pdfName) string {
+	s, ok := p.resolve(d[key]).(pdfString)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(pdfText(s))
+}
+
+var authorSeparators = regexp.MustCompile(`\s*(?:;|&|\band\b)\s*`)
+
+func (p *pdfFile) readInfo(meta *Metadata) {
+	info, ok := p.resolve(p.trailer["Info"]).(pdfDict)
+	if !ok {
+		meta.warn("The PDF has no document information dictionary")
+		return
+	}
+
+	meta.Title = p.text(info, "Title")
+	meta.Description = p.text(info, "Subject")
+	for _, author := range authorSeparators.Split(p.text(info, "Author"), -1) {
+		if author != "" {
+			meta.Authors = append(meta.Authors, author)
+		}
+	}
+	for _, keyword := range strings.FieldsFunc(p.text(info, "Keywords"), func(r rune) bool { return r == ',' || r == ';' }) {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			meta.Subjects = append(meta.Subjects, keyword)
+		}
+	}
+
+	for _, key := range []pdfName{"ISBN", "Subject", "Keywords", "Title"} {
+		if isbn := findISBN(p.text(info, key)); isbn != "" {
+			meta.ISBN = isbn
+			break
+		}
+	}
+}
+
+var isbnPattern = regexp.MustCompile(`(?i)(?:ISBN(?:-1[03])?:?\s*)?((?:97[89][-\s]?)?\d[-\s]?(?:\d[-\s]?){8}[\dX])\b`)
+
+func findISBN(s string) string {
+	for _, m := range isbnPattern.FindAllStringSubmatch(s, -1) {
+		if isbn := normalizeISBN(m[1]); isbn != "" {
+			return isbn
+		}
+	}
+	return ""
+}
+
+// pages returns the page objects in order.
+func (p *pdfFile) pages(catalog pdfDict) []pdfRef {
+	var pages []pdfRef
+	seen := map[int]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		ref, isRef := v.(pdfRef)
+		if isRef {
+			if seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+		}
+		node, ok := p.resolve(v).(pdfDict)
+		if !ok {
+			return
+		}
+		if kids, ok := p.resolve(node["Kids"]).(pdfArray); ok && node["Type"] != pdfName("Page") {
+			for _, kid := range kids {
+				walk(kid)
+			}
+			return
+		}
+		if isRef {
+			pages = append(pages, ref)
+		}
+	}
+	walk(catalog["Pages"])
+	return pages
+}
+
+// readOutline flattens the document outline (bookmarks) into the TOC.
+func (p *pdfFile) readOutline(catalog pdfDict, pages []pdfRef, meta *Metadata) {
+	outlines, ok := p.resolve(catalog["Outlines"]).(pdfDict)
+	if !ok {
+		return
+	}
+
+	pageNumbers := make(map[int]int, len(pages))
+	for i, ref := range pages {
+		pageNumbers[ref.Num] = i + 1
+	}
+
+	seen := map[int]bool{}
+	var walk func(first interface{}, level int)
+	walk = func(first interface{}, level int) {
+		for item := first; item != nil && len(meta.TOC) < maxOutlineEntries; {
+			ref, ok := item.(pdfRef)
+			if !ok || seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+			node, ok := p.resolve(ref).(pdfDict)
+			if !ok {
+				return
+			}
+			if title := cleanText(p.text(node, "Title")); title != "" {
+				meta.TOC = append(meta.TOC, TOCEntry{Title: title, Level: level, Page: p.destinationPage(catalog, node, pageNumbers)})
+			}
+			walk(node["First"], level+1)
+			item = node["Next"]
+		}
+	}
+	walk(outlines["First"], 1)
+}
+
+// destinationPage returns the 1-based page an outline item points at, or 0.
+func (p *pdfFile) destinationPage(catalog, node pdfDict, pageNumbers map[int]int) int {
+	dest := p.resolve(node["Dest"])
+	if dest == nil {
+		if action, ok := p.resolve(node["A"]).(pdfDict); ok && action["S"] == pdfName("GoTo") {
+			dest = p.resolve(action["D"])
+		}
+	}
+
+	// Named destinations live in the catalog's Dests dictionary or name tree
+	switch name := dest.(type) {
+	case pdfName:
+		if dests, ok := p.resolve(catalog["Dests"]).(pdfDict); ok {
+			dest = p.resolve(dests[name])
+		}
+	case pdfString:
+		if names, ok := p.resolve(catalog["Names"]).(pdfDict); ok {
+			dest = p.lookupName(names["Dests"], string(name), 0)
+		}
+	}
+	if d, ok := dest.(pdfDict); ok {
+		dest = p.resolve(d["D"])
+	}
+
+	arr, ok := dest.(pdfArray)
+	if !ok || len(arr) == 0 {
+		return 0
+	}
+	switch target := arr[0].(type) {
+	case pdfRef:
+		return pageNumbers[target.Num]
+	case int64:
+		// Remote-style destinations use a 0-based page index
+		return int(target) + 1
+	}
+	return 0
+}
+
+func (p *pdfFile) lookupName(tree interface{}, key string, depth int) interface{} {
+	node, ok := p.resolve(tree).(pdfDict)
+	if !ok || depth > 32 {
+		return nil
+	}
+	if names, ok := p.resolve(node["Names"]).(pdfArray); ok {
+		for i := 0; i+1 < len(names); i += 2 {
+			if s, ok := p.resolve(names[i]).(pdfString); ok && string(s) == key {
+				return p.resolve(names[i+1])
+			}
+		}
+	}
+	if kids, ok := p.resolve(node["Kids"]).(pdfArray); ok {
+		for _, kid := range kids {
+			if v := p.lookupName(kid, key, depth+1); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// firstPageImage returns the largest JPEG image drawn on the first page,
+// which for scanned books and most commercial PDFs is the cover art.
+func (p *pdfFile) firstPageImage(pages []pdfRef) []byte {
+	if len(pages) == 0 {
+		return nil
+	}
+	node, _ := p.resolve(pages[0]).(pdfDict)
+	var resources pdfDict
+	for depth := 0; node != nil && resources == nil && depth < 32; depth++ {
+		resources, _ = p.resolve(node["Resources"]).(pdfDict)
+		node, _ = p.resolve(node["Parent"]).(pdfDict)
+	}
+	xobjects, ok := p.resolve(resources["XObject"]).(pdfDict)
+	if !ok {
+		return nil
+	}
+
+	var best *pdfStream
+	bestArea := 0
+	for _, v := range xobjects {
+		stream, ok := p.resolve(v).(*pdfStream)
+		if !ok || stream.Dict["Subtype"] != pdfName("Image") || !isJPEG(stream.Dict["Filter"]) {
+			continue
+		}
+		width := pdfInt(p.resolve(stream.Dict["Width"]), 0)
+		area := width * pdfInt(p.resolve(stream.Dict["Height"]), 0)
+		if width >= minCoverWidth && area > bestArea {
+			best, bestArea = stream, area
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	data, err := p.streamData(best)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func isJPEG(filter interface{}) bool {
+	switch f := filter.(type) {
+	case pdfName:
+		return f == "DCTDecode"
+	case pdfArray:
+		return len(f) > 0 && f[len(f)-1] == pdfName("DCTDecode")
+	}
+	return false
+}
+
+// renderPDFCover renders the first page with poppler's pdftoppm.
+func renderPDFCover(filePath string) ([]byte, error) {
+	bin, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, fmt.Errorf("pdftoppm is not installed")
+	}
+
+	dir, err := os.MkdirTemp("", "cover-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), coverRenderTimeout)
+	defer cancel()
+
+	out := filepath.Join(dir, "cover")
+	cmd := exec.CommandContext(ctx, bin, "-f", "1", "-l", "1", "-singlefile", "-jpeg", "-scale-to", "1800", filePath, out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pdftoppm failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.ReadFile(out + ".jpg")
+}
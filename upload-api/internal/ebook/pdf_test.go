@@ -0,0 +1,267 @@
+package ebook
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pdfFixture assembles a PDF from object bodies, numbered from 1, with a
+// classic cross-reference table.
+func pdfFixture(objects []string, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return b.Bytes()
+}
+
+// xrefStreamFixture assembles a PDF whose cross-reference data is an xref
+// stream with field widths w. rows gives the entries for objects 0 to
+// len(objects) from the objects' offsets; extra is added to the stream's
+// dictionary and compress deflates its data.
+func xrefStreamFixture(objects []string, w [3]int, rows func(offsets []int) [][3]int64, extra string, compress bool) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	var data bytes.Buffer
+	for _, row := range rows(offsets) {
+		for f := range w {
+			for k := w[f] - 1; k >= 0; k-- {
+				data.WriteByte(byte(row[f] >> (8 * k)))
+			}
+		}
+	}
+	encoded := data.Bytes()
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(encoded)
+		zw.Close()
+		encoded = z.Bytes()
+		extra += " /Filter /FlateDecode"
+	}
+
+	xref := b.Len()
+	num := len(objects) + 1
+	fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /Root 1 0 R /W [%d %d %d] /Length %d %s >>\nstream\n",
+		num, num+1, w[0], w[1], w[2], len(encoded), extra)
+	b.Write(encoded)
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
+
+// objectStreamPDF keeps its only page, object 3, in the object stream 4;
+// streamDict holds that stream's entries beyond its type and length.
+func objectStreamPDF(streamDict string, w [3]int, index int64) []byte {
+	content := "3 0 << /Type /Page /Parent 2 0 R >>"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"null",
+		fmt.Sprintf("<< /Type /ObjStm %s /Length %d >>\nstream\n%s\nendstream", streamDict, len(content), content),
+	}
+	return xrefStreamFixture(objects, w, func(offsets []int) [][3]int64 {
+		return [][3]int64{
+			{0, 0, 0},
+			{1, int64(offsets[0]), 0},
+			{1, int64(offsets[1]), 0},
+			{2, 4, index},
+			{1, int64(offsets[3]), 0},
+		}
+	}, "", false)
+}
+
+func samplePDF() []byte {
+	return pdfFixture([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 5 0 R /Lang (en-GB) >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Outlines /First 6 0 R /Last 7 0 R >>",
+		"<< /Title (Chapter One) /Parent 5 0 R /Next 7 0 R /Dest [3 0 R /Fit] >>",
+		"<< /Title (Chapter Two) /Parent 5 0 R /A << /S /GoTo /D [4 0 R /XYZ 0 0 0] >> >>",
+		"<< /Title (A Test Book) /Author (Ann Author; Bo Writer) /Keywords (fiction, tests) /Subject (ISBN 978-0-306-40615-7) >>",
+	}, "/Root 1 0 R /Info 8 0 R")
+}
+
+func writeFixture(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParsePDF(t *testing.T) {
+	sample := samplePDF()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		title    string
+		authors  []string
+		isbn     string
+		pages    int
+		toc      []TOCEntry
+		warnings []string // substrings, one per expected warning
+		err      bool
+	}{
+		{
+			name:    "info, pages and outline",
+			data:    sample,
+			title:   "A Test Book",
+			authors: []string{"Ann Author", "Bo Writer"},
+			isbn:    "9780306406157",
+			pages:   2,
+			toc: []TOCEntry{
+				{Title: "Chapter One", Level: 1, Page: 1, Progression: 0},
+				{Title: "Chapter Two", Level: 1, Page: 2, Progression: 0.5},
+			},
+		},
+		{
+			name:    "damaged startxref is repaired",
+			data:    bytes.Replace(sample, []byte("startxref\n"), []byte("startxref\n9"), 1),
+			title:   "A Test Book",
+			authors: []string{"Ann Author", "Bo Writer"},
+			isbn:    "9780306406157",
+			pages:   2,
+			toc: []TOCEntry{
+				{Title: "Chapter One", Level: 1, Page: 1, Progression: 0},
+				{Title: "Chapter Two", Level: 1, Page: 2, Progression: 0.5},
+			},
+		},
+		{
+			name: "encrypted only reports pages",
+			data: pdfFixture([]string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R >>",
+				"<< /Filter /Standard /V 1 >>",
+				"<< /Title (Secret) >>",
+			}, "/Root 1 0 R /Encrypt 4 0 R /Info 5 0 R"),
+			authors:  []string{},
+			pages:    1,
+			toc:      []TOCEntry{},
+			warnings: []string{"encrypted"},
+		},
+		{
+			name:     "object stream",
+			data:     objectStreamPDF("/N 1 /First 4", [3]int{1, 4, 2}, 0),
+			authors:  []string{},
+			pages:    1,
+			toc:      []TOCEntry{},
+			warnings: []string{"no document information", "no outline"},
+		},
+		{name: "not a pdf", data: []byte("hello, world"), err: true},
+		{name: "empty", data: nil, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParsePDF(writeFixture(t, "book.pdf", tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParsePDF() = %+v, want an error", meta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePDF() error = %v", err)
+			}
+			if meta.Title != tt.title || meta.ISBN != tt.isbn || meta.PageCount != tt.pages {
+				t.Errorf("ParsePDF() title %q isbn %q pages %d, want %q %q %d", meta.Title, meta.ISBN, meta.PageCount, tt.title, tt.isbn, tt.pages)
+			}
+			if !reflect.DeepEqual(meta.Authors, tt.authors) {
+				t.Errorf("ParsePDF() authors = %q, want %q", meta.Authors, tt.authors)
+			}
+			if !reflect.DeepEqual(meta.TOC, tt.toc) {
+				t.Errorf("ParsePDF() toc = %+v, want %+v", meta.TOC, tt.toc)
+			}
+			for _, want := range tt.warnings {
+				found := false
+				for _, w := range meta.Warnings {
+					found = found || strings.Contains(w, want)
+				}
+				if !found {
+					t.Errorf("ParsePDF() warnings = %q, want one containing %q", meta.Warnings, want)
+				}
+			}
+		})
+	}
+}
+
+// malformedPDFs are inputs that once crashed the parser or made it allocate
+// without bound. Each must be refused or read without panicking.
+func malformedPDFs() map[string][]byte {
+	valid := objectStreamPDF("/N 1 /First 4", [3]int{1, 4, 2}, 0)
+	return map[string][]byte{
+		"negative xref width":     bytes.Replace(valid, []byte("/W [1 4 2]"), []byte("/W [-1 4 2]"), 1),
+		"zero xref widths":        bytes.Replace(valid, []byte("/W [1 4 2]"), []byte("/W [0 0 0]"), 1),
+		"xref width past int64":   bytes.Replace(valid, []byte("/W [1 4 2]"), []byte("/W [1 4 9]"), 1),
+		"negative object index":   objectStreamPDF("/N 1 /First 4", [3]int{1, 4, 8}, -1),
+		"object index past count": objectStreamPDF("/N 1 /First 4", [3]int{1, 4, 2}, 7),
+		"negative object count":   objectStreamPDF("/N -1 /First 4", [3]int{1, 4, 2}, 0),
+		"huge object count":       objectStreamPDF("/N 2000000000 /First 4", [3]int{1, 4, 2}, 0),
+		"first past the data":     objectStreamPDF("/N 1 /First 999", [3]int{1, 4, 2}, 0),
+		"negative first":          objectStreamPDF("/N 1 /First -4", [3]int{1, 4, 2}, 0),
+		"huge predictor columns": xrefStreamFixture([]string{"<< /Type /Catalog >>"}, [3]int{1, 4, 2}, func(offsets []int) [][3]int64 {
+			return [][3]int64{{0, 0, 0}, {1, int64(offsets[0]), 0}}
+		}, "/DecodeParms << /Predictor 12 /Columns 2000000000 >>", true),
+		"deep nesting": pdfFixture([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [] /Count 0 >>",
+			"<< /Title " + strings.Repeat("[", 5000) + strings.Repeat("]", 5000) + " >>",
+		}, "/Root 1 0 R /Info 3 0 R"),
+		"self-referencing pages": pdfFixture([]string{
+			"<< /Type /Catalog /Pages 2 0 R /Outlines 3 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R 2 0 R] /Count 2 >>",
+			"<< /First 4 0 R >>",
+			"<< /Title (Loop) /Next 4 0 R /First 4 0 R >>",
+		}, "/Root 1 0 R"),
+		"length points at itself": pdfFixture([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [] /Count 0 >>",
+			"<< /Length 3 0 R >>\nstream\nabc\nendstream",
+		}, "/Root 1 0 R"),
+	}
+}
+
+func TestParsePDFMalformed(t *testing.T) {
+	for name, data := range malformedPDFs() {
+		t.Run(name, func(t *testing.T) {
+			// Any outcome but a panic will do
+			ParsePDF(writeFixture(t, "book.pdf", data))
+		})
+	}
+}
+
+func FuzzParsePDF(f *testing.F) {
+	f.Add(samplePDF())
+	for _, data := range malformedPDFs() {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParsePDF(writeFixture(t, "book.pdf", data))
+	})
+}
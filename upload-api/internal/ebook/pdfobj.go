@@ -0,0 +1,446 @@
+package ebook
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+)
+
+// A minimal reader for the PDF object syntax: enough to follow the cross
+// reference data, the document catalog, the page tree and the outline. It
+// doesn't handle content streams or encryption.
+
+type pdfName string
+
+type pdfRef struct {
+	Num int
+	Gen int
+}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfArray []interface{}
+
+type pdfString []byte
+
+type pdfKeyword string
+
+// pdfStream is a stream object; the data is read lazily from Offset.
+type pdfStream struct {
+	Dict   pdfDict
+	Offset int64
+}
+
+const (
+	// maxPDFNesting bounds how deeply arrays and dictionaries may nest.
+	maxPDFNesting = 100
+	// maxDecodedStream caps what one stream may inflate to.
+	maxDecodedStream = 64 * 1024 * 1024
+)
+
+var (
+	errPDFTruncated    = errors.New("pdf object truncated")
+	errPDFTooDeep      = errors.New("pdf objects nested too deeply")
+	errPDFStreamTooBig = errors.New("pdf stream too large")
+)
+
+type pdfLexer struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		if c == '%' {
+			for l.pos < len(l.buf) && l.buf[l.pos] != '\n' && l.buf[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// keyword reads a bare word such as obj, stream, true or R.
+func (l *pdfLexer) keyword() string {
+	start := l.pos
+	for l.pos < len(l.buf) && !isPDFDelimiter(l.buf[l.pos]) {
+		l.pos++
+	}
+	return string(l.buf[start:l.pos])
+}
+
+func (l *pdfLexer) object() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.buf) {
+		return nil, errPDFTruncated
+	}
+	if l.depth >= maxPDFNesting {
+		return nil, errPDFTooDeep
+	}
+	l.depth++
+	defer func() { l.depth-- }()
+
+	switch c := l.buf[l.pos]; {
+	case c == '/':
+		return l.name(), nil
+	case c == '(':
+		return l.literalString()
+	case c == '<':
+		if l.pos+1 < len(l.buf) && l.buf[l.pos+1] == '<' {
+			return l.dict()
+		}
+		return l.hexString()
+	case c == '[':
+		l.pos++
+		arr := pdfArray{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.buf) {
+				return nil, errPDFTruncated
+			}
+			if l.buf[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			v, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.number()
+	default:
+		word := l.keyword()
+		switch word {
+		case "":
+			return nil, fmt.Errorf("unexpected %q at %d", c, l.pos)
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return pdfKeyword(word), nil
+	}
+}
+
+func (l *pdfLexer) name() pdfName {
+	l.pos++
+	var name []byte
+	for l.pos < len(l.buf) && !isPDFDelimiter(l.buf[l.pos]) {
+		c := l.buf[l.pos]
+		if c == '#' && l.pos+2 < len(l.buf) {
+			if v, err := strconv.ParseUint(string(l.buf[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		l.pos++
+	}
+	return pdfName(name)
+}
+
+// number reads an integer or real, and an indirect reference "n g R" when
+// the integer starts one.
+func (l *pdfLexer) number() (interface{}, error) {
+	start := l.pos
+	word := l.keyword()
+	if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+		save := l.pos
+		l.skipSpace()
+		genStart := l.pos
+		for l.pos < len(l.buf) && l.buf[l.pos] >= '0' && l.buf[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos > genStart && l.pos < len(l.buf) {
+			gen, _ := strconv.Atoi(string(l.buf[genStart:l.pos]))
+			l.skipSpace()
+			if l.pos < len(l.buf) && l.buf[l.pos] == 'R' && (l.pos+1 == len(l.buf) || isPDFDelimiter(l.buf[l.pos+1])) {
+				l.pos++
+				return pdfRef{Num: int(n), Gen: gen}, nil
+			}
+		}
+		l.pos = save
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad number %q at %d", word, start)
+	}
+	return f, nil
+}
+
+func (l *pdfLexer) literalString() (pdfString, error) {
+	l.pos++
+	var s []byte
+	depth := 1
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, nil
+			}
+		case '\\':
+			if l.pos >= len(l.buf) {
+				return nil, errPDFTruncated
+			}
+			e := l.buf[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r':
+				if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.buf) && l.buf[l.pos] >= '0' && l.buf[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.buf[l.pos]-'0')
+						l.pos++
+					}
+					s = append(s, byte(v))
+				} else {
+					s = append(s, e)
+				}
+			}
+			continue
+		}
+		s = append(s, c)
+	}
+	return nil, errPDFTruncated
+}
+
+func (l *pdfLexer) hexString() (pdfString, error) {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		l.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			s := make([]byte, len(digits)/2)
+			for i := range s {
+				v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("bad hex string")
+				}
+				s[i] = byte(v)
+			}
+			return s, nil
+		}
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, errPDFTruncated
+}
+
+func (l *pdfLexer) dict() (pdfDict, error) {
+	l.pos += 2
+	d := pdfDict{}
+	for {
+		l.skipSpace()
+		if l.pos+1 >= len(l.buf) {
+			return nil, errPDFTruncated
+		}
+		if l.buf[l.pos] == '>' && l.buf[l.pos+1] == '>' {
+			l.pos += 2
+			return d, nil
+		}
+		key, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key is not a name")
+		}
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		d[name] = value
+	}
+}
+
+// decodeStream undoes the stream's filters. Only Flate (with PNG or TIFF
+// predictors) is supported; image data is returned as stored.
+func decodeStream(dict pdfDict, data []byte) ([]byte, error) {
+	filters := pdfArray{}
+	switch f := dict["Filter"].(type) {
+	case pdfName:
+		filters = append(filters, f)
+	case pdfArray:
+		filters = f
+	}
+	params := pdfArray{}
+	switch p := dict["DecodeParms"].(type) {
+	case pdfDict:
+		params = append(params, p)
+	case pdfArray:
+		params = p
+	}
+
+	for i, f := range filters {
+		name, _ := f.(pdfName)
+		switch name {
+		case "FlateDecode", "Fl":
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			out, err := io.ReadAll(io.LimitReader(zr, maxDecodedStream+1))
+			if len(out) > maxDecodedStream {
+				return nil, errPDFStreamTooBig
+			}
+			// Truncated deflate data is common and usually still usable
+			if err != nil && len(out) == 0 {
+				return nil, err
+			}
+			data = out
+			if i < len(params) {
+				if p, ok := params[i].(pdfDict); ok {
+					if data, err = unpredict(p, data); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case "DCTDecode", "DCT", "JPXDecode":
+			return data, nil
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %s", name)
+		}
+	}
+	return data, nil
+}
+
+// unpredict reverses the PNG predictors xref and object streams use.
+func unpredict(params pdfDict, data []byte) ([]byte, error) {
+	predictor := pdfInt(params["Predictor"], 1)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns := pdfInt(params["Columns"], 1) * pdfInt(params["Colors"], 1) * pdfInt(params["BitsPerComponent"], 8) / 8
+	bpp := max(1, pdfInt(params["Colors"], 1)*pdfInt(params["BitsPerComponent"], 8)/8)
+	if columns <= 0 || columns > len(data) {
+		return nil, fmt.Errorf("bad predictor columns")
+	}
+
+	rowLen := columns + 1
+	out := make([]byte, 0, len(data)/rowLen*columns)
+	prev := make([]byte, columns)
+	for start := 0; start+rowLen <= len(data); start += rowLen {
+		filter := data[start]
+		row := append([]byte(nil), data[start+1:start+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func pdfInt(v interface{}, fallback int) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return fallback
+}
+
+// pdfText decodes a PDF text string: UTF-16BE with a byte order mark,
+// UTF-8 with one, or PDFDocEncoding, which matches Latin-1 for text.
+func pdfText(s pdfString) string {
+	switch {
+	case len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF:
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF:
+		return string(s[3:])
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
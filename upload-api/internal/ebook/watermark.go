@@ -0,0 +1,513 @@
+package ebook
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const (
+	// WatermarkMarker prefixes the code hidden in a watermarked PDF; the
+	// backend looks for it to trace a leaked copy.
+	WatermarkMarker = "readagain-wm:"
+
+	watermarkFont     = pdfName("RAWm")
+	watermarkMargin   = 18.0
+	watermarkFontSize = 8.0
+	// Helvetica's average glyph width, in ems, for fitting the line
+	watermarkGlyphWidth = 0.5
+)
+
+var (
+	ErrPDFEncrypted   = errors.New("pdf is encrypted")
+	ErrPDFUnreadable  = errors.New("pdf cross-reference data is damaged")
+	errEmptyWatermark = errors.New("watermark has neither text nor code")
+)
+
+// Watermark is stamped on a reader's copy of a PDF: Text is printed at the
+// foot of every page and Code is hidden in the document information
+// dictionary and as invisible text on every page. Either may be empty.
+type Watermark struct {
+	Text string
+	Code string
+}
+
+// WatermarkPDF writes src to dst with mark stamped on it. The whole file
+// is rewritten from the objects the document still uses, so the watermark
+// sits in every page's content and no earlier revision is left to cut back
+// to.
+func WatermarkPDF(src, dst string, mark Watermark) error {
+	if mark.Text == "" && mark.Code == "" {
+		return errEmptyWatermark
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open pdf: %w", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	pdf := &pdfFile{f: f, size: stat.Size(), xref: map[int]xrefEntry{}, cache: map[int]interface{}{}, objStms: map[int]*objectStream{}}
+	if err := pdf.loadXref(); err != nil {
+		pdf.xref = map[int]xrefEntry{}
+		if err := pdf.reconstructXref(); err != nil {
+			return ErrPDFUnreadable
+		}
+	}
+	if pdf.trailer["Encrypt"] != nil {
+		return ErrPDFEncrypted
+	}
+	catalog, _ := pdf.resolve(pdf.trailer["Root"]).(pdfDict)
+	if catalog == nil {
+		return fmt.Errorf("pdf has no document catalog")
+	}
+	pages := pdf.pages(catalog)
+	if len(pages) == 0 {
+		return fmt.Errorf("pdf has no pages")
+	}
+
+	u := &pdfRewrite{next: max(pdfInt(pdf.trailer["Size"], 0), maxKey(pdf.xref)+1)}
+	font := u.add(pdfDict{
+		"Type":     pdfName("Font"),
+		"Subtype":  pdfName("Type1"),
+		"BaseFont": pdfName("Helvetica"),
+		"Encoding": pdfName("WinAnsiEncoding"),
+	})
+	// Saves the graphics state before the page's own content, so the
+	// watermark is drawn untransformed whatever the page leaves behind
+	save := u.add(&pdfNewStream{Data: []byte("q\n")})
+
+	for _, ref := range pages {
+		page, ok := pdf.resolve(ref).(pdfDict)
+		if !ok {
+			continue
+		}
+		stamp := u.add(&pdfNewStream{Data: watermarkContent(pdf.pageGeometry(page), mark)})
+
+		updated := copyDict(page)
+		contents := pdfArray{save}
+		switch c := page["Contents"].(type) {
+		case pdfArray:
+			contents = append(contents, c...)
+		case pdfRef:
+			if arr, ok := pdf.resolve(c).(pdfArray); ok {
+				contents = append(contents, arr...)
+			} else {
+				contents = append(contents, c)
+			}
+		}
+		updated["Contents"] = append(contents, stamp)
+
+		resources, _ := pdf.resolve(pdf.inherited(page, "Resources")).(pdfDict)
+		resources = copyDict(resources)
+		fonts, _ := pdf.resolve(resources["Font"]).(pdfDict)
+		fonts = copyDict(fonts)
+		fonts[watermarkFont] = font
+		resources["Font"] = fonts
+		updated["Resources"] = resources
+
+		u.set(ref, updated)
+	}
+
+	trailer := pdfDict{"Root": pdf.trailer["Root"]}
+	if id, ok := pdf.trailer["ID"]; ok {
+		trailer["ID"] = id
+	}
+	if info, ok := pdf.trailer["Info"]; ok {
+		trailer["Info"] = info
+	}
+	if mark.Code != "" {
+		info, _ := pdf.resolve(pdf.trailer["Info"]).(pdfDict)
+		info = copyDict(info)
+		info["ReadAgainWatermark"] = pdfString(WatermarkMarker + mark.Code)
+		trailer["Info"] = u.add(info)
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dst), ".watermark-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	w := bufio.NewWriter(out)
+	if err := u.write(w, pdf, trailer); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+func maxKey(m map[int]xrefEntry) int {
+	n := 0
+	for k := range m {
+		n = max(n, k)
+	}
+	return n
+}
+
+func copyDict(d pdfDict) pdfDict {
+	c := make(pdfDict, len(d)+1)
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
+
+// inherited reads a page attribute that may be set on an ancestor in the
+// page tree.
+func (p *pdfFile) inherited(page pdfDict, key pdfName) interface{} {
+	node := page
+	for i := 0; node != nil && i < 32; i++ {
+		if v, ok := node[key]; ok {
+			return v
+		}
+		node, _ = p.resolve(node["Parent"]).(pdfDict)
+	}
+	return nil
+}
+
+// pageGeometry is the visible area of a page and how it is turned for
+// display.
+type pageGeometry struct {
+	llx, lly, urx, ury float64
+	rotate             int
+}
+
+func (p *pdfFile) pageGeometry(page pdfDict) pageGeometry {
+	g := pageGeometry{urx: 612, ury: 792} // US Letter, should no box be set
+	for _, key := range []pdfName{"CropBox", "MediaBox"} {
+		box, ok := p.resolve(p.inherited(page, key)).(pdfArray)
+		if !ok || len(box) != 4 {
+			continue
+		}
+		var v [4]float64
+		for i, n := range box {
+			v[i] = pdfFloat(p.resolve(n))
+		}
+		g = pageGeometry{llx: min(v[0], v[2]), lly: min(v[1], v[3]), urx: max(v[0], v[2]), ury: max(v[1], v[3])}
+		break
+	}
+	g.rotate = ((pdfInt(p.resolve(p.inherited(page, "Rotate")), 0)%360 + 360) % 360) / 90 * 90
+	return g
+}
+
+func pdfFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// watermarkContent draws the watermark along the bottom of the page as it
+// is displayed, after restoring the state saved before the page's content.
+func watermarkContent(g pageGeometry, mark Watermark) []byte {
+	width, height := g.urx-g.llx, g.ury-g.lly
+	// Maps display coordinates, origin at the displayed bottom left, to
+	// the page's own
+	var cm [6]float64
+	switch g.rotate {
+	case 90:
+		cm = [6]float64{0, 1, -1, 0, g.urx, g.lly}
+		width = height
+	case 180:
+		cm = [6]float64{-1, 0, 0, -1, g.urx, g.ury}
+	case 270:
+		cm = [6]float64{0, -1, 1, 0, g.llx, g.ury}
+		width = height
+	default:
+		cm = [6]float64{1, 0, 0, 1, g.llx, g.lly}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("Q\nq\n")
+	fmt.Fprintf(&b, "%s %s %s %s %s %s cm\n", pdfNum(cm[0]), pdfNum(cm[1]), pdfNum(cm[2]), pdfNum(cm[3]), pdfNum(cm[4]), pdfNum(cm[5]))
+	if mark.Text != "" {
+		text := winAnsi(mark.Text)
+		size := watermarkFontSize
+		if fit := (width - 2*watermarkMargin) / (watermarkGlyphWidth * float64(len(text))); fit < size {
+			size = max(fit, 3)
+		}
+		fmt.Fprintf(&b, "BT /%s %s Tf 0.5 g 1 0 0 1 %s %s Tm ", watermarkFont, pdfNum(size), pdfNum(watermarkMargin), pdfNum(watermarkMargin/2))
+		writePDFObject(&b, pdfString(text))
+		b.WriteString(" Tj ET\n")
+	}
+	if mark.Code != "" {
+		// Render mode 3 draws nothing but keeps the text extractable
+		fmt.Fprintf(&b, "BT 3 Tr /%s 1 Tf 1 0 0 1 %s %s Tm ", watermarkFont, pdfNum(watermarkMargin), pdfNum(watermarkMargin/2))
+		writePDFObject(&b, pdfString(WatermarkMarker+mark.Code))
+		b.WriteString(" Tj ET\n")
+	}
+	b.WriteString("Q\n")
+	return b.Bytes()
+}
+
+// winAnsiExtras are the characters WinAnsiEncoding places in 0x80-0x9F.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// winAnsi encodes s for the standard Helvetica font; characters it lacks
+// become "?".
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7F, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case winAnsiExtras[r] != 0:
+			out = append(out, winAnsiExtras[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfNewStream is a stream written by a rewrite, stored as given.
+type pdfNewStream struct {
+	Dict pdfDict
+	Data []byte
+}
+
+// pdfRewrite collects the objects a rewrite adds or replaces.
+type pdfRewrite struct {
+	next    int
+	objects map[int]interface{}
+}
+
+// add stores v as a new object and returns a reference to it.
+func (u *pdfRewrite) add(v interface{}) pdfRef {
+	ref := pdfRef{Num: u.next}
+	u.next++
+	u.set(ref, v)
+	return ref
+}
+
+// set replaces the object ref points to.
+func (u *pdfRewrite) set(ref pdfRef, v interface{}) {
+	if u.objects == nil {
+		u.objects = map[int]interface{}{}
+	}
+	u.objects[ref.Num] = v
+}
+
+var pdfVersionPattern = regexp.MustCompile(`%PDF-(\d\.\d)`)
+
+// write writes a single-revision file of every object reachable from
+// trailer, with the rewrite's objects in place of the originals. Objects are
+// renumbered in the order they are reached, which drops earlier revisions,
+// object and xref streams and anything else the document no longer uses.
+func (u *pdfRewrite) write(w io.Writer, p *pdfFile, trailer pdfDict) error {
+	version := "1.7"
+	if head, err := p.readAt(0, 1024); err == nil {
+		if m := pdfVersionPattern.FindSubmatch(head); m != nil {
+			version = string(m[1])
+		}
+	}
+
+	lookup := func(num int) interface{} {
+		if v, ok := u.objects[num]; ok {
+			return v
+		}
+		return p.object(num)
+	}
+	numbers := map[int]int{}
+	var order []int
+	var renumber func(v interface{}) interface{}
+	renumber = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case pdfRef:
+			if n, ok := numbers[v.Num]; ok {
+				return pdfRef{Num: n}
+			}
+			// A reference to a missing object reads as null
+			if lookup(v.Num) == nil {
+				return nil
+			}
+			order = append(order, v.Num)
+			numbers[v.Num] = len(order)
+			return pdfRef{Num: len(order)}
+		case pdfArray:
+			arr := make(pdfArray, len(v))
+			for i, item := range v {
+				arr[i] = renumber(item)
+			}
+			return arr
+		case pdfDict:
+			dict := make(pdfDict, len(v))
+			for k, item := range v {
+				dict[k] = renumber(item)
+			}
+			return dict
+		}
+		return v
+	}
+	trailer = renumber(trailer).(pdfDict)
+
+	cw := &countingWriter{w: w}
+	// The comment's high bytes tell transfer tools the file is binary
+	cw.WriteString("%PDF-" + version + "\n%\xE2\xE3\xCF\xD3\n")
+
+	offsets := make([]int64, 0, len(order))
+	for i := 0; i < len(order); i++ {
+		var v interface{}
+		switch obj := lookup(order[i]).(type) {
+		case *pdfStream:
+			// Data is copied still encoded. The length is written directly,
+			// so an indirect one is not carried over for its own sake
+			data, _ := p.rawStreamData(obj)
+			dict := copyDict(obj.Dict)
+			delete(dict, "Length")
+			v = &pdfNewStream{Dict: renumber(dict).(pdfDict), Data: data}
+		case *pdfNewStream:
+			v = &pdfNewStream{Dict: renumber(obj.Dict).(pdfDict), Data: obj.Data}
+		default:
+			v = renumber(obj)
+		}
+
+		offsets = append(offsets, cw.n)
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		writePDFObject(&b, v)
+		b.WriteString("\nendobj\n")
+		cw.Write(b.Bytes())
+		if cw.err != nil {
+			return cw.err
+		}
+	}
+
+	xrefOffset := cw.n
+	trailer["Size"] = int64(len(order) + 1)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", len(order)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n\r\n", off)
+	}
+	b.WriteString("trailer\n")
+	writePDFObject(&b, trailer)
+	fmt.Fprintf(&b, "\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	cw.Write(b.Bytes())
+	return cw.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) {
+	c.Write([]byte(s))
+}
+
+func pdfNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// writePDFObject serializes the values the lexer produces, plus streams
+// written by an update.
+func writePDFObject(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(pdfNum(v))
+	case pdfKeyword:
+		b.WriteString(string(v))
+	case pdfRef:
+		fmt.Fprintf(b, "%d %d R", v.Num, v.Gen)
+	case pdfName:
+		b.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c <= ' ' || c >= 0x7F || c == '#' || isPDFDelimiter(c) {
+				fmt.Fprintf(b, "#%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	case pdfString:
+		b.WriteByte('(')
+		for _, c := range []byte(v) {
+			switch {
+			case c == '(' || c == ')' || c == '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case c < ' ' || c >= 0x7F:
+				fmt.Fprintf(b, "\\%03o", c)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte(')')
+	case pdfArray:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writePDFObject(b, item)
+		}
+		b.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			writePDFObject(b, pdfName(k))
+			b.WriteByte(' ')
+			writePDFObject(b, v[pdfName(k)])
+		}
+		b.WriteString(">>")
+	case *pdfNewStream:
+		dict := copyDict(v.Dict)
+		dict["Length"] = int64(len(v.Data))
+		writePDFObject(b, dict)
+		b.WriteString("\nstream\n")
+		b.Write(v.Data)
+		b.WriteString("\nendstream")
+	default:
+		// Streams are always indirect, so never met inside a dictionary
+		b.WriteString("null")
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sectionFile streams part of a file and closes it once sent.
+type sectionFile struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (s sectionFile) Close() error {
+	return s.file.Close()
+}
+
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange reads a single "bytes=" range against a file of size bytes.
+// ok is false when the header should be ignored (absent, malformed or
+// several ranges); a range past the end of the file is unsatisfiable.
+func parseRange(header string, size int64) (start, length int64, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		n = min(n, size)
+		return size - n, n, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	return start, end - start + 1, true, true
+}
+
+// serveFile sends a regular file with an ETag, the given Cache-Control and
+// support for conditional and single byte-range requests, which PDF readers
+// use to fetch large books a piece at a time.
+func serveFile(c *fiber.Ctx, path, cacheControl string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+
+	etag := fileETag(info)
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+	c.Set(fiber.HeaderLastModified, info.ModTime().UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Type(filepath.Ext(path))
+
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" && etagMatches(match, etag) {
+		file.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	size := info.Size()
+	rangeHeader := c.Get(fiber.HeaderRange)
+	// A range is only valid for the version of the file the client has
+	if ifRange := c.Get(fiber.HeaderIfRange); ifRange != "" && ifRange != etag {
+		rangeHeader = ""
+	}
+	if rangeHeader != "" {
+		start, length, ok, satisfiable := parseRange(rangeHeader, size)
+		if ok && !satisfiable {
+			file.Close()
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+			return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+		}
+		if ok {
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+			c.Status(fiber.StatusPartialContent)
+			c.Context().SetBodyStream(sectionFile{io.NewSectionReader(file, start, length), file}, int(length))
+			return nil
+		}
+	}
+
+	c.Status(fiber.StatusOK)
+	c.Context().SetBodyStream(file, int(size))
+	return nil
+}
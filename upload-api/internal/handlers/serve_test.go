@@ -0,0 +1,47 @@
+package handlers
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		size        int64
+		start       int64
+		length      int64
+		ok          bool
+		satisfiable bool
+	}{
+		{name: "absent", header: "", size: 1000},
+		{name: "other unit", header: "items=0-1", size: 1000},
+		{name: "several ranges", header: "bytes=0-1,5-6", size: 1000},
+		{name: "no dash", header: "bytes=10", size: 1000},
+		{name: "not a number", header: "bytes=a-b", size: 1000},
+		{name: "negative start", header: "bytes=-5-10", size: 1000},
+		{name: "end before start", header: "bytes=10-5", size: 1000},
+		{name: "negative suffix", header: "bytes=--5", size: 1000},
+
+		{name: "closed range", header: "bytes=0-99", size: 1000, start: 0, length: 100, ok: true, satisfiable: true},
+		{name: "padded", header: "bytes= 10-19 ", size: 1000, start: 10, length: 10, ok: true, satisfiable: true},
+		{name: "open ended", header: "bytes=900-", size: 1000, start: 900, length: 100, ok: true, satisfiable: true},
+		{name: "end clamped to the file", header: "bytes=990-5000", size: 1000, start: 990, length: 10, ok: true, satisfiable: true},
+		{name: "single byte", header: "bytes=999-999", size: 1000, start: 999, length: 1, ok: true, satisfiable: true},
+		{name: "suffix", header: "bytes=-100", size: 1000, start: 900, length: 100, ok: true, satisfiable: true},
+		{name: "suffix longer than the file", header: "bytes=-5000", size: 1000, start: 0, length: 1000, ok: true, satisfiable: true},
+
+		{name: "start past the end", header: "bytes=1000-", size: 1000, ok: true},
+		{name: "empty suffix", header: "bytes=-0", size: 1000, ok: true},
+		{name: "empty file", header: "bytes=-10", size: 0, ok: true},
+		{name: "any start in an empty file", header: "bytes=0-", size: 0, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, length, ok, satisfiable := parseRange(tt.header, tt.size)
+			if start != tt.start || length != tt.length || ok != tt.ok || satisfiable != tt.satisfiable {
+				t.Errorf("parseRange(%q, %d) = %d, %d, %v, %v, want %d, %d, %v, %v",
+					tt.header, tt.size, start, length, ok, satisfiable, tt.start, tt.length, tt.ok, tt.satisfiable)
+			}
+		})
+	}
+}
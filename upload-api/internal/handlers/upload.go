@@ -1,26 +1,40 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"readagain/upload-api/internal/ebook"
 	"readagain/upload-api/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+// Covers and profile pictures get a new name on every upload, so they can
+// be cached for a long time.
+const publicFileCacheControl = "public, max-age=604800"
+
 type UploadHandler struct {
-	storagePath string
-	optimizer   *utils.ImageOptimizer
+	storagePath    string
+	optimizer      *utils.ImageOptimizer
+	signer         *utils.FileSigner
+	watermarkLocks [watermarkLockStripes]sync.Mutex
 }
 
-func NewUploadHandler(storagePath string) *UploadHandler {
+func NewUploadHandler(storagePath string, signer *utils.FileSigner) *UploadHandler {
 	return &UploadHandler{
 		storagePath: storagePath,
 		optimizer:   utils.NewImageOptimizer(1200, 1800, 85),
+		signer:      signer,
 	}
 }
 
@@ -127,33 +141,140 @@ func (h *UploadHandler) UploadBook(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	response := fiber.Map{
 		"filename": filename,
 		"path":     fmt.Sprintf("books/%s", filename),
 		"url":      fmt.Sprintf("/api/files/%s", filename),
 		"size":     file.Size,
-	})
+	}
+
+	// A file we can't read metadata from is still a valid upload
+	metadata, err := h.ingest(filePath)
+	if err != nil {
+		response["metadata_error"] = "Could not read book metadata"
+	} else if metadata != nil {
+		response["metadata"] = metadata
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ExtractMetadata re-runs ingestion for a book uploaded earlier, e.g. to
+// backfill chapters and covers for books created before extraction existed.
+// The route needs a URL the backend signed for the "metadata" action.
+func (h *UploadHandler) ExtractMetadata(c *fiber.Ctx) error {
+	filename := filepath.Base(c.Params("filename"))
+	filePath := filepath.Join(h.storagePath, "books", filename)
+	if _, err := os.Stat(filePath); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	metadata, err := h.ingest(filePath)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Could not read book metadata",
+		})
+	}
+	if metadata == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Metadata can only be extracted from EPUB and PDF files",
+		})
+	}
+
+	return c.JSON(fiber.Map{"metadata": metadata})
 }
 
+// ingest parses an EPUB or PDF and saves its cover to the covers directory.
+// It returns nil metadata for formats it doesn't read, like HTML.
+func (h *UploadHandler) ingest(filePath string) (*ebook.Metadata, error) {
+	metadata, err := ebook.Parse(filePath)
+	if errors.Is(err, ebook.ErrUnsupportedFormat) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to read metadata from %s: %v", filepath.Base(filePath), err)
+		return nil, err
+	}
+
+	data, source := metadata.CoverData()
+	if data == nil {
+		return metadata, nil
+	}
+
+	// Named after its content, so extracting the same book again reuses
+	// the cover saved the first time
+	sum := sha256.Sum256(data)
+	coverName := hex.EncodeToString(sum[:16]) + ".jpg"
+	coverPath := filepath.Join(h.storagePath, "covers", coverName)
+	if _, err := os.Stat(coverPath); err != nil {
+		if err := h.optimizer.SaveImage(data, coverPath); err != nil {
+			log.Printf("Failed to save cover from %s: %v", filepath.Base(filePath), err)
+			metadata.Warnings = append(metadata.Warnings, "The cover image could not be decoded")
+			return metadata, nil
+		}
+	}
+
+	metadata.Cover = &ebook.Cover{
+		Filename: coverName,
+		Path:     fmt.Sprintf("covers/%s", coverName),
+		URL:      fmt.Sprintf("/api/files/%s", coverName),
+		Source:   source,
+	}
+	return metadata, nil
+}
+
+// ServeFile serves profile pictures and covers to anyone. Book files need
+// the signed URL the backend hands to readers who may open the book; PDFs
+// are served stamped with the watermark the URL carries.
 func (h *UploadHandler) ServeFile(c *fiber.Ctx) error {
 	filename := c.Params("filename")
-	
+	if filename == "" || filename != filepath.Base(filename) || filename == ".." {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
 	// Check in profiles directory
 	profilePath := filepath.Join(h.storagePath, "profiles", filename)
 	if _, err := os.Stat(profilePath); err == nil {
-		return c.SendFile(profilePath)
+		return serveFile(c, profilePath, publicFileCacheControl)
 	}
 
 	// Check in covers directory
 	coverPath := filepath.Join(h.storagePath, "covers", filename)
 	if _, err := os.Stat(coverPath); err == nil {
-		return c.SendFile(coverPath)
+		return serveFile(c, coverPath, publicFileCacheControl)
 	}
 
 	// Check in books directory
 	bookPath := filepath.Join(h.storagePath, "books", filename)
 	if _, err := os.Stat(bookPath); err == nil {
-		return c.SendFile(bookPath)
+		expiresAt, err := h.signer.Verify("books/"+filename, c.Query("expires"), c.Query("uid"), c.Query("wm"), c.Query("wmseal"), c.Query("sig"))
+		var text string
+		if err == nil {
+			text, err = h.signer.OpenText(c.Query("wmseal"))
+		}
+		if err != nil {
+			log.Printf("Refused book file %s: %v", filename, err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This link is invalid or has expired",
+			})
+		}
+		mark := ebook.Watermark{Code: c.Query("wm"), Text: text}
+		if mark != (ebook.Watermark{}) && strings.EqualFold(filepath.Ext(filename), ".pdf") {
+			// A copy that can't be traced is never handed out instead
+			if bookPath, err = h.watermarkedBook(filename, bookPath, mark); err != nil {
+				log.Printf("Failed to watermark %s for user %s: %v", filename, c.Query("uid"), err)
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error": "This book can't be prepared for reading",
+				})
+			}
+		}
+		// Let the reader cache the file no longer than the link lasts
+		maxAge := max(0, int(time.Until(expiresAt).Seconds()))
+		return serveFile(c, bookPath, fmt.Sprintf("private, max-age=%d", maxAge))
 	}
 
 	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -161,9 +282,16 @@ func (h *UploadHandler) ServeFile(c *fiber.Ctx) error {
 	})
 }
 
+// DeleteFile removes a stored file. The route needs a URL the backend
+// signed for the "delete" action, which also checks the name.
 func (h *UploadHandler) DeleteFile(c *fiber.Ctx) error {
 	filename := c.Params("filename")
-	
+	if filename == "" || filename != filepath.Base(filename) || filename == ".." {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
 	// Try to delete from profiles
 	profilePath := filepath.Join(h.storagePath, "profiles", filename)
 	if err := os.Remove(profilePath); err == nil {
@@ -183,6 +311,7 @@ func (h *UploadHandler) DeleteFile(c *fiber.Ctx) error {
 	// Try to delete from books
 	bookPath := filepath.Join(h.storagePath, "books", filename)
 	if err := os.Remove(bookPath); err == nil {
+		os.RemoveAll(filepath.Join(h.storagePath, "watermarked", filename))
 		return c.JSON(fiber.Map{
 			"message": "File deleted successfully",
 		})
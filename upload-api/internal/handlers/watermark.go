@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"readagain/upload-api/internal/ebook"
+)
+
+const (
+	// watermarkLockStripes bounds the locks that keep two requests for the
+	// same copy (a PDF reader's parallel range requests) from stamping it
+	// twice.
+	watermarkLockStripes = 64
+
+	// Stamped copies are dropped once unread for watermarkCacheMaxAge, and
+	// the least recently read go first while the cache holds more than
+	// watermarkCacheMaxBytes.
+	watermarkCacheMaxAge   = 7 * 24 * time.Hour
+	watermarkCacheMaxBytes = 5 << 30
+	watermarkSweepInterval = time.Hour
+)
+
+// watermarkedBook returns the path of the copy of a book stamped with mark,
+// stamping it first unless an up-to-date copy is cached. Copies are kept in
+// watermarked/<book filename>/, one per watermark, so they go with the book.
+func (h *UploadHandler) watermarkedBook(filename, bookPath string, mark ebook.Watermark) (string, error) {
+	sum := sha256.Sum256([]byte(mark.Code + "\n" + mark.Text))
+	key := hex.EncodeToString(sum[:16])
+	dir := filepath.Join(h.storagePath, "watermarked", filename)
+	copyPath := filepath.Join(dir, key+".pdf")
+
+	lock := &h.watermarkLocks[sum[0]%watermarkLockStripes]
+	lock.Lock()
+	defer lock.Unlock()
+
+	source, err := os.Stat(bookPath)
+	if err != nil {
+		return "", err
+	}
+	if cached, err := os.Stat(copyPath); err == nil && !cached.ModTime().Before(source.ModTime()) {
+		// The sweeper goes by when a copy was last read
+		now := time.Now()
+		os.Chtimes(copyPath, now, now)
+		return copyPath, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := ebook.WatermarkPDF(bookPath, copyPath, mark); err != nil {
+		return "", err
+	}
+	return copyPath, nil
+}
+
+// RunWatermarkSweeper keeps the stamped copies cache within its age and
+// size caps.
+func (h *UploadHandler) RunWatermarkSweeper() {
+	ticker := time.NewTicker(watermarkSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		h.sweepWatermarked(time.Now())
+		<-ticker.C
+	}
+}
+
+func (h *UploadHandler) sweepWatermarked(now time.Time) {
+	type cachedCopy struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	root := filepath.Join(h.storagePath, "watermarked")
+	var copies []cachedCopy
+	var total int64
+	removed := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if now.Sub(info.ModTime()) > watermarkCacheMaxAge {
+			if os.Remove(path) == nil {
+				removed++
+			}
+			return nil
+		}
+		// Copies still being stamped are left alone
+		if !strings.HasPrefix(d.Name(), ".") {
+			copies = append(copies, cachedCopy{path, info.Size(), info.ModTime()})
+			total += info.Size()
+		}
+		return nil
+	})
+
+	sort.Slice(copies, func(i, j int) bool { return copies[i].modTime.Before(copies[j].modTime) })
+	for _, c := range copies {
+		if total <= watermarkCacheMaxBytes {
+			break
+		}
+		if os.Remove(c.path) == nil {
+			total -= c.size
+			removed++
+		}
+	}
+
+	// Books left without copies lose their directory, unless one is
+	// about to be stamped into it
+	if entries, err := os.ReadDir(root); err == nil {
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && e.IsDir() && now.Sub(info.ModTime()) > time.Minute {
+				os.Remove(filepath.Join(root, e.Name()))
+			}
+		}
+	}
+	if removed > 0 {
+		log.Printf("Removed %d watermarked copies from the cache", removed)
+	}
+}
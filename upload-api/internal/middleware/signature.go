@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log"
+	"path/filepath"
+
+	"readagain/upload-api/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireSignedAction only lets through requests carrying a URL the
+// backend signed for this action on this file: the same signature as book
+// downloads, over "<action>/<filename>" and without a watermark.
+func RequireSignedAction(signer *utils.FileSigner, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filename := c.Params("filename")
+		if filename == "" || filename != filepath.Base(filename) || filename == ".." {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "File not found",
+			})
+		}
+
+		if _, err := signer.Verify(action+"/"+filename, c.Query("expires"), c.Query("uid"), "", "", c.Query("sig")); err != nil {
+			log.Printf("Refused %s of %s: %v", action, filename, err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This link is invalid or has expired",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -3,13 +3,16 @@ package routes
 import (
 	"readagain/upload-api/internal/handlers"
 	"readagain/upload-api/internal/middleware"
+	"readagain/upload-api/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App, storagePath string) {
+func SetupRoutes(app *fiber.App, storagePath, fileURLSecret string) {
 	// Initialize handler
-	uploadHandler := handlers.NewUploadHandler(storagePath)
+	signer := utils.NewFileSigner(fileURLSecret)
+	uploadHandler := handlers.NewUploadHandler(storagePath, signer)
+	go uploadHandler.RunWatermarkSweeper()
 
 	// API routes
 	api := app.Group("/api")
@@ -18,10 +21,11 @@ func SetupRoutes(app *fiber.App, storagePath string) {
 	api.Post("/upload/cover", middleware.ValidateImageUpload(), uploadHandler.UploadCover)
 	api.Post("/upload/book", middleware.ValidateBookUpload(), uploadHandler.UploadBook)
 	api.Post("/upload/profile", middleware.ValidateImageUpload(), uploadHandler.UploadProfile)
+	api.Post("/metadata/:filename", middleware.RequireSignedAction(signer, "metadata"), uploadHandler.ExtractMetadata)
 	
 	// File serving and deletion
 	api.Get("/files/:filename", uploadHandler.ServeFile)
-	api.Delete("/files/:filename", uploadHandler.DeleteFile)
+	api.Delete("/files/:filename", middleware.RequireSignedAction(signer, "delete"), uploadHandler.DeleteFile)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
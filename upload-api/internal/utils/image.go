@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"image"
 	"image/jpeg"
 	"os"
@@ -50,6 +51,22 @@ func (io *ImageOptimizer) OptimizeImage(filePath string) error {
 	}
 }
 
+// SaveImage decodes an image held in memory, such as a cover pulled out of
+// a book file, and writes it to filePath as an optimized JPEG.
+func (io *ImageOptimizer) SaveImage(data []byte, filePath string) error {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() > io.MaxWidth || bounds.Dy() > io.MaxHeight {
+		img = imaging.Fit(img, io.MaxWidth, io.MaxHeight, imaging.Lanczos)
+	}
+
+	return io.saveJPEG(img, filePath)
+}
+
 func (io *ImageOptimizer) saveJPEG(img image.Image, filePath string) error {
 	out, err := os.Create(filePath)
 	if err != nil {